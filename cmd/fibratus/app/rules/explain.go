@@ -0,0 +1,167 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rules
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/enescakir/emoji"
+	"github.com/rabbitstack/fibratus/internal/bootstrap"
+	"github.com/rabbitstack/fibratus/pkg/cap"
+	"github.com/rabbitstack/fibratus/pkg/event"
+	"github.com/rabbitstack/fibratus/pkg/filter"
+	"github.com/rabbitstack/fibratus/pkg/filter/ql"
+	"github.com/rabbitstack/fibratus/pkg/rules"
+)
+
+// captureIdleTimeout determines how long to wait for the
+// next event before the capture is considered exhausted
+const captureIdleTimeout = time.Second * 2
+
+func explainRule(rule string) error {
+	if err := bootstrap.InitConfigAndLogger(cfg); err != nil {
+		return err
+	}
+	switch {
+	case eventsFile != "" && capFile != "":
+		return fmt.Errorf("%v events file and capture file are mutually exclusive", emoji.DisappointedFace)
+	case eventsFile != "":
+		return explainEvents(rule)
+	case capFile != "":
+		return explainCapture(rule)
+	default:
+		return fmt.Errorf("%v events file or capture file is required", emoji.DisappointedFace)
+	}
+}
+
+// explainEvents explains the rule for each event
+// declared in the YAML or JSON events file.
+func explainEvents(rule string) error {
+	b, err := os.ReadFile(eventsFile)
+	if err != nil {
+		return fmt.Errorf("%v %v", emoji.DisappointedFace, err)
+	}
+	evts, psnap, err := rules.DecodeEvents(b)
+	if err != nil {
+		return fmt.Errorf("%v invalid events file %s: %v", emoji.DisappointedFace, eventsFile, err)
+	}
+	x, err := rules.NewExplainer(cfg, psnap, rule)
+	if err != nil {
+		return fmt.Errorf("%v %v", emoji.DisappointedFace, err)
+	}
+	for _, evt := range evts {
+		printExplanation(x, evt)
+	}
+	return nil
+}
+
+// explainCapture explains the rule for capture events in
+// the rule scope. Events can be further narrowed down by
+// the filter expression.
+func explainCapture(rule string) error {
+	reader, err := cap.NewReader(capFile, cfg)
+	if err != nil {
+		return fmt.Errorf("%v %v", emoji.DisappointedFace, err)
+	}
+	defer reader.Close()
+	_, psnap, err := reader.RecoverSnapshotters()
+	if err != nil {
+		return fmt.Errorf("%v %v", emoji.DisappointedFace, err)
+	}
+	x, err := rules.NewExplainer(cfg, psnap, rule)
+	if err != nil {
+		return fmt.Errorf("%v %v", emoji.DisappointedFace, err)
+	}
+	if eventFilter != "" {
+		f, err := filter.NewFromCLIWithAllAccessors([]string{eventFilter})
+		if err != nil {
+			return fmt.Errorf("%v %v", emoji.DisappointedFace, err)
+		}
+		reader.SetFilter(f)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	evts, errs := reader.Read(ctx)
+
+	var n int
+	for limit == 0 || n < limit {
+		select {
+		case evt := <-evts:
+			if !x.InScope(evt) {
+				continue
+			}
+			printExplanation(x, evt)
+			n++
+		case err := <-errs:
+			return fmt.Errorf("%v %v", emoji.DisappointedFace, err)
+		case <-time.After(captureIdleTimeout):
+			if n == 0 {
+				return fmt.Errorf("%v no events in the scope of the %q rule found in %s", emoji.DisappointedFace, x.Rule().Name, capFile)
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+func printExplanation(x *rules.Explainer, evt *event.Event) {
+	nodes := x.Explain(evt)
+	proc := "N/A"
+	if evt.PS != nil {
+		proc = evt.PS.Name
+	}
+	emo("%v %s (seq: %d, pid: %d, process: %s)\n", emoji.MagnifyingGlassTiltedRight, evt.Name, evt.Seq, evt.PID, proc)
+	if !x.IsSequence() {
+		for _, n := range nodes {
+			printNode(n, 1)
+		}
+		fmt.Println()
+		return
+	}
+	for i, n := range nodes {
+		fmt.Printf("  |%d|\n", i+1)
+		printNode(n, 2)
+	}
+	fmt.Println()
+}
+
+// printNode renders the annotated expression tree. Predicates are
+// prefixed with the emoji that reflects their truth result, while
+// fields are followed by the values they evaluated to.
+func printNode(n *ql.ExplainNode, depth int) {
+	indent := strings.Repeat("  ", depth)
+	switch {
+	case !n.Evaluated:
+		emo("%s%v %s\n", indent, emoji.NextTrackButton, n.Expr)
+	case n.IsPredicate() && n.Matched():
+		emo("%s%v %s\n", indent, emoji.CheckMarkButton, n.Expr)
+	case n.IsPredicate():
+		emo("%s%v %s\n", indent, emoji.CrossMark, n.Expr)
+	default:
+		fmt.Printf("%s%s = %s\n", indent, n.Expr, ql.FormatValue(n.Value))
+	}
+	for _, c := range n.Children {
+		printNode(c, depth+1)
+	}
+}
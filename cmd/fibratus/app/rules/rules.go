@@ -33,7 +33,7 @@ var Command = &cobra.Command{
 
 var validateCmd = &cobra.Command{
 	Use:   "validate",
-	Short: "Validate rules for structural and syntactic correctness",
+	Short: "Validate rules for structural and syntactic correctness and run static analysis",
 	RunE:  validate,
 }
 
@@ -49,29 +49,71 @@ var createCmd = &cobra.Command{
 	RunE:  create,
 }
 
+var testCmd = &cobra.Command{
+	Use:   "test",
+	Short: "Run rule unit tests and report the failed test cases",
+	RunE:  test,
+}
+
+var importSigmaCmd = &cobra.Command{
+	Use:   "import-sigma [paths...]",
+	Short: "Convert Sigma rules into Fibratus rules",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  importSigma,
+}
+
+var explainCmd = &cobra.Command{
+	Use:   "explain [rule]",
+	Short: "Explain which rule predicates matched or failed for events",
+	Args:  cobra.ExactArgs(1),
+	RunE:  explain,
+}
+
 var cfg = config.NewWithOpts(config.WithValidate(), config.WithList())
 
 var (
 	summarized bool
 	tacticID   string
+	outputDir  string
+	format     string
+
+	eventsFile  string
+	capFile     string
+	eventFilter string
+	limit       int
 )
 
 func init() {
 	cfg.MustViperize(Command)
 
+	validateCmd.PersistentFlags().StringVar(&format, "format", "text", "Specifies the output format of the validation report. Possible values are text, json, and sarif")
 	Command.AddCommand(validateCmd)
+	Command.AddCommand(testCmd)
 
 	listCmd.PersistentFlags().BoolVarP(&summarized, "summary", "s", false, "Show rules summary by MITRE tactics and techniques")
 	Command.AddCommand(listCmd)
 
 	createCmd.PersistentFlags().StringVarP(&tacticID, "tactic-id", "t", "", "Specifies the MITRE tactic identifier for the rule (e.g. TA0001)")
 	Command.AddCommand(createCmd)
+
+	importSigmaCmd.PersistentFlags().StringVarP(&outputDir, "output-dir", "o", ".", "Specifies the directory where converted rules are written")
+	Command.AddCommand(importSigmaCmd)
+
+	explainCmd.PersistentFlags().StringVarP(&eventsFile, "events", "e", "", "Specifies the YAML or JSON file with events in the rule test format")
+	explainCmd.PersistentFlags().StringVarP(&capFile, "capture", "k", "", "Specifies the capture file with events to explain")
+	explainCmd.PersistentFlags().StringVar(&eventFilter, "filter", "", "Filter expression that narrows down capture events")
+	explainCmd.PersistentFlags().IntVarP(&limit, "limit", "n", 0, "Maximum number of capture events to explain. All events in the rule scope are explained if zero")
+	Command.AddCommand(explainCmd)
 }
 
 func validate(cmd *cobra.Command, args []string) error {
 	return validateRules()
 }
 
+func test(cmd *cobra.Command, args []string) error {
+	return testRules()
+}
+
 func list(cmd *cobra.Command, args []string) error {
 	return listRules()
 }
@@ -87,4 +129,12 @@ func create(cmd *cobra.Command, args []string) error {
 	return createRule(args[0])
 }
 
+func explain(cmd *cobra.Command, args []string) error {
+	return explainRule(args[0])
+}
+
+func importSigma(cmd *cobra.Command, args []string) error {
+	return importSigmaRules(args)
+}
+
 func emo(s string, args ...any) { fmt.Printf(s, args...) }
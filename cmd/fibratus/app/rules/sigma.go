@@ -0,0 +1,101 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"github.com/enescakir/emoji"
+	"github.com/rabbitstack/fibratus/pkg/rules/sigma"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var nonAlnumRegexp = regexp.MustCompile(`[^a-z0-9]+`)
+
+func importSigmaRules(patterns []string) error {
+	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+		return err
+	}
+
+	var converted, failed int
+	for _, pattern := range patterns {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			if !strings.HasSuffix(path, ".yml") && !strings.HasSuffix(path, ".yaml") {
+				continue
+			}
+			n, warnings, err := importSigmaRule(path)
+			if err != nil {
+				failed++
+				var e *sigma.UnsupportedError
+				if errors.As(err, &e) {
+					emo("%v Skipping %s. Rule contains unsupported constructs:\n", emoji.CrossMark, path)
+					for _, construct := range e.Constructs {
+						fmt.Printf("  %v %s\n", emoji.CrossMark, construct)
+					}
+					continue
+				}
+				emo("%v Skipping %s: %v\n", emoji.CrossMark, path, err)
+				continue
+			}
+			converted++
+			emo("%v Converted %s to %s\n", emoji.CheckMarkButton, path, n)
+			for _, w := range warnings {
+				fmt.Printf("  %v %s\n", emoji.Warning, w)
+			}
+		}
+	}
+
+	if converted+failed == 0 {
+		return fmt.Errorf("%v no Sigma rules found in %s", emoji.DisappointedFace, strings.Join(patterns, ","))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%v %d of %d Sigma rule(s) couldn't be converted", emoji.DisappointedFace, failed, converted+failed)
+	}
+
+	emo("%v Converted %d Sigma rule(s). Review the rules before deploying them", emoji.Rocket, converted)
+	return nil
+}
+
+func importSigmaRule(path string) (string, []string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+	r, err := sigma.Parse(b)
+	if err != nil {
+		return "", nil, err
+	}
+	f, warnings, err := sigma.Convert(r)
+	if err != nil {
+		return "", warnings, err
+	}
+	out, err := sigma.Marshal(f)
+	if err != nil {
+		return "", warnings, err
+	}
+	n := filepath.Join(outputDir, strings.Trim(nonAlnumRegexp.ReplaceAllString(strings.ToLower(f.Name), "_"), "_")+".yml")
+	return n, warnings, os.WriteFile(n, out, 0644)
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rules
+
+import (
+	"fmt"
+	"github.com/enescakir/emoji"
+	"github.com/rabbitstack/fibratus/internal/bootstrap"
+	"github.com/rabbitstack/fibratus/pkg/rules"
+	"github.com/rabbitstack/fibratus/pkg/util/convert"
+	"sort"
+	"strings"
+)
+
+func matchString(match bool) string {
+	if match {
+		return "match"
+	}
+	return "no match"
+}
+
+func testRules() error {
+	if err := bootstrap.InitConfigAndLogger(cfg); err != nil {
+		return err
+	}
+
+	results, err := rules.RunTests(cfg)
+	if err != nil {
+		return fmt.Errorf("%v %v", emoji.DisappointedFace, err)
+	}
+	if len(results) == 0 {
+		return fmt.Errorf("%v no rule tests found in %s", emoji.DisappointedFace, strings.Join(cfg.Filters.Rules.FromPaths, ","))
+	}
+
+	var failed int
+	for _, r := range results {
+		if r.Passed() {
+			emo("%v %s: %s\n", emoji.CheckMarkButton, r.Rule, r.Test)
+			continue
+		}
+		failed++
+		emo("%v %s: %s\n", emoji.CrossMark, r.Rule, r.Test)
+		if r.Err != nil {
+			fmt.Printf("  %v\n", r.Err)
+			continue
+		}
+		fmt.Printf("  - expected: %s\n", matchString(r.Expected))
+		fmt.Printf("  + actual:   %s\n", matchString(r.Matched))
+		for i, values := range r.Fields {
+			fmt.Printf("  event #%d:\n", i+1)
+			names := convert.MapKeysToSlice(values)
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Printf("    %s = %q\n", name, values[name])
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%v %d of %d rule test(s) failed", emoji.DisappointedFace, failed, len(results))
+	}
+
+	emo("%v All %d rule test(s) passed", emoji.Rocket, len(results))
+	return nil
+}
@@ -22,30 +22,25 @@ import (
 	"fmt"
 	"github.com/enescakir/emoji"
 	"github.com/rabbitstack/fibratus/internal/bootstrap"
-	"github.com/rabbitstack/fibratus/pkg/filter"
-	"github.com/rabbitstack/fibratus/pkg/filter/fields"
+	"github.com/rabbitstack/fibratus/pkg/filter/ql"
 	"github.com/rabbitstack/fibratus/pkg/rules"
 	"path/filepath"
 	"strings"
 )
 
-type warning struct {
-	rule     string
-	messages []string
-}
-
-func (w *warning) addMessage(msg string) {
-	if w.messages == nil {
-		w.messages = make([]string, 0)
-	}
-	w.messages = append(w.messages, msg)
-}
-
 func validateRules() error {
 	if err := bootstrap.InitConfigAndLogger(cfg); err != nil {
 		return err
 	}
 
+	switch format {
+	case "text", "json", "sarif":
+	default:
+		return fmt.Errorf("invalid output format %q. Expected any of text, json, sarif", format)
+	}
+	// machine-readable reports are the sole output
+	text := format == "text"
+
 	isValidExt := func(path string) bool {
 		return filepath.Ext(path) == ".yml" || filepath.Ext(path) == ".yaml"
 	}
@@ -56,7 +51,7 @@ func validateRules() error {
 			return err
 		}
 		for _, path := range paths {
-			if !isValidExt(path) {
+			if !isValidExt(path) || !text {
 				continue
 			}
 			emo("%v Loading macros from %s\n", emoji.Hook, path)
@@ -72,7 +67,7 @@ func validateRules() error {
 			return err
 		}
 		for _, path := range paths {
-			if !isValidExt(path) {
+			if !isValidExt(path) || !text {
 				continue
 			}
 			emo("%v Loading rule %s\n", emoji.Package, path)
@@ -85,54 +80,70 @@ func validateRules() error {
 		return fmt.Errorf("%v no rules found in %s", emoji.DisappointedFace, strings.Join(cfg.Filters.Rules.FromPaths, ","))
 	}
 
-	warnings := make([]warning, 0)
+	// compile and analyze rules
+	report, err := rules.Analyze(cfg)
+	if err != nil {
+		return fmt.Errorf("%v %v", emoji.DisappointedFace, err)
+	}
 
-	// validate rules
-	for _, rule := range cfg.GetFilters() {
-		f := filter.New(rule.Condition, cfg)
-		err := f.Compile()
+	switch format {
+	case "json":
+		b, err := report.JSON()
 		if err != nil {
-			return fmt.Errorf("%v %v", emoji.DisappointedFace, rules.ErrInvalidFilter(rule.Name, err))
+			return err
 		}
-
-		w := warning{rule: rule.Name}
-		for _, fld := range f.GetFields() {
-			if isDeprecated, dep := fields.IsDeprecated(fld.Name); isDeprecated {
-				w.addMessage(fmt.Sprintf("%s field deprecated in favor of %v", fld.Name.String(), dep.Fields))
-			}
+		fmt.Println(string(b))
+	case "sarif":
+		b, err := report.SARIF()
+		if err != nil {
+			return err
 		}
+		fmt.Println(string(b))
+	default:
+		printReport(report)
+	}
 
-		if !rule.HasLabel("tactic.id") {
-			w.addMessage("tactic.id label is missing")
-		}
-		if !rule.HasLabel("tactic.name") {
-			w.addMessage("tactic.name label is missing")
-		}
-		if !rule.HasLabel("tactic.ref") {
-			w.addMessage("tactic.ref label is missing")
-		}
-		if !rule.HasLabel("technique.id") {
-			w.addMessage("technique.id label is missing")
-		}
-		if !rule.HasLabel("technique.name") {
-			w.addMessage("technique.name label is missing")
-		}
-		if !rule.HasLabel("technique.ref") {
-			w.addMessage("technique.ref label is missing")
-		}
+	if report.HasErrors() {
+		return fmt.Errorf("%v validation failed with %d error(s)", emoji.DisappointedFace, report.Count(ql.Error))
+	}
 
-		if len(w.messages) > 0 {
-			warnings = append(warnings, w)
+	if text {
+		emo("%v Validation successful. Ready to go!", emoji.Rocket)
+	}
+	return nil
+}
+
+// printReport prints findings grouped by rule. Findings that
+// don't pertain to any rule are printed at the end.
+func printReport(report *rules.Report) {
+	groups := make(map[string][]rules.Finding)
+	names := make([]string, 0)
+	for _, f := range report.Findings {
+		if _, ok := groups[f.Rule]; !ok {
+			names = append(names, f.Rule)
 		}
+		groups[f.Rule] = append(groups[f.Rule], f)
 	}
 
-	for _, warn := range warnings {
-		emo("%v %d warning(s) in rule %s:\n", emoji.Warning, len(warn.messages), warn.rule)
-		for _, msg := range warn.messages {
-			fmt.Printf("  %v %s\n", emoji.Warning, msg)
+	icon := func(sev ql.Severity) emoji.Emoji {
+		switch sev {
+		case ql.Error:
+			return emoji.CrossMark
+		case ql.Warning:
+			return emoji.Warning
 		}
+		return emoji.Information
 	}
 
-	emo("%v Validation successful. Ready to go!", emoji.Rocket)
-	return nil
+	for _, name := range names {
+		findings := groups[name]
+		if name != "" {
+			emo("%v %d finding(s) in rule %s:\n", emoji.Warning, len(findings), name)
+		} else {
+			emo("%v %d finding(s) in macros:\n", emoji.Information, len(findings))
+		}
+		for _, f := range findings {
+			fmt.Printf("  %v %s [%s]\n", icon(f.Severity), f.Message, f.Code)
+		}
+	}
 }
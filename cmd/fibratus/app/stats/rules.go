@@ -0,0 +1,111 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/rabbitstack/fibratus/internal/bootstrap"
+	errs "github.com/rabbitstack/fibratus/pkg/errors"
+	"github.com/rabbitstack/fibratus/pkg/rules"
+	"github.com/rabbitstack/fibratus/pkg/util/rest"
+	"github.com/spf13/cobra"
+)
+
+var rulesCmd = &cobra.Command{
+	Use:   "rules",
+	Short: "Show per-rule evaluation cost",
+	RunE:  rulesStats,
+}
+
+var (
+	sortBy string
+	limit  int
+)
+
+// sorters defines the sort order for each of the supported columns.
+// Rules are always sorted in descending order.
+var sorters = map[string]func(a, b rules.RuleProfile) bool{
+	"total":     func(a, b rules.RuleProfile) bool { return a.Total > b.Total },
+	"avg":       func(a, b rules.RuleProfile) bool { return a.Avg > b.Avg },
+	"p99":       func(a, b rules.RuleProfile) bool { return a.P99 > b.P99 },
+	"evals":     func(a, b rules.RuleProfile) bool { return a.Evals > b.Evals },
+	"matches":   func(a, b rules.RuleProfile) bool { return a.Matches > b.Matches },
+	"accessors": func(a, b rules.RuleProfile) bool { return a.Accessors > b.Accessors },
+	"functions": func(a, b rules.RuleProfile) bool { return a.Functions > b.Functions },
+}
+
+func init() {
+	rulesCmd.Flags().StringVarP(&sortBy, "sort", "s", "total", "Column to sort rules by. One of total, avg, p99, evals, matches, accessors, or functions")
+	rulesCmd.Flags().IntVarP(&limit, "limit", "n", 0, "Maximum number of rules to show. All rules are shown if zero")
+	Command.AddCommand(rulesCmd)
+}
+
+// RulesStats stores rule profiles retrieved from the expvar endpoint.
+type RulesStats struct {
+	Profiles []rules.RuleProfile `json:"rules.profile"`
+}
+
+func rulesStats(cmd *cobra.Command, args []string) error {
+	less, ok := sorters[sortBy]
+	if !ok {
+		return fmt.Errorf("invalid sort column: %s", sortBy)
+	}
+	if err := bootstrap.InitConfigAndLogger(cfg); err != nil {
+		return err
+	}
+	c := cfg.API
+	body, err := rest.Get(rest.WithTransport(c.Transport), rest.WithURI("debug/vars"))
+	if err != nil {
+		return errs.ErrHTTPServerUnavailable(c.Transport, err)
+	}
+	var stats RulesStats
+	if err := json.Unmarshal(body, &stats); err != nil {
+		return err
+	}
+	if len(stats.Profiles) == 0 {
+		return fmt.Errorf("no rule profiles found. Make sure the filters.rules.profile option is enabled")
+	}
+
+	profiles := stats.Profiles
+	sort.SliceStable(profiles, func(i, j int) bool { return less(profiles[i], profiles[j]) })
+	if limit > 0 && limit < len(profiles) {
+		profiles = profiles[:limit]
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.SetStyle(table.StyleLight)
+	t.AppendHeader(table.Row{"#", "Rule", "Evals", "Matches", "Total", "Avg", "P99", "Accessors", "Functions"})
+	t.SetColumnConfigs([]table.ColumnConfig{
+		{Name: "#", WidthMax: 5},
+		{Name: "Rule", WidthMax: 60},
+	})
+	for i, p := range profiles {
+		t.AppendRow(table.Row{i + 1, p.Name, p.Evals, p.Matches, p.Total, p.Avg, p.P99, p.Accessors, p.Functions})
+	}
+
+	t.Render()
+
+	return nil
+}
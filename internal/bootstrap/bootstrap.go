@@ -21,6 +21,7 @@ package bootstrap
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
 
 	"github.com/rabbitstack/fibratus/internal/evasion"
@@ -58,6 +59,7 @@ type App struct {
 	evs        *EventSourceControl
 	symbolizer *symbolize.Symbolizer
 	engine     *rules.Engine
+	watcher    *rules.Watcher
 	hsnap      handle.Snapshotter
 	psnap      ps.Snapshotter
 	filament   filament.Filament
@@ -247,6 +249,13 @@ func (f *App) Run(args []string) error {
 		// register rule engine
 		if f.engine != nil {
 			f.evs.RegisterEventListener(f.engine)
+			api.SetRulesReloader(f.engine.Reload)
+			if cfg.Filters.Rules.HotReload {
+				f.watcher = rules.NewWatcher(f.engine, cfg)
+				if err := f.watcher.Start(); err != nil {
+					return err
+				}
+			}
 		}
 		// register YARA scanner
 		if cfg.Yara.Enabled {
@@ -256,6 +265,10 @@ func (f *App) Run(args []string) error {
 			}
 			f.evs.RegisterEventListener(scanner)
 		}
+		// compile output routing filters
+		if err := initOutputs(cfg); err != nil {
+			return err
+		}
 		err = f.evs.Open(cfg)
 		if err != nil {
 			return multierror.Wrap(err, f.evs.Close())
@@ -265,7 +278,7 @@ func (f *App) Run(args []string) error {
 			f.evs.Events(),
 			f.evs.Errors(),
 			cfg.Aggregator,
-			cfg.Output,
+			cfg.Outputs,
 			cfg.Transformers,
 			cfg.Alertsenders,
 		)
@@ -352,12 +365,15 @@ func (f *App) ReadCapture(ctx context.Context, args []string) error {
 		}
 		// use the channels where events are read
 		// from the capture as aggregator source
+		if err := initOutputs(f.config); err != nil {
+			return err
+		}
 		evts, errs := f.reader.Read(ctx)
 		f.agg, err = aggregator.NewBuffered(
 			evts,
 			errs,
 			f.config.Aggregator,
-			f.config.Output,
+			f.config.Outputs,
 			f.config.Transformers,
 			f.config.Alertsenders,
 		)
@@ -379,6 +395,13 @@ func (f *App) Wait() {
 // Shutdown is responsible for tearing down everything gracefully.
 func (f *App) Shutdown() error {
 	errs := make([]error, 0)
+	// the watcher is closed first, so the
+	// reload can't fire against the closed engine
+	if f.watcher != nil {
+		if err := f.watcher.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
 	if f.symbolizer != nil {
 		f.symbolizer.Close()
 	}
@@ -387,6 +410,11 @@ func (f *App) Shutdown() error {
 			errs = append(errs, err)
 		}
 	}
+	if f.engine != nil {
+		if err := f.engine.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
 	if f.hsnap != nil {
 		if err := f.hsnap.Close(); err != nil {
 			errs = append(errs, err)
@@ -433,6 +461,24 @@ func (f *App) Shutdown() error {
 	return multierror.Wrap(errs...)
 }
 
+// initOutputs compiles the routing filters of the
+// outputs and hooks up the field valuer. Outputs
+// without the filter receive all events.
+func initOutputs(cfg *config.Config) error {
+	for i, output := range cfg.Outputs {
+		cfg.Outputs[i].Valuer = filter.FieldValue
+		if output.Filter == "" {
+			continue
+		}
+		fltr := filter.New(output.Filter, cfg)
+		if err := fltr.Compile(); err != nil {
+			return fmt.Errorf("invalid %s output filter: %v", output.Type, err)
+		}
+		cfg.Outputs[i].Predicate = fltr
+	}
+	return nil
+}
+
 func (f *App) stop() {
 	if f.signals != nil {
 		f.signals <- struct{}{}
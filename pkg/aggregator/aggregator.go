@@ -34,8 +34,11 @@ import (
 	_ "github.com/rabbitstack/fibratus/pkg/outputs/console"
 	_ "github.com/rabbitstack/fibratus/pkg/outputs/elasticsearch"
 	_ "github.com/rabbitstack/fibratus/pkg/outputs/eventlog"
+	_ "github.com/rabbitstack/fibratus/pkg/outputs/file"
 	_ "github.com/rabbitstack/fibratus/pkg/outputs/http"
+	_ "github.com/rabbitstack/fibratus/pkg/outputs/kafka"
 	_ "github.com/rabbitstack/fibratus/pkg/outputs/null"
+	_ "github.com/rabbitstack/fibratus/pkg/outputs/syslog"
 
 	// initialize alert senders
 	_ "github.com/rabbitstack/fibratus/pkg/alertsender/mail"
@@ -64,17 +67,18 @@ var (
 )
 
 // BufferedAggregator collects events from the inbound channel and produces batches on regular intervals. The batches
-// are pushed to the work queue from which load-balanced configured workers consume the batches and publish to the outputs.
+// are routed to the work queue of each output from which load-balanced workers consume the batches and publish to the
+// output.
 type BufferedAggregator struct {
 	evtsc   <-chan *event.Event
 	errsc   <-chan error
 	stop    chan struct{}
+	done    chan struct{}
 	flusher *time.Ticker
 	// queue of inbound events
 	evts []*event.Event
-	// work queue that forwarder passes to outputs
-	wq         queue
-	submitter  *submitter
+	// submitters route batches to the work queue of each output
+	submitters []*submitter
 	transforms []transformers.Transformer
 	c          Config
 }
@@ -84,7 +88,7 @@ func NewBuffered(
 	evts <-chan *event.Event,
 	errs <-chan error,
 	aggConfig Config,
-	outputConfig outputs.Config,
+	outputConfigs []outputs.Config,
 	transformerConfigs []transformers.Config,
 	alertsenderConfigs []alertsender.Config,
 ) (*BufferedAggregator, error) {
@@ -97,16 +101,23 @@ func NewBuffered(
 		evts:    make([]*event.Event, 0),
 		errsc:   errs,
 		stop:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
 		flusher: time.NewTicker(flushInterval),
-		wq:      make(chan *event.Batch),
 		c:       aggConfig,
 	}
 
-	var err error
-	agg.submitter, err = newSubmitter(agg.wq, outputConfig)
-	if err != nil {
-		return nil, err
+	if len(outputConfigs) == 0 {
+		return nil, errors.New("no outputs configured")
 	}
+	for _, outputConfig := range outputConfigs {
+		s, err := newSubmitter(outputConfig, aggConfig.Spool)
+		if err != nil {
+			return nil, err
+		}
+		agg.submitters = append(agg.submitters, s)
+	}
+
+	var err error
 	agg.transforms, err = transformers.LoadAll(transformerConfigs)
 	if err != nil {
 		return nil, err
@@ -125,30 +136,22 @@ func NewBuffered(
 // Stop flushes pending event batches and instructs the aggregator to stop processing events.
 func (agg *BufferedAggregator) Stop() error {
 	agg.stop <- struct{}{}
+	<-agg.done
 
 	// flush enqueued events
 	b := event.NewBatch(agg.evts...)
 	if b.Len() > 0 {
-		done := make(chan struct{}, 1)
-		go func() {
-			agg.wq <- b
-			done <- struct{}{}
-		}()
-
-		select {
-		case <-done:
-			close(agg.wq)
-		case <-time.After(agg.c.FlushTimeout):
-			return errors.New("fail to flush events after stop timed out")
+		for _, s := range agg.submitters {
+			if err := s.flush(b, agg.c.FlushTimeout); err != nil {
+				return err
+			}
 		}
 	}
 
-	// sleep a bit before closing the clients
-	time.Sleep(time.Millisecond * 150)
-
-	err := agg.submitter.shutdown()
-	if err != nil {
-		return err
+	for _, s := range agg.submitters {
+		if err := s.shutdown(agg.c.FlushTimeout); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -161,6 +164,7 @@ func (agg *BufferedAggregator) run() {
 		select {
 		case <-agg.stop:
 			agg.flusher.Stop()
+			close(agg.done)
 			return
 		case <-agg.flusher.C:
 			if len(agg.evts) == 0 {
@@ -169,9 +173,11 @@ func (agg *BufferedAggregator) run() {
 			b := event.NewBatch(agg.evts...)
 			l := b.Len()
 			batchEvents.Add(l)
-			// push the batch to the work queue
+			// route the batch to the outputs
 			if l > 0 {
-				agg.wq <- b
+				for _, s := range agg.submitters {
+					s.submit(b)
+				}
 			}
 			flushesCount.Add(1)
 			// clear the queue
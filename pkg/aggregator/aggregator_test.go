@@ -37,7 +37,7 @@ func TestNewBufferedAggregator(t *testing.T) {
 		eventsc,
 		errsc,
 		Config{FlushPeriod: time.Millisecond * 200},
-		outputs.Config{Type: outputs.Console, Output: console.Config{Format: "pretty"}},
+		[]outputs.Config{{Type: outputs.Console, Output: console.Config{Format: "pretty"}}},
 		nil,
 		nil,
 	)
@@ -19,6 +19,7 @@
 package aggregator
 
 import (
+	"github.com/rabbitstack/fibratus/pkg/aggregator/spool"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"time"
@@ -35,16 +36,20 @@ type Config struct {
 	FlushPeriod time.Duration `json:"aggregator.flush-period" yaml:"aggregator.flush-period"`
 	// FlushTimeout represents the max time to wait before announcing failed flushing of enqueued events
 	FlushTimeout time.Duration `json:"aggregator.flush-timeout" yaml:"aggregator.flush-timeout"`
+	// Spool contains the settings of the disk-backed spool for unavailable outputs.
+	Spool spool.Config `json:"aggregator.spool" yaml:"aggregator.spool"`
 }
 
 // AddFlags registers persistent aggregator flags.
 func AddFlags(flags *pflag.FlagSet) {
 	flags.Duration(flushPeriod, time.Millisecond*200, "Determines the period for flushing batches to outputs")
 	flags.Duration(flushTimeout, time.Second*4, "Represents the max time to wait before announcing failed flushing of enqueued events on aggregator shutdown")
+	spool.AddFlags(flags)
 }
 
 // InitFromViper initializes aggregator flags from viper.
 func (c *Config) InitFromViper(v *viper.Viper) {
 	c.FlushPeriod = v.GetDuration(flushPeriod)
 	c.FlushTimeout = v.GetDuration(flushTimeout)
+	c.Spool.InitFromViper(v)
 }
@@ -0,0 +1,179 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package spool
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/rabbitstack/fibratus/pkg/callstack"
+	"github.com/rabbitstack/fibratus/pkg/cap/section"
+	capver "github.com/rabbitstack/fibratus/pkg/cap/version"
+	"github.com/rabbitstack/fibratus/pkg/event"
+	pstypes "github.com/rabbitstack/fibratus/pkg/ps/types"
+	"github.com/rabbitstack/fibratus/pkg/util/va"
+)
+
+// encode serializes the batch to the byte stream. Each event is
+// stored in the capture format followed by the process state and
+// the call stack. The capture format only retains the process state
+// of process creation events and doesn't retain the call stack, so
+// these are written separately.
+func encode(b *event.Batch) []byte {
+	buf := make([]byte, 4, 4096)
+	binary.LittleEndian.PutUint32(buf, uint32(len(b.Events)))
+	for _, evt := range b.Events {
+		raw := evt.MarshalRaw()
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(raw)))
+		buf = append(buf, raw...)
+
+		var ps []byte
+		if evt.PS != nil && !evt.IsCreateProcess() && !evt.IsProcessRundown() {
+			ps = evt.PS.Marshal()
+		}
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(ps)))
+		buf = append(buf, ps...)
+
+		cs := encodeCallstack(evt.Callstack)
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(cs)))
+		buf = append(buf, cs...)
+	}
+	return buf
+}
+
+// encodeCallstack serializes the call stack frames.
+func encodeCallstack(s callstack.Callstack) []byte {
+	if s.IsEmpty() {
+		return nil
+	}
+	buf := binary.LittleEndian.AppendUint32(nil, uint32(s.Depth()))
+	for _, f := range s {
+		buf = binary.LittleEndian.AppendUint32(buf, f.PID)
+		buf = binary.LittleEndian.AppendUint64(buf, f.Addr.Uint64())
+		buf = binary.LittleEndian.AppendUint64(buf, f.Offset)
+		buf = binary.LittleEndian.AppendUint64(buf, f.ModuleAddress.Uint64())
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(len(f.Symbol)))
+		buf = append(buf, f.Symbol...)
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(len(f.Module)))
+		buf = append(buf, f.Module...)
+	}
+	return buf
+}
+
+// decodeCallstack recovers the call stack frames.
+func decodeCallstack(buf []byte) (callstack.Callstack, error) {
+	if len(buf) < 4 {
+		return nil, fmt.Errorf("expected at least 4 bytes of call stack but got %d bytes", len(buf))
+	}
+	n := int(binary.LittleEndian.Uint32(buf))
+	off := 4
+	str := func() (string, error) {
+		if len(buf) < off+2 {
+			return "", fmt.Errorf("unexpected end of call stack at offset %d", off)
+		}
+		l := int(binary.LittleEndian.Uint16(buf[off:]))
+		off += 2
+		if len(buf) < off+l {
+			return "", fmt.Errorf("unexpected end of call stack at offset %d", off)
+		}
+		s := string(buf[off : off+l])
+		off += l
+		return s, nil
+	}
+
+	s := make(callstack.Callstack, 0, min(n, len(buf)/28))
+	for i := 0; i < n; i++ {
+		if len(buf) < off+28 {
+			return nil, fmt.Errorf("unexpected end of call stack at offset %d", off)
+		}
+		f := callstack.Frame{
+			PID:           binary.LittleEndian.Uint32(buf[off:]),
+			Addr:          va.Address(binary.LittleEndian.Uint64(buf[off+4:])),
+			Offset:        binary.LittleEndian.Uint64(buf[off+12:]),
+			ModuleAddress: va.Address(binary.LittleEndian.Uint64(buf[off+20:])),
+		}
+		off += 28
+		var err error
+		if f.Symbol, err = str(); err != nil {
+			return nil, err
+		}
+		if f.Module, err = str(); err != nil {
+			return nil, err
+		}
+		s = append(s, f)
+	}
+	return s, nil
+}
+
+// decode recovers the batch from the byte stream.
+func decode(buf []byte) (*event.Batch, error) {
+	if len(buf) < 4 {
+		return nil, fmt.Errorf("expected at least 4 bytes but got %d bytes", len(buf))
+	}
+	n := binary.LittleEndian.Uint32(buf)
+	off := 4
+	next := func() ([]byte, error) {
+		if len(buf) < off+4 {
+			return nil, fmt.Errorf("unexpected end of batch at offset %d", off)
+		}
+		l := int(binary.LittleEndian.Uint32(buf[off:]))
+		off += 4
+		if len(buf) < off+l {
+			return nil, fmt.Errorf("unexpected end of batch at offset %d", off)
+		}
+		b := buf[off : off+l]
+		off += l
+		return b, nil
+	}
+
+	evts := make([]*event.Event, 0, n)
+	for i := 0; i < int(n); i++ {
+		raw, err := next()
+		if err != nil {
+			return nil, err
+		}
+		evt, err := event.NewFromCapture(raw, capver.EvtSecV2)
+		if err != nil {
+			return nil, err
+		}
+		ps, err := next()
+		if err != nil {
+			return nil, err
+		}
+		if len(ps) > 0 {
+			sec := section.New(section.Process, capver.ProcessSecV4, 0, uint32(len(ps)))
+			evt.PS, err = pstypes.NewFromCapture(ps, sec)
+			if err != nil {
+				return nil, err
+			}
+		}
+		cs, err := next()
+		if err != nil {
+			return nil, err
+		}
+		if len(cs) > 0 {
+			evt.Callstack, err = decodeCallstack(cs)
+			if err != nil {
+				return nil, err
+			}
+		}
+		evts = append(evts, evt)
+	}
+	return event.NewBatch(evts...), nil
+}
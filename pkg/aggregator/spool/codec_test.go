@@ -0,0 +1,47 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package spool
+
+import (
+	"testing"
+
+	"github.com/rabbitstack/fibratus/pkg/callstack"
+	"github.com/rabbitstack/fibratus/pkg/util/va"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodecCallstack(t *testing.T) {
+	b := newBatch(1, 2)
+	b.Events[0].Callstack = callstack.Callstack{
+		{PID: 1234, Addr: va.Address(0x7ffb5c1d0396), Offset: 0x61, Symbol: "CreateFileW", Module: "C:\\Windows\\System32\\KernelBase.dll", ModuleAddress: va.Address(0x7ffb5c1a0000)},
+		{PID: 1234, Addr: va.Address(0x2638e59e0a5), Module: "unbacked"},
+		{PID: 1234, Addr: va.Address(0xfffff8072ebc1f6f), Offset: 0x4ef, Symbol: "NtCreateFile", Module: "C:\\Windows\\System32\\ntoskrnl.exe", ModuleAddress: va.Address(0xfffff8072e800000)},
+	}
+
+	decoded, err := decode(encode(b))
+	require.NoError(t, err)
+	require.Len(t, decoded.Events, 2)
+	assert.Equal(t, b.Events[0].Callstack, decoded.Events[0].Callstack)
+	assert.True(t, decoded.Events[1].Callstack.IsEmpty())
+
+	// truncated call stack is rejected
+	_, err = decodeCallstack(encodeCallstack(b.Events[0].Callstack)[:40])
+	require.Error(t, err)
+}
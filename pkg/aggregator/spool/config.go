@@ -0,0 +1,85 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package spool
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+const (
+	enabled     = "aggregator.spool.enabled"
+	dir         = "aggregator.spool.dir"
+	maxSize     = "aggregator.spool.max-size"
+	maxAge      = "aggregator.spool.max-age"
+	compression = "aggregator.spool.compression"
+)
+
+const (
+	// CompressionNone disables the compression of spooled batches
+	CompressionNone = "none"
+	// CompressionZstd compresses spooled batches with the zstd algorithm
+	CompressionZstd = "zstd"
+)
+
+// Config contains the settings of the disk-backed spool where batches
+// are persisted while outputs are unavailable.
+type Config struct {
+	// Enabled indicates if batches are spooled to disk when outputs are unavailable.
+	Enabled bool `json:"aggregator.spool.enabled" yaml:"aggregator.spool.enabled"`
+	// Dir is the directory where spool files are stored. Each output is spooled to a dedicated subdirectory.
+	Dir string `json:"aggregator.spool.dir" yaml:"aggregator.spool.dir"`
+	// MaxSize is the maximum size in megabytes of pending batches per output. The oldest batches
+	// are dropped when the limit is reached.
+	MaxSize int `json:"aggregator.spool.max-size" yaml:"aggregator.spool.max-size"`
+	// MaxAge is the maximum time the batch is retained in the spool before it is dropped.
+	MaxAge time.Duration `json:"aggregator.spool.max-age" yaml:"aggregator.spool.max-age"`
+	// Compression is the compression algorithm applied to spooled batches.
+	Compression string `json:"aggregator.spool.compression" yaml:"aggregator.spool.compression"`
+}
+
+// AddFlags registers persistent spool flags.
+func AddFlags(flags *pflag.FlagSet) {
+	flags.Bool(enabled, false, "Indicates if batches are spooled to disk when outputs are unavailable")
+	flags.String(dir, filepath.Join(os.Getenv("PROGRAMFILES"), "Fibratus", "Spool"), "Specifies the directory where spool files are stored")
+	flags.Int(maxSize, 1024, "Specifies the maximum size in megabytes of pending batches per output")
+	flags.Duration(maxAge, time.Hour*24, "Specifies the maximum time the batch is retained in the spool")
+	flags.String(compression, CompressionZstd, "Specifies the compression algorithm applied to spooled batches. Choose between zstd|none")
+}
+
+// InitFromViper initializes spool flags from viper.
+func (c *Config) InitFromViper(v *viper.Viper) {
+	c.Enabled = v.GetBool(enabled)
+	c.Dir = v.GetString(dir)
+	c.MaxSize = v.GetInt(maxSize)
+	c.MaxAge = v.GetDuration(maxAge)
+	c.Compression = v.GetString(compression)
+}
+
+// maxBytes returns the maximum size in bytes of pending batches.
+func (c Config) maxBytes() int64 {
+	if c.MaxSize <= 0 {
+		return 1024 * 1024 * 1024
+	}
+	return int64(c.MaxSize) * 1024 * 1024
+}
@@ -0,0 +1,568 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package spool
+
+import (
+	"encoding/binary"
+	"errors"
+	"expvar"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rabbitstack/fibratus/pkg/event"
+	"github.com/rabbitstack/fibratus/pkg/util/multierror"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// spoolDepth represents the number of batches pending in the spool of each output
+	spoolDepth = expvar.NewMap("aggregator.spool.depth")
+	// spoolBytes represents the size in bytes of batches pending in the spool of each output
+	spoolBytes = expvar.NewMap("aggregator.spool.bytes")
+	// spoolDroppedBytes counts the bytes of batches dropped due to spool size or age limits
+	spoolDroppedBytes = expvar.NewMap("aggregator.spool.dropped.bytes")
+	// spoolDroppedBatches counts the batches dropped due to spool size or age limits
+	spoolDroppedBatches = expvar.NewMap("aggregator.spool.dropped.batches")
+	// spoolCorruptedBatches counts the spooled batches that couldn't be decoded
+	spoolCorruptedBatches = expvar.NewMap("aggregator.spool.corrupted.batches")
+)
+
+// ErrClosed is returned when the batch is appended to the closed spool.
+var ErrClosed = errors.New("spool is closed")
+
+// ErrBatchTooLarge is returned when the batch exceeds the spool size limit.
+var ErrBatchTooLarge = errors.New("batch exceeds the spool size limit")
+
+var errChecksum = errors.New("checksum mismatch")
+
+const (
+	segmentExt = ".spool"
+	cursorFile = "cursor"
+	// maxSegmentSize is the size after which a new segment file is started
+	maxSegmentSize = 16 * 1024 * 1024
+	// recordHeaderSize is the size of the record header. The header
+	// contains the payload length, CRC32 checksum and the codec.
+	recordHeaderSize = 9
+)
+
+const (
+	codecNone byte = iota
+	codecZstd
+)
+
+// segment is the append-only file that stores a contiguous run of spooled batches.
+type segment struct {
+	id    uint64
+	size  int64
+	count int
+	// mtime is the time the last batch was appended to the segment
+	mtime time.Time
+}
+
+// Spool is the disk-backed write-ahead queue that persists batches while
+// the output is unavailable. Batches are stored in segment files and
+// replayed in the same order they were appended. The read position is
+// recorded in the cursor file, so pending batches survive restarts.
+type Spool struct {
+	mu  sync.Mutex
+	rmu sync.Mutex // serializes replays
+
+	dir   string
+	codec byte
+
+	segments []*segment // from the oldest to the newest segment
+	head     *os.File   // the newest segment open for appending
+	cursor   *os.File
+
+	// offset is the read position within the oldest segment
+	offset int64
+	// consumed is the number of replayed batches within the oldest segment
+	consumed int
+
+	limit       int64
+	segmentSize int64
+	maxAge      time.Duration
+
+	name   string
+	depth  *expvar.Int
+	bytes  *expvar.Int
+	ready  chan struct{}
+	closed bool
+}
+
+// Open opens the spool of the named output. Pending batches from
+// previous runs are recovered from the spool directory.
+func Open(name string, c Config) (*Spool, error) {
+	s := &Spool{
+		dir:         filepath.Join(c.Dir, name),
+		limit:       c.maxBytes(),
+		segmentSize: min(maxSegmentSize, c.maxBytes()/8),
+		maxAge:      c.MaxAge,
+		name:        name,
+		depth:       new(expvar.Int),
+		bytes:       new(expvar.Int),
+		ready:       make(chan struct{}, 1),
+	}
+
+	switch c.Compression {
+	case CompressionZstd:
+		if zstdSupported {
+			s.codec = codecZstd
+		} else {
+			log.Warnf("zstd compression is not available. Spooled batches of %s output are not compressed", name)
+		}
+	case CompressionNone, "":
+	default:
+		return nil, fmt.Errorf("unknown spool compression: %s", c.Compression)
+	}
+
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return nil, err
+	}
+	if err := s.recover(); err != nil {
+		return nil, err
+	}
+
+	spoolDepth.Set(name, s.depth)
+	spoolBytes.Set(name, s.bytes)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(time.Now())
+	s.updateMetrics()
+
+	return s, nil
+}
+
+// recover loads the segments and the read position from the spool directory.
+func (s *Spool) recover() error {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), segmentExt) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(f.Name(), segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			return err
+		}
+		s.segments = append(s.segments, &segment{id: id, mtime: info.ModTime()})
+	}
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i].id < s.segments[j].id })
+
+	// read the position of the next batch to replay
+	s.cursor, err = os.OpenFile(filepath.Join(s.dir, cursorFile), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+	var id uint64
+	var offset int64
+	b := make([]byte, 16)
+	if n, _ := s.cursor.ReadAt(b, 0); n == len(b) {
+		id, offset = binary.LittleEndian.Uint64(b), int64(binary.LittleEndian.Uint64(b[8:]))
+	}
+
+	// discard segments replayed in previous runs and scan the
+	// rest to count pending batches. Truncated batches left
+	// behind by an abrupt termination are chopped off
+	last := id
+	segments := make([]*segment, 0, len(s.segments))
+	for _, seg := range s.segments {
+		last = max(last, seg.id)
+		if seg.id < id {
+			_ = os.Remove(s.path(seg))
+			continue
+		}
+		var upto int64
+		if seg.id == id {
+			upto = offset
+		}
+		size, count, consumed, err := scan(s.path(seg), upto)
+		if err != nil {
+			return err
+		}
+		if count == consumed {
+			// nothing left to replay
+			_ = os.Remove(s.path(seg))
+			continue
+		}
+		seg.size, seg.count = size, count
+		if seg.id == id {
+			s.offset, s.consumed = offset, consumed
+		}
+		segments = append(segments, seg)
+	}
+	s.segments = segments
+
+	if len(s.segments) == 0 {
+		// keep segment identifiers increasing across runs
+		s.segments = append(s.segments, &segment{id: last})
+		if err := s.rotate(); err != nil {
+			return err
+		}
+		s.segments = s.segments[1:]
+	} else {
+		seg := s.segments[len(s.segments)-1]
+		s.head, err = os.OpenFile(s.path(seg), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+	}
+	return s.writeCursor()
+}
+
+// scan walks the segment records and returns the size occupied by
+// valid records, the number of records, and the number of records
+// ending at or before the given offset. The segment is truncated
+// after the last valid record.
+func scan(path string, upto int64) (int64, int, int, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0o644)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	defer f.Close()
+
+	var (
+		off      int64
+		count    int
+		consumed int
+	)
+	hdr := make([]byte, recordHeaderSize)
+	for {
+		if _, err := f.ReadAt(hdr, off); err != nil {
+			break
+		}
+		l := int64(binary.LittleEndian.Uint32(hdr))
+		if _, err := f.Seek(off+recordHeaderSize+l-1, io.SeekStart); err != nil {
+			break
+		}
+		b := make([]byte, 1)
+		if _, err := f.Read(b); err != nil {
+			break
+		}
+		off += recordHeaderSize + l
+		count++
+		if off <= upto {
+			consumed++
+		}
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if info.Size() > off {
+		log.Warnf("truncating incomplete spool segment %s at offset %d", path, off)
+		if err := f.Truncate(off); err != nil {
+			return 0, 0, 0, err
+		}
+	}
+	return off, count, consumed, nil
+}
+
+// Append persists the batch at the end of the spool. If the spool size
+// limit is reached, the oldest batches are dropped to make room for the
+// batch.
+func (s *Spool) Append(b *event.Batch) error {
+	payload := encode(b)
+	if s.codec == codecZstd {
+		payload = compress(payload)
+	}
+	rec := make([]byte, recordHeaderSize, recordHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(rec, uint32(len(payload)))
+	binary.LittleEndian.PutUint32(rec[4:], crc32.ChecksumIEEE(payload))
+	rec[8] = s.codec
+	rec = append(rec, payload...)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+
+	n := int64(len(rec))
+	if n > s.limit {
+		spoolDroppedBytes.Add(s.name, n)
+		spoolDroppedBatches.Add(s.name, 1)
+		return ErrBatchTooLarge
+	}
+
+	now := time.Now()
+	s.expire(now)
+	for s.pending()+n > s.limit {
+		if err := s.evict(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := s.head.Write(rec); err != nil {
+		return err
+	}
+	seg := s.segments[len(s.segments)-1]
+	seg.size += n
+	seg.count++
+	seg.mtime = now
+
+	if seg.size >= s.segmentSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	s.updateMetrics()
+
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Replay reads spooled batches in order and passes them to the publish
+// function. The batch is removed from the spool only after it is successfully
+// published. Replay returns when the spool is drained or the first publish
+// error occurs.
+func (s *Spool) Replay(publish func(*event.Batch) error) error {
+	s.rmu.Lock()
+	defer s.rmu.Unlock()
+
+	for {
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			return ErrClosed
+		}
+		s.expire(time.Now())
+		if s.len() == 0 {
+			s.mu.Unlock()
+			return nil
+		}
+		seg, offset := s.segments[0], s.offset
+		payload, codec, err := s.read(seg, offset)
+		s.mu.Unlock()
+		if err != nil && !errors.Is(err, errChecksum) {
+			return err
+		}
+		next := offset + recordHeaderSize + int64(len(payload))
+
+		var b *event.Batch
+		if err == nil {
+			b, err = s.decode(payload, codec)
+		}
+		if err != nil {
+			// skip the batch that can't be recovered
+			spoolCorruptedBatches.Add(s.name, 1)
+			log.Warnf("skipping corrupted batch in %s spool: %v", s.name, err)
+		} else if err := publish(b); err != nil {
+			return err
+		}
+
+		s.mu.Lock()
+		err = s.commit(seg, offset, next)
+		s.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Len returns the number of pending batches.
+func (s *Spool) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.len()
+}
+
+// Ready returns the channel that is signaled when the batch is appended to the spool.
+func (s *Spool) Ready() <-chan struct{} { return s.ready }
+
+// Close closes the spool. Pending batches are replayed when the spool is opened again.
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return multierror.Wrap(s.head.Close(), s.cursor.Close())
+}
+
+func (s *Spool) path(seg *segment) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", seg.id, segmentExt))
+}
+
+func (s *Spool) len() int {
+	var n int
+	for _, seg := range s.segments {
+		n += seg.count
+	}
+	return n - s.consumed
+}
+
+// pending returns the size in bytes of pending batches.
+func (s *Spool) pending() int64 {
+	var n int64
+	for _, seg := range s.segments {
+		n += seg.size
+	}
+	return n - s.offset
+}
+
+// read reads the record payload at the given segment offset.
+func (s *Spool) read(seg *segment, offset int64) ([]byte, byte, error) {
+	f, err := os.Open(s.path(seg))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	hdr := make([]byte, recordHeaderSize)
+	if _, err := f.ReadAt(hdr, offset); err != nil {
+		return nil, 0, err
+	}
+	payload := make([]byte, binary.LittleEndian.Uint32(hdr))
+	if _, err := f.ReadAt(payload, offset+recordHeaderSize); err != nil {
+		return nil, 0, err
+	}
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(hdr[4:]) {
+		// the payload is still returned to advance past the record
+		return payload, hdr[8], errChecksum
+	}
+	return payload, hdr[8], nil
+}
+
+// decode validates and decodes the record payload into the batch.
+func (s *Spool) decode(payload []byte, codec byte) (*event.Batch, error) {
+	var err error
+	switch codec {
+	case codecNone:
+	case codecZstd:
+		payload, err = decompress(payload)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown codec %d", codec)
+	}
+	return decode(payload)
+}
+
+// commit advances the read position past the replayed batch. The
+// position is left intact if the segment was evicted in the meantime.
+func (s *Spool) commit(seg *segment, offset, next int64) error {
+	if len(s.segments) == 0 || s.segments[0] != seg || s.offset != offset {
+		return nil
+	}
+	s.offset = next
+	s.consumed++
+	if s.consumed == seg.count {
+		return s.remove()
+	}
+	s.updateMetrics()
+	return s.writeCursor()
+}
+
+// evict drops the oldest segment along with its pending batches.
+func (s *Spool) evict() error {
+	seg := s.segments[0]
+	spoolDroppedBytes.Add(s.name, seg.size-s.offset)
+	spoolDroppedBatches.Add(s.name, int64(seg.count-s.consumed))
+	log.Warnf("dropping %d batch(es) from %s spool", seg.count-s.consumed, s.name)
+	return s.remove()
+}
+
+// remove deletes the oldest segment. If the oldest
+// segment is also the head, a new head is started.
+func (s *Spool) remove() error {
+	if len(s.segments) == 1 {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(s.path(s.segments[0])); err != nil {
+		return err
+	}
+	s.segments = s.segments[1:]
+	s.offset, s.consumed = 0, 0
+	s.updateMetrics()
+	return s.writeCursor()
+}
+
+// expire drops the segments with batches older than the maximum age.
+func (s *Spool) expire(now time.Time) {
+	if s.maxAge <= 0 {
+		return
+	}
+	for s.len() > 0 && now.Sub(s.segments[0].mtime) > s.maxAge {
+		if err := s.evict(); err != nil {
+			log.Warnf("unable to expire %s spool segment: %v", s.name, err)
+			return
+		}
+	}
+}
+
+// rotate starts a new head segment.
+func (s *Spool) rotate() error {
+	var id uint64 = 1
+	if len(s.segments) > 0 {
+		id = s.segments[len(s.segments)-1].id + 1
+	}
+	if s.head != nil {
+		if err := s.head.Close(); err != nil {
+			return err
+		}
+	}
+	seg := &segment{id: id, mtime: time.Now()}
+	f, err := os.OpenFile(s.path(seg), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	s.head = f
+	s.segments = append(s.segments, seg)
+	return nil
+}
+
+func (s *Spool) writeCursor() error {
+	b := make([]byte, 16)
+	if len(s.segments) > 0 {
+		binary.LittleEndian.PutUint64(b, s.segments[0].id)
+	}
+	binary.LittleEndian.PutUint64(b[8:], uint64(s.offset))
+	_, err := s.cursor.WriteAt(b, 0)
+	return err
+}
+
+func (s *Spool) updateMetrics() {
+	s.depth.Set(int64(s.len()))
+	s.bytes.Set(s.pending())
+}
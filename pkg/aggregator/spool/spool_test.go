@@ -0,0 +1,189 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package spool
+
+import (
+	"expvar"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rabbitstack/fibratus/pkg/event"
+	"github.com/rabbitstack/fibratus/pkg/event/params"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBatch(seqs ...uint64) *event.Batch {
+	evts := make([]*event.Event, 0, len(seqs))
+	for _, seq := range seqs {
+		evts = append(evts, &event.Event{
+			Seq:       seq,
+			Type:      event.CreateFile,
+			Name:      "CreateFile",
+			Category:  event.File,
+			PID:       1234,
+			Timestamp: time.Date(2026, 10, 18, 12, 30, 15, 0, time.UTC),
+			Params: event.Params{
+				params.FilePath: {Name: params.FilePath, Type: params.UnicodeString, Value: "C:\\Windows\\System32\\kernel32.dll"},
+			},
+			Metadata: map[event.MetadataKey]any{event.RuleNameKey: "Suspicious DLL load"},
+		})
+	}
+	return event.NewBatch(evts...)
+}
+
+func replay(t *testing.T, s *Spool) []uint64 {
+	var seqs []uint64
+	require.NoError(t, s.Replay(func(b *event.Batch) error {
+		for _, evt := range b.Events {
+			seqs = append(seqs, evt.Seq)
+		}
+		return nil
+	}))
+	return seqs
+}
+
+func value(m *expvar.Map, key string) int64 {
+	if v, ok := m.Get(key).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+func TestSpoolAppendReplay(t *testing.T) {
+	s, err := Open("http", Config{Dir: t.TempDir(), Compression: CompressionNone})
+	require.NoError(t, err)
+	defer s.Close()
+
+	require.NoError(t, s.Append(newBatch(1, 2)))
+	require.NoError(t, s.Append(newBatch(3)))
+	require.NoError(t, s.Append(newBatch(4, 5)))
+	assert.Equal(t, 3, s.Len())
+
+	var batches []*event.Batch
+	require.NoError(t, s.Replay(func(b *event.Batch) error {
+		batches = append(batches, b)
+		return nil
+	}))
+	require.Len(t, batches, 3)
+	assert.Equal(t, uint64(1), batches[0].Events[0].Seq)
+	assert.Equal(t, "CreateFile", batches[0].Events[0].Name)
+	assert.Equal(t, "C:\\Windows\\System32\\kernel32.dll", batches[0].Events[0].GetParamAsString(params.FilePath))
+	assert.Equal(t, "Suspicious DLL load", batches[0].Events[0].GetMetaAsString(event.RuleNameKey))
+	assert.Equal(t, 0, s.Len())
+
+	// the spool is reused after it is drained
+	require.NoError(t, s.Append(newBatch(6)))
+	assert.Equal(t, []uint64{6}, replay(t, s))
+}
+
+func TestSpoolReplayFailure(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open("amqp", Config{Dir: dir, Compression: CompressionNone})
+	require.NoError(t, err)
+
+	for i := uint64(1); i <= 3; i++ {
+		require.NoError(t, s.Append(newBatch(i)))
+	}
+
+	var n int
+	err = s.Replay(func(b *event.Batch) error {
+		n++
+		if n > 1 {
+			return os.ErrDeadlineExceeded
+		}
+		return nil
+	})
+	require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	assert.Equal(t, 2, s.Len())
+	require.NoError(t, s.Close())
+
+	// pending batches survive restarts
+	s, err = Open("amqp", Config{Dir: dir, Compression: CompressionNone})
+	require.NoError(t, err)
+	defer s.Close()
+	assert.Equal(t, 2, s.Len())
+	require.NoError(t, s.Append(newBatch(4)))
+	assert.Equal(t, []uint64{2, 3, 4}, replay(t, s))
+}
+
+func TestSpoolSizeLimit(t *testing.T) {
+	s, err := Open("elasticsearch", Config{Dir: t.TempDir(), Compression: CompressionNone})
+	require.NoError(t, err)
+	defer s.Close()
+
+	b := newBatch(11)
+	size := int64(recordHeaderSize + len(encode(b)))
+	s.limit = size * 4
+	s.segmentSize = size * 2
+
+	droppedBatches, droppedBytes := value(spoolDroppedBatches, "elasticsearch"), value(spoolDroppedBytes, "elasticsearch")
+	for i := uint64(11); i <= 20; i++ {
+		require.NoError(t, s.Append(newBatch(i)))
+		assert.True(t, s.pending() <= s.limit)
+	}
+	assert.Equal(t, int64(6), value(spoolDroppedBatches, "elasticsearch")-droppedBatches)
+	assert.Equal(t, size*6, value(spoolDroppedBytes, "elasticsearch")-droppedBytes)
+
+	// the oldest batches are dropped
+	assert.Equal(t, []uint64{17, 18, 19, 20}, replay(t, s))
+
+	// the batch larger than the spool is rejected
+	s.limit = size - 1
+	require.ErrorIs(t, s.Append(newBatch(21)), ErrBatchTooLarge)
+}
+
+func TestSpoolMaxAge(t *testing.T) {
+	s, err := Open("eventlog", Config{Dir: t.TempDir(), MaxAge: time.Millisecond * 100, Compression: CompressionNone})
+	require.NoError(t, err)
+	defer s.Close()
+
+	require.NoError(t, s.Append(newBatch(1)))
+	require.NoError(t, s.Append(newBatch(2)))
+	time.Sleep(time.Millisecond * 200)
+	require.NoError(t, s.Append(newBatch(3)))
+
+	assert.Equal(t, []uint64{3}, replay(t, s))
+}
+
+func TestSpoolCorruptedBatch(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open("console", Config{Dir: dir, Compression: CompressionNone})
+	require.NoError(t, err)
+
+	require.NoError(t, s.Append(newBatch(1)))
+	require.NoError(t, s.Append(newBatch(2)))
+	path := s.path(s.segments[0])
+	require.NoError(t, s.Close())
+
+	// flip the payload byte of the first batch and leave
+	// the incomplete batch at the end of the segment
+	buf, err := os.ReadFile(path)
+	require.NoError(t, err)
+	buf[recordHeaderSize+1] ^= 0xff
+	buf = append(buf, 0xff, 0xff, 0xff)
+	require.NoError(t, os.WriteFile(path, buf, 0o644))
+
+	s, err = Open("console", Config{Dir: dir, Compression: CompressionNone})
+	require.NoError(t, err)
+	defer s.Close()
+	assert.Equal(t, 2, s.Len())
+	assert.Equal(t, []uint64{2}, replay(t, s))
+}
@@ -19,39 +19,227 @@
 package aggregator
 
 import (
+	"expvar"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rabbitstack/fibratus/pkg/aggregator/spool"
 	"github.com/rabbitstack/fibratus/pkg/event"
 	"github.com/rabbitstack/fibratus/pkg/outputs"
+	"github.com/rabbitstack/fibratus/pkg/util/multierror"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// outputBatchesDropped counts the number of batches dropped per output
+	outputBatchesDropped = expvar.NewMap("aggregator.output.batches.dropped")
+	// outputEventsRouted counts the number of events routed to each output
+	outputEventsRouted = expvar.NewMap("aggregator.output.events.routed")
 )
 
+// dropWarnInterval specifies the minimum interval between warnings about dropped batches
+const dropWarnInterval = time.Minute
+
 // queue defines the type alias for the batch worker queue
 type queue chan *event.Batch
 
-// submitter initializes a group of load balanced output producers.
+// submitter initializes a group of load balanced output producers. Each
+// output gets its own submitter with the dedicated work queue and workers,
+// so a slow output doesn't stall the delivery of batches to other outputs.
+//
+// If the spool is enabled, batches that can't be delivered to the output
+// are persisted to disk instead of being dropped. While the spool contains
+// pending batches, all batches are appended to the spool to preserve the
+// order in which batches are published to the output.
 type submitter struct {
-	wq      queue
+	config outputs.Config
+	wq     queue
+	spool  *spool.Spool
+	// mu synchronizes the decision of whether the batch
+	// is pushed to the work queue or appended to the spool
+	mu      sync.Mutex
+	quit    chan struct{}
 	workers []*worker
+	wg      sync.WaitGroup
+	// dropped counts the batches dropped since the last warning
+	dropped atomic.Uint64
+	// lastDropWarn is the unix nano timestamp of the last warning
+	lastDropWarn atomic.Int64
 }
 
-func newSubmitter(wq queue, outputConfig outputs.Config) (*submitter, error) {
+func newSubmitter(outputConfig outputs.Config, spoolConfig spool.Config) (*submitter, error) {
 	output, err := outputs.Load(outputConfig.Type, outputConfig)
 	if err != nil {
 		return nil, err
 	}
 	clients := output.Clients
-	workers := make([]*worker, len(clients))
+	s := &submitter{
+		config:  outputConfig,
+		wq:      make(queue, outputConfig.GetQueueSize()),
+		quit:    make(chan struct{}),
+		workers: make([]*worker, len(clients)),
+	}
+	if spoolConfig.Enabled {
+		s.spool, err = spool.Open(outputConfig.Type.String(), spoolConfig)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open %s output spool: %v", outputConfig.Type, err)
+		}
+	}
 
 	for i, client := range clients {
-		workers[i] = initWorker(wq, client)
+		s.wg.Add(1)
+		s.workers[i] = initWorker(s, client)
+	}
+
+	return s, nil
+}
+
+// submit routes the batch events to the output work queue. If the queue
+// is full, the batch is spooled if the spool is enabled. Otherwise, the
+// batch is dropped if the output opted in to drop batches on the full
+// queue, or the submitter blocks until the output catches up.
+func (s *submitter) submit(b *event.Batch) {
+	b = s.config.Route(b)
+	if b.Len() == 0 {
+		return
 	}
+	outputEventsRouted.Add(s.config.Type.String(), b.Len())
 
-	return &submitter{wq: wq, workers: workers}, nil
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.spooling() {
+		s.spill(b)
+		return
+	}
+	select {
+	case s.wq <- b:
+	default:
+		switch {
+		case s.spool != nil:
+			s.spill(b)
+		case s.config.DropOnFull:
+			s.drop()
+		default:
+			s.wq <- b
+		}
+	}
+}
+
+// flush routes the batch events to the output work queue and blocks
+// until the batch is enqueued or the timeout expires. The batch is
+// spooled if it can't be enqueued in time.
+func (s *submitter) flush(b *event.Batch, timeout time.Duration) error {
+	b = s.config.Route(b)
+	if b.Len() == 0 {
+		return nil
+	}
+	outputEventsRouted.Add(s.config.Type.String(), b.Len())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.spooling() {
+		s.spill(b)
+		return nil
+	}
+	select {
+	case s.wq <- b:
+		return nil
+	case <-time.After(timeout):
+		if s.spool != nil {
+			s.spill(b)
+			return nil
+		}
+		return fmt.Errorf("fail to flush events to %s output after stop timed out", s.config.Type)
+	}
 }
 
-func (s *submitter) shutdown() error {
+// spooling determines if batches are appended to the spool
+// because previously spooled batches haven't been replayed.
+func (s *submitter) spooling() bool {
+	return s.spool != nil && s.spool.Len() > 0
+}
+
+// spill appends the batch to the spool. The caller must hold the lock.
+func (s *submitter) spill(b *event.Batch) {
+	if err := s.spool.Append(b); err != nil {
+		outputBatchesDropped.Add(s.config.Type.String(), 1)
+		log.Warnf("unable to spool batch for %s output: %v", s.config.Type, err)
+	}
+}
+
+// drop accounts for the batch dropped because the output can't keep
+// up, the spool is disabled, and the output opted in to drop batches. To avoid flooding the log, the warning
+// is emitted at most once per interval and reports the number of batches
+// dropped since the previous warning.
+func (s *submitter) drop() {
+	outputBatchesDropped.Add(s.config.Type.String(), 1)
+	n := s.dropped.Add(1)
+	now := time.Now().UnixNano()
+	last := s.lastDropWarn.Load()
+	if now-last < int64(dropWarnInterval) || !s.lastDropWarn.CompareAndSwap(last, now) {
+		return
+	}
+	s.dropped.Add(^(n - 1))
+	log.Warnf("dropped %d batch(es) for %s output because the work queue is full. "+
+		"Consider increasing the queue size or enabling the spool", n, s.config.Type)
+}
+
+// requeue appends the batch that failed to publish to the spool, followed
+// by all batches pending in the work queue, so they are replayed in order
+// once the output recovers. The batch is dropped if the spool is disabled.
+func (s *submitter) requeue(b *event.Batch) {
+	if s.spool == nil {
+		if b != nil {
+			outputBatchesDropped.Add(s.config.Type.String(), 1)
+		}
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b != nil {
+		s.spill(b)
+	}
+	for {
+		select {
+		case b, ok := <-s.wq:
+			if !ok {
+				return
+			}
+			s.spill(b)
+		default:
+			return
+		}
+	}
+}
+
+// shutdown closes the work queue and waits for the workers
+// to drain pending batches before closing the output clients.
+func (s *submitter) shutdown(timeout time.Duration) error {
+	close(s.wq)
+	close(s.quit)
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+	}
+
+	var errs []error
 	for _, w := range s.workers {
 		if err := w.close(); err != nil {
-			return err
+			errs = append(errs, err)
+		}
+	}
+	if s.spool != nil {
+		if err := s.spool.Close(); err != nil {
+			errs = append(errs, err)
 		}
 	}
-	return nil
+	return multierror.Wrap(errs...)
 }
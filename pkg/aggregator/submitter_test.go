@@ -0,0 +1,248 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package aggregator
+
+import (
+	"errors"
+	"expvar"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rabbitstack/fibratus/pkg/aggregator/spool"
+	"github.com/rabbitstack/fibratus/pkg/event"
+	"github.com/rabbitstack/fibratus/pkg/outputs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type predicateFunc func(evt *event.Event) bool
+
+func (f predicateFunc) Eval(evt *event.Event) bool { return f(evt) }
+
+type mockClient struct {
+	mu        sync.Mutex
+	published []*event.Event
+	block     chan struct{}
+	failures  int
+}
+
+func (c *mockClient) Connect() error { return nil }
+func (c *mockClient) Close() error   { return nil }
+
+func (c *mockClient) Publish(b *event.Batch) error {
+	if c.block != nil {
+		<-c.block
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.failures > 0 {
+		c.failures--
+		return errors.New("output unavailable")
+	}
+	c.published = append(c.published, b.Events...)
+	return nil
+}
+
+func (c *mockClient) Published() []*event.Event {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.published
+}
+
+func newMockSubmitter(config outputs.Config, client outputs.Client, sp *spool.Spool) *submitter {
+	s := &submitter{
+		config: config,
+		wq:     make(queue, config.GetQueueSize()),
+		quit:   make(chan struct{}),
+		spool:  sp,
+	}
+	s.wg.Add(1)
+	s.workers = []*worker{initWorker(s, client)}
+	return s
+}
+
+func TestSubmitterRouting(t *testing.T) {
+	all := &mockClient{}
+	net := &mockClient{}
+
+	submitters := []*submitter{
+		newMockSubmitter(outputs.Config{Type: outputs.Elasticsearch}, all, nil),
+		newMockSubmitter(outputs.Config{
+			Type: outputs.HTTP,
+			Predicate: predicateFunc(func(evt *event.Event) bool {
+				return evt.Category == event.Net
+			}),
+		}, net, nil),
+	}
+
+	b := event.NewBatch(
+		&event.Event{Seq: 1, Type: event.CreateProcess, Category: event.Process},
+		&event.Event{Seq: 2, Type: event.SendTCPv4, Category: event.Net},
+		&event.Event{Seq: 3, Type: event.CreateFile, Category: event.File},
+		&event.Event{Seq: 4, Type: event.ConnectTCPv4, Category: event.Net},
+	)
+	for _, s := range submitters {
+		s.submit(b)
+	}
+	for _, s := range submitters {
+		require.NoError(t, s.shutdown(time.Second))
+	}
+
+	assert.Len(t, all.Published(), 4)
+	require.Len(t, net.Published(), 2)
+	assert.Equal(t, uint64(2), net.Published()[0].Seq)
+	assert.Equal(t, uint64(4), net.Published()[1].Seq)
+}
+
+func TestSubmitterSlowOutput(t *testing.T) {
+	fast := &mockClient{}
+	slow := &mockClient{block: make(chan struct{})}
+
+	fastSubmitter := newMockSubmitter(outputs.Config{Type: outputs.Console}, fast, nil)
+	slowSubmitter := newMockSubmitter(outputs.Config{Type: outputs.AMQP, QueueSize: 1, DropOnFull: true}, slow, nil)
+
+	dropped := func() int64 {
+		if v := outputBatchesDropped.Get(outputs.AMQP.String()); v != nil {
+			return v.(*expvar.Int).Value()
+		}
+		return 0
+	}
+	droppedBefore := dropped()
+
+	// the slow output worker holds the first batch and the
+	// second batch fills the queue. The remaining batches are
+	// dropped for the slow output, but still reach the fast one
+	for i := 0; i < 5; i++ {
+		b := event.NewBatch(&event.Event{Seq: uint64(i), Category: event.Net})
+		fastSubmitter.submit(b)
+		slowSubmitter.submit(b)
+		time.Sleep(time.Millisecond * 20)
+	}
+
+	require.NoError(t, fastSubmitter.shutdown(time.Second))
+	assert.Len(t, fast.Published(), 5)
+
+	close(slow.block)
+	require.NoError(t, slowSubmitter.shutdown(time.Second))
+	assert.Len(t, slow.Published(), 2)
+	assert.Equal(t, int64(3), dropped()-droppedBefore)
+	// the first drop is reported right away, while the
+	// subsequent drops are held until the warn interval
+	assert.Equal(t, uint64(2), slowSubmitter.dropped.Load())
+}
+
+func TestSubmitterSlowSingleOutput(t *testing.T) {
+	slow := &mockClient{block: make(chan struct{})}
+	s := newMockSubmitter(outputs.Config{Type: outputs.Elasticsearch, QueueSize: 1}, slow, nil)
+
+	// the worker holds the first batch and the second batch
+	// fills the queue. The remaining batches block the submit
+	// until the output catches up
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 5; i++ {
+			s.submit(event.NewBatch(&event.Event{Seq: uint64(i), Category: event.Net}))
+		}
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("submit should block on the full queue")
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	close(slow.block)
+	<-done
+	require.NoError(t, s.shutdown(time.Second))
+	assert.Len(t, slow.Published(), 5)
+	assert.Equal(t, uint64(0), s.dropped.Load())
+}
+
+func TestSubmitterSpool(t *testing.T) {
+	dir := t.TempDir()
+	c := spool.Config{Enabled: true, Dir: dir, MaxSize: 16, Compression: spool.CompressionNone}
+
+	sp, err := spool.Open(outputs.HTTP.String(), c)
+	require.NoError(t, err)
+
+	client := &mockClient{failures: 1}
+	s := newMockSubmitter(outputs.Config{Type: outputs.HTTP}, client, sp)
+
+	// the first batch fails to publish and is spooled. The
+	// rest of batches are spooled until the spool is replayed
+	for i := 1; i <= 5; i++ {
+		s.submit(event.NewBatch(&event.Event{
+			Seq:       uint64(i),
+			Type:      event.ConnectTCPv4,
+			Category:  event.Net,
+			Name:      "Connect",
+			Timestamp: time.Now(),
+			Params:    event.Params{},
+		}))
+		time.Sleep(time.Millisecond * 50)
+	}
+	assert.True(t, sp.Len() > 0)
+
+	require.Eventually(t, func() bool { return len(client.Published()) == 5 }, time.Second*5, time.Millisecond*100)
+	for i, evt := range client.Published() {
+		assert.Equal(t, uint64(i+1), evt.Seq)
+	}
+	assert.Equal(t, 0, sp.Len())
+
+	// batches are pushed to the work queue after the spool is drained
+	s.submit(event.NewBatch(&event.Event{Seq: 6, Type: event.ConnectTCPv4, Category: event.Net, Params: event.Params{}}))
+	require.Eventually(t, func() bool { return len(client.Published()) == 6 }, time.Second, time.Millisecond*20)
+	assert.Equal(t, 0, sp.Len())
+
+	require.NoError(t, s.shutdown(time.Second))
+}
+
+func TestSubmitterSpoolOnShutdown(t *testing.T) {
+	dir := t.TempDir()
+	c := spool.Config{Enabled: true, Dir: dir, MaxSize: 16, Compression: spool.CompressionNone}
+
+	sp, err := spool.Open(outputs.AMQP.String(), c)
+	require.NoError(t, err)
+
+	// the output keeps failing, so pending batches are persisted
+	client := &mockClient{failures: 100}
+	s := newMockSubmitter(outputs.Config{Type: outputs.AMQP}, client, sp)
+	for i := 1; i <= 3; i++ {
+		s.submit(event.NewBatch(&event.Event{Seq: uint64(i), Type: event.ConnectTCPv4, Category: event.Net, Params: event.Params{}}))
+	}
+	require.NoError(t, s.flush(event.NewBatch(&event.Event{Seq: 4, Type: event.ConnectTCPv4, Category: event.Net, Params: event.Params{}}), time.Second))
+	require.NoError(t, s.shutdown(time.Second))
+
+	// pending batches are replayed in order on the next run
+	sp, err = spool.Open(outputs.AMQP.String(), c)
+	require.NoError(t, err)
+	defer sp.Close()
+	require.Equal(t, 4, sp.Len())
+
+	var seqs []uint64
+	require.NoError(t, sp.Replay(func(b *event.Batch) error {
+		for _, evt := range b.Events {
+			seqs = append(seqs, evt.Seq)
+		}
+		return nil
+	}))
+	assert.Equal(t, []uint64{1, 2, 3, 4}, seqs)
+}
@@ -19,49 +19,161 @@
 package aggregator
 
 import (
+	"errors"
 	"expvar"
+	"time"
+
+	"github.com/rabbitstack/fibratus/pkg/event"
 	"github.com/rabbitstack/fibratus/pkg/outputs"
 	log "github.com/sirupsen/logrus"
-	"time"
 )
 
 // maxBackoff determines the maximum exponential backoff wait time before reconnecting the client
 const maxBackoff = time.Minute
 
-var clientPublishErrors = expvar.NewInt("aggregator.worker.client.publish.errors")
+// initialBackoff is the initial backoff wait time
+const initialBackoff = time.Second * 2
+
+var (
+	clientPublishErrors = expvar.NewInt("aggregator.worker.client.publish.errors")
+	// outputPublishErrors counts the number of publish errors per output
+	outputPublishErrors = expvar.NewMap("aggregator.output.publish.errors")
+)
+
+var errWorkerStopped = errors.New("worker stopped")
 
 type worker struct {
-	qu      queue
+	s       *submitter
 	client  outputs.Client
 	backoff time.Duration
 }
 
-func initWorker(q queue, client outputs.Client) *worker {
-	w := &worker{qu: q, client: client, backoff: time.Second * 2}
+func initWorker(s *submitter, client outputs.Client) *worker {
+	w := &worker{s: s, client: client, backoff: initialBackoff}
 	go w.run()
 	return w
 }
 
 func (w *worker) run() {
+	defer w.s.wg.Done()
 	for {
 		err := w.client.Connect()
 		if err != nil {
 			// schedule an exponential backoff reconnect strategy for the client
 			w.backoff *= 2
-			log.Warnf("fail to connect the client: %v. Reconnecting in %v...", err, w.backoff)
+			log.Warnf("fail to connect the %s client: %v. Reconnecting in %v...", w.s.config.Type, err, w.backoff)
 			if w.backoff > maxBackoff {
 				w.backoff = maxBackoff
 			}
-			<-time.After(w.backoff)
+			if !w.wait() {
+				// persist pending batches until the next run
+				w.s.requeue(nil)
+				return
+			}
 			continue
 		}
+		w.backoff = initialBackoff
 		break
 	}
-	for batch := range w.qu {
-		if err := w.client.Publish(batch); err != nil {
-			clientPublishErrors.Add(1)
-			log.Warnf("couldn't publish batch to client: %v", err)
+
+	var ready <-chan struct{}
+	if w.s.spool != nil {
+		ready = w.s.spool.Ready()
+	}
+
+	for {
+		// batches in the work queue always precede spooled
+		// batches, so they are published in the first place
+		select {
+		case batch, ok := <-w.s.wq:
+			if !ok {
+				return
+			}
+			if !w.publish(batch) {
+				return
+			}
+			continue
+		default:
 		}
+
+		if w.s.spooling() {
+			err := w.s.spool.Replay(w.replay)
+			switch {
+			case errors.Is(err, errWorkerStopped):
+				return
+			case err != nil:
+				w.fail(err)
+				if !w.retry() {
+					return
+				}
+			default:
+				w.backoff = initialBackoff
+			}
+			continue
+		}
+
+		select {
+		case batch, ok := <-w.s.wq:
+			if !ok {
+				return
+			}
+			if !w.publish(batch) {
+				return
+			}
+		case <-ready:
+		}
+	}
+}
+
+// publish publishes the batch to the output. If the batch can't be
+// published, it is handed over to the spool along with the rest of
+// pending batches, and the worker backs off before replaying them.
+// Returns false if the worker is stopped while backing off.
+func (w *worker) publish(batch *event.Batch) bool {
+	if err := w.client.Publish(batch); err != nil {
+		w.fail(err)
+		w.s.requeue(batch)
+		if w.s.spool != nil {
+			return w.retry()
+		}
+	}
+	return true
+}
+
+// replay publishes the spooled batch unless the worker is stopped.
+func (w *worker) replay(batch *event.Batch) error {
+	select {
+	case <-w.s.quit:
+		return errWorkerStopped
+	default:
+	}
+	return w.client.Publish(batch)
+}
+
+func (w *worker) fail(err error) {
+	clientPublishErrors.Add(1)
+	outputPublishErrors.Add(w.s.config.Type.String(), 1)
+	log.Warnf("couldn't publish batch to %s client: %v", w.s.config.Type, err)
+}
+
+// retry waits for the backoff period and increases it exponentially.
+// Returns false if the worker is stopped while waiting.
+func (w *worker) retry() bool {
+	if !w.wait() {
+		return false
+	}
+	w.backoff = min(w.backoff*2, maxBackoff)
+	return true
+}
+
+// wait blocks for the backoff period. Returns false if
+// the worker is stopped while waiting.
+func (w *worker) wait() bool {
+	select {
+	case <-time.After(w.backoff):
+		return true
+	case <-w.s.quit:
+		return false
 	}
 }
 
@@ -20,6 +20,7 @@ package aggregator
 
 import (
 	"github.com/rabbitstack/fibratus/pkg/event"
+	"github.com/rabbitstack/fibratus/pkg/outputs"
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"net/http/httptest"
@@ -68,9 +69,6 @@ func (c *httpClient) Publish(b *event.Batch) error {
 }
 
 func TestRunWorker(t *testing.T) {
-	q := make(chan *event.Batch, 2)
-	q <- &event.Batch{}
-	q <- &event.Batch{}
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/publish", func(w http.ResponseWriter, r *http.Request) {
@@ -82,8 +80,10 @@ func TestRunWorker(t *testing.T) {
 
 	client := &httpClient{url: srv.URL, wait: make(chan struct{}, 1), expectedPublished: 2}
 
-	w := initWorker(q, client)
-	defer w.close()
+	s := newMockSubmitter(outputs.Config{Type: outputs.HTTP, QueueSize: 2}, client, nil)
+	defer s.shutdown(time.Second)
+	s.wq <- &event.Batch{}
+	s.wq <- &event.Batch{}
 
 	<-client.wait
 
@@ -91,9 +91,6 @@ func TestRunWorker(t *testing.T) {
 }
 
 func TestConnectClientBackoff(t *testing.T) {
-	q := make(chan *event.Batch, 2)
-	q <- &event.Batch{}
-	q <- &event.Batch{}
 
 	fail := true
 
@@ -118,8 +115,10 @@ func TestConnectClientBackoff(t *testing.T) {
 		fail = false
 	})
 
-	w := initWorker(q, client)
-	defer w.close()
+	s := newMockSubmitter(outputs.Config{Type: outputs.HTTP, QueueSize: 2}, client, nil)
+	defer s.shutdown(time.Second)
+	s.wq <- &event.Batch{}
+	s.wq <- &event.Batch{}
 
 	<-client.wait
 
@@ -30,9 +30,19 @@ import (
 	"strings"
 )
 
+// rulesReloader is the function that reloads the ruleset on demand
+var rulesReloader func() error
+
+// SetRulesReloader registers the function that is invoked
+// to reload the ruleset when the reload endpoint is hit.
+func SetRulesReloader(fn func() error) { rulesReloader = fn }
+
 func setupServer(lis net.Listener, c *config.Config) {
 	mux := http.NewServeMux()
 	mux.Handle("/config", handler.Config(c))
+	if rulesReloader != nil {
+		mux.Handle("/rules/reload", handler.RulesReload(rulesReloader))
+	}
 	mux.Handle("/debug/vars", expvar.Handler())
 
 	mux.HandleFunc("/debug/pprof/", pprof.Index)
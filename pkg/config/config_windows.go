@@ -27,8 +27,11 @@ import (
 	"golang.org/x/sys/windows"
 
 	"github.com/rabbitstack/fibratus/pkg/outputs/eventlog"
+	"github.com/rabbitstack/fibratus/pkg/outputs/file"
 
 	"github.com/rabbitstack/fibratus/pkg/outputs/http"
+	"github.com/rabbitstack/fibratus/pkg/outputs/kafka"
+	"github.com/rabbitstack/fibratus/pkg/outputs/syslog"
 
 	"github.com/rabbitstack/fibratus/pkg/aggregator"
 	"github.com/rabbitstack/fibratus/pkg/aggregator/transformers"
@@ -88,8 +91,8 @@ type Config struct {
 	Filament FilamentConfig `json:"filament" yaml:"filament"`
 	// PE contains the settings that influences the behaviour of the PE (Portable Executable) reader.
 	PE pe.Config `json:"pe" yaml:"pe"`
-	// Output stores the currently active output config
-	Output outputs.Config
+	// Outputs stores the configs of all active outputs
+	Outputs []outputs.Config
 	// InitHandleSnapshot indicates whether initial handle snapshot is built
 	InitHandleSnapshot bool `json:"init-handle-snapshot" yaml:"init-handle-snapshot"`
 	// EnumerateHandles indicates if process handles are collected during startup or
@@ -225,6 +228,9 @@ func NewWithOpts(options ...Option) *Config {
 		elasticsearch.AddFlags(flagSet)
 		http.AddFlags(flagSet)
 		eventlog.AddFlags(flagSet)
+		kafka.AddFlags(flagSet)
+		syslog.AddFlags(flagSet)
+		file.AddFlags(flagSet)
 		removet.AddFlags(flagSet)
 		replacet.AddFlags(flagSet)
 		renamet.AddFlags(flagSet)
@@ -397,6 +403,28 @@ func (c *Config) addFlags() {
 		c.flags.StringSlice(rulesFromPaths, []string{filepath.Join(dir, "*")}, "Comma-separated list of rules files")
 		c.flags.StringSlice(macrosFromPaths, []string{filepath.Join(dir, "Macros", "*")}, "Comma-separated list of macro files")
 		c.flags.StringSlice(rulesFromURLs, []string{}, "Comma-separated list of rules URL resources")
+		c.flags.StringSlice(exceptionsPaths, []string{}, "Comma-separated list of rule exception files")
+		c.flags.Bool(rulesHotReload, false, "Indicates if rules, macros, and exceptions are reloaded when rule files or URLs change")
+		c.flags.Duration(rulesRefresh, time.Minute*5, "Specifies how often rule URLs are checked for changes when the hot reload is enabled")
+		c.flags.Bool(rulesProfile, false, "Indicates if the per-rule evaluation cost is recorded and exposed via the stats endpoint")
+		c.flags.Bool(rulesExplain, false, "Indicates if alerts carry the explanation of the rule condition evaluation")
+		c.flags.Bool(rulesOptimize, true, "Indicates if rule conditions are reordered by evaluation cost and identical subexpressions are shared across rules")
+		c.flags.Int(seqMaxExpressions, 5, "Specifies the maximum number of expressions in the sequence")
+		c.flags.Duration(seqMaxSpan, time.Hour*4, "Specifies the upper bound of the sequence max span and the lifetime of partials in sequences without max span")
+		c.flags.Int(seqMaxPartials, 1000, "Specifies the maximum number of partials per sequence expression")
+		c.flags.Int(seqMaxPartialsPerKey, 0, "Specifies the maximum number of partials per sequence expression sharing the same link key")
+		c.flags.Int(seqMaxPartialsMemory, 0, "Specifies the maximum memory in megabytes occupied by partials of a single sequence rule")
+		c.flags.String(seqEviction, "drop-newest", "Specifies the policy applied when the partials limit is reached. Possible values are drop-newest and evict-oldest")
+		c.flags.String(seqSpillDir, "", "Specifies the directory where partials evicted due to the memory limit are spilled")
+		c.flags.String(seqStateFile, "", "Specifies the file where the sequence state is checkpointed and restored from on startup")
+		c.flags.Duration(seqCheckpointInterval, time.Minute, "Specifies how often the sequence state is checkpointed")
+		c.flags.Duration(thresholdMaxWindow, time.Hour*4, "Specifies the upper bound of the threshold time window")
+		c.flags.Duration(lookupsRefresh, time.Minute, "Specifies how often lookup table files are checked for changes and reloaded")
+		c.flags.String(rarityStateFile, "", "Specifies the file where the frequency store backing the first_seen and seen_count functions is persisted")
+		c.flags.Int(rarityMaxEntries, 100000, "Specifies the maximum number of distinct value combinations tracked by the frequency store")
+		c.flags.Duration(rarityTTL, time.Hour*24*30, "Specifies the period after which value combinations not observed again are forgotten")
+		c.flags.Duration(rarityLearningPeriod, time.Hour*24, "Specifies the period during which the frequency store only records observations")
+		c.flags.Duration(rarityFlushInterval, time.Minute, "Specifies how often the frequency store is persisted to the state file")
 		c.flags.Bool(matchAll, true, "Indicates if the match all strategy is enabled for the rule engine. If the match all strategy is enabled, a single event can trigger multiple rules")
 	}
 	if c.opts.capture {
@@ -27,9 +27,11 @@ import (
 	u "net/url"
 	"os"
 	"path/filepath"
+	"regexp"
 	"slices"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/Masterminds/sprig/v3"
 	"github.com/rabbitstack/fibratus/pkg/event"
@@ -57,8 +59,54 @@ type FilterConfig struct {
 	MinEngineVersion string            `json:"min-engine-version" yaml:"min-engine-version"`
 	Enabled          *bool             `json:"enabled" yaml:"enabled"`
 	Authors          []string          `json:"authors" yaml:"authors"`
+	Throttle         *Throttle         `json:"throttle" yaml:"throttle"`
+	Tests            []RuleTest        `json:"tests" yaml:"tests"`
+	resource         string
 }
 
+// ThrottleMode determines how the alerts exceeding
+// the throttle limit are handled.
+type ThrottleMode string
+
+const (
+	// ThrottleFirst emits the first N alerts per
+	// window and silently drops the rest.
+	ThrottleFirst ThrottleMode = "first"
+	// ThrottleSummarize emits the first N alerts per
+	// window and reports the number of suppressed
+	// alerts in the next emitted alert.
+	ThrottleSummarize ThrottleMode = "summarize"
+)
+
+// Throttle describes the deduplication and rate limiting
+// of the alerts produced by the rule. Alerts are grouped
+// by the rule and the values of the group-by fields.
+type Throttle struct {
+	// By contains the fields whose values group the alerts.
+	// Fields may contain the leading ordinal to reference
+	// the event in the particular sequence stage, e.g. 2.file.path.
+	By []string `json:"by" yaml:"by"`
+	// Window is the duration of the throttle window.
+	Window time.Duration `json:"window" yaml:"window"`
+	// Limit is the maximum number of alerts per window and group.
+	Limit uint64 `json:"limit" yaml:"limit"`
+	// Mode is the throttle mode.
+	Mode ThrottleMode `json:"mode" yaml:"mode"`
+}
+
+// GetLimit returns the maximum number of alerts emitted
+// per window. At least one alert is emitted per window.
+func (t Throttle) GetLimit() uint64 {
+	if t.Limit == 0 {
+		return 1
+	}
+	return t.Limit
+}
+
+// IsSummarize determines if the suppressed alerts are
+// reported in the next emitted alert.
+func (t Throttle) IsSummarize() bool { return t.Mode == ThrottleSummarize }
+
 // FilterAction wraps all possible filter actions.
 type FilterAction any
 
@@ -115,15 +163,37 @@ func (f FilterConfig) IsDisabled() bool { return f.Enabled != nil && !*f.Enabled
 // HasLabel determines if the filter has the given label.
 func (f FilterConfig) HasLabel(l string) bool { return f.Labels[l] != "" }
 
+// HasTests determines if the filter declares unit test cases.
+func (f FilterConfig) HasTests() bool { return len(f.Tests) > 0 }
+
+// Resource returns the path or the URL of the file the filter was loaded from.
+func (f FilterConfig) Resource() string { return f.resource }
+
 // Filters contains references to rule and macro definitions.
 type Filters struct {
-	Rules  Rules  `json:"rules" yaml:"rules"`
-	Macros Macros `json:"macros" yaml:"macros"`
+	Rules      Rules      `json:"rules" yaml:"rules"`
+	Macros     Macros     `json:"macros" yaml:"macros"`
+	Exceptions Exceptions `json:"exceptions" yaml:"exceptions"`
+	Sequences  Sequences  `json:"sequences" yaml:"sequences"`
+	Thresholds Thresholds `json:"thresholds" yaml:"thresholds"`
+	Lookups    Lookups    `json:"lookups" yaml:"lookups"`
+	Rarity     Rarity     `json:"rarity" yaml:"rarity"`
 	// MatchAll indicates if the match all strategy is enabled for the rule engine.
 	// If the match all strategy is enabled, a single event can trigger multiple rules.
-	MatchAll bool `json:"match-all" yaml:"match-all"`
-	macros   map[string]*Macro
-	filters  []*FilterConfig
+	MatchAll   bool `json:"match-all" yaml:"match-all"`
+	macros     map[string]*Macro
+	filters    []*FilterConfig
+	exceptions []*Exception
+}
+
+// Publish replaces the loaded macros, rules, and exceptions with
+// the ones loaded into the staged filters. This permits loading
+// rule resources into a copy of the filters and making them
+// visible only after the rules compile successfully.
+func (f *Filters) Publish(staged *Filters) {
+	f.macros = staged.macros
+	f.filters = staged.filters
+	f.exceptions = staged.exceptions
 }
 
 // FiltersWithMacros builds the filter config with the map of
@@ -138,6 +208,21 @@ type Rules struct {
 	Enabled   bool     `json:"enabled" yaml:"enabled"`
 	FromPaths []string `json:"from-paths" yaml:"from-paths"`
 	FromURLs  []string `json:"from-urls" yaml:"from-urls"`
+	// HotReload indicates if the rules, macros, and exceptions
+	// are reloaded when the underlying files or URLs change.
+	HotReload bool `json:"hot-reload" yaml:"hot-reload"`
+	// RefreshInterval determines how often the rule URLs are
+	// checked for changes when the hot reload is enabled.
+	RefreshInterval time.Duration `json:"refresh-interval" yaml:"refresh-interval"`
+	// Profile indicates if the per-rule evaluation cost is recorded.
+	Profile bool `json:"profile" yaml:"profile"`
+	// Explain indicates if alerts carry the explanation
+	// of the rule condition evaluation.
+	Explain bool `json:"explain" yaml:"explain"`
+	// Optimize indicates if rule conditions are rewritten
+	// for cheaper evaluation and identical subexpressions
+	// are evaluated once per event across rules.
+	Optimize bool `json:"optimize" yaml:"optimize"`
 }
 
 // Macros contains attributes that describe the location of
@@ -146,13 +231,345 @@ type Macros struct {
 	FromPaths []string `json:"from-paths" yaml:"from-paths"`
 }
 
+// Lookups contains the lookup tables that supply the external
+// reference data to rules, e.g. known file hashes or the list
+// of approved software.
+type Lookups struct {
+	Tables []LookupTable `json:"tables" yaml:"tables" mapstructure:"tables"`
+	// RefreshInterval determines how often lookup table
+	// files are checked for changes and reloaded.
+	RefreshInterval time.Duration `json:"refresh-interval" yaml:"refresh-interval" mapstructure:"refresh-interval"`
+}
+
+// LookupTable describes the lookup table loaded from the
+// local CSV or JSON file.
+type LookupTable struct {
+	// Name is the table name referenced in rule conditions.
+	Name string `json:"name" yaml:"name" mapstructure:"name"`
+	// Path is the location of the CSV or JSON file. The file
+	// format is derived from the file extension.
+	Path string `json:"path" yaml:"path" mapstructure:"path"`
+	// Key is the CSV column or JSON attribute that contains
+	// the lookup key. Defaults to the first CSV column.
+	Key string `json:"key" yaml:"key" mapstructure:"key"`
+	// Value is the CSV column or JSON attribute that contains
+	// the value returned for the key. Defaults to the second
+	// CSV column. If the table has no value column, the key
+	// itself is returned.
+	Value string `json:"value" yaml:"value" mapstructure:"value"`
+	// IgnoreCase indicates if keys are matched case-insensitively.
+	IgnoreCase bool `json:"ignore-case" yaml:"ignore-case" mapstructure:"ignore-case"`
+}
+
+const defaultLookupsRefreshInterval = time.Minute
+
+// GetRefreshInterval returns the lookup tables refresh interval.
+func (l *Lookups) GetRefreshInterval() time.Duration {
+	if l == nil || l.RefreshInterval == 0 {
+		return defaultLookupsRefreshInterval
+	}
+	return l.RefreshInterval
+}
+
+// Rarity contains the settings of the frequency store that keeps track
+// of how often combinations of values are observed by the first_seen
+// and seen_count rule functions. Zero values fall back to the defaults.
+type Rarity struct {
+	// StateFile is the file where the frequency store is periodically
+	// persisted. The store is restored from the file when the rule engine
+	// starts. If the file is not set, the store is only kept in memory.
+	StateFile string `json:"state-file" yaml:"state-file"`
+	// MaxEntries is the maximum number of distinct value combinations
+	// tracked by the store. When the limit is reached, the least recently
+	// observed combinations are evicted.
+	MaxEntries int `json:"max-entries" yaml:"max-entries"`
+	// TTL is the period after which the value combination that wasn't
+	// observed again is forgotten.
+	TTL time.Duration `json:"ttl" yaml:"ttl"`
+	// LearningPeriod is the period since the store was first created
+	// during which observations are only recorded. While learning, the
+	// first_seen function is never satisfied and the seen_count function
+	// yields no value.
+	LearningPeriod time.Duration `json:"learning-period" yaml:"learning-period"`
+	// FlushInterval specifies how often the store is persisted to the state file.
+	FlushInterval time.Duration `json:"flush-interval" yaml:"flush-interval"`
+}
+
+const (
+	defaultRarityMaxEntries    = 100000
+	defaultRarityTTL           = time.Hour * 24 * 30
+	defaultRarityFlushInterval = time.Minute
+)
+
+// GetMaxEntries returns the maximum number of frequency store entries.
+func (r *Rarity) GetMaxEntries() int {
+	if r == nil || r.MaxEntries == 0 {
+		return defaultRarityMaxEntries
+	}
+	return r.MaxEntries
+}
+
+// GetTTL returns the lifetime of frequency store entries.
+func (r *Rarity) GetTTL() time.Duration {
+	if r == nil || r.TTL == 0 {
+		return defaultRarityTTL
+	}
+	return r.TTL
+}
+
+// GetFlushInterval returns the frequency store flush interval.
+func (r *Rarity) GetFlushInterval() time.Duration {
+	if r == nil || r.FlushInterval == 0 {
+		return defaultRarityFlushInterval
+	}
+	return r.FlushInterval
+}
+
+// Eviction policies applied when the sequence partials limit is reached.
+const (
+	// DropNewestPartial discards the incoming partial
+	DropNewestPartial = "drop-newest"
+	// EvictOldestPartial evicts the oldest partial to make room for the incoming partial
+	EvictOldestPartial = "evict-oldest"
+)
+
+// Sequences contains the limits that govern sequence rules and the
+// memory occupied by sequence partials. Zero values fall back to the
+// default limits.
+type Sequences struct {
+	// MaxExpressions is the maximum number of expressions in the sequence.
+	MaxExpressions int `json:"max-expressions" yaml:"max-expressions"`
+	// MaxSpan is the upper bound of the sequence max span. It also
+	// determines the lifetime of partials in sequences without max span.
+	MaxSpan time.Duration `json:"max-span" yaml:"max-span"`
+	// MaxPartials is the maximum number of partials per sequence expression.
+	MaxPartials int `json:"max-partials" yaml:"max-partials"`
+	// MaxPartialsPerKey is the maximum number of partials per sequence
+	// expression that share the same link key. Zero means no limit.
+	MaxPartialsPerKey int `json:"max-partials-per-key" yaml:"max-partials-per-key"`
+	// MaxPartialsMemory is the maximum memory in megabytes occupied
+	// by partials of a single sequence rule. Zero means no limit.
+	MaxPartialsMemory int `json:"max-partials-memory" yaml:"max-partials-memory"`
+	// Eviction is the policy applied when any of the partials limits
+	// is reached. It's either drop-newest or evict-oldest.
+	Eviction string `json:"eviction" yaml:"eviction"`
+	// SpillDir is the directory where partials evicted due to the memory
+	// limit are spilled. Spilled partials are loaded back when the downstream
+	// sequence expression matches. If the directory is not set, evicted
+	// partials are discarded.
+	SpillDir string `json:"spill-dir" yaml:"spill-dir"`
+	// StateFile is the file where the sequence state is periodically
+	// checkpointed. The state is restored from the file when the rule
+	// engine starts. If the file is not set, the sequence state is not
+	// persisted across restarts.
+	StateFile string `json:"state-file" yaml:"state-file"`
+	// CheckpointInterval specifies how often the sequence state is checkpointed.
+	CheckpointInterval time.Duration `json:"checkpoint-interval" yaml:"checkpoint-interval"`
+}
+
+const (
+	defaultSeqMaxExpressions     = 5
+	defaultSeqMaxSpan            = time.Hour * 4
+	defaultSeqCheckpointInterval = time.Minute
+)
+
+// GetMaxExpressions returns the maximum number of sequence expressions.
+func (s *Sequences) GetMaxExpressions() int {
+	if s == nil || s.MaxExpressions == 0 {
+		return defaultSeqMaxExpressions
+	}
+	return s.MaxExpressions
+}
+
+// GetMaxSpan returns the upper bound of the sequence max span.
+func (s *Sequences) GetMaxSpan() time.Duration {
+	if s == nil || s.MaxSpan == 0 {
+		return defaultSeqMaxSpan
+	}
+	return s.MaxSpan
+}
+
+// GetCheckpointInterval returns the sequence state checkpoint interval.
+func (s *Sequences) GetCheckpointInterval() time.Duration {
+	if s == nil || s.CheckpointInterval == 0 {
+		return defaultSeqCheckpointInterval
+	}
+	return s.CheckpointInterval
+}
+
+// IsEvictOldest determines if the oldest partials are evicted
+// when the partials limit is reached.
+func (s Sequences) IsEvictOldest() bool { return s.Eviction == EvictOldestPartial }
+
+// Thresholds contains the limits that govern threshold rules.
+// Zero values fall back to the default limits.
+type Thresholds struct {
+	// MaxWindow is the upper bound of the threshold time window.
+	MaxWindow time.Duration `json:"max-window" yaml:"max-window"`
+}
+
+const defaultThresholdMaxWindow = time.Hour * 4
+
+// GetMaxWindow returns the upper bound of the threshold time window.
+func (t *Thresholds) GetMaxWindow() time.Duration {
+	if t == nil || t.MaxWindow == 0 {
+		return defaultThresholdMaxWindow
+	}
+	return t.MaxWindow
+}
+
+// Exceptions contains attributes that describe the location of
+// rule exception resources.
+type Exceptions struct {
+	FromPaths []string `json:"from-paths" yaml:"from-paths"`
+}
+
+// Exception suppresses the rule identified by the rule ID when the
+// exception condition is satisfied by the events that matched the
+// rule. Exceptions live outside the rule definitions, so the rules
+// can be updated without losing the environment-specific tuning.
+type Exception struct {
+	RuleID    string `json:"rule-id" yaml:"rule-id"`
+	Condition string `json:"condition" yaml:"condition"`
+	Owner     string `json:"owner" yaml:"owner"`
+	Reason    string `json:"reason" yaml:"reason"`
+	// Expires represents the date in the YYYY-MM-DD or RFC3339 format
+	// after which the exception is no longer enforced. If empty, the
+	// exception never expires.
+	Expires string `json:"expires" yaml:"expires"`
+	// expiresAt is the parsed expiration date
+	expiresAt time.Time
+}
+
+// ExpiresAt returns the exception expiration time. The zero
+// time is returned if the exception never expires.
+func (e Exception) ExpiresAt() time.Time { return e.expiresAt }
+
+// IsExpired determines if the exception expired at the given time.
+func (e Exception) IsExpired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && now.After(e.expiresAt)
+}
+
+// parseExpiry parses the exception expiration date. Dates without
+// the time component expire at the end of the given day in the
+// local time zone.
+func parseExpiry(s string) (time.Time, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t.Add(24*time.Hour - time.Nanosecond), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
 // Macro represents the state of the rule macro. Macros
-// either expand to expressions or lists.
+// either expand to expressions or lists. Function macros
+// expand to expressions where parameters are substituted
+// by the arguments given in the function call.
 type Macro struct {
 	ID          string   `json:"macro" yaml:"macro"`
 	Description string   `json:"description" yaml:"description"`
 	Expr        string   `json:"expr" yaml:"expr"`
 	List        []string `json:"list" yaml:"list"`
+	// Function is the function macro signature, e.g. is_lolbin(path).
+	Function string `json:"function" yaml:"function"`
+	// Params contains parameters declared in the function signature.
+	Params []MacroParam `json:"-" yaml:"-"`
+}
+
+// IsFunction determines if this is the function macro.
+func (m *Macro) IsFunction() bool { return m.Function != "" }
+
+// MacroParam represents the function macro parameter. The
+// parameter type restricts the arguments the function accepts.
+type MacroParam struct {
+	Name string
+	Type string
+}
+
+// Function macro parameter types.
+const (
+	// MacroParamAny accepts arbitrary arguments
+	MacroParamAny = "any"
+	// MacroParamString accepts string arguments
+	MacroParamString = "string"
+	// MacroParamNumber accepts numeric arguments
+	MacroParamNumber = "number"
+	// MacroParamIP accepts IP address arguments
+	MacroParamIP = "ip"
+	// MacroParamBool accepts boolean arguments and expressions
+	MacroParamBool = "bool"
+	// MacroParamList accepts list arguments
+	MacroParamList = "list"
+	// MacroParamDateTime accepts date and time arguments
+	MacroParamDateTime = "datetime"
+	// MacroParamField accepts field arguments
+	MacroParamField = "field"
+)
+
+// macroParamTypes contains recognized function macro parameter types.
+var macroParamTypes = []string{
+	MacroParamAny,
+	MacroParamString,
+	MacroParamNumber,
+	MacroParamIP,
+	MacroParamBool,
+	MacroParamList,
+	MacroParamDateTime,
+	MacroParamField,
+}
+
+// builtinFunctions contains the names of built-in filter functions. The
+// names are registered by the filter package, so function macros named
+// after built-in functions are rejected when macros are loaded.
+var builtinFunctions = make(map[string]bool)
+
+// RegisterFunction registers the name of the built-in filter function.
+func RegisterFunction(name string) {
+	builtinFunctions[strings.ToLower(name)] = true
+}
+
+// funcSignatureRegexp matches the function name and the parameter list.
+var funcSignatureRegexp = regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_]*)\s*\((.*)\)\s*$`)
+
+// paramRegexp matches the parameter name with the optional type.
+var paramRegexp = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)(?:\s+([a-z]+))?$`)
+
+// parseFunctionSignature parses the function macro signature. Each
+// parameter name can be followed by the parameter type, e.g. port number.
+// Parameters without type accept any argument.
+func parseFunctionSignature(sig string) (string, []MacroParam, error) {
+	matches := funcSignatureRegexp.FindStringSubmatch(sig)
+	if matches == nil {
+		return "", nil, fmt.Errorf("invalid function signature %q", sig)
+	}
+	name, args := matches[1], strings.TrimSpace(matches[2])
+	if builtinFunctions[strings.ToLower(name)] {
+		return "", nil, fmt.Errorf("%s function macro shadows the built-in function", name)
+	}
+	params := make([]MacroParam, 0)
+	if args == "" {
+		return name, params, nil
+	}
+	for _, arg := range strings.Split(args, ",") {
+		m := paramRegexp.FindStringSubmatch(strings.TrimSpace(arg))
+		if m == nil {
+			return "", nil, fmt.Errorf("invalid parameter %q in function signature %q", strings.TrimSpace(arg), sig)
+		}
+		param := MacroParam{Name: m[1], Type: m[2]}
+		if param.Type == "" {
+			param.Type = MacroParamAny
+		}
+		if !slices.Contains(macroParamTypes, param.Type) {
+			return "", nil, fmt.Errorf("unknown type %q of parameter %s in function signature %q. Expected one of %s",
+				param.Type, param.Name, sig, strings.Join(macroParamTypes, "|"))
+		}
+		for _, p := range params {
+			if p.Name == param.Name {
+				return "", nil, fmt.Errorf("duplicate parameter %s in function signature %q", param.Name, sig)
+			}
+		}
+		params = append(params, param)
+	}
+	return name, params, nil
 }
 
 // ActionContext is the convenient structure
@@ -165,6 +582,17 @@ type ActionContext struct {
 	Events []*event.Event
 	// Filter represents the filter that matched the event
 	Filter *FilterConfig
+	// Labels contains additional alert labels that
+	// complement the labels declared in the rule
+	Labels map[string]string
+}
+
+// AddLabel adds a label to the action context.
+func (ctx *ActionContext) AddLabel(key, value string) {
+	if ctx.Labels == nil {
+		ctx.Labels = make(map[string]string)
+	}
+	ctx.Labels[key] = value
 }
 
 // UniquePids returns a set of process identifiers
@@ -313,20 +741,86 @@ const (
 	rulesEnabled    = "filters.rules.enabled"
 	rulesFromPaths  = "filters.rules.from-paths"
 	rulesFromURLs   = "filters.rules.from-urls"
+	rulesHotReload  = "filters.rules.hot-reload"
+	rulesRefresh    = "filters.rules.refresh-interval"
+	rulesProfile    = "filters.rules.profile"
+	rulesExplain    = "filters.rules.explain"
+	rulesOptimize   = "filters.rules.optimize"
 	macrosFromPaths = "filters.macros.from-paths"
+	exceptionsPaths = "filters.exceptions.from-paths"
 	matchAll        = "filters.match-all"
+	lookupsRefresh  = "filters.lookups.refresh-interval"
+)
+
+const (
+	seqMaxExpressions     = "filters.sequences.max-expressions"
+	seqMaxSpan            = "filters.sequences.max-span"
+	seqMaxPartials        = "filters.sequences.max-partials"
+	seqMaxPartialsPerKey  = "filters.sequences.max-partials-per-key"
+	seqMaxPartialsMemory  = "filters.sequences.max-partials-memory"
+	seqEviction           = "filters.sequences.eviction"
+	seqSpillDir           = "filters.sequences.spill-dir"
+	seqStateFile          = "filters.sequences.state-file"
+	seqCheckpointInterval = "filters.sequences.checkpoint-interval"
+)
+
+const (
+	thresholdMaxWindow = "filters.thresholds.max-window"
+)
+
+const (
+	rarityStateFile      = "filters.rarity.state-file"
+	rarityMaxEntries     = "filters.rarity.max-entries"
+	rarityTTL            = "filters.rarity.ttl"
+	rarityLearningPeriod = "filters.rarity.learning-period"
+	rarityFlushInterval  = "filters.rarity.flush-interval"
 )
 
 func (f *Filters) initFromViper(v *viper.Viper) {
 	f.Rules.Enabled = v.GetBool(rulesEnabled)
 	f.Rules.FromPaths = v.GetStringSlice(rulesFromPaths)
 	f.Rules.FromURLs = v.GetStringSlice(rulesFromURLs)
+	f.Rules.HotReload = v.GetBool(rulesHotReload)
+	f.Rules.RefreshInterval = v.GetDuration(rulesRefresh)
+	f.Rules.Profile = v.GetBool(rulesProfile)
+	f.Rules.Explain = v.GetBool(rulesExplain)
+	f.Rules.Optimize = v.GetBool(rulesOptimize)
 	f.Macros.FromPaths = v.GetStringSlice(macrosFromPaths)
+	f.Exceptions.FromPaths = v.GetStringSlice(exceptionsPaths)
+	f.Sequences.MaxExpressions = v.GetInt(seqMaxExpressions)
+	f.Sequences.MaxSpan = v.GetDuration(seqMaxSpan)
+	f.Sequences.MaxPartials = v.GetInt(seqMaxPartials)
+	f.Sequences.MaxPartialsPerKey = v.GetInt(seqMaxPartialsPerKey)
+	f.Sequences.MaxPartialsMemory = v.GetInt(seqMaxPartialsMemory)
+	f.Sequences.Eviction = v.GetString(seqEviction)
+	f.Sequences.SpillDir = v.GetString(seqSpillDir)
+	f.Sequences.StateFile = v.GetString(seqStateFile)
+	f.Sequences.CheckpointInterval = v.GetDuration(seqCheckpointInterval)
+	f.Thresholds.MaxWindow = v.GetDuration(thresholdMaxWindow)
 	f.MatchAll = v.GetBool(matchAll)
+	f.Lookups.RefreshInterval = v.GetDuration(lookupsRefresh)
+	f.Rarity.StateFile = v.GetString(rarityStateFile)
+	f.Rarity.MaxEntries = v.GetInt(rarityMaxEntries)
+	f.Rarity.TTL = v.GetDuration(rarityTTL)
+	f.Rarity.LearningPeriod = v.GetDuration(rarityLearningPeriod)
+	f.Rarity.FlushInterval = v.GetDuration(rarityFlushInterval)
+
+	filters, ok := v.AllSettings()["filters"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	lookups, ok := filters["lookups"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	var tables []LookupTable
+	_ = decode(lookups["tables"], &tables)
+	f.Lookups.Tables = tables
 }
 
-func (f Filters) HasMacros() bool           { return len(f.macros) > 0 }
-func (f Filters) GetMacro(id string) *Macro { return f.macros[id] }
+func (f Filters) HasMacros() bool              { return len(f.macros) > 0 }
+func (f Filters) GetMacro(id string) *Macro    { return f.macros[id] }
+func (f Filters) GetMacros() map[string]*Macro { return f.macros }
 func (f Filters) IsMacroList(id string) bool {
 	macro, ok := f.macros[id]
 	if !ok {
@@ -378,12 +872,83 @@ func (f *Filters) LoadMacros() error {
 				return err
 			}
 			for _, m := range macros {
-				f.macros[m.ID] = &Macro{
+				macro := &Macro{
 					ID:          m.ID,
 					Description: m.Description,
 					Expr:        m.Expr,
 					List:        m.List,
+					Function:    m.Function,
+				}
+				if macro.IsFunction() {
+					macro.ID, macro.Params, err = parseFunctionSignature(m.Function)
+					if err != nil {
+						return fmt.Errorf("%s: %v", path, err)
+					}
+				}
+				f.macros[macro.ID] = macro
+			}
+		}
+	}
+	return nil
+}
+
+// GetExceptions returns all loaded rule exceptions.
+func (f Filters) GetExceptions() []*Exception { return f.exceptions }
+
+// LoadExceptions loads rule exceptions from YAML files. Each file
+// contains a list of exceptions. Exceptions with unparsable expiration
+// dates are rejected, while the expired exceptions are loaded but
+// reported, so the owners can either extend or remove them.
+func (f *Filters) LoadExceptions() error {
+	f.exceptions = make([]*Exception, 0)
+	for _, p := range f.Exceptions.FromPaths {
+		paths, err := filepath.Glob(p)
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			if !isValidExt(path) {
+				continue
+			}
+			log.Infof("loading rule exceptions from file %s", path)
+			buf, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("couldn't load exceptions from file: %v", err)
+			}
+			// validate exceptions yaml structure
+			var out interface{}
+			err = yaml.Unmarshal(buf, &out)
+			if err != nil {
+				return fmt.Errorf("%q is invalid exceptions yaml file: %v", path, err)
+			}
+			valid, errs := validate(exceptionsSchema, out)
+			if !valid || len(errs) > 0 {
+				b, err := yaml.Marshal(&out)
+				if err == nil {
+					out = string(b)
+				}
+				return fmt.Errorf("invalid exception definition: \n\n"+
+					"%v in %s: %v", out, path, multierror.Wrap(errs...))
+			}
+			buf, err = renderTmpl(path, buf)
+			if err != nil {
+				return err
+			}
+			var exceptions []*Exception
+			if err := yaml.Unmarshal(buf, &exceptions); err != nil {
+				return err
+			}
+			for _, e := range exceptions {
+				if e.Expires != "" {
+					e.expiresAt, err = parseExpiry(e.Expires)
+					if err != nil {
+						return fmt.Errorf("exception for rule %s in %s has invalid expiration date %q", e.RuleID, path, e.Expires)
+					}
+				}
+				if e.IsExpired(time.Now()) {
+					log.Warnf("exception for rule %s owned by %s expired on %s", e.RuleID, e.Owner, e.Expires)
 				}
+				f.exceptions = append(f.exceptions, e)
 			}
 		}
 	}
@@ -492,6 +1057,7 @@ func decodeFilter(resource string, b []byte) (*FilterConfig, error) {
 	if err := yaml.Unmarshal(b, &flt); err != nil {
 		return nil, err
 	}
+	flt.resource = resource
 	return &flt, nil
 }
 
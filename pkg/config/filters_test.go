@@ -26,6 +26,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestLoadRulesFromPaths(t *testing.T) {
@@ -37,9 +38,15 @@ func TestLoadRulesFromPaths(t *testing.T) {
 			},
 		},
 		Macros{FromPaths: nil},
+		Exceptions{FromPaths: nil},
+		Sequences{},
+		Thresholds{},
+		Lookups{},
+		Rarity{},
 		false,
 		map[string]*Macro{},
 		[]*FilterConfig{},
+		[]*Exception{},
 	}
 	err := filters.LoadFilters()
 	require.NoError(t, err)
@@ -85,9 +92,15 @@ func TestLoadRulesFromPathsWithTemplate(t *testing.T) {
 			},
 		},
 		Macros{FromPaths: nil},
+		Exceptions{FromPaths: nil},
+		Sequences{},
+		Thresholds{},
+		Lookups{},
+		Rarity{},
 		false,
 		map[string]*Macro{},
 		[]*FilterConfig{},
+		[]*Exception{},
 	}
 	err := filters.LoadFilters()
 	require.NoError(t, err)
@@ -124,9 +137,15 @@ func TestLoadGroupsFromURLs(t *testing.T) {
 			},
 		},
 		Macros{FromPaths: nil},
+		Exceptions{FromPaths: nil},
+		Sequences{},
+		Thresholds{},
+		Lookups{},
+		Rarity{},
 		false,
 		map[string]*Macro{},
 		[]*FilterConfig{},
+		[]*Exception{},
 	}
 	err = filters.LoadFilters()
 	require.NoError(t, err)
@@ -148,3 +167,85 @@ func TestLoadGroupsFromURLs(t *testing.T) {
 
 	assert.Equal(t, "2.0.0", f1.MinEngineVersion)
 }
+
+func TestLoadExceptions(t *testing.T) {
+	filters := Filters{
+		Exceptions: Exceptions{
+			FromPaths: []string{"_fixtures/exceptions/exceptions.yml"},
+		},
+	}
+	require.NoError(t, filters.LoadExceptions())
+	excs := filters.GetExceptions()
+	require.Len(t, excs, 3)
+
+	assert.Equal(t, "313933e7-8eb9-45d9-81af-0305fee70e29", excs[0].RuleID)
+	assert.Equal(t, "ps.exe = 'C:\\\\Program Files\\\\Backup\\\\agent.exe'", excs[0].Condition)
+	assert.Equal(t, "secops@example.com", excs[0].Owner)
+	assert.Equal(t, "backup agent reaches out to the storage appliance", excs[0].Reason)
+	assert.Equal(t, 2099, excs[0].ExpiresAt().Year())
+	assert.False(t, excs[0].IsExpired(time.Now()))
+
+	assert.True(t, excs[1].IsExpired(time.Now()))
+	assert.Equal(t, time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC), excs[1].ExpiresAt())
+
+	assert.True(t, excs[2].ExpiresAt().IsZero())
+	assert.False(t, excs[2].IsExpired(time.Now()))
+
+	filters.Exceptions.FromPaths = []string{"_fixtures/exceptions/invalid-expiry.yml"}
+	require.Error(t, filters.LoadExceptions())
+}
+
+func TestLoadFunctionMacros(t *testing.T) {
+	filters := Filters{
+		Macros: Macros{
+			FromPaths: []string{"_fixtures/macros/macros.yml"},
+		},
+	}
+	require.NoError(t, filters.LoadMacros())
+
+	assert.True(t, filters.IsMacroList("lolbins"))
+	assert.False(t, filters.GetMacro("spawn_process").IsFunction())
+
+	fn := filters.GetMacro("is_lolbin")
+	require.NotNil(t, fn)
+	assert.True(t, fn.IsFunction())
+	assert.Equal(t, "base(path) iin lolbins", fn.Expr)
+	assert.Equal(t, []MacroParam{{Name: "path", Type: "any"}}, fn.Params)
+
+	fn = filters.GetMacro("in_port_range")
+	require.NotNil(t, fn)
+	assert.Equal(t, []MacroParam{{Name: "port", Type: "number"}, {Name: "low", Type: "number"}, {Name: "high", Type: "number"}}, fn.Params)
+
+	filters.Macros.FromPaths = []string{"_fixtures/macros/invalid-function.yml"}
+	require.Error(t, filters.LoadMacros())
+	filters.Macros.FromPaths = []string{"_fixtures/macros/invalid-schema.yml"}
+	require.Error(t, filters.LoadMacros())
+}
+
+func TestParseFunctionSignature(t *testing.T) {
+	var tests = []struct {
+		sig    string
+		name   string
+		params []MacroParam
+		err    bool
+	}{
+		{"is_lolbin(path)", "is_lolbin", []MacroParam{{Name: "path", Type: "any"}}, false},
+		{"is_system()", "is_system", []MacroParam{}, false},
+		{"in_range( port  number , ip ip )", "in_range", []MacroParam{{Name: "port", Type: "number"}, {Name: "ip", Type: "ip"}}, false},
+		{"is_lolbin", "", nil, true},
+		{"is_lolbin(path, path)", "", nil, true},
+		{"is_lolbin(path file)", "", nil, true},
+		{"is_lolbin(1path)", "", nil, true},
+	}
+
+	for _, tt := range tests {
+		name, params, err := parseFunctionSignature(tt.sig)
+		if tt.err {
+			assert.Error(t, err, tt.sig)
+			continue
+		}
+		require.NoError(t, err, tt.sig)
+		assert.Equal(t, tt.name, name)
+		assert.Equal(t, tt.params, params)
+	}
+}
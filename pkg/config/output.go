@@ -22,7 +22,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
-	"strconv"
+	"sort"
 
 	"github.com/rabbitstack/fibratus/pkg/outputs/eventlog"
 
@@ -30,8 +30,11 @@ import (
 	"github.com/rabbitstack/fibratus/pkg/outputs/amqp"
 	"github.com/rabbitstack/fibratus/pkg/outputs/console"
 	"github.com/rabbitstack/fibratus/pkg/outputs/elasticsearch"
+	"github.com/rabbitstack/fibratus/pkg/outputs/file"
 	"github.com/rabbitstack/fibratus/pkg/outputs/http"
+	"github.com/rabbitstack/fibratus/pkg/outputs/kafka"
 	"github.com/rabbitstack/fibratus/pkg/outputs/null"
+	"github.com/rabbitstack/fibratus/pkg/outputs/syslog"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/sys/windows/svc"
 )
@@ -50,31 +53,8 @@ func (c *Config) tryLoadOutput() error {
 		return fmt.Errorf("expected map[string]interface{} type for output but found %s", reflect.TypeOf(output))
 	}
 
-	humNum := func(n int) string {
-		switch n {
-		case 2:
-			return "two"
-		case 3:
-			return "three"
-		case 4:
-			return "four"
-		case 5:
-			return "five"
-		case 6:
-			return "six"
-		case 7:
-			return "seven"
-		default:
-			return strconv.Itoa(n)
-		}
-	}
-	// don't permit if there are various outputs enabled at a time
-	activeOutputs := findActiveOutputs(mapping)
-	if len(activeOutputs) > 1 {
-		return fmt.Errorf("expected one but found %s active outputs: %s", humNum(len(activeOutputs)), activeOutputs)
-	}
-
 	for typ, config := range mapping {
+		var output interface{}
 		switch outputs.TypeFromString(typ) {
 		case outputs.Console:
 			var consoleConfig console.Config
@@ -84,7 +64,7 @@ func (c *Config) tryLoadOutput() error {
 			if !consoleConfig.Enabled {
 				continue
 			}
-			c.Output.Type, c.Output.Output = outputs.Console, consoleConfig
+			output = consoleConfig
 
 		case outputs.AMQP:
 			var amqpConfig amqp.Config
@@ -94,7 +74,7 @@ func (c *Config) tryLoadOutput() error {
 			if !amqpConfig.Enabled {
 				continue
 			}
-			c.Output.Type, c.Output.Output = outputs.AMQP, amqpConfig
+			output = amqpConfig
 
 		case outputs.Elasticsearch:
 			var esConfig elasticsearch.Config
@@ -104,7 +84,7 @@ func (c *Config) tryLoadOutput() error {
 			if !esConfig.Enabled {
 				continue
 			}
-			c.Output.Type, c.Output.Output = outputs.Elasticsearch, esConfig
+			output = esConfig
 
 		case outputs.HTTP:
 			var httpConfig http.Config
@@ -114,7 +94,7 @@ func (c *Config) tryLoadOutput() error {
 			if !httpConfig.Enabled {
 				continue
 			}
-			c.Output.Type, c.Output.Output = outputs.HTTP, httpConfig
+			output = httpConfig
 
 		case outputs.Eventlog:
 			var eventlogConfig eventlog.Config
@@ -124,41 +104,72 @@ func (c *Config) tryLoadOutput() error {
 			if !eventlogConfig.Enabled {
 				continue
 			}
-			c.Output.Type, c.Output.Output = outputs.Eventlog, eventlogConfig
+			output = eventlogConfig
+
+		case outputs.Kafka:
+			var kafkaConfig kafka.Config
+			if err := decode(config, &kafkaConfig); err != nil {
+				return errOutputConfig(typ, err)
+			}
+			if !kafkaConfig.Enabled {
+				continue
+			}
+			output = kafkaConfig
+
+		case outputs.Syslog:
+			var syslogConfig syslog.Config
+			if err := decode(config, &syslogConfig); err != nil {
+				return errOutputConfig(typ, err)
+			}
+			if !syslogConfig.Enabled {
+				continue
+			}
+			output = syslogConfig
+
+		case outputs.File:
+			var fileConfig file.Config
+			if err := decode(config, &fileConfig); err != nil {
+				return errOutputConfig(typ, err)
+			}
+			if !fileConfig.Enabled {
+				continue
+			}
+			output = fileConfig
+
+		default:
+			continue
 		}
-	}
 
-	// if it is not an interactive session but the console output is enabled
-	// we default to null output and warn about that
-	if isWindowsService() && c.Output.Output != nil {
-		if c.Output.Type == outputs.Console {
+		// decode routing options shared by all outputs
+		var outputConfig outputs.Config
+		if err := decode(config, &outputConfig); err != nil {
+			return errOutputConfig(typ, err)
+		}
+		outputConfig.Type, outputConfig.Output = outputs.TypeFromString(typ), output
+
+		// if it is not an interactive session but the console output is enabled
+		// we skip the console output and warn about that
+		if outputConfig.Type == outputs.Console && isWindowsService() {
 			log.Warn("running in non-interactive session with console output. " +
-				"Please configure a different output type. Defaulting to null output")
-			c.Output.Type, c.Output.Output = outputs.Null, &null.Config{}
-			return nil
+				"Please configure a different output type. Skipping console output")
+			continue
 		}
+
+		c.Outputs = append(c.Outputs, outputConfig)
 	}
 
+	// keep the outputs order stable across runs
+	sort.Slice(c.Outputs, func(i, j int) bool { return c.Outputs[i].Type < c.Outputs[j].Type })
+
 	// default to null output
-	if c.Output.Output == nil {
+	if len(c.Outputs) == 0 {
 		log.Warn("all outputs disabled. Defaulting to null output")
-		c.Output.Type, c.Output.Output = outputs.Null, &null.Config{}
+		c.Outputs = []outputs.Config{{Type: outputs.Null, Output: &null.Config{}}}
 	}
 
 	return nil
 }
 
-func findActiveOutputs(outputs map[string]interface{}) []string {
-	outputTypes := make([]string, 0)
-	for typ, rawConfig := range outputs {
-		enabled, ok := rawConfig.(map[string]interface{})["enabled"].(bool)
-		if ok && enabled {
-			outputTypes = append(outputTypes, typ)
-		}
-	}
-	return outputTypes
-}
-
 // isWindowsService returns true if the process is running inside Windows Service.
 func isWindowsService() bool {
 	isWinService, err := svc.IsWindowsService()
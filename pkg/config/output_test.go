@@ -22,10 +22,15 @@ import (
 	"testing"
 	"time"
 
+	"github.com/rabbitstack/fibratus/pkg/outputs"
+	"github.com/rabbitstack/fibratus/pkg/outputs/elasticsearch"
 	"github.com/rabbitstack/fibratus/pkg/outputs/eventlog"
+	"github.com/rabbitstack/fibratus/pkg/outputs/file"
 
 	"github.com/rabbitstack/fibratus/pkg/outputs/amqp"
 	"github.com/rabbitstack/fibratus/pkg/outputs/http"
+	"github.com/rabbitstack/fibratus/pkg/outputs/kafka"
+	"github.com/rabbitstack/fibratus/pkg/outputs/syslog"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -40,10 +45,10 @@ func TestAMQPOutput(t *testing.T) {
 
 	require.NoError(t, c.Init())
 
-	require.NotNil(t, c.Output)
-	require.IsType(t, amqp.Config{}, c.Output.Output)
+	require.Len(t, c.Outputs, 1)
+	require.IsType(t, amqp.Config{}, c.Outputs[0].Output)
 
-	amqpConfig := c.Output.Output.(amqp.Config)
+	amqpConfig := c.Outputs[0].Output.(amqp.Config)
 	assert.Equal(t, "amqp://localhost:5672", amqpConfig.URL)
 	assert.Equal(t, time.Second*5, amqpConfig.Timeout)
 	assert.Equal(t, "fibratus", amqpConfig.Exchange)
@@ -62,10 +67,10 @@ func TestHTTPOutput(t *testing.T) {
 
 	require.NoError(t, c.Init())
 
-	require.NotNil(t, c.Output)
-	require.IsType(t, http.Config{}, c.Output.Output)
+	require.Len(t, c.Outputs, 1)
+	require.IsType(t, http.Config{}, c.Outputs[0].Output)
 
-	httpConfig := c.Output.Output.(http.Config)
+	httpConfig := c.Outputs[0].Output.(http.Config)
 	assert.True(t, httpConfig.Enabled)
 	assert.Len(t, httpConfig.Endpoints, 2)
 	assert.Contains(t, httpConfig.Endpoints, "http://localhost:8081")
@@ -90,10 +95,108 @@ func TestEventlogOutput(t *testing.T) {
 
 	require.NoError(t, c.Init())
 
-	require.NotNil(t, c.Output)
-	require.IsType(t, eventlog.Config{}, c.Output.Output)
+	require.Len(t, c.Outputs, 1)
+	require.IsType(t, eventlog.Config{}, c.Outputs[0].Output)
 
-	eventlogConfig := c.Output.Output.(eventlog.Config)
+	eventlogConfig := c.Outputs[0].Output.(eventlog.Config)
 	assert.True(t, eventlogConfig.Enabled)
 	assert.Equal(t, "INFO", eventlogConfig.Level)
 }
+
+func TestKafkaOutput(t *testing.T) {
+	c := NewWithOpts(WithRun())
+
+	err := c.flags.Parse([]string{"--config-file=_fixtures/kafka-output.yml"})
+	require.NoError(t, c.viper.BindPFlags(c.flags))
+	require.NoError(t, err)
+	require.NoError(t, c.TryLoadFile(c.GetConfigFile()))
+
+	require.NoError(t, c.Init())
+
+	require.Len(t, c.Outputs, 1)
+	require.IsType(t, kafka.Config{}, c.Outputs[0].Output)
+	assert.Equal(t, "evt.category in ('process', 'net')", c.Outputs[0].Filter)
+
+	kafkaConfig := c.Outputs[0].Output.(kafka.Config)
+	assert.Equal(t, []string{"kafka-1:9092", "kafka-2:9092"}, kafkaConfig.Brokers)
+	assert.Equal(t, "fibratus-%evt.category", kafkaConfig.Topic)
+	assert.Equal(t, "ps.uuid", kafkaConfig.PartitionKey)
+	assert.Equal(t, "all", kafkaConfig.Acks)
+	assert.Equal(t, "zstd", kafkaConfig.Compression)
+	assert.Equal(t, "scram-sha-512", kafkaConfig.SASLMechanism)
+	assert.Equal(t, "fibratus", kafkaConfig.ClientID)
+	assert.Equal(t, time.Second*10, kafkaConfig.Timeout)
+}
+
+func TestSyslogOutput(t *testing.T) {
+	c := NewWithOpts(WithRun())
+
+	err := c.flags.Parse([]string{"--config-file=_fixtures/syslog-output.yml"})
+	require.NoError(t, c.viper.BindPFlags(c.flags))
+	require.NoError(t, err)
+	require.NoError(t, c.TryLoadFile(c.GetConfigFile()))
+
+	require.NoError(t, c.Init())
+
+	require.Len(t, c.Outputs, 1)
+	require.IsType(t, syslog.Config{}, c.Outputs[0].Output)
+
+	syslogConfig := c.Outputs[0].Output.(syslog.Config)
+	assert.Equal(t, syslog.TLS, syslogConfig.Network)
+	assert.Equal(t, "siem.corp:6514", syslogConfig.Address)
+	assert.Equal(t, syslog.RFC5424, syslogConfig.Format)
+	assert.Equal(t, syslog.OctetCounting, syslogConfig.Framing)
+	assert.Equal(t, outputs.CEF, syslogConfig.Serializer)
+	assert.Equal(t, "local4", syslogConfig.Facility)
+	assert.Equal(t, "info", syslogConfig.Severity)
+	assert.Equal(t, map[string]string{"cs1": "ps.cmdline", "cs1label": "'CommandLine'"}, syslogConfig.Fields)
+}
+
+func TestFileOutput(t *testing.T) {
+	c := NewWithOpts(WithRun())
+
+	err := c.flags.Parse([]string{"--config-file=_fixtures/file-output.yml"})
+	require.NoError(t, c.viper.BindPFlags(c.flags))
+	require.NoError(t, err)
+	require.NoError(t, c.TryLoadFile(c.GetConfigFile()))
+
+	require.NoError(t, c.Init())
+
+	require.Len(t, c.Outputs, 1)
+	require.IsType(t, file.Config{}, c.Outputs[0].Output)
+
+	fileConfig := c.Outputs[0].Output.(file.Config)
+	assert.Equal(t, `C:\ProgramData\Fibratus\Events\fibratus-%Y.%m.%d-%H.jsonl`, fileConfig.Path)
+	assert.Equal(t, 50, fileConfig.MaxSize)
+	assert.Equal(t, time.Hour, fileConfig.RotateInterval)
+	assert.Equal(t, 48, fileConfig.MaxBackups)
+	assert.Equal(t, 1024, fileConfig.MaxTotalSize)
+	assert.Equal(t, "zstd", fileConfig.Compression)
+}
+
+func TestMultipleOutputs(t *testing.T) {
+	c := NewWithOpts(WithRun())
+
+	err := c.flags.Parse([]string{"--config-file=_fixtures/multi-output.yml"})
+	require.NoError(t, c.viper.BindPFlags(c.flags))
+	require.NoError(t, err)
+	require.NoError(t, c.TryLoadFile(c.GetConfigFile()))
+
+	require.NoError(t, c.Init())
+
+	require.Len(t, c.Outputs, 3)
+
+	require.IsType(t, amqp.Config{}, c.Outputs[0].Output)
+	assert.Equal(t, outputs.AMQP, c.Outputs[0].Type)
+	assert.Equal(t, "evt.rule.name != ''", c.Outputs[0].Filter)
+	assert.Equal(t, 64, c.Outputs[0].GetQueueSize())
+
+	require.IsType(t, elasticsearch.Config{}, c.Outputs[1].Output)
+	assert.Equal(t, outputs.Elasticsearch, c.Outputs[1].Type)
+	assert.Empty(t, c.Outputs[1].Filter)
+	assert.Equal(t, outputs.DefaultQueueSize, c.Outputs[1].GetQueueSize())
+
+	require.IsType(t, http.Config{}, c.Outputs[2].Output)
+	assert.Equal(t, outputs.HTTP, c.Outputs[2].Type)
+	assert.Equal(t, "evt.category = 'net'", c.Outputs[2].Filter)
+}
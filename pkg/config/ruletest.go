@@ -0,0 +1,81 @@
+/*
+ * Copyright 2020-2021 by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import "time"
+
+// RuleTest represents the rule unit test case. The test case
+// describes a series of synthetic events that are fed to the
+// rule along with the expectation whether the rule matches.
+type RuleTest struct {
+	// Name is the short test case description.
+	Name string `json:"name" yaml:"name"`
+	// Match indicates if the rule is expected to match.
+	Match bool `json:"match" yaml:"match"`
+	// Events contains the events processed by the rule
+	// in the order of appearance.
+	Events []TestEvent `json:"events" yaml:"events"`
+}
+
+// TestEvent describes the synthetic event used in rule test cases.
+type TestEvent struct {
+	// Name is the event name, e.g. CreateProcess.
+	Name string `json:"name" yaml:"name"`
+	// PID is the identifier of the process generating
+	// the event. If omitted, the process identifier is
+	// taken from the process state.
+	PID uint32 `json:"pid" yaml:"pid"`
+	// TID is the identifier of the thread generating the event.
+	TID uint32 `json:"tid" yaml:"tid"`
+	// Delay is the amount of time elapsed since the
+	// previous event. It moves the event timestamp
+	// forward, e.g. to place the event outside the
+	// threshold window.
+	Delay time.Duration `json:"delay" yaml:"delay"`
+	// Params contains event parameters indexed by name.
+	// Parameter types are inferred from the parameter
+	// name and the value.
+	Params map[string]any `json:"params" yaml:"params"`
+	// PS represents the state of the process
+	// generating the event.
+	PS *TestProcess `json:"ps" yaml:"ps"`
+}
+
+// TestProcess describes the state of the process attached to the synthetic event.
+type TestProcess struct {
+	PID                 uint32            `json:"pid" yaml:"pid"`
+	Ppid                uint32            `json:"ppid" yaml:"ppid"`
+	Name                string            `json:"name" yaml:"name"`
+	Exe                 string            `json:"exe" yaml:"exe"`
+	Cmdline             string            `json:"cmdline" yaml:"cmdline"`
+	Cwd                 string            `json:"cwd" yaml:"cwd"`
+	SID                 string            `json:"sid" yaml:"sid"`
+	Username            string            `json:"username" yaml:"username"`
+	Domain              string            `json:"domain" yaml:"domain"`
+	Args                []string          `json:"args" yaml:"args"`
+	SessionID           uint32            `json:"session-id" yaml:"session-id"`
+	Envs                map[string]string `json:"envs" yaml:"envs"`
+	IsWOW64             bool              `json:"is-wow64" yaml:"is-wow64"`
+	IsPackaged          bool              `json:"is-packaged" yaml:"is-packaged"`
+	IsProtected         bool              `json:"is-protected" yaml:"is-protected"`
+	TokenIntegrityLevel string            `json:"token-integrity-level" yaml:"token-integrity-level"`
+	TokenElevationType  string            `json:"token-elevation-type" yaml:"token-elevation-type"`
+	IsTokenElevated     bool              `json:"is-token-elevated" yaml:"is-token-elevated"`
+	Parent              *TestProcess      `json:"parent" yaml:"parent"`
+}
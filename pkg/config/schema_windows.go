@@ -30,3 +30,6 @@ var rulesSchema string
 
 //go:embed macros.schema.json
 var macrosSchema string
+
+//go:embed exceptions.schema.json
+var exceptionsSchema string
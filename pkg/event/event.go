@@ -44,6 +44,8 @@ const (
 	YaraMatchesKey MetadataKey = "yara.matches"
 	// RuleNameKey identifies the rule that was triggered by the event
 	RuleNameKey MetadataKey = "rule.name"
+	// RuleSeverityKey designates the severity of the rule that was triggered by the event
+	RuleSeverityKey MetadataKey = "rule.severity"
 	// RuleSequenceLink represents the join link values in sequence rules
 	RuleSequenceLinks MetadataKey = "rule.seq.links"
 	// RuleSequenceOOOKey the presence of this metadata key indicates the
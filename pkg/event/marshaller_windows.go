@@ -421,8 +421,6 @@ func (e *Event) UnmarshalRaw(b []byte, ver capver.Version) error {
 	return nil
 }
 
-var js = newJSONStream()
-
 func writePsResources() bool {
 	return SerializeHandles || SerializeThreads || SerializeModules || SerializePE
 }
@@ -433,6 +431,10 @@ func (e *Event) MarshalJSON() []byte {
 		return []byte{}
 	}
 
+	// the same event may be serialized
+	// concurrently by multiple outputs
+	js := newJSONStream()
+
 	// start of JSON
 	js.writeObjectStart()
 
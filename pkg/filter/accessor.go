@@ -142,6 +142,11 @@ func (*evtAccessor) Get(f Field, evt *event.Event) (params.Value, error) {
 		return evt.Evasions&uint32(evasion.DirectSyscall) != 0, nil
 	case fields.EvtIsIndirectSyscall:
 		return evt.Evasions&uint32(evasion.IndirectSyscall) != 0, nil
+	case fields.EvtRuleName:
+		if name, ok := evt.GetMeta(event.RuleNameKey).(string); ok {
+			return name, nil
+		}
+		return nil, nil
 	}
 
 	return nil, nil
@@ -1139,6 +1139,8 @@ func (pa *peAccessor) Get(f Field, e *event.Event) (params.Value, error) {
 		return p.Symbols, nil
 	case fields.PeImports, fields.PsPeImports:
 		return p.Imports, nil
+	case fields.PeTimestamp, fields.PsPeTimestamp:
+		return p.LinkTime, nil
 	case fields.PeImphash, fields.PsPeImphash:
 		return p.Imphash, nil
 	case fields.PeIsDotnet, fields.PsPeIsDotnet:
@@ -367,6 +367,8 @@ const (
 	// EvtIsIndirectSyscall represents the field that designates if this event is
 	// performing an indirect syscall.
 	EvtIsIndirectSyscall Field = "evt.is_indirect_syscall"
+	// EvtRuleName represents the name of the rule that matched the event
+	EvtRuleName Field = "evt.rule.name"
 
 	// KevtSeq is the event sequence number
 	KevtSeq Field = "kevt.seq"
@@ -940,6 +942,7 @@ var fields = map[Field]FieldInfo{
 	}}},
 	EvtIsDirectSyscall:   {EvtIsDirectSyscall, "indicates if the event is performing a direct syscall", params.Bool, []string{"evt.is_direct_syscall = true"}, nil, nil},
 	EvtIsIndirectSyscall: {EvtIsIndirectSyscall, "indicates if the event is performing an indirect syscall", params.Bool, []string{"evt.is_indirect_syscall = true"}, nil, nil},
+	EvtRuleName:          {EvtRuleName, "name of the rule that matched the event", params.UnicodeString, []string{"evt.rule.name = 'LSASS memory dumping'"}, nil, nil},
 
 	KevtSeq:         {KevtSeq, "event sequence number", params.Uint64, []string{"kevt.seq > 666"}, &Deprecation{Since: "3.0.0", Fields: []Field{EvtSeq}}, nil},
 	KevtPID:         {KevtPID, "process identifier generating the event", params.Uint32, []string{"kevt.pid = 6"}, &Deprecation{Since: "3.0.0", Fields: []Field{EvtPID}}, nil},
@@ -1039,6 +1042,7 @@ var fields = map[Field]FieldInfo{
 	PsPeEntrypoint:  {PsPeEntrypoint, "address of the entrypoint function", params.Address, []string{"ps.pe.address.entrypoint = '20110'"}, nil, nil},
 	PsPeSymbols:     {PsPeSymbols, "imported symbols", params.Slice, []string{"ps.pe.symbols in ('GetTextFaceW', 'GetProcessHeap')"}, nil, nil},
 	PsPeImports:     {PsPeImports, "imported dynamic linked libraries", params.Slice, []string{"ps.pe.imports in ('msvcrt.dll', 'GDI32.dll'"}, nil, nil},
+	PsPeTimestamp:   {PsPeTimestamp, "timestamp at which the executable was linked", params.Time, []string{"age(ps.pe.timestamp) < 7d"}, nil, nil},
 	PsPeResources: {PsPeResources, "version resources", params.Map, []string{"ps.pe.resources[FileDescription] = 'Notepad'"}, nil, &Argument{Optional: true, Pattern: "[a-zA-Z0-9_]+", ValidationFunc: func(s string) bool {
 		for _, c := range s {
 			switch {
@@ -1215,6 +1219,7 @@ var fields = map[Field]FieldInfo{
 	PeEntrypoint:  {PeEntrypoint, "address of the entrypoint function", params.Address, []string{"pe.address.entrypoint = '20110'"}, &Deprecation{Since: "3.0.0", Fields: []Field{PsPeEntrypoint}}, nil},
 	PeSymbols:     {PeSymbols, "imported symbols", params.Slice, []string{"pe.symbols in ('GetTextFaceW', 'GetProcessHeap')"}, &Deprecation{Since: "3.0.0", Fields: []Field{PsPeSymbols}}, nil},
 	PeImports:     {PeImports, "imported dynamic linked libraries", params.Slice, []string{"pe.imports in ('msvcrt.dll', 'GDI32.dll'"}, &Deprecation{Since: "3.0.0", Fields: []Field{PsPeImports}}, nil},
+	PeTimestamp:   {PeTimestamp, "timestamp at which the image was linked", params.Time, []string{"age(pe.timestamp) < 7d"}, &Deprecation{Since: "3.0.0", Fields: []Field{PsPeTimestamp}}, nil},
 
 	PeResources: {PeResources, "version resources", params.Map, []string{"pe.resources[FileDescription] = 'Notepad'"}, &Deprecation{Since: "3.0.0", Fields: []Field{PsPeResources}}, &Argument{Optional: true, Pattern: "[a-zA-Z0-9_]+", ValidationFunc: func(s string) bool {
 		for _, c := range s {
@@ -30,6 +30,7 @@ import (
 	errs "github.com/rabbitstack/fibratus/pkg/errors"
 	"github.com/rabbitstack/fibratus/pkg/event"
 	"github.com/rabbitstack/fibratus/pkg/filter/fields"
+	"github.com/rabbitstack/fibratus/pkg/filter/lookup"
 	"github.com/rabbitstack/fibratus/pkg/filter/ql"
 )
 
@@ -61,6 +62,19 @@ type Filter interface {
 	// The valuer cache is acquired before the evaluation stage and provides a fast
 	// access to extracted field values.
 	EvalSequence(evt *event.Event, valuer *ValuerCache, seqID int, partials map[int][]*event.Event, rawMatch bool) bool
+	// EvalThreshold evaluates the event against the threshold expression. If the
+	// expression matches, the group key derived from the threshold group-by fields
+	// is returned as well. Events without group-by fields share the same key.
+	EvalThreshold(evt *event.Event, valuer *ValuerCache) (bool, string)
+	// Explain evaluates the event against the filter expression and returns the
+	// annotated expression tree revealing the outcome of each predicate. Sequence
+	// filters yield a tree for each sequence expression. Join conditions and bound
+	// fields are not resolved in sequence expressions.
+	Explain(evt *event.Event) []*ql.ExplainNode
+	// Value returns the value of the field declared in the filter expression.
+	// The value is extracted once per event and stored in the valuer cache.
+	// The second return value is false if the filter doesn't declare the field.
+	Value(evt *event.Event, valuer *ValuerCache, field string) (any, bool)
 	// GetStringFields returns field names mapped to their string values.
 	GetStringFields() map[fields.Field][]string
 	// GetFields returns all fields used in the filter expression.
@@ -69,6 +83,10 @@ type Filter interface {
 	GetSequence() *ql.Sequence
 	// IsSequence determines if this filter is a sequence.
 	IsSequence() bool
+	// GetThreshold returns the threshold descriptor or nil if this filter is not a threshold.
+	GetThreshold() *ql.Threshold
+	// IsThreshold determines if this filter is a threshold.
+	IsThreshold() bool
 	// Expr returns the raw AST expression.
 	Expr() ql.Expr
 }
@@ -144,11 +162,14 @@ func (b *BoundField) Accessor(f *filter) Accessor {
 }
 
 type filter struct {
-	expr        ql.Expr
-	seq         *ql.Sequence
-	parser      *ql.Parser
-	accessors   []Accessor
-	fields      []Field
+	expr      ql.Expr
+	seq       *ql.Sequence
+	threshold *ql.Threshold
+	parser    *ql.Parser
+	accessors []Accessor
+	fields    []Field
+	// fieldIndex maps field names to fields
+	fieldIndex  map[string]Field
 	segments    []fields.Segment
 	boundFields []*ql.BoundFieldLiteral
 	// seqBoundFields contains per-sequence bound fields resolved from bound field literals
@@ -156,6 +177,9 @@ type filter struct {
 	// stringFields contains filter field names mapped to their string values
 	stringFields map[fields.Field][]string
 	hasFunctions bool
+	// optimize indicates if the expressions are
+	// rewritten by the optimizer after parsing
+	optimize bool
 }
 
 // Compile parsers the filter expression and builds a binary expression tree
@@ -168,9 +192,12 @@ type filter struct {
 // until all nodes are visited.
 func (f *filter) Compile() error {
 	var err error
-	if f.parser.IsSequence() {
+	switch {
+	case f.parser.IsSequence():
 		f.seq, err = f.parser.ParseSequence()
-	} else {
+	case f.parser.IsThreshold():
+		f.threshold, err = f.parser.ParseThreshold()
+	default:
 		f.expr, err = f.parser.ParseExpr()
 	}
 	if err != nil {
@@ -181,10 +208,14 @@ func (f *filter) Compile() error {
 	walk := func(n ql.Node) {
 		switch expr := n.(type) {
 		case *ql.BinaryExpr:
+			expr.CompileMatcher()
 			if lhs, ok := expr.LHS.(*ql.FieldLiteral); ok {
 				f.addField(lhs)
 				f.addStringFields(lhs.Field, expr.RHS)
 			}
+			if lhs, ok := expr.LHS.(*ql.QuantifierExpr); ok {
+				f.addField(lhs.Field)
+			}
 			if rhs, ok := expr.RHS.(*ql.FieldLiteral); ok {
 				f.addField(rhs)
 				f.addStringFields(rhs.Field, expr.LHS)
@@ -224,9 +255,17 @@ func (f *filter) Compile() error {
 		}
 	}
 
-	if f.expr != nil {
+	switch {
+	case f.expr != nil:
 		ql.WalkFunc(f.expr, walk)
-	} else {
+	case f.threshold != nil:
+		if f.threshold.By != nil {
+			for _, fld := range f.threshold.By.Fields {
+				f.addField(fld)
+			}
+		}
+		ql.WalkFunc(f.threshold.Expr, walk)
+	default:
 		if f.seq.By != nil {
 			for _, fld := range f.seq.By.Fields {
 				f.addField(fld)
@@ -246,6 +285,10 @@ func (f *filter) Compile() error {
 		return ErrNoFields
 	}
 
+	if f.optimize {
+		f.optimizeExprs()
+	}
+
 	// only retain accessors for declared filter fields
 	f.narrowAccessors()
 
@@ -262,7 +305,58 @@ func (f *filter) EvalWithValuer(e *event.Event, cache *ValuerCache) bool {
 	if f.expr == nil {
 		return false
 	}
-	return ql.Eval(f.expr, f.mapValuer(e, cache), f.hasFunctions)
+	return f.evalExpr(f.expr, lazyValuer{f: f, evt: e, cache: cache}, cache.profile)
+}
+
+func (f *filter) EvalThreshold(e *event.Event, cache *ValuerCache) (bool, string) {
+	if f.threshold == nil {
+		return false, ""
+	}
+	valuer := lazyValuer{f: f, evt: e, cache: cache}
+	if !f.evalExpr(f.threshold.Expr, valuer, cache.profile) {
+		return false, ""
+	}
+	if !f.threshold.IsGrouped() {
+		return true, ""
+	}
+	values := make([]any, 0, len(f.threshold.By.Fields))
+	for _, fld := range f.threshold.By.Fields {
+		v, _ := valuer.Value(fld.String())
+		values = append(values, v)
+	}
+	return true, hashFields(values)
+}
+
+func (f *filter) Value(e *event.Event, cache *ValuerCache, field string) (any, bool) {
+	return lazyValuer{f: f, evt: e, cache: cache}.Value(field)
+}
+
+func (f *filter) Explain(e *event.Event) []*ql.ExplainNode {
+	valuer := AcquireValuerCache()
+	defer valuer.Release()
+	m := f.mapValuer(e, valuer)
+	switch {
+	case f.seq != nil:
+		nodes := make([]*ql.ExplainNode, 0, len(f.seq.Expressions))
+		for _, expr := range f.seq.Expressions {
+			nodes = append(nodes, ql.Explain(expr.Expr, m, f.hasFunctions))
+		}
+		return nodes
+	case f.threshold != nil:
+		return []*ql.ExplainNode{ql.Explain(f.threshold.Expr, m, f.hasFunctions)}
+	case f.expr != nil:
+		return []*ql.ExplainNode{ql.Explain(f.expr, m, f.hasFunctions)}
+	}
+	return nil
+}
+
+// evalExpr evaluates the expression against the valuer. If the
+// profile is given, the time spent in function calls is recorded.
+func (f *filter) evalExpr(expr ql.Expr, valuer ql.Valuer, prof *EvalProfile) bool {
+	if prof == nil {
+		return ql.EvalValuer(expr, valuer, f.hasFunctions, nil)
+	}
+	return ql.EvalValuer(expr, valuer, f.hasFunctions, &prof.Functions)
 }
 
 func (f *filter) Expr() ql.Expr {
@@ -278,6 +372,7 @@ func (f *filter) evalBoundSequence(
 	expr *ql.SequenceExpr,
 	partials map[int][]*event.Event,
 	valuer ql.MapValuer,
+	prof *EvalProfile,
 ) bool {
 	//  map all partials to their sequence aliases
 	maxSlots := len(partials[seqID])
@@ -336,7 +431,7 @@ func (f *filter) evalBoundSequence(
 		}
 
 		// evaluate the expression with the current valuer state
-		if ql.Eval(expr.Expr, valuer, f.hasFunctions) {
+		if f.evalExpr(expr.Expr, valuer, prof) {
 			// compute sequence key hash to stich events
 			values := make([]any, 0)
 			for _, fld := range flds {
@@ -365,6 +460,7 @@ func (f *filter) evalSequence(
 	expr *ql.SequenceExpr,
 	partials map[int][]*event.Event,
 	valuer ql.MapValuer,
+	prof *EvalProfile,
 ) bool {
 	// top-level sequence link is defined
 	by := f.seq.By
@@ -380,6 +476,11 @@ func (f *filter) evalSequence(
 		joins := make([]bool, seqID)
 	outer:
 		for i := range seqID {
+			// negated expressions never store partials
+			if f.seq.Expressions[i].IsNegated {
+				joins[i] = true
+				continue
+			}
 			for _, p := range partials[i] {
 				if CompareSeqLink(linkID, p.SequenceLinks()) {
 					joins[i] = true
@@ -387,9 +488,9 @@ func (f *filter) evalSequence(
 				}
 			}
 		}
-		match = joinsEqual(joins) && ql.Eval(expr.Expr, valuer, f.hasFunctions)
+		match = joinsEqual(joins) && f.evalExpr(expr.Expr, valuer, prof)
 	} else {
-		match = ql.Eval(expr.Expr, valuer, f.hasFunctions)
+		match = f.evalExpr(expr.Expr, valuer, prof)
 	}
 
 	if match && by != nil {
@@ -413,16 +514,16 @@ func (f *filter) EvalSequence(e *event.Event, valuerCache *ValuerCache, seqID in
 	if rawMatch {
 		// only check if the condition matches
 		// without evaluating joins/bound fields
-		return ql.Eval(expr.Expr, valuer, f.hasFunctions)
+		return f.evalExpr(expr.Expr, valuer, valuerCache.profile)
 	}
 
 	var match bool
 	if seqID >= 1 && expr.HasBoundFields() {
 		// evaluate bound field driven sequences
-		match = f.evalBoundSequence(e, seqID, &expr, partials, valuer)
+		match = f.evalBoundSequence(e, seqID, &expr, partials, valuer, valuerCache.profile)
 	} else {
 		// evaluate constrained/unconstrained sequences
-		match = f.evalSequence(e, seqID, &expr, partials, valuer)
+		match = f.evalSequence(e, seqID, &expr, partials, valuer, valuerCache.profile)
 	}
 
 	return match
@@ -434,10 +535,14 @@ func (f *filter) GetFields() []Field                         { return f.fields }
 func (f *filter) IsSequence() bool          { return f.seq != nil }
 func (f *filter) GetSequence() *ql.Sequence { return f.seq }
 
+func (f *filter) IsThreshold() bool           { return f.threshold != nil }
+func (f *filter) GetThreshold() *ql.Threshold { return f.threshold }
+
 // InterpolateFields replaces all occurrences of field modifiers in the given string
 // with values extracted from the event. Field modifiers may contain a leading ordinal
 // which refers to the event in particular sequence stage. Otherwise, the modifier is
-// a well-known field name prepended with the `%` symbol.
+// a well-known field name prepended with the `%` symbol. The `%lookup.table[field]`
+// modifier is replaced with the value the lookup table associates with the field value.
 func InterpolateFields(s string, evts []*event.Event) string {
 	var fieldsReplRegexp = regexp.MustCompile(`%([1-9]?)\.?([a-z0-9A-Z\[\]._]+)`)
 	matches := fieldsReplRegexp.FindAllStringSubmatch(s, -1)
@@ -446,17 +551,6 @@ func InterpolateFields(s string, evts []*event.Event) string {
 		return s
 	}
 
-	split := func(s string) (string, string) {
-		n, m := strings.Index(s, "["), strings.Index(s, "]")
-		if n < 0 || m < 0 {
-			return s, ""
-		}
-		if n > m {
-			return s, ""
-		}
-		return s[0:n], s[n+1 : m]
-	}
-
 	for _, m := range matches {
 		switch {
 		case len(m) == 3:
@@ -473,21 +567,8 @@ func InterpolateFields(s string, evts []*event.Event) string {
 			if i-1 > len(evts)-1 {
 				continue
 			}
-			evt := evts[i-1]
 			// extract field value from the event and replace in string
-			var val any
-			for _, accessor := range GetAccessors() {
-				name, arg := split(m[2])
-				f := Field{Value: m[2], Name: fields.Field(name), Arg: arg}
-				var err error
-				val, err = accessor.Get(f, evt)
-				if err != nil {
-					continue
-				}
-				if val != nil {
-					break
-				}
-			}
+			val := FieldValue(evts[i-1], m[2])
 			if val != nil {
 				r = strings.ReplaceAll(r, m[0], fmt.Sprintf("%v", val))
 			} else {
@@ -500,12 +581,73 @@ func InterpolateFields(s string, evts []*event.Event) string {
 	return r
 }
 
+// FieldValue extracts the value of the field, e.g. ps.uuid or evt.arg[exe],
+// from the event. The lookup.table[field] modifier resolves the value the
+// lookup table associates with the field value. Returns nil if the field
+// value can't be resolved.
+func FieldValue(evt *event.Event, field string) any {
+	name, arg := splitFieldArg(field)
+	if table, ok := strings.CutPrefix(name, lookupModifier); ok {
+		return lookupField(table, arg, evt)
+	}
+	return accessField(Field{Value: field, Name: fields.Field(name), Arg: arg}, evt)
+}
+
+// splitFieldArg splits the field into name and the argument enclosed in brackets.
+func splitFieldArg(s string) (string, string) {
+	n, m := strings.Index(s, "["), strings.Index(s, "]")
+	if n < 0 || m < 0 {
+		return s, ""
+	}
+	if n > m {
+		return s, ""
+	}
+	return s[0:n], s[n+1 : m]
+}
+
+// lookupModifier is the prefix of the field modifier that
+// interpolates the value from the lookup table
+const lookupModifier = "lookup."
+
+// accessField extracts the field value from the
+// event by trying all registered accessors.
+func accessField(f Field, evt *event.Event) any {
+	for _, accessor := range GetAccessors() {
+		val, err := accessor.Get(f, evt)
+		if err != nil {
+			continue
+		}
+		if val != nil {
+			return val
+		}
+	}
+	return nil
+}
+
+// lookupField resolves the value the lookup table
+// associates with the value of the event field.
+func lookupField(table, field string, evt *event.Event) any {
+	t := lookup.Get(table)
+	if t == nil || field == "" {
+		return nil
+	}
+	key := accessField(Field{Value: field, Name: fields.Field(field)}, evt)
+	if key == nil {
+		return nil
+	}
+	val, ok := t.Lookup(fmt.Sprintf("%v", key))
+	if !ok {
+		return nil
+	}
+	return val
+}
+
 // mapValuer for each field present in the AST, we run the
 // accessors and extract the field values that are supplied
 // to the valuer. The valuer feeds the expression with correct
 // values. If the field value is present in the valuer cache then
 // we directly populate the valuer for the field.
-func (f *filter) mapValuer(evt *event.Event, valuerCache *ValuerCache) map[string]any {
+func (f *filter) mapValuer(evt *event.Event, valuerCache *ValuerCache) ql.MapValuer {
 	for _, field := range f.fields {
 		valuerCache.populateValuer(field, func() any {
 			return f.extractField(field, evt)
@@ -541,7 +683,9 @@ func (f *filter) addField(field *ql.FieldLiteral) {
 			return
 		}
 	}
-	f.fields = append(f.fields, Field{Value: field.Value, Name: field.Field, Arg: field.Arg})
+	fld := Field{Value: field.Value, Name: field.Field, Arg: field.Arg}
+	f.fields = append(f.fields, fld)
+	f.fieldIndex[fld.String()] = fld
 }
 
 // addStringFields appends values for all string field expressions.
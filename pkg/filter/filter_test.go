@@ -35,6 +35,7 @@ import (
 	"github.com/rabbitstack/fibratus/pkg/event"
 	"github.com/rabbitstack/fibratus/pkg/event/params"
 	"github.com/rabbitstack/fibratus/pkg/filter/fields"
+	"github.com/rabbitstack/fibratus/pkg/filter/lookup"
 	"github.com/rabbitstack/fibratus/pkg/filter/ql"
 	"github.com/rabbitstack/fibratus/pkg/fs"
 	"github.com/rabbitstack/fibratus/pkg/pe"
@@ -801,7 +802,7 @@ func TestEventFilter(t *testing.T) {
 			params.FileType:      {Name: params.FileType, Type: params.AnsiString, Value: "file"},
 			params.FileOperation: {Name: params.FileOperation, Type: params.AnsiString, Value: "open"},
 		},
-		Metadata: map[event.MetadataKey]any{"foo": "bar", "fooz": "barz"},
+		Metadata: map[event.MetadataKey]any{"foo": "bar", "fooz": "barz", event.RuleNameKey: "Suspicious DLL open"},
 	}
 
 	evt.Timestamp, _ = time.Parse(time.RFC3339, "2011-05-03T15:04:05.323Z")
@@ -824,6 +825,7 @@ func TestEventFilter(t *testing.T) {
 		{`evt.arg[pid] = 3434`, true},
 		{`evt.is_direct_syscall = false`, true},
 		{`evt.is_indirect_syscall`, true},
+		{`evt.rule.name = 'Suspicious DLL open'`, true},
 
 		{`evt.desc contains 'Creates or opens a new file'`, true},
 
@@ -1555,6 +1557,46 @@ eo\Temp\lsass.dump</code> dump file to the disk device`,
 	}
 }
 
+func TestLookupFilterAndInterpolation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "software.csv")
+	require.NoError(t, os.WriteFile(path, []byte("name,vendor\nVaultCmd.exe,Microsoft\nnc.exe,\n"), 0o644))
+	require.NoError(t, lookup.Load([]config.LookupTable{{Name: "software", Path: path, IgnoreCase: true}}))
+
+	evt := &event.Event{
+		Type:     event.CreateProcess,
+		Category: event.Process,
+		Name:     "CreateProcess",
+		PID:      1023,
+		PS: &pstypes.PS{
+			Name: "vaultcmd.exe",
+			Ppid: 345,
+		},
+	}
+
+	var tests = []struct {
+		filter  string
+		matches bool
+	}{
+		{`lookup('software', ps.name) = 'Microsoft'`, true},
+		{`lookup('software', ps.name) = 'Google'`, false},
+		{`lookup('software', ps.pid) = 'Microsoft'`, false},
+		{`ps.name iin lookup_list('software')`, true},
+		{`ps.name in lookup_list('software')`, false},
+	}
+
+	for i, tt := range tests {
+		f := New(tt.filter, cfg)
+		require.NoError(t, f.Compile())
+		matches := f.Eval(evt)
+		if matches != tt.matches {
+			t.Errorf("%d. %q lookup filter mismatch: exp=%t got=%t", i, tt.filter, tt.matches, matches)
+		}
+	}
+
+	s := InterpolateFields("%ps.name by %lookup.software[ps.name] (%lookup.software[ps.pid]) %lookup.unknown[ps.name]", []*event.Event{evt})
+	assert.Equal(t, "vaultcmd.exe by Microsoft (N/A) N/A", s)
+}
+
 func BenchmarkFilterRun(b *testing.B) {
 	b.ReportAllocs()
 	f := New(`ps.name = 'mimikatz.exe' or ps.name contains 'svc'`, cfg)
@@ -1595,3 +1637,83 @@ func getNtdllAddress(pid uint32) uintptr {
 	}
 	return moduleInfo.BaseOfDll
 }
+
+func TestExplain(t *testing.T) {
+	evt := &event.Event{
+		Type: event.SendTCPv4,
+		Name: "Send",
+		Tid:  2484,
+		PID:  859,
+		PS: &pstypes.PS{
+			Name: "cmd.exe",
+		},
+		Category: event.Net,
+		Params: event.Params{
+			params.NetDport: {Name: params.NetDport, Type: params.Uint16, Value: uint16(8443)},
+			params.NetDIP:   {Name: params.NetDIP, Type: params.IPv4, Value: net.ParseIP("216.58.201.174")},
+		},
+	}
+
+	f := New(`ps.name = 'cmd.exe' and net.dport = 443`, cfg)
+	require.NoError(t, f.Compile())
+	nodes := f.Explain(evt)
+	require.Len(t, nodes, 1)
+	assert.False(t, nodes[0].Matched())
+	assert.Equal(t, f.Eval(evt), nodes[0].Matched())
+	require.Len(t, nodes[0].Children, 2)
+	assert.True(t, nodes[0].Children[0].Matched())
+	assert.False(t, nodes[0].Children[1].Matched())
+	assert.Equal(t, uint16(8443), nodes[0].Children[1].Children[0].Value)
+
+	f = New(`sequence
+|evt.name = 'CreateProcess'| by ps.pid
+|evt.name = 'Send' and cidr_contains(net.dip, '216.58.201.1/24')| by ps.pid
+`, cfg)
+	require.NoError(t, f.Compile())
+	nodes = f.Explain(evt)
+	require.Len(t, nodes, 2)
+	assert.False(t, nodes[0].Matched())
+	assert.True(t, nodes[1].Matched())
+}
+
+func TestFilterOptimizer(t *testing.T) {
+	evt := &event.Event{
+		Type: event.SendTCPv4,
+		Name: "Send",
+		Tid:  2484,
+		PID:  859,
+		PS: &pstypes.PS{
+			Name: "cmd.exe",
+		},
+		Category: event.Net,
+		Params: event.Params{
+			params.NetDport: {Name: params.NetDport, Type: params.Uint16, Value: uint16(8443)},
+			params.NetDIP:   {Name: params.NetDIP, Type: params.IPv4, Value: net.ParseIP("216.58.201.174")},
+		},
+	}
+
+	f := New(`ps.pe.is_dotnet and ps.name = 'cmd.exe' and net.dport = 443`, cfg, WithOptimizer())
+	require.NoError(t, f.Compile())
+	assert.Equal(t, "net.dport = 443 AND ps.name = cmd.exe AND ps.pe.is_dotnet", f.Expr().String())
+	assert.Len(t, f.GetFields(), 3)
+
+	// fields of short-circuited predicates are not extracted
+	cache := AcquireValuerCache()
+	assert.False(t, f.EvalWithValuer(evt, cache))
+	assert.Contains(t, cache.valuer, "net.dport")
+	assert.NotContains(t, cache.valuer, "ps.name")
+	assert.NotContains(t, cache.valuer, "ps.pe.is_dotnet")
+	cache.Release()
+
+	f1 := New(`net.dport = 8443 and (ps.name = 'cmd.exe' or ps.name = 'powershell.exe')`, cfg, WithOptimizer())
+	require.NoError(t, f1.Compile())
+	f2 := New(`evt.name = 'Send' and (ps.name = 'cmd.exe' or ps.name = 'powershell.exe')`, cfg, WithOptimizer())
+	require.NoError(t, f2.Compile())
+	require.Equal(t, 1, Share([]Filter{f1, f2}))
+
+	cache = AcquireValuerCache()
+	defer cache.Release()
+	assert.True(t, f1.EvalWithValuer(evt, cache))
+	assert.True(t, f2.EvalWithValuer(evt, cache))
+	assert.Len(t, cache.results, 1)
+}
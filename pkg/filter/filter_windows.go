@@ -29,7 +29,8 @@ import (
 )
 
 type opts struct {
-	psnap ps.Snapshotter
+	psnap    ps.Snapshotter
+	optimize bool
 }
 
 // Option defines the option supplied to the filter
@@ -42,6 +43,14 @@ func WithPSnapshotter(psnap ps.Snapshotter) Option {
 	}
 }
 
+// WithOptimizer enables the optimizer that rewrites the filter
+// expression for cheaper evaluation after it is parsed.
+func WithOptimizer() Option {
+	return func(o *opts) {
+		o.optimize = true
+	}
+}
+
 // New creates a new filter with the specified filter expression. The consumers must ensure
 // the expression is correctly parsed before executing the filter. This is achieved by calling the
 // `Compile` method after constructing the filter.
@@ -100,10 +109,12 @@ func New(expr string, config *config.Config, options ...Option) Filter {
 		parser:         parser,
 		accessors:      accessors,
 		fields:         make([]Field, 0),
+		fieldIndex:     make(map[string]Field),
 		segments:       make([]fields.Segment, 0),
 		stringFields:   make(map[fields.Field][]string),
 		boundFields:    make([]*ql.BoundFieldLiteral, 0),
 		seqBoundFields: make(map[int][]BoundField),
+		optimize:       opts.optimize,
 	}
 }
 
@@ -130,6 +141,7 @@ func NewFromCLIWithAllAccessors(args []string) (Filter, error) {
 		parser:         ql.NewParser(expr),
 		accessors:      GetAccessors(),
 		fields:         make([]Field, 0),
+		fieldIndex:     make(map[string]Field),
 		segments:       make([]fields.Segment, 0),
 		stringFields:   make(map[fields.Field][]string),
 		boundFields:    make([]*ql.BoundFieldLiteral, 0),
@@ -0,0 +1,379 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package lookup manages lookup tables that supply the external reference
+// data to rules. Tables are loaded from local CSV or JSON files and are
+// periodically reloaded when the underlying files change.
+package lookup
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rabbitstack/fibratus/pkg/config"
+	"github.com/rabbitstack/fibratus/pkg/util/stringcase"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// tableReloads counts the number of lookup table reloads
+	tableReloads = expvar.NewInt("lookup.table.reloads")
+	// tableReloadErrors counts the number of failed lookup table reloads
+	tableReloadErrors = expvar.NewInt("lookup.table.reload.errors")
+)
+
+var (
+	// ErrInvalidTableName is returned when the table name is not a valid identifier
+	ErrInvalidTableName = func(name string) error {
+		return fmt.Errorf("invalid lookup table name %q. Table names may only contain letters, digits, and underscores", name)
+	}
+	// ErrDuplicateTable is returned when the table name is declared more than once
+	ErrDuplicateTable = func(name string) error {
+		return fmt.Errorf("lookup table %q is declared more than once", name)
+	}
+	// ErrUnsupportedFormat is returned when the lookup table file format is not recognized
+	ErrUnsupportedFormat = func(path string) error {
+		return fmt.Errorf("unsupported lookup table format %q. Only CSV and JSON files are supported", filepath.Ext(path))
+	}
+	// ErrMissingColumn is returned when the key or value column is absent from the CSV header
+	ErrMissingColumn = func(col string) error {
+		return fmt.Errorf("column %q not found in the CSV header", col)
+	}
+)
+
+var tableNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+
+var (
+	mu     sync.RWMutex
+	tables = make(Tables)
+)
+
+// Table contains the key/value pairs loaded from the lookup file.
+type Table struct {
+	config.LookupTable
+	values map[string]string
+	keys   []string
+	// modTime and size identify the file version the table was loaded from
+	modTime time.Time
+	size    int64
+}
+
+// Lookup returns the value associated with the key.
+func (t *Table) Lookup(key string) (string, bool) {
+	if t.IgnoreCase {
+		key = stringcase.Fold(key)
+	}
+	v, ok := t.values[key]
+	return v, ok
+}
+
+// Keys returns all table keys in the order they appear in the file.
+func (t *Table) Keys() []string { return t.keys }
+
+// Len returns the number of table entries.
+func (t *Table) Len() int { return len(t.keys) }
+
+// Tables contains lookup tables keyed by table name.
+type Tables map[string]*Table
+
+// Load loads all lookup tables and replaces the previously loaded
+// tables. If any of the tables fails to load, the previous tables
+// are retained.
+func Load(configs []config.LookupTable) error {
+	loaded, err := Read(configs)
+	if err != nil {
+		return err
+	}
+	Publish(loaded)
+	return nil
+}
+
+// Read loads all lookup tables without replacing the previously
+// loaded tables. The tables are made available to lookup functions
+// once they are published.
+func Read(configs []config.LookupTable) (Tables, error) {
+	loaded := make(Tables, len(configs))
+	for _, c := range configs {
+		if !tableNameRegexp.MatchString(c.Name) {
+			return nil, ErrInvalidTableName(c.Name)
+		}
+		if _, ok := loaded[c.Name]; ok {
+			return nil, ErrDuplicateTable(c.Name)
+		}
+		t, err := load(c)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load %q lookup table from %s: %v", c.Name, c.Path, err)
+		}
+		log.Infof("loaded %d entries into %q lookup table from %s", t.Len(), c.Name, c.Path)
+		loaded[c.Name] = t
+	}
+	return loaded, nil
+}
+
+// Publish replaces the previously loaded tables with the given tables.
+func Publish(loaded Tables) {
+	mu.Lock()
+	defer mu.Unlock()
+	tables = loaded
+}
+
+// Get returns the lookup table by name or nil if the table doesn't exist.
+func Get(name string) *Table {
+	mu.RLock()
+	defer mu.RUnlock()
+	return tables[name]
+}
+
+// Refresh reloads lookup tables whose files changed since the tables
+// were loaded. If the table fails to reload, the error is logged and
+// the previous table contents remain in use.
+func Refresh() {
+	mu.RLock()
+	current := maps.Clone(tables)
+	mu.RUnlock()
+
+	for name, t := range current {
+		fi, err := os.Stat(t.Path)
+		if err != nil {
+			tableReloadErrors.Add(1)
+			log.Warnf("unable to stat %q lookup table file: %v", name, err)
+			continue
+		}
+		if fi.ModTime().Equal(t.modTime) && fi.Size() == t.size {
+			continue
+		}
+		nt, err := load(t.LookupTable)
+		if err != nil {
+			tableReloadErrors.Add(1)
+			log.Warnf("unable to reload %q lookup table from %s: %v", name, t.Path, err)
+			continue
+		}
+		mu.Lock()
+		// the tables may have been replaced in the meantime
+		if tables[name] == t {
+			tables[name] = nt
+		}
+		mu.Unlock()
+		tableReloads.Add(1)
+		log.Infof("reloaded %q lookup table from %s with %d entries", name, t.Path, nt.Len())
+	}
+}
+
+// Refresher periodically refreshes lookup tables.
+type Refresher struct {
+	ticker *time.Ticker
+	quit   chan struct{}
+}
+
+// NewRefresher starts refreshing lookup tables in the given interval.
+func NewRefresher(interval time.Duration) *Refresher {
+	r := &Refresher{ticker: time.NewTicker(interval), quit: make(chan struct{})}
+	go func() {
+		for {
+			select {
+			case <-r.ticker.C:
+				Refresh()
+			case <-r.quit:
+				return
+			}
+		}
+	}()
+	return r
+}
+
+// Stop stops refreshing lookup tables.
+func (r *Refresher) Stop() {
+	r.ticker.Stop()
+	close(r.quit)
+}
+
+func load(c config.LookupTable) (*Table, error) {
+	f, err := os.Open(c.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Table{
+		LookupTable: c,
+		values:      make(map[string]string),
+		keys:        make([]string, 0),
+		modTime:     fi.ModTime(),
+		size:        fi.Size(),
+	}
+
+	switch strings.ToLower(filepath.Ext(c.Path)) {
+	case ".csv":
+		err = t.readCSV(f)
+	case ".json":
+		err = t.readJSON(f)
+	default:
+		err = ErrUnsupportedFormat(c.Path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// add stores the table entry. Empty keys and duplicate keys are
+// ignored, so the first occurrence of the key wins.
+func (t *Table) add(key, value string) {
+	if key == "" {
+		return
+	}
+	k := key
+	if t.IgnoreCase {
+		k = stringcase.Fold(key)
+	}
+	if _, ok := t.values[k]; ok {
+		return
+	}
+	t.values[k] = value
+	t.keys = append(t.keys, key)
+}
+
+// readCSV reads the CSV file with the header row. Lines
+// starting with the # character are treated as comments.
+func (t *Table) readCSV(r io.Reader) error {
+	rd := csv.NewReader(r)
+	rd.Comment = '#'
+	rd.FieldsPerRecord = -1
+	rd.TrimLeadingSpace = true
+
+	header, err := rd.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return err
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+
+	key, value := 0, 1
+	if t.Key != "" {
+		if key = slices.Index(header, t.Key); key < 0 {
+			return ErrMissingColumn(t.Key)
+		}
+	}
+	if t.Value != "" {
+		if value = slices.Index(header, t.Value); value < 0 {
+			return ErrMissingColumn(t.Value)
+		}
+	} else if len(header) < 2 {
+		value = -1
+	}
+
+	for {
+		rec, err := rd.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if key >= len(rec) {
+			continue
+		}
+		k := strings.TrimSpace(rec[key])
+		v := k
+		if value >= 0 && value < len(rec) {
+			v = strings.TrimSpace(rec[value])
+		}
+		t.add(k, v)
+	}
+}
+
+// readJSON reads the JSON file. The file contains either the
+// object mapping keys to values, the array of keys, or the array
+// of objects with key and value attributes.
+func (t *Table) readJSON(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return err
+	}
+
+	switch doc := doc.(type) {
+	case map[string]any:
+		for k, v := range doc {
+			if obj, ok := v.(map[string]any); ok && t.Value != "" {
+				v = obj[t.Value]
+			}
+			t.add(k, stringify(v))
+		}
+		// map iteration order is random
+		slices.Sort(t.keys)
+	case []any:
+		for _, elem := range doc {
+			switch elem := elem.(type) {
+			case map[string]any:
+				if t.Key == "" {
+					return errors.New("key attribute is required for arrays of objects")
+				}
+				k := stringify(elem[t.Key])
+				v := k
+				if t.Value != "" {
+					v = stringify(elem[t.Value])
+				}
+				t.add(k, v)
+			default:
+				k := stringify(elem)
+				t.add(k, k)
+			}
+		}
+	default:
+		return errors.New("expected JSON object or array")
+	}
+
+	return nil
+}
+
+func stringify(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case map[string]any, []any:
+		b, _ := json.Marshal(v)
+		return string(b)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
@@ -0,0 +1,161 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lookup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rabbitstack/fibratus/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	require.NoError(t, Load([]config.LookupTable{
+		{Name: "hashes", Path: "_fixtures/hashes.csv", Key: "hash", Value: "verdict"},
+		{Name: "software", Path: "_fixtures/software.json", IgnoreCase: true},
+		{Name: "domains", Path: "_fixtures/domains.json", Value: "category"},
+		{Name: "users", Path: "_fixtures/users.json", Key: "sid", Value: "name"},
+	}))
+
+	hashes := Get("hashes")
+	require.NotNil(t, hashes)
+	assert.Equal(t, 2, hashes.Len())
+	v, ok := hashes.Lookup("44d88612fea8a8f36de82e1278abb02f")
+	require.True(t, ok)
+	// the first occurrence of the key wins
+	assert.Equal(t, "malware", v)
+	_, ok = hashes.Lookup("44D88612FEA8A8F36DE82E1278ABB02F")
+	assert.False(t, ok)
+
+	software := Get("software")
+	require.NotNil(t, software)
+	assert.Equal(t, []string{"chrome.exe", "firefox.exe", "Code.exe"}, software.Keys())
+	v, ok = software.Lookup("CODE.EXE")
+	require.True(t, ok)
+	assert.Equal(t, "Code.exe", v)
+
+	domains := Get("domains")
+	require.NotNil(t, domains)
+	assert.Equal(t, []string{"evil.com", "phish.net"}, domains.Keys())
+	v, _ = domains.Lookup("phish.net")
+	assert.Equal(t, "phishing", v)
+
+	users := Get("users")
+	require.NotNil(t, users)
+	assert.Equal(t, 3, users.Len())
+	v, _ = users.Lookup("S-1-5-19")
+	assert.Equal(t, "LOCAL SERVICE", v)
+	v, ok = users.Lookup("S-1-5-20")
+	require.True(t, ok)
+	assert.Empty(t, v)
+
+	assert.Nil(t, Get("unknown"))
+}
+
+func TestReadPublish(t *testing.T) {
+	require.NoError(t, Load([]config.LookupTable{
+		{Name: "hashes", Path: "_fixtures/hashes.csv", Key: "hash", Value: "verdict"},
+	}))
+
+	loaded, err := Read([]config.LookupTable{
+		{Name: "domains", Path: "_fixtures/domains.json", Value: "category"},
+	})
+	require.NoError(t, err)
+	require.Contains(t, loaded, "domains")
+	// the read tables are not visible until published
+	assert.NotNil(t, Get("hashes"))
+	assert.Nil(t, Get("domains"))
+
+	Publish(loaded)
+	assert.Nil(t, Get("hashes"))
+	assert.NotNil(t, Get("domains"))
+}
+
+func TestLoadErrors(t *testing.T) {
+	require.NoError(t, Load([]config.LookupTable{{Name: "hashes", Path: "_fixtures/hashes.csv"}}))
+
+	var tests = []struct {
+		tables []config.LookupTable
+		err    string
+	}{
+		{
+			[]config.LookupTable{{Name: "known-hashes", Path: "_fixtures/hashes.csv"}},
+			ErrInvalidTableName("known-hashes").Error(),
+		},
+		{
+			[]config.LookupTable{{Name: "hashes", Path: "_fixtures/hashes.csv"}, {Name: "hashes", Path: "_fixtures/software.json"}},
+			ErrDuplicateTable("hashes").Error(),
+		},
+		{
+			[]config.LookupTable{{Name: "software", Path: "_fixtures/software.txt"}},
+			`unable to load "software" lookup table from _fixtures/software.txt: ` + ErrUnsupportedFormat("_fixtures/software.txt").Error(),
+		},
+		{
+			[]config.LookupTable{{Name: "hashes", Path: "_fixtures/hashes.csv", Key: "md5"}},
+			`unable to load "hashes" lookup table from _fixtures/hashes.csv: ` + ErrMissingColumn("md5").Error(),
+		},
+		{
+			[]config.LookupTable{{Name: "users", Path: "_fixtures/users.json"}},
+			`unable to load "users" lookup table from _fixtures/users.json: key attribute is required for arrays of objects`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.err, func(t *testing.T) {
+			require.EqualError(t, Load(tt.tables), tt.err)
+			// the previous tables are retained
+			assert.NotNil(t, Get("hashes"))
+		})
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "iocs.csv")
+	require.NoError(t, os.WriteFile(path, []byte("ioc,type\nevil.com,domain\n"), 0o644))
+	require.NoError(t, Load([]config.LookupTable{{Name: "iocs", Path: path}}))
+
+	v, ok := Get("iocs").Lookup("evil.com")
+	require.True(t, ok)
+	assert.Equal(t, "domain", v)
+
+	// unchanged files are not reloaded
+	t1 := Get("iocs")
+	Refresh()
+	assert.Same(t, t1, Get("iocs"))
+
+	require.NoError(t, os.WriteFile(path, []byte("ioc,type\nevil.com,c2\n10.0.0.1,ip\n"), 0o644))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+	Refresh()
+
+	t2 := Get("iocs")
+	require.NotSame(t, t1, t2)
+	assert.Equal(t, 2, t2.Len())
+	v, _ = t2.Lookup("evil.com")
+	assert.Equal(t, "c2", v)
+
+	// malformed files keep the previous table
+	require.NoError(t, os.WriteFile(path, []byte("ioc,type\n\"evil.com,c2\n"), 0o644))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Hour)))
+	Refresh()
+	assert.Same(t, t2, Get("iocs"))
+}
@@ -0,0 +1,61 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filter
+
+import "github.com/rabbitstack/fibratus/pkg/filter/ql"
+
+// optimizeExprs rewrites the filter expressions with the optimizer.
+// The fields are collected from the original expressions, so fields
+// referenced in folded predicates are still declared by the filter.
+func (f *filter) optimizeExprs() {
+	switch {
+	case f.expr != nil:
+		f.expr = ql.Optimize(f.expr)
+	case f.threshold != nil:
+		f.threshold.Expr = ql.Optimize(f.threshold.Expr)
+	default:
+		for i := range f.seq.Expressions {
+			f.seq.Expressions[i].Expr = ql.Optimize(f.seq.Expressions[i].Expr)
+		}
+	}
+}
+
+// Share replaces parenthesized subexpressions that appear in multiple
+// filters with shared expressions. The result of the shared expression
+// is stored in the valuer cache by the first filter that evaluates it,
+// and the rest of filters evaluated against the same event reuse the
+// result. Sequence filters don't participate in sharing since they are
+// evaluated against the partials state. Returns the number of distinct
+// shared expressions.
+func Share(filters []Filter) int {
+	exprs := make([]*ql.Expr, 0, len(filters))
+	for _, flt := range filters {
+		f, ok := flt.(*filter)
+		if !ok {
+			continue
+		}
+		switch {
+		case f.expr != nil:
+			exprs = append(exprs, &f.expr)
+		case f.threshold != nil:
+			exprs = append(exprs, &f.threshold.Expr)
+		}
+	}
+	return ql.Share(exprs...)
+}
@@ -0,0 +1,796 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ql
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rabbitstack/fibratus/pkg/event/params"
+)
+
+// Severity determines the importance of the diagnostic.
+type Severity uint8
+
+const (
+	// Info is the severity of hints that don't alter the rule behaviour.
+	Info Severity = iota
+	// Warning is the severity of suspicious, yet legitimate expressions.
+	Warning
+	// Error is the severity of expressions that can't behave as intended.
+	Error
+)
+
+// String returns the severity name.
+func (s Severity) String() string {
+	switch s {
+	case Info:
+		return "info"
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	}
+	return "unknown"
+}
+
+// MarshalText encodes the severity by its name.
+func (s Severity) MarshalText() ([]byte, error) { return []byte(s.String()), nil }
+
+// Diagnostic codes identify the class of the issue found by the analyzer.
+const (
+	// TypeMismatch is reported when the operator or the operand is
+	// not applicable to the field type.
+	TypeMismatch = "type-mismatch"
+	// AlwaysTrue is reported for expressions that match every event.
+	AlwaysTrue = "always-true"
+	// AlwaysFalse is reported for expressions that never match.
+	AlwaysFalse = "always-false"
+	// RedundantBranch is reported for or branches already covered by other branches.
+	RedundantBranch = "redundant-branch"
+	// UnreachableStep is reported for sequence steps that can never be reached.
+	UnreachableStep = "unreachable-step"
+	// UnusedMacro is reported for macros not referenced by any rule.
+	UnusedMacro = "unused-macro"
+)
+
+// Diagnostic describes the issue found by the static analyzer.
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	// Code is the diagnostic code, e.g. type-mismatch.
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Expr is the offending expression. It is empty if the
+	// diagnostic doesn't pertain to a particular expression.
+	Expr string `json:"expr,omitempty"`
+}
+
+// String returns the human-readable diagnostic representation.
+func (d Diagnostic) String() string {
+	return d.Severity.String() + " [" + d.Code + "]: " + d.Message
+}
+
+// kind is the coarse-grained type of the field or the literal
+// that determines which operators and operands are applicable.
+type kind uint8
+
+const (
+	unknownKind kind = iota
+	stringKind
+	numberKind
+	ipKind
+	boolKind
+	sliceKind
+	durationKind
+	timeKind
+	listKind
+)
+
+var kindNames = map[kind]string{
+	stringKind:   "string",
+	numberKind:   "numeric",
+	ipKind:       "IP address",
+	boolKind:     "boolean",
+	sliceKind:    "slice",
+	durationKind: "duration",
+	timeKind:     "datetime",
+	listKind:     "list",
+}
+
+func (k kind) String() string { return kindNames[k] }
+
+// fieldOperators contains operators the evaluator
+// supports for each field kind. Any other operator
+// makes the predicate evaluate to false.
+var fieldOperators = map[kind][]Token{
+	stringKind: {Eq, IEq, Neq, Contains, IContains, In, IIn, Startswith, IStartswith, Endswith, IEndswith,
+		Matches, IMatches, Fuzzy, IFuzzy, Fuzzynorm, IFuzzynorm, Between},
+	numberKind: {Eq, Neq, Lt, Lte, Gt, Gte, In, Between, InRange},
+	ipKind:     {Eq, Neq, In, Startswith, Endswith, InRange},
+	boolKind:   {Eq, Neq},
+	sliceKind: {Contains, IContains, In, IIn, Startswith, IStartswith, Endswith, IEndswith,
+		Matches, IMatches, Intersects, IIntersects},
+}
+
+// fieldKind classifies the field by its parameter type. Fields
+// of types with loosely defined values, such as enumerations,
+// flags, or timestamps are classified as unknown and thus
+// excluded from type checks.
+func fieldKind(f *FieldLiteral) kind {
+	switch f.Field.Type() {
+	case params.UnicodeString, params.AnsiString, params.Path, params.DOSPath, params.Key, params.SID, params.WbemSID:
+		return stringKind
+	case params.Int8, params.Uint8, params.Int16, params.Uint16, params.Int32, params.Uint32, params.Int64, params.Uint64,
+		params.Float, params.Double, params.PID, params.TID, params.Port:
+		return numberKind
+	case params.IP, params.IPv4, params.IPv6:
+		return ipKind
+	case params.Bool:
+		return boolKind
+	case params.Slice:
+		// the field argument selects the slice element
+		if f.Arg != "" {
+			return unknownKind
+		}
+		return sliceKind
+	}
+	return unknownKind
+}
+
+// literalKind classifies the literal. Fields, functions,
+// and bound fields are of unknown kind.
+func literalKind(expr Expr) kind {
+	switch expr.(type) {
+	case *StringLiteral:
+		return stringKind
+	case *IntegerLiteral, *UnsignedLiteral, *DecimalLiteral:
+		return numberKind
+	case *IPLiteral:
+		return ipKind
+	case *BoolLiteral:
+		return boolKind
+	case *DurationLiteral:
+		return durationKind
+	case *DateTimeLiteral:
+		return timeKind
+	case *ListLiteral:
+		return listKind
+	}
+	return unknownKind
+}
+
+// isLiteral determines if the expression value is known before evaluation.
+func isLiteral(expr Expr) bool {
+	switch e := expr.(type) {
+	case *StringLiteral, *IntegerLiteral, *UnsignedLiteral, *DecimalLiteral, *IPLiteral, *BoolLiteral,
+		*DurationLiteral, *DateTimeLiteral, *ListLiteral, *RangesLiteral:
+		return true
+	case *RangeLiteral:
+		return isLiteral(e.Low) && isLiteral(e.High)
+	}
+	return false
+}
+
+// literalValue evaluates the literal expression.
+func literalValue(expr Expr) (interface{}, bool) {
+	if !isLiteral(expr) {
+		return nil, false
+	}
+	v := ValuerEval{Valuer: MapValuer{}}
+	return v.Eval(expr), true
+}
+
+// Analyze runs the static analysis pass over the expression and
+// returns diagnostics for type mismatches, always-true or always-false
+// predicates, and redundant or branches. Field types are resolved from
+// the field metadata.
+func Analyze(expr Expr) []Diagnostic {
+	a := &analyzer{}
+	a.analyze(expr, true)
+	return a.diags
+}
+
+// AnalyzeSequence analyzes each sequence step expression. In addition,
+// it reports steps that are unreachable, either because one of the
+// preceding steps never matches, or because the step joins on the
+// alias of the same or any of the later steps.
+func AnalyzeSequence(seq *Sequence) []Diagnostic {
+	diags := make([]Diagnostic, 0)
+	steps := make(map[string]int)
+	for i, e := range seq.Expressions {
+		if e.Alias != "" {
+			steps[e.Alias] = i
+		}
+	}
+
+	never := -1
+	for i, e := range seq.Expressions {
+		diags = append(diags, Analyze(e.Expr)...)
+		if never >= 0 {
+			diags = append(diags, Diagnostic{
+				Severity: Error,
+				Code:     UnreachableStep,
+				Message:  fmt.Sprintf("sequence step %d is unreachable because step %d never matches", i+1, never+1),
+				Expr:     e.Expr.String(),
+			})
+			continue
+		}
+		if c, ok := constant(e.Expr); ok && !c && !e.IsNegated {
+			never = i
+		}
+
+		for _, b := range e.BoundFields {
+			step, ok := steps[b.BoundVar.Value]
+			if !ok || step < i {
+				continue
+			}
+			var msg string
+			if step == i {
+				msg = fmt.Sprintf("sequence step %d is unreachable because %s references the alias of the same step", i+1, b.Value)
+			} else {
+				msg = fmt.Sprintf("sequence step %d is unreachable because %s references the alias of the later step %d", i+1, b.Value, step+1)
+			}
+			diags = append(diags, Diagnostic{Severity: Error, Code: UnreachableStep, Message: msg, Expr: e.Expr.String()})
+		}
+	}
+
+	return diags
+}
+
+// Identifiers returns all identifiers in the expression. Identifiers
+// that are not fields or function names are references to macros.
+func Identifiers(expr string) []string {
+	s := newBufScanner(strings.NewReader(expr))
+	ids := make([]string, 0)
+	for {
+		tok, _, lit := s.scan()
+		switch tok {
+		case EOF:
+			return ids
+		case Ident:
+			ids = append(ids, lit)
+		}
+	}
+}
+
+type analyzer struct {
+	diags []Diagnostic
+}
+
+func (a *analyzer) report(sev Severity, code string, expr Expr, format string, args ...any) {
+	a.diags = append(a.diags, Diagnostic{Severity: sev, Code: code, Message: fmt.Sprintf(format, args...), Expr: expr.String()})
+}
+
+// analyze visits the expression tree. Once the expression is
+// found to be constant, its subexpressions are not visited to
+// avoid reporting the same issue multiple times.
+func (a *analyzer) analyze(expr Expr, root bool) {
+	if c, ok := constant(expr); ok {
+		switch {
+		case c:
+			a.report(Warning, AlwaysTrue, expr, "expression %s is always true", expr)
+		case root:
+			a.report(Error, AlwaysFalse, expr, "expression %s is always false and the rule never matches", expr)
+		default:
+			a.report(Warning, AlwaysFalse, expr, "expression %s is always false", expr)
+		}
+		return
+	}
+
+	switch e := expr.(type) {
+	case *ParenExpr:
+		a.analyze(e.Expr, root)
+	case *NotExpr:
+		a.analyze(e.Expr, false)
+	case *BinaryExpr:
+		switch e.Op {
+		case And:
+			a.analyze(e.LHS, false)
+			a.analyze(e.RHS, false)
+		case Or:
+			branches := disjuncts(e)
+			a.checkRedundancy(branches)
+			for _, b := range branches {
+				a.analyze(b, false)
+			}
+		default:
+			a.checkTypes(e)
+		}
+	}
+}
+
+// checkTypes verifies the operator and the operand are
+// applicable to the type of the field on the left side.
+func (a *analyzer) checkTypes(expr *BinaryExpr) {
+	lhs, ok := expr.LHS.(*FieldLiteral)
+	if !ok {
+		return
+	}
+	fk := fieldKind(lhs)
+	if fk == unknownKind {
+		return
+	}
+
+	if !hasOperator(fk, expr.Op) {
+		a.report(Error, TypeMismatch, expr, "%s operator is not applicable to the %s field %s", strings.ToLower(expr.Op.String()), fk, lhs)
+		return
+	}
+
+	switch rhs := expr.RHS.(type) {
+	case *RangeLiteral:
+		for _, bound := range []Expr{rhs.Low, rhs.High} {
+			bk := literalKind(bound)
+			if bk != unknownKind && bk != fk {
+				a.report(Error, TypeMismatch, expr, "%s field %s can't be in range of %s bound %s", fk, lhs, bk, bound)
+				return
+			}
+		}
+	case *RangesLiteral:
+		switch {
+		case fk == numberKind && len(rhs.nets) > 0:
+			a.report(Error, TypeMismatch, expr, "CIDR ranges never match the %s field %s", fk, lhs)
+		case fk == ipKind && len(rhs.ranges) > 0:
+			a.report(Error, TypeMismatch, expr, "numeric ranges never match the %s field %s", fk, lhs)
+		}
+	case *ListLiteral:
+		if (expr.Op != In && expr.Op != IIn) && fk != stringKind && fk != sliceKind {
+			a.report(Error, TypeMismatch, expr, "%s field %s can't be compared to list %s", fk, lhs, rhs)
+			return
+		}
+		if (expr.Op == Eq || expr.Op == IEq || expr.Op == Neq) && fk == stringKind {
+			a.report(Error, TypeMismatch, expr, "%s field %s can't be compared to list %s", fk, lhs, rhs)
+			return
+		}
+		var invalid []string
+		for _, v := range rhs.Values {
+			switch fk {
+			case numberKind:
+				if _, err := strconv.ParseFloat(v, 64); err != nil {
+					invalid = append(invalid, v)
+				}
+			case ipKind:
+				if net.ParseIP(v) == nil {
+					invalid = append(invalid, v)
+				}
+			}
+		}
+		if len(invalid) > 0 {
+			a.report(Warning, TypeMismatch, expr, "%s field %s never matches %s list values", fk, lhs, strings.Join(invalid, ", "))
+		}
+	default:
+		rk := literalKind(rhs)
+		if rk == unknownKind {
+			return
+		}
+		switch {
+		case fk == rk:
+		case fk == sliceKind && rk == stringKind:
+		case fk == ipKind && rk == stringKind && (expr.Op == Startswith || expr.Op == Endswith):
+		default:
+			a.report(Error, TypeMismatch, expr, "%s field %s can't be compared to %s value %s", fk, lhs, rk, rhs)
+		}
+	}
+}
+
+// checkRedundancy reports or branches that are duplicated or
+// whose values are already contained in the list of another
+// branch operating on the same field.
+func (a *analyzer) checkRedundancy(branches []Expr) {
+	for i, b := range branches {
+		for j, o := range branches {
+			if i == j {
+				continue
+			}
+			if b.String() == o.String() {
+				if j < i {
+					a.report(Warning, RedundantBranch, b, "or branch %s is duplicated", b)
+					break
+				}
+				continue
+			}
+			if covers(o, b) && (!covers(b, o) || j < i) {
+				a.report(Warning, RedundantBranch, b, "or branch %s is redundant because %s already covers it", b, o)
+				break
+			}
+		}
+	}
+}
+
+func hasOperator(k kind, op Token) bool {
+	for _, tok := range fieldOperators[k] {
+		if tok == op {
+			return true
+		}
+	}
+	return false
+}
+
+// constant determines if the expression evaluates to the same
+// value for every event. The second return value is false if
+// the outcome of the expression depends on the event.
+func constant(expr Expr) (bool, bool) {
+	switch e := expr.(type) {
+	case *ParenExpr:
+		return constant(e.Expr)
+	case *NotExpr:
+		c, ok := constant(e.Expr)
+		return !c, ok
+	case *BoolLiteral:
+		return e.Value, true
+	case *BinaryExpr:
+		switch e.Op {
+		case And:
+			l, lok := constant(e.LHS)
+			r, rok := constant(e.RHS)
+			switch {
+			case (lok && !l) || (rok && !r):
+				return false, true
+			case lok && rok:
+				return true, true
+			}
+			return false, contradicts(conjuncts(e))
+		case Or:
+			l, lok := constant(e.LHS)
+			r, rok := constant(e.RHS)
+			switch {
+			case (lok && l) || (rok && r):
+				return true, true
+			case lok && rok:
+				return false, true
+			}
+			return true, tautology(disjuncts(e))
+		}
+
+		if isLiteral(e.LHS) && isLiteral(e.RHS) {
+			v := ValuerEval{Valuer: MapValuer{}}
+			c, ok := v.Eval(e).(bool)
+			return c, ok
+		}
+		if r, ok := e.RHS.(*RangeLiteral); ok && e.Op == Between {
+			low, lok := literalValue(r.Low)
+			high, hok := literalValue(r.High)
+			if lok && hok && isEmptyRange(low, high) {
+				return false, true
+			}
+		}
+	}
+	return false, false
+}
+
+// isEmptyRange determines if the lower bound is greater than the upper bound.
+func isEmptyRange(low, high interface{}) bool {
+	if c, ok := compareNumbers(low, high); ok {
+		return c > 0
+	}
+	switch low := low.(type) {
+	case time.Duration:
+		high, ok := high.(time.Duration)
+		return ok && low > high
+	case time.Time:
+		high, ok := high.(time.Time)
+		return ok && low.After(high)
+	}
+	return false
+}
+
+// conjuncts flattens the chain of and expressions.
+func conjuncts(expr Expr) []Expr {
+	return flatten(expr, And)
+}
+
+// disjuncts flattens the chain of or expressions.
+func disjuncts(expr Expr) []Expr {
+	return flatten(expr, Or)
+}
+
+func flatten(expr Expr, op Token) []Expr {
+	expr = unparen(expr)
+	if e, ok := expr.(*BinaryExpr); ok && e.Op == op {
+		return append(flatten(e.LHS, op), flatten(e.RHS, op)...)
+	}
+	return []Expr{expr}
+}
+
+// limit is the lower or upper bound of the numeric field.
+type limit struct {
+	v      interface{}
+	strict bool
+}
+
+// constraint accumulates values the field must satisfy in all conjuncts.
+type constraint struct {
+	eq        []interface{}
+	neq       []interface{}
+	in        [][]string
+	low, high *limit
+}
+
+func (c *constraint) add(op Token, rhs Expr) {
+	if r, ok := rhs.(*RangeLiteral); ok && op == Between {
+		low, lok := literalValue(r.Low)
+		high, hok := literalValue(r.High)
+		if lok && hok {
+			c.lower(low, false)
+			c.upper(high, false)
+		}
+		return
+	}
+	v, ok := literalValue(rhs)
+	if !ok {
+		return
+	}
+	switch op {
+	case Eq:
+		c.eq = append(c.eq, v)
+		c.lower(v, false)
+		c.upper(v, false)
+	case Neq:
+		c.neq = append(c.neq, v)
+	case In:
+		if list, ok := v.([]string); ok {
+			c.in = append(c.in, list)
+		}
+	case Gt, Gte:
+		c.lower(v, op == Gt)
+	case Lt, Lte:
+		c.upper(v, op == Lt)
+	}
+}
+
+func (c *constraint) lower(v interface{}, strict bool) {
+	if _, ok := numeric(v); !ok {
+		return
+	}
+	if c.low == nil {
+		c.low = &limit{v, strict}
+		return
+	}
+	n, _ := compareNumbers(v, c.low.v)
+	if n > 0 || (n == 0 && strict) {
+		c.low = &limit{v, strict}
+	}
+}
+
+func (c *constraint) upper(v interface{}, strict bool) {
+	if _, ok := numeric(v); !ok {
+		return
+	}
+	if c.high == nil {
+		c.high = &limit{v, strict}
+		return
+	}
+	n, _ := compareNumbers(v, c.high.v)
+	if n < 0 || (n == 0 && strict) {
+		c.high = &limit{v, strict}
+	}
+}
+
+// unsatisfiable determines if no value satisfies all constraints.
+func (c *constraint) unsatisfiable() bool {
+	for i, x := range c.eq {
+		for _, y := range c.eq[i+1:] {
+			if !equalValues(x, y) {
+				return true
+			}
+		}
+		for _, y := range c.neq {
+			if equalValues(x, y) {
+				return true
+			}
+		}
+		for _, list := range c.in {
+			if !inList(x, list) {
+				return true
+			}
+		}
+	}
+	if c.low != nil && c.high != nil {
+		n, _ := compareNumbers(c.low.v, c.high.v)
+		return n > 0 || (n == 0 && (c.low.strict || c.high.strict))
+	}
+	return false
+}
+
+// fieldPredicate returns the field on the left side of the
+// comparison if the field holds a single scalar value.
+func fieldPredicate(expr Expr) (*BinaryExpr, *FieldLiteral, bool) {
+	e, ok := expr.(*BinaryExpr)
+	if !ok {
+		return nil, nil, false
+	}
+	f, ok := e.LHS.(*FieldLiteral)
+	if !ok {
+		return nil, nil, false
+	}
+	switch f.Field.Type() {
+	case params.Null, params.Slice, params.Map, params.Object:
+		return nil, nil, false
+	}
+	return e, f, true
+}
+
+// contradicts determines if the conjuncts can't be satisfied
+// by the same event, e.g. when the field is compared to
+// different values or the numeric range is empty.
+func contradicts(exprs []Expr) bool {
+	constraints := make(map[string]*constraint)
+	for _, expr := range exprs {
+		e, f, ok := fieldPredicate(expr)
+		if !ok {
+			continue
+		}
+		c, ok := constraints[f.String()]
+		if !ok {
+			c = &constraint{}
+			constraints[f.String()] = c
+		}
+		c.add(e.Op, e.RHS)
+	}
+	for _, c := range constraints {
+		if c.unsatisfiable() {
+			return true
+		}
+	}
+	return false
+}
+
+// tautology determines if one of the disjuncts always
+// matches, e.g. when the field is compared to the value
+// for both equality and inequality.
+func tautology(exprs []Expr) bool {
+	eq := make(map[string][]interface{})
+	neq := make(map[string][]interface{})
+	for _, expr := range exprs {
+		e, f, ok := fieldPredicate(expr)
+		if !ok {
+			continue
+		}
+		v, ok := literalValue(e.RHS)
+		if !ok {
+			continue
+		}
+		switch e.Op {
+		case Eq:
+			eq[f.String()] = append(eq[f.String()], v)
+		case Neq:
+			neq[f.String()] = append(neq[f.String()], v)
+		}
+	}
+	for f, values := range neq {
+		for i, x := range values {
+			// distinct values can't be both equal to the field
+			for _, y := range values[i+1:] {
+				if !equalValues(x, y) {
+					return true
+				}
+			}
+			for _, y := range eq[f] {
+				if equalValues(x, y) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// covers determines if the branch o matches all events
+// matched by the branch b. This holds if both branches
+// compare the same field and o contains all values of b.
+func covers(o, b Expr) bool {
+	oe, ok := o.(*BinaryExpr)
+	if !ok || (oe.Op != In && oe.Op != IIn) {
+		return false
+	}
+	olhs, ok := oe.LHS.(*FieldLiteral)
+	if !ok {
+		return false
+	}
+	list, ok := oe.RHS.(*ListLiteral)
+	if !ok {
+		return false
+	}
+	be, ok := b.(*BinaryExpr)
+	if !ok {
+		return false
+	}
+	blhs, ok := be.LHS.(*FieldLiteral)
+	if !ok || blhs.String() != olhs.String() {
+		return false
+	}
+
+	var values []string
+	switch be.Op {
+	case Eq, IEq:
+		switch v := be.RHS.(type) {
+		case *StringLiteral, *IntegerLiteral, *UnsignedLiteral:
+			values = []string{v.String()}
+		default:
+			return false
+		}
+	case In, IIn:
+		l, ok := be.RHS.(*ListLiteral)
+		if !ok {
+			return false
+		}
+		values = l.Values
+	default:
+		return false
+	}
+
+	// case-insensitive branches are only
+	// covered by case-insensitive lists
+	fold := oe.Op == IIn
+	if !fold && (be.Op == IEq || be.Op == IIn) {
+		return false
+	}
+	for _, v := range values {
+		var found bool
+		for _, s := range list.Values {
+			if s == v || (fold && strings.EqualFold(s, v)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func equalValues(x, y interface{}) bool {
+	if c, ok := compareNumbers(x, y); ok {
+		return c == 0
+	}
+	if ip, ok := x.(net.IP); ok {
+		other, ok := y.(net.IP)
+		return ok && ip.Equal(other)
+	}
+	return fmt.Sprint(x) == fmt.Sprint(y)
+}
+
+// inList determines if the value is in the list of strings. Values
+// other than strings, numbers, and IP addresses are assumed present.
+func inList(v interface{}, list []string) bool {
+	for _, s := range list {
+		switch v := v.(type) {
+		case string:
+			if s == v {
+				return true
+			}
+		case net.IP:
+			if v.Equal(net.ParseIP(s)) {
+				return true
+			}
+		default:
+			if _, ok := numeric(v); !ok {
+				return true
+			}
+			n, err := strconv.ParseFloat(s, 64)
+			if err == nil && equalValues(v, n) {
+				return true
+			}
+		}
+	}
+	return false
+}
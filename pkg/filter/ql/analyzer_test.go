@@ -0,0 +1,183 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyze(t *testing.T) {
+	var tests = []struct {
+		expr  string
+		diags []Diagnostic
+	}{
+		{"ps.name = 'cmd.exe' and ps.pid = 4 and net.dport in (80, 443)", nil},
+		{"ps.envs[windir] = 'windows'", nil},
+		{
+			"ps.pid = '4'",
+			[]Diagnostic{{Severity: Error, Code: TypeMismatch, Message: "numeric field ps.pid can't be compared to string value 4", Expr: "ps.pid = 4"}},
+		},
+		{
+			"ps.name > 'cmd.exe'",
+			[]Diagnostic{{Severity: Error, Code: TypeMismatch, Message: "> operator is not applicable to the string field ps.name", Expr: "ps.name > cmd.exe"}},
+		},
+		{
+			"ps.args = '/c'",
+			[]Diagnostic{{Severity: Error, Code: TypeMismatch, Message: "= operator is not applicable to the slice field ps.args", Expr: "ps.args = /c"}},
+		},
+		{
+			"net.dip = '::1'",
+			[]Diagnostic{{Severity: Error, Code: TypeMismatch, Message: "IP address field net.dip can't be compared to string value ::1", Expr: "net.dip = ::1"}},
+		},
+		{
+			"net.dport in ('80', 'http')",
+			[]Diagnostic{{Severity: Warning, Code: TypeMismatch, Message: "numeric field net.dport never matches http list values", Expr: "net.dport IN (80, http)"}},
+		},
+		{
+			"net.dport in range ('10.0.0.0/8')",
+			[]Diagnostic{{Severity: Error, Code: TypeMismatch, Message: "CIDR ranges never match the numeric field net.dport", Expr: "net.dport IN RANGE (10.0.0.0/8)"}},
+		},
+		{
+			"ps.name = 'cmd.exe' and ps.name = 'powershell.exe'",
+			[]Diagnostic{{Severity: Error, Code: AlwaysFalse, Message: "expression ps.name = cmd.exe AND ps.name = powershell.exe is always false and the rule never matches", Expr: "ps.name = cmd.exe AND ps.name = powershell.exe"}},
+		},
+		{
+			"ps.name = 'cmd.exe' and ps.name != 'cmd.exe'",
+			[]Diagnostic{{Severity: Error, Code: AlwaysFalse, Message: "expression ps.name = cmd.exe AND ps.name != cmd.exe is always false and the rule never matches", Expr: "ps.name = cmd.exe AND ps.name != cmd.exe"}},
+		},
+		{
+			"ps.pid > 10 and ps.pid < 5",
+			[]Diagnostic{{Severity: Error, Code: AlwaysFalse, Message: "expression ps.pid > 10 AND ps.pid < 5 is always false and the rule never matches", Expr: "ps.pid > 10 AND ps.pid < 5"}},
+		},
+		{
+			"ps.pid between 10 and 5",
+			[]Diagnostic{{Severity: Error, Code: AlwaysFalse, Message: "expression ps.pid BETWEEN 10 AND 5 is always false and the rule never matches", Expr: "ps.pid BETWEEN 10 AND 5"}},
+		},
+		{
+			"ps.pid = 4 and ps.pid in (8, 16)",
+			[]Diagnostic{{Severity: Error, Code: AlwaysFalse, Message: "expression ps.pid = 4 AND ps.pid IN (8, 16) is always false and the rule never matches", Expr: "ps.pid = 4 AND ps.pid IN (8, 16)"}},
+		},
+		{"ps.pid = 4 and ps.pid in (4, 8)", nil},
+		{"ps.pid >= 10 and ps.pid <= 10", nil},
+		{
+			"ps.name = 'cmd.exe' and 1 = 2",
+			[]Diagnostic{{Severity: Error, Code: AlwaysFalse, Message: "expression ps.name = cmd.exe AND 1 = 2 is always false and the rule never matches", Expr: "ps.name = cmd.exe AND 1 = 2"}},
+		},
+		{
+			"ps.name = 'cmd.exe' or ps.name != 'cmd.exe'",
+			[]Diagnostic{{Severity: Warning, Code: AlwaysTrue, Message: "expression ps.name = cmd.exe OR ps.name != cmd.exe is always true", Expr: "ps.name = cmd.exe OR ps.name != cmd.exe"}},
+		},
+		{
+			"ps.name != 'cmd.exe' or ps.name != 'powershell.exe'",
+			[]Diagnostic{{Severity: Warning, Code: AlwaysTrue, Message: "expression ps.name != cmd.exe OR ps.name != powershell.exe is always true", Expr: "ps.name != cmd.exe OR ps.name != powershell.exe"}},
+		},
+		{
+			"ps.pid = 4 or (ps.pid > 10 and ps.pid < 5)",
+			[]Diagnostic{{Severity: Warning, Code: AlwaysFalse, Message: "expression ps.pid > 10 AND ps.pid < 5 is always false", Expr: "ps.pid > 10 AND ps.pid < 5"}},
+		},
+		{
+			"ps.name = 'cmd.exe' and not (ps.pid > 10 and ps.pid < 5)",
+			[]Diagnostic{{Severity: Warning, Code: AlwaysTrue, Message: "expression NOT (ps.pid > 10 AND ps.pid < 5) is always true", Expr: "NOT (ps.pid > 10 AND ps.pid < 5)"}},
+		},
+		{
+			"ps.name = 'cmd.exe' or ps.name in ('cmd.exe', 'powershell.exe')",
+			[]Diagnostic{{Severity: Warning, Code: RedundantBranch, Message: "or branch ps.name = cmd.exe is redundant because ps.name IN (cmd.exe, powershell.exe) already covers it", Expr: "ps.name = cmd.exe"}},
+		},
+		{
+			"ps.name = 'cmd.exe' or ps.pid = 4 or ps.name = 'cmd.exe'",
+			[]Diagnostic{{Severity: Warning, Code: RedundantBranch, Message: "or branch ps.name = cmd.exe is duplicated", Expr: "ps.name = cmd.exe"}},
+		},
+		{
+			"ps.name iin ('CMD.EXE') or ps.name = 'cmd.exe'",
+			[]Diagnostic{{Severity: Warning, Code: RedundantBranch, Message: "or branch ps.name = cmd.exe is redundant because ps.name IIN (CMD.EXE) already covers it", Expr: "ps.name = cmd.exe"}},
+		},
+		{"ps.name in ('CMD.EXE') or ps.name ~= 'cmd.exe'", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr, err := NewParser(tt.expr).ParseExpr()
+			require.NoError(t, err)
+			diags := Analyze(expr)
+			if tt.diags == nil {
+				assert.Empty(t, diags)
+				return
+			}
+			assert.Equal(t, tt.diags, diags)
+		})
+	}
+}
+
+func TestAnalyzeSequence(t *testing.T) {
+	var tests = []struct {
+		expr  string
+		codes []string
+	}{
+		{
+			`maxspan 1m
+			 |evt.name = 'CreateProcess'| as e1
+			 |evt.name = 'CreateFile' and file.name = $e1.ps.name|
+			`,
+			nil,
+		},
+		{
+			`maxspan 1m
+			 |evt.name = 'CreateProcess' and evt.name = 'CreateFile'| as e1
+			 |evt.name = 'CreateFile' and file.name = $e1.ps.name|
+			`,
+			[]string{AlwaysFalse, UnreachableStep},
+		},
+		{
+			`maxspan 1m
+			 |evt.name = 'CreateProcess' and ps.name = $e2.file.name| as e1
+			 |evt.name = 'CreateFile'| as e2
+			`,
+			[]string{UnreachableStep},
+		},
+		{
+			`maxspan 1m
+			 |evt.name = 'CreateProcess' and ps.name = $e1.ps.parent.name| as e1
+			 |evt.name = 'CreateFile'|
+			`,
+			[]string{UnreachableStep},
+		},
+	}
+
+	for i, tt := range tests {
+		seq, err := NewParser(tt.expr).ParseSequence()
+		require.NoError(t, err, i)
+		codes := make([]string, 0)
+		for _, d := range AnalyzeSequence(seq) {
+			codes = append(codes, d.Code)
+		}
+		if tt.codes == nil {
+			assert.Empty(t, codes, i)
+			continue
+		}
+		assert.Equal(t, tt.codes, codes, i)
+	}
+}
+
+func TestIdentifiers(t *testing.T) {
+	ids := Identifiers("spawn_process and ps.name in msoffice_binaries and not is_lolbin(ps.exe, 'rundll32.exe')")
+	assert.Equal(t, []string{"spawn_process", "ps.name", "msoffice_binaries", "is_lolbin", "ps.exe"}, ids)
+}
@@ -22,21 +22,40 @@ package ql
 
 import (
 	"net"
+	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
 	fuzzysearch "github.com/lithammer/fuzzysearch/fuzzy"
+	"github.com/rabbitstack/fibratus/pkg/filter/ql/functions"
 	"github.com/rabbitstack/fibratus/pkg/util/sets"
 	"github.com/rabbitstack/fibratus/pkg/util/wildcard"
 )
 
 // Eval evaluates expr against a map that contains the field values.
 func Eval(expr Expr, m map[string]interface{}, useFuncValuer bool) bool {
+	return EvalValuer(expr, MapValuer(m), useFuncValuer, nil)
+}
+
+// EvalWithFuncTime evaluates expr like Eval, but it also accumulates
+// the time spent in function calls into the provided duration.
+func EvalWithFuncTime(expr Expr, m map[string]interface{}, useFuncValuer bool, elapsed *time.Duration) bool {
+	return EvalValuer(expr, MapValuer(m), useFuncValuer, elapsed)
+}
+
+// EvalValuer evaluates expr against the valuer that resolves the field
+// values. If the elapsed duration is given, the time spent in function
+// calls is accumulated into it.
+func EvalValuer(expr Expr, valuer Valuer, useFuncValuer bool, elapsed *time.Duration) bool {
 	var eval ValuerEval
-	if useFuncValuer {
-		eval = ValuerEval{Valuer: MultiValuer(MapValuer(m), FunctionValuer{m})}
-	} else {
-		eval = ValuerEval{Valuer: MapValuer(m)}
+	switch {
+	case useFuncValuer && elapsed != nil:
+		eval = ValuerEval{Valuer: MultiValuer(valuer, timedFunctionValuer{FunctionValuer{}, elapsed})}
+	case useFuncValuer:
+		eval = ValuerEval{Valuer: MultiValuer(valuer, FunctionValuer{})}
+	default:
+		eval = ValuerEval{Valuer: valuer}
 	}
 	v, ok := eval.Eval(expr).(bool)
 	if !ok {
@@ -45,6 +64,21 @@ func Eval(expr Expr, m map[string]interface{}, useFuncValuer bool) bool {
 	return v
 }
 
+// timedFunctionValuer measures the duration of function calls
+// delegated to the underlying function valuer. Function arguments
+// are evaluated before the call, so the time spent in nested
+// function calls is not accounted twice.
+type timedFunctionValuer struct {
+	FunctionValuer
+	elapsed *time.Duration
+}
+
+func (f timedFunctionValuer) Call(name string, args []interface{}) (interface{}, bool) {
+	start := time.Now()
+	defer func() { *f.elapsed += time.Since(start) }()
+	return f.FunctionValuer.Call(name, args)
+}
+
 // MapValuer is a valuer that substitutes values for the mapped interface.
 type MapValuer map[string]interface{}
 
@@ -85,6 +119,24 @@ func (valuers multiValuer) Value(key string) (interface{}, bool) {
 	return nil, false
 }
 
+func (valuers multiValuer) Result(key string) (interface{}, bool) {
+	for _, valuer := range valuers {
+		if cache, ok := valuer.(ResultCache); ok {
+			return cache.Result(key)
+		}
+	}
+	return nil, false
+}
+
+func (valuers multiValuer) SetResult(key string, v interface{}) {
+	for _, valuer := range valuers {
+		if cache, ok := valuer.(ResultCache); ok {
+			cache.SetResult(key, v)
+			return
+		}
+	}
+}
+
 func (valuers multiValuer) Call(name string, args []interface{}) (interface{}, bool) {
 	for _, valuer := range valuers {
 		if valuer, ok := valuer.(CallValuer); ok {
@@ -103,6 +155,10 @@ type ValuerEval struct {
 	// IntegerFloatDivision will set the eval system to treat
 	// a division between two integers as a floating point division.
 	IntegerFloatDivision bool
+
+	// explain indicates the expression is evaluated to be explained,
+	// so stateful functions don't record observations
+	explain bool
 }
 
 // Eval evaluates an expression and returns a value.
@@ -160,7 +216,7 @@ func (v *ValuerEval) Eval(expr Expr) interface{} {
 					}
 				}
 
-				value, _ := valuer.Call(exp.Name, args)
+				value := v.call(valuer, exp, args)
 				if value == nil {
 					return true
 				}
@@ -170,8 +226,8 @@ func (v *ValuerEval) Eval(expr Expr) interface{} {
 				return nil
 			}
 			return nil
-		case *ParenExpr:
-			v := v.Eval(exp.Expr)
+		case *ParenExpr, *SharedExpr:
+			v := v.Eval(exp)
 			if v == nil {
 				return nil
 			}
@@ -192,6 +248,17 @@ func (v *ValuerEval) Eval(expr Expr) interface{} {
 		return expr.Value
 	case *ParenExpr:
 		return v.Eval(expr.Expr)
+	case *SharedExpr:
+		cache, ok := v.Valuer.(ResultCache)
+		if !ok {
+			return v.Eval(expr.Expr)
+		}
+		if val, ok := cache.Result(expr.Key); ok {
+			return val
+		}
+		val := v.Eval(expr.Expr)
+		cache.SetResult(expr.Key, val)
+		return val
 	case *StringLiteral:
 		return expr.Value
 	case *ListLiteral:
@@ -224,6 +291,16 @@ func (v *ValuerEval) Eval(expr Expr) interface{} {
 		return val
 	case *IPLiteral:
 		return expr.Value
+	case *DurationLiteral:
+		return expr.Value
+	case *DateTimeLiteral:
+		return expr.Value
+	case *RangeLiteral:
+		return valueRange{low: v.Eval(expr.Low), high: v.Eval(expr.High)}
+	case *RangesLiteral:
+		return expr
+	case *QuantifierExpr:
+		return v.Eval(expr.Field)
 	case *Function:
 		if valuer, ok := v.Valuer.(CallValuer); ok {
 			var args []interface{}
@@ -258,8 +335,7 @@ func (v *ValuerEval) Eval(expr Expr) interface{} {
 				}
 			}
 
-			val, _ := valuer.Call(expr.Name, args)
-			return val
+			return v.call(valuer, expr, args)
 		}
 		return nil
 	default:
@@ -268,7 +344,17 @@ func (v *ValuerEval) Eval(expr Expr) interface{} {
 }
 
 func (v *ValuerEval) evalBinaryExpr(expr *BinaryExpr) interface{} {
+	// quantified fields evaluate the operator
+	// for each element of the slice value
+	if q, ok := expr.LHS.(*QuantifierExpr); ok {
+		return v.evalQuantifier(q, expr.Op, v.Eval(expr.RHS))
+	}
 	lhs := v.Eval(expr.LHS)
+	if expr.matcher != nil {
+		if s, ok := lhs.(string); ok {
+			return expr.matcher.Match(s)
+		}
+	}
 	// lazy evaluation for the AND/OR operators
 	if lhs != nil && expr.Op == And {
 		if val, ok := lhs.(bool); ok && !val {
@@ -293,11 +379,26 @@ func (v *ValuerEval) evalBinaryExpr(expr *BinaryExpr) interface{} {
 			rhs = false
 		}
 	}
+	return v.evalOp(expr.Op, lhs, rhs)
+}
+
+// evalOp applies the operator to the evaluated left-hand
+// and right-hand side values.
+func (v *ValuerEval) evalOp(op Token, lhs, rhs interface{}) interface{} {
+	switch op {
+	case Between:
+		r, ok := rhs.(valueRange)
+		return ok && r.contains(lhs)
+	case InRange:
+		r, ok := rhs.(*RangesLiteral)
+		return ok && r.contains(lhs)
+	}
+
 	// evaluate if both sides are simple types.
 	switch lhs := lhs.(type) {
 	case bool:
 		rhs, ok := rhs.(bool)
-		switch expr.Op {
+		switch op {
 		case And:
 			return ok && (lhs && rhs)
 		case Or:
@@ -311,7 +412,7 @@ func (v *ValuerEval) evalBinaryExpr(expr *BinaryExpr) interface{} {
 		switch rhs := rhs.(type) {
 		case float64:
 			lhs := float64(lhs)
-			switch expr.Op {
+			switch op {
 			case Eq:
 				return lhs == rhs
 			case Neq:
@@ -326,7 +427,7 @@ func (v *ValuerEval) evalBinaryExpr(expr *BinaryExpr) interface{} {
 				return lhs >= rhs
 			}
 		case int64:
-			switch expr.Op {
+			switch op {
 			case Eq:
 				return int64(lhs) == rhs
 			case Neq:
@@ -341,7 +442,7 @@ func (v *ValuerEval) evalBinaryExpr(expr *BinaryExpr) interface{} {
 				return int64(lhs) >= rhs
 			}
 		case uint64:
-			switch expr.Op {
+			switch op {
 			case Eq:
 				return uint64(lhs) == rhs
 			case Neq:
@@ -368,7 +469,7 @@ func (v *ValuerEval) evalBinaryExpr(expr *BinaryExpr) interface{} {
 				return uint64(lhs) >= rhs
 			}
 		case []uint16:
-			switch expr.Op {
+			switch op {
 			case In:
 				for _, i := range rhs {
 					if int(i) == lhs {
@@ -382,7 +483,7 @@ func (v *ValuerEval) evalBinaryExpr(expr *BinaryExpr) interface{} {
 		switch rhs := rhs.(type) {
 		case float64:
 			lhs := float64(lhs)
-			switch expr.Op {
+			switch op {
 			case Eq:
 				return lhs == rhs
 			case Neq:
@@ -397,7 +498,7 @@ func (v *ValuerEval) evalBinaryExpr(expr *BinaryExpr) interface{} {
 				return lhs >= rhs
 			}
 		case int64:
-			switch expr.Op {
+			switch op {
 			case Eq:
 				return int64(lhs) == rhs
 			case Neq:
@@ -412,7 +513,7 @@ func (v *ValuerEval) evalBinaryExpr(expr *BinaryExpr) interface{} {
 				return int64(lhs) >= rhs
 			}
 		case uint64:
-			switch expr.Op {
+			switch op {
 			case Eq:
 				return uint64(lhs) == rhs
 			case Neq:
@@ -440,7 +541,7 @@ func (v *ValuerEval) evalBinaryExpr(expr *BinaryExpr) interface{} {
 		}
 
 		rhs := rhsf
-		switch expr.Op {
+		switch op {
 		case Eq:
 			return ok && (lhs == rhs)
 		case Neq:
@@ -459,7 +560,7 @@ func (v *ValuerEval) evalBinaryExpr(expr *BinaryExpr) interface{} {
 		switch rhs := rhs.(type) {
 		case float64:
 			lhs := float64(lhs)
-			switch expr.Op {
+			switch op {
 			case Eq:
 				return lhs == rhs
 			case Neq:
@@ -474,7 +575,7 @@ func (v *ValuerEval) evalBinaryExpr(expr *BinaryExpr) interface{} {
 				return lhs >= rhs
 			}
 		case int64:
-			switch expr.Op {
+			switch op {
 			case Eq:
 				return lhs == rhs
 			case Neq:
@@ -489,7 +590,7 @@ func (v *ValuerEval) evalBinaryExpr(expr *BinaryExpr) interface{} {
 				return lhs >= rhs
 			}
 		case uint64:
-			switch expr.Op {
+			switch op {
 			case Eq:
 				return uint64(lhs) == rhs
 			case Neq:
@@ -521,7 +622,7 @@ func (v *ValuerEval) evalBinaryExpr(expr *BinaryExpr) interface{} {
 		switch rhs := rhs.(type) {
 		case float64:
 			lhs := float64(lhs)
-			switch expr.Op {
+			switch op {
 			case Eq:
 				return lhs == rhs
 			case Neq:
@@ -536,7 +637,7 @@ func (v *ValuerEval) evalBinaryExpr(expr *BinaryExpr) interface{} {
 				return lhs >= rhs
 			}
 		case int64:
-			switch expr.Op {
+			switch op {
 			case Eq:
 				return lhs == uint64(rhs)
 			case Neq:
@@ -563,7 +664,7 @@ func (v *ValuerEval) evalBinaryExpr(expr *BinaryExpr) interface{} {
 				return lhs >= uint64(rhs)
 			}
 		case uint64:
-			switch expr.Op {
+			switch op {
 			case Eq:
 				return lhs == rhs
 			case Neq:
@@ -581,7 +682,7 @@ func (v *ValuerEval) evalBinaryExpr(expr *BinaryExpr) interface{} {
 	case []uint64:
 		switch rhs := rhs.(type) {
 		case uint64:
-			switch expr.Op {
+			switch op {
 			case Gt:
 				for _, i := range lhs {
 					if i > rhs {
@@ -598,7 +699,7 @@ func (v *ValuerEval) evalBinaryExpr(expr *BinaryExpr) interface{} {
 				return false
 			}
 		case int64:
-			switch expr.Op {
+			switch op {
 			case Gt:
 				for _, i := range lhs {
 					if i > uint64(rhs) {
@@ -619,7 +720,7 @@ func (v *ValuerEval) evalBinaryExpr(expr *BinaryExpr) interface{} {
 		switch rhs := rhs.(type) {
 		case float64:
 			lhs := float64(lhs)
-			switch expr.Op {
+			switch op {
 			case Eq:
 				return lhs == rhs
 			case Neq:
@@ -634,7 +735,7 @@ func (v *ValuerEval) evalBinaryExpr(expr *BinaryExpr) interface{} {
 				return lhs >= rhs
 			}
 		case int32:
-			switch expr.Op {
+			switch op {
 			case Eq:
 				return lhs == uint32(rhs)
 			case Neq:
@@ -661,7 +762,7 @@ func (v *ValuerEval) evalBinaryExpr(expr *BinaryExpr) interface{} {
 				return lhs >= uint32(rhs)
 			}
 		case int64:
-			switch expr.Op {
+			switch op {
 			case Eq:
 				return lhs == uint32(rhs)
 			case Neq:
@@ -688,7 +789,7 @@ func (v *ValuerEval) evalBinaryExpr(expr *BinaryExpr) interface{} {
 				return lhs >= uint32(rhs)
 			}
 		case uint32:
-			switch expr.Op {
+			switch op {
 			case Eq:
 				return lhs == rhs
 			case Neq:
@@ -703,7 +804,7 @@ func (v *ValuerEval) evalBinaryExpr(expr *BinaryExpr) interface{} {
 				return lhs >= rhs
 			}
 		case []string:
-			switch expr.Op {
+			switch op {
 			case In:
 				for _, s := range rhs {
 					n, err := strconv.ParseUint(s, 10, 32)
@@ -721,7 +822,7 @@ func (v *ValuerEval) evalBinaryExpr(expr *BinaryExpr) interface{} {
 		switch rhs := rhs.(type) {
 		case float64:
 			lhs := float64(lhs)
-			switch expr.Op {
+			switch op {
 			case Eq:
 				return lhs == rhs
 			case Neq:
@@ -736,7 +837,7 @@ func (v *ValuerEval) evalBinaryExpr(expr *BinaryExpr) interface{} {
 				return lhs >= rhs
 			}
 		case int32:
-			switch expr.Op {
+			switch op {
 			case Eq:
 				return lhs == uint16(rhs)
 			case Neq:
@@ -763,7 +864,7 @@ func (v *ValuerEval) evalBinaryExpr(expr *BinaryExpr) interface{} {
 				return lhs >= uint16(rhs)
 			}
 		case int64:
-			switch expr.Op {
+			switch op {
 			case Eq:
 				return lhs == uint16(rhs)
 			case Neq:
@@ -790,7 +891,7 @@ func (v *ValuerEval) evalBinaryExpr(expr *BinaryExpr) interface{} {
 				return lhs >= uint16(rhs)
 			}
 		case uint16:
-			switch expr.Op {
+			switch op {
 			case Eq:
 				return lhs == rhs
 			case Neq:
@@ -805,7 +906,7 @@ func (v *ValuerEval) evalBinaryExpr(expr *BinaryExpr) interface{} {
 				return lhs >= rhs
 			}
 		case []string:
-			switch expr.Op {
+			switch op {
 			case In:
 				for _, s := range rhs {
 					n, err := strconv.Atoi(s)
@@ -820,7 +921,7 @@ func (v *ValuerEval) evalBinaryExpr(expr *BinaryExpr) interface{} {
 			}
 		}
 	case string:
-		switch expr.Op {
+		switch op {
 		case Eq:
 			rhs, ok := rhs.(string)
 			if !ok {
@@ -1031,8 +1132,46 @@ func (v *ValuerEval) evalBinaryExpr(expr *BinaryExpr) interface{} {
 				return false
 			}
 		}
+	case time.Time:
+		rhs, ok := functions.ParseTime(rhs)
+		if !ok {
+			return false
+		}
+		switch op {
+		case Eq:
+			return lhs.Equal(rhs)
+		case Neq:
+			return !lhs.Equal(rhs)
+		case Lt:
+			return lhs.Before(rhs)
+		case Lte:
+			return !lhs.After(rhs)
+		case Gt:
+			return lhs.After(rhs)
+		case Gte:
+			return !lhs.Before(rhs)
+		}
+	case time.Duration:
+		rhs, ok := rhs.(time.Duration)
+		if !ok {
+			return false
+		}
+		switch op {
+		case Eq:
+			return lhs == rhs
+		case Neq:
+			return lhs != rhs
+		case Lt:
+			return lhs < rhs
+		case Lte:
+			return lhs <= rhs
+		case Gt:
+			return lhs > rhs
+		case Gte:
+			return lhs >= rhs
+		}
 	case net.IP:
-		switch expr.Op {
+		switch op {
 		case Eq:
 			rhs, ok := rhs.(net.IP)
 			if !ok {
@@ -1080,7 +1219,7 @@ func (v *ValuerEval) evalBinaryExpr(expr *BinaryExpr) interface{} {
 			return strings.HasSuffix(lhs.String(), rhs)
 		}
 	case []string:
-		switch expr.Op {
+		switch op {
 		case Contains:
 			s, ok := rhs.(string)
 			if !ok {
@@ -1254,9 +1393,205 @@ func (v *ValuerEval) evalBinaryExpr(expr *BinaryExpr) interface{} {
 
 	// the types were not comparable. If our operation was an equality operation,
 	// return false instead of true.
-	switch expr.Op {
+	switch op {
 	case Eq, IEq, Neq, Lt, Lte, Gt, Gte:
 		return false
 	}
 	return nil
 }
+
+// evalQuantifier applies the operator to each element of the
+// quantified field value. The any quantifier is satisfied if
+// at least one element matches, whereas the all quantifier
+// requires all elements to match. Missing or empty values
+// never satisfy the quantifier.
+func (v *ValuerEval) evalQuantifier(q *QuantifierExpr, op Token, rhs interface{}) interface{} {
+	val := v.Eval(q.Field)
+	if val == nil {
+		return false
+	}
+
+	var n int
+	matches := func(elem interface{}) bool {
+		n++
+		ok, _ := v.evalOp(op, elem, rhs).(bool)
+		return ok
+	}
+
+	switch s := val.(type) {
+	case []string:
+		for _, elem := range s {
+			if matches(elem) != (q.Quantifier == All) {
+				return q.Quantifier == Any
+			}
+		}
+	case net.IP, []byte:
+		if matches(val) != (q.Quantifier == All) {
+			return q.Quantifier == Any
+		}
+	default:
+		rv := reflect.ValueOf(val)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			// scalar values are regarded as
+			// single-element slices
+			if matches(val) != (q.Quantifier == All) {
+				return q.Quantifier == Any
+			}
+			break
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if matches(rv.Index(i).Interface()) != (q.Quantifier == All) {
+				return q.Quantifier == Any
+			}
+		}
+	}
+
+	return q.Quantifier == All && n > 0
+}
+
+// valueRange represents the evaluated bounds of the between operator.
+type valueRange struct {
+	low, high interface{}
+}
+
+// contains determines if the value falls within the inclusive range.
+// Numeric values of different types are compared by their magnitude,
+// timestamps are compared against datetime or date/time string bounds,
+// and durations against duration bounds.
+func (r valueRange) contains(v interface{}) bool {
+	switch v := v.(type) {
+	case time.Time:
+		low, ok := functions.ParseTime(r.low)
+		if !ok {
+			return false
+		}
+		high, ok := functions.ParseTime(r.high)
+		if !ok {
+			return false
+		}
+		return !v.Before(low) && !v.After(high)
+	case time.Duration:
+		low, ok := r.low.(time.Duration)
+		if !ok {
+			return false
+		}
+		high, ok := r.high.(time.Duration)
+		if !ok {
+			return false
+		}
+		return v >= low && v <= high
+	case string:
+		low, ok := r.low.(string)
+		if !ok {
+			return false
+		}
+		high, ok := r.high.(string)
+		if !ok {
+			return false
+		}
+		return v >= low && v <= high
+	}
+
+	low, ok := compareNumbers(v, r.low)
+	if !ok {
+		return false
+	}
+	high, ok := compareNumbers(v, r.high)
+	if !ok {
+		return false
+	}
+	return low >= 0 && high <= 0
+}
+
+// number stores the signed, unsigned, or floating point
+// representation of the numeric value as given by the kind.
+type number struct {
+	i    int64
+	u    uint64
+	f    float64
+	kind reflect.Kind
+}
+
+func (n number) float() float64 {
+	switch n.kind {
+	case reflect.Int64:
+		return float64(n.i)
+	case reflect.Uint64:
+		return float64(n.u)
+	}
+	return n.f
+}
+
+// numeric converts the value to the number. It
+// returns false if the value is not numeric.
+func numeric(v interface{}) (number, bool) {
+	switch v := v.(type) {
+	case int:
+		return number{i: int64(v), kind: reflect.Int64}, true
+	case int8:
+		return number{i: int64(v), kind: reflect.Int64}, true
+	case int16:
+		return number{i: int64(v), kind: reflect.Int64}, true
+	case int32:
+		return number{i: int64(v), kind: reflect.Int64}, true
+	case int64:
+		return number{i: v, kind: reflect.Int64}, true
+	case uint:
+		return number{u: uint64(v), kind: reflect.Uint64}, true
+	case uint8:
+		return number{u: uint64(v), kind: reflect.Uint64}, true
+	case uint16:
+		return number{u: uint64(v), kind: reflect.Uint64}, true
+	case uint32:
+		return number{u: uint64(v), kind: reflect.Uint64}, true
+	case uint64:
+		return number{u: v, kind: reflect.Uint64}, true
+	case float32:
+		return number{f: float64(v), kind: reflect.Float64}, true
+	case float64:
+		return number{f: v, kind: reflect.Float64}, true
+	}
+	return number{}, false
+}
+
+// compareNumbers returns -1, 0, or 1 if the first number is
+// less than, equal to, or greater than the second number.
+func compareNumbers(a, b interface{}) (int, bool) {
+	x, ok := numeric(a)
+	if !ok {
+		return 0, false
+	}
+	y, ok := numeric(b)
+	if !ok {
+		return 0, false
+	}
+
+	cmp := func(less, greater bool) int {
+		switch {
+		case less:
+			return -1
+		case greater:
+			return 1
+		}
+		return 0
+	}
+
+	switch {
+	case x.kind == reflect.Float64 || y.kind == reflect.Float64:
+		return cmp(x.float() < y.float(), x.float() > y.float()), true
+	case x.kind == reflect.Int64 && y.kind == reflect.Int64:
+		return cmp(x.i < y.i, x.i > y.i), true
+	case x.kind == reflect.Uint64 && y.kind == reflect.Uint64:
+		return cmp(x.u < y.u, x.u > y.u), true
+	case x.kind == reflect.Int64:
+		if x.i < 0 {
+			return -1, true
+		}
+		return cmp(uint64(x.i) < y.u, uint64(x.i) > y.u), true
+	default:
+		if y.i < 0 {
+			return 1, true
+		}
+		return cmp(x.u < uint64(y.i), x.u > uint64(y.i)), true
+	}
+}
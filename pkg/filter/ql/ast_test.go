@@ -0,0 +1,165 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ql
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvalRangeOperators(t *testing.T) {
+	m := map[string]interface{}{
+		"ps.pid":           uint32(1234),
+		"ps.ppid":          uint32(4),
+		"net.dport":        uint16(8080),
+		"net.dip":          net.ParseIP("10.12.0.5"),
+		"evt.time":         time.Date(2024, 6, 15, 10, 30, 0, 0, time.Local),
+		"ps.modules":       []string{"C:\\Windows\\System32\\ntdll.dll", "C:\\Windows\\System32\\kernel32.dll", "C:\\Temp\\evil.dll"},
+		"ps.args":          []string{},
+		"evt.arg[elapsed]": 45 * time.Second,
+	}
+
+	var tests = []struct {
+		expr    string
+		matches bool
+	}{
+		{"ps.pid between 1000 and 2000", true},
+		{"ps.pid between 1234 and 1234", true},
+		{"ps.pid between 1235 and 2000", false},
+		{"ps.pid not between 1000 and 2000", false},
+		{"ps.ppid between 0 and 10", true},
+		{"ps.pid between 1000.5 and 1234.1", true},
+		{"evt.time between '2024-01-01' and '2024-12-31'", true},
+		{"evt.time between '2024-06-15 10:31:00' and '2024-12-31'", false},
+		{"evt.arg[elapsed] between 30s and 1m", true},
+		{"evt.arg[elapsed] between 1m and 2m", false},
+		{"evt.arg[elapsed] > 30s", true},
+		{"evt.arg[elapsed] = 45s", true},
+		{"net.dport in range ('1-1024', '8080')", true},
+		{"net.dport in range ('1-1024', '8000-8079')", false},
+		{"net.dport not in range ('1-1024')", true},
+		{"net.dip in range ('10.0.0.0/8', '192.168.0.0/16')", true},
+		{"net.dip in range ('172.16.0.0/12')", false},
+		{"any ps.modules imatches '?:\\\\Temp\\\\*'", true},
+		{"all ps.modules imatches '?:\\\\Temp\\\\*'", false},
+		{"all ps.modules iendswith '.dll'", true},
+		{"any ps.modules in ('C:\\\\Windows\\\\System32\\\\ntdll.dll')", true},
+		{"any ps.args = 'foo'", false},
+		{"all ps.args = 'foo'", false},
+		{"any ps.envs = 'foo'", false},
+		{"any ps.pid between 1000 and 2000", true},
+	}
+
+	for i, tt := range tests {
+		p := NewParser(tt.expr)
+		expr, err := p.ParseExpr()
+		require.NoError(t, err, tt.expr)
+		assert.Equal(t, tt.matches, Eval(expr, m, false), "%d. %s", i, tt.expr)
+	}
+}
+
+func TestRangeOperatorsString(t *testing.T) {
+	var tests = []struct {
+		expr string
+		s    string
+	}{
+		{"ps.pid between 1 and 10", "ps.pid BETWEEN 1 AND 10"},
+		{"evt.arg[elapsed] between 30s and 1m", "evt.arg[elapsed] BETWEEN 30s AND 1m0s"},
+		{"net.dport in range ('1-1024', '8080')", "net.dport IN RANGE (1-1024, 8080)"},
+		{"any ps.modules iendswith '.dll'", "ANY ps.modules IENDSWITH .dll"},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(tt.expr)
+		expr, err := p.ParseExpr()
+		require.NoError(t, err, tt.expr)
+		assert.Equal(t, tt.s, expr.String())
+	}
+}
+
+func TestParseInvalidRanges(t *testing.T) {
+	for _, expr := range []string{
+		"net.dport in range ('1024-1')",
+		"net.dport in range ('http')",
+		"net.dip in range ('10.0.0.0/33')",
+		"net.dport in range 80",
+	} {
+		p := NewParser(expr)
+		_, err := p.ParseExpr()
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestEvalTimeFunctions(t *testing.T) {
+	now := time.Now()
+	m := map[string]interface{}{
+		"ps.pe.timestamp":    now.Add(-72 * time.Hour),
+		"ps.signature.after": time.Date(2024, 6, 15, 0, 0, 0, 0, time.UTC),
+		"evt.time.ns":        now.UnixNano(),
+		"$e1.evt.time.ns":    now.UnixNano(),
+		"$e2.evt.time.ns":    now.Add(3 * time.Second).UnixNano(),
+	}
+
+	var tests = []struct {
+		expr    string
+		matches bool
+	}{
+		{"age(ps.pe.timestamp) < 7d", true},
+		{"age(ps.pe.timestamp) < 2d", false},
+		{"age(ps.pe.timestamp) between 1d and 1w", true},
+		{"ps.signature.after < now()", true},
+		{"ps.signature.after > now()", false},
+		{"ps.signature.after = datetime('2024-06-15T00:00:00Z')", true},
+		{"ps.signature.after < datetime('2024-06-14')", false},
+		{"ps.signature.after between datetime('2024-01-01T00:00:00Z') and datetime('2024-12-31T00:00:00Z')", true},
+		{"time_diff($e1.evt.time.ns, $e2.evt.time.ns) < 10s", true},
+		{"time_diff($e1.evt.time.ns, $e2.evt.time.ns) > 10s", false},
+		{"time_diff(ps.pe.timestamp, now()) >= 3d", true},
+	}
+
+	for i, tt := range tests {
+		p := NewParser(tt.expr)
+		expr, err := p.ParseExpr()
+		require.NoError(t, err, tt.expr)
+		assert.Equal(t, tt.matches, Eval(expr, m, true), "%d. %s", i, tt.expr)
+	}
+}
+
+func TestParseDateTime(t *testing.T) {
+	p := NewParser("ps.signature.after < datetime('2024-06-15T00:00:00Z')")
+	expr, err := p.ParseExpr()
+	require.NoError(t, err)
+	assert.Equal(t, "ps.signature.after < datetime('2024-06-15T00:00:00Z')", expr.String())
+
+	for _, s := range []string{
+		"ps.signature.after < datetime('yesterday')",
+		"ps.signature.after < datetime('10:30:00')",
+		"ps.signature.after < datetime(ps.pe.timestamp)",
+		"ps.signature.after < datetime('2024-06-15'",
+		"age() < 1d",
+	} {
+		p := NewParser(s)
+		_, err := p.ParseExpr()
+		assert.Error(t, err, s)
+	}
+}
@@ -0,0 +1,186 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ExplainNode is the node of the annotated expression tree produced
+// by the explain evaluation. Each node holds the expression along with
+// the value the expression evaluated to. Logical, negation, and function
+// nodes contain child nodes for their operands, while fields are leaf
+// nodes that reveal the values fed to the predicates. Literals are not
+// represented as nodes since their values are visible in the parent
+// expression.
+type ExplainNode struct {
+	// Expr is the expression of this node.
+	Expr Expr
+	// Value is the value produced by the expression evaluation.
+	Value any
+	// Evaluated is false if the expression was not evaluated
+	// due to the short-circuiting of the AND/OR operators.
+	Evaluated bool
+	// Children contains the nodes of expression operands.
+	Children []*ExplainNode
+}
+
+// IsPredicate determines if the node evaluated to a boolean value.
+func (n *ExplainNode) IsPredicate() bool {
+	_, ok := n.Value.(bool)
+	return ok
+}
+
+// Matched returns true if the node expression evaluated to true.
+func (n *ExplainNode) Matched() bool {
+	v, ok := n.Value.(bool)
+	return ok && v
+}
+
+// String renders the annotated tree with one node per line. Child nodes
+// are indented relative to their parents. Predicates are prefixed with
+// their truth result, and fields are followed by their values.
+func (n *ExplainNode) String() string {
+	var b strings.Builder
+	n.write(&b, 0)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func (n *ExplainNode) write(b *strings.Builder, depth int) {
+	b.WriteString(strings.Repeat("  ", depth))
+	switch {
+	case !n.Evaluated:
+		b.WriteString("[skipped] ")
+		b.WriteString(n.Expr.String())
+	case n.IsPredicate():
+		fmt.Fprintf(b, "[%t] %s", n.Value, n.Expr.String())
+	default:
+		fmt.Fprintf(b, "%s = %s", n.Expr.String(), FormatValue(n.Value))
+	}
+	b.WriteByte('\n')
+	for _, c := range n.Children {
+		c.write(b, depth+1)
+	}
+}
+
+// FormatValue renders the value of the explain node. Strings are
+// quoted to make leading or trailing whitespaces noticeable.
+func FormatValue(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return "nil"
+	case string, []string:
+		return fmt.Sprintf("%q", val)
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
+
+// Explain evaluates the expression and returns the annotated expression
+// tree. The value of each node is obtained by evaluating the node's
+// expression, so the explain evaluation is more expensive than the
+// regular evaluation and it is not meant to be used in the hot path.
+// The right-hand side of the AND/OR operators is not evaluated if the
+// left-hand side determines the result, which mimics the behaviour of
+// the regular evaluation.
+func (v *ValuerEval) Explain(expr Expr) *ExplainNode {
+	switch e := expr.(type) {
+	case *ParenExpr:
+		return v.Explain(e.Expr)
+	case *SharedExpr:
+		return v.Explain(e.Expr)
+	}
+
+	n := &ExplainNode{Expr: expr, Value: v.Eval(expr), Evaluated: true}
+
+	switch expr := expr.(type) {
+	case *BinaryExpr:
+		lhs := v.explainOperand(expr.LHS)
+		if expr.Op == And || expr.Op == Or {
+			val, ok := lhs.Value.(bool)
+			if ok && ((expr.Op == And && !val) || (expr.Op == Or && val)) {
+				n.Children = append(n.Children, lhs, &ExplainNode{Expr: unparen(expr.RHS)})
+				return n
+			}
+		}
+		n.Children = appendNode(n.Children, lhs)
+		n.Children = appendNode(n.Children, v.explainOperand(expr.RHS))
+	case *NotExpr:
+		n.Children = appendNode(n.Children, v.explainOperand(expr.Expr))
+	case *Function:
+		for i, arg := range expr.Args {
+			// the foreach predicate is evaluated for
+			// each element of the iterable, so only
+			// the iterable argument is explained
+			if expr.IsForeach() && i > 0 {
+				break
+			}
+			n.Children = appendNode(n.Children, v.explainOperand(arg))
+		}
+	}
+
+	return n
+}
+
+// explainOperand explains the operand expression if it
+// is a field, function, or a composite expression. Nil
+// is returned for literals.
+func (v *ValuerEval) explainOperand(expr Expr) *ExplainNode {
+	switch expr.(type) {
+	case *BinaryExpr, *NotExpr, *ParenExpr, *SharedExpr, *Function, *QuantifierExpr,
+		*FieldLiteral, *BoundFieldLiteral, *BoundSegmentLiteral, *BareBoundVariableLiteral:
+		return v.Explain(expr)
+	default:
+		return nil
+	}
+}
+
+func appendNode(nodes []*ExplainNode, n *ExplainNode) []*ExplainNode {
+	if n == nil {
+		return nodes
+	}
+	return append(nodes, n)
+}
+
+func unparen(expr Expr) Expr {
+	for {
+		switch e := expr.(type) {
+		case *ParenExpr:
+			expr = e.Expr
+		case *SharedExpr:
+			expr = e.Expr
+		default:
+			return expr
+		}
+	}
+}
+
+// Explain evaluates expr against a map that contains the field values
+// and returns the annotated expression tree.
+func Explain(expr Expr, m map[string]interface{}, useFuncValuer bool) *ExplainNode {
+	var eval ValuerEval
+	if useFuncValuer {
+		eval = ValuerEval{Valuer: MultiValuer(MapValuer(m), FunctionValuer{m})}
+	} else {
+		eval = ValuerEval{Valuer: MapValuer(m)}
+	}
+	eval.explain = true
+	return eval.Explain(expr)
+}
@@ -0,0 +1,129 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplain(t *testing.T) {
+	m := map[string]interface{}{
+		"ps.name": "cmd.exe",
+		"ps.exe":  "C:\\Windows\\System32\\cmd.exe",
+	}
+
+	var tests = []struct {
+		name  string
+		expr  string
+		match bool
+		check func(t *testing.T, n *ExplainNode)
+	}{
+		{
+			"failed predicate",
+			"ps.name = 'cmd.exe' and ps.exe istartswith 'C:\\\\Temp'",
+			false,
+			func(t *testing.T, n *ExplainNode) {
+				require.Len(t, n.Children, 2)
+				lhs, rhs := n.Children[0], n.Children[1]
+				assert.True(t, lhs.Matched())
+				assert.True(t, rhs.Evaluated)
+				assert.False(t, rhs.Matched())
+				require.Len(t, rhs.Children, 1)
+				assert.Equal(t, "ps.exe", rhs.Children[0].Expr.String())
+				assert.Equal(t, "C:\\Windows\\System32\\cmd.exe", rhs.Children[0].Value)
+				assert.Empty(t, rhs.Children[0].Children)
+			},
+		},
+		{
+			"short-circuit and",
+			"ps.name = 'svchost.exe' and (ps.exe icontains 'temp' or ps.exe icontains 'appdata')",
+			false,
+			func(t *testing.T, n *ExplainNode) {
+				require.Len(t, n.Children, 2)
+				assert.False(t, n.Children[0].Matched())
+				rhs := n.Children[1]
+				assert.False(t, rhs.Evaluated)
+				assert.Nil(t, rhs.Value)
+				assert.IsType(t, &BinaryExpr{}, rhs.Expr)
+			},
+		},
+		{
+			"short-circuit or",
+			"ps.name = 'cmd.exe' or ps.exe icontains 'temp'",
+			true,
+			func(t *testing.T, n *ExplainNode) {
+				require.Len(t, n.Children, 2)
+				assert.True(t, n.Children[0].Matched())
+				assert.False(t, n.Children[1].Evaluated)
+			},
+		},
+		{
+			"negation",
+			"not ps.name in ('cmd.exe', 'powershell.exe')",
+			false,
+			func(t *testing.T, n *ExplainNode) {
+				require.Len(t, n.Children, 1)
+				assert.True(t, n.Children[0].Matched())
+				require.Len(t, n.Children[0].Children, 1)
+				assert.Equal(t, "cmd.exe", n.Children[0].Children[0].Value)
+			},
+		},
+		{
+			"function",
+			"length(ps.name) = 7",
+			true,
+			func(t *testing.T, n *ExplainNode) {
+				require.Len(t, n.Children, 1)
+				fn := n.Children[0]
+				assert.IsType(t, &Function{}, fn.Expr)
+				assert.Equal(t, 7, fn.Value)
+				require.Len(t, fn.Children, 1)
+				assert.Equal(t, "cmd.exe", fn.Children[0].Value)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := NewParser(tt.expr).ParseExpr()
+			require.NoError(t, err)
+			n := Explain(expr, m, true)
+			require.NotNil(t, n)
+			assert.Equal(t, tt.match, n.Matched())
+			assert.Equal(t, Eval(expr, m, true), n.Matched())
+			assert.True(t, n.Evaluated)
+			tt.check(t, n)
+		})
+	}
+}
+
+func TestExplainString(t *testing.T) {
+	expr, err := NewParser("ps.name = 'cmd.exe' and ps.exe = 'C:\\\\cmd.exe'").ParseExpr()
+	require.NoError(t, err)
+
+	n := Explain(expr, map[string]interface{}{"ps.name": "cmd.exe", "ps.exe": " C:\\cmd.exe"}, false)
+	assert.Equal(t, `[false] ps.name = cmd.exe AND ps.exe = C:\cmd.exe
+  [true] ps.name = cmd.exe
+    ps.name = "cmd.exe"
+  [false] ps.exe = C:\cmd.exe
+    ps.exe = " C:\\cmd.exe"`, n.String())
+}
@@ -52,6 +52,9 @@ type BinaryExpr struct {
 	Op  Token
 	LHS Expr
 	RHS Expr
+	// matcher is the precompiled matcher for
+	// the large list literal on the right side
+	matcher listMatcher
 }
 
 // String returns a string representation of the binary expression.
@@ -86,3 +89,23 @@ func (e *NotExpr) String() string {
 	b.WriteString(e.Expr.String())
 	return b.String()
 }
+
+// QuantifierExpr represents the field whose slice value is
+// quantified by the any or all quantifiers. The binary
+// expression with the quantified field as the left-hand
+// side is evaluated for each element of the slice.
+type QuantifierExpr struct {
+	Quantifier Token
+	Field      *FieldLiteral
+}
+
+// String returns a string representation of the quantifier expression.
+func (e *QuantifierExpr) String() string {
+	var b strings.Builder
+	q, field := e.Quantifier.String(), e.Field.String()
+	b.Grow(len(q) + len(field) + 1)
+	b.WriteString(q)
+	b.WriteString(" ")
+	b.WriteString(field)
+	return b.String()
+}
@@ -27,7 +27,9 @@ import (
 	"strings"
 
 	"github.com/rabbitstack/fibratus/pkg/callstack"
+	"github.com/rabbitstack/fibratus/pkg/config"
 	"github.com/rabbitstack/fibratus/pkg/filter/fields"
+	"github.com/rabbitstack/fibratus/pkg/filter/rarity"
 	"github.com/rabbitstack/fibratus/pkg/pe"
 	pstypes "github.com/rabbitstack/fibratus/pkg/ps/types"
 	"github.com/rabbitstack/fibratus/pkg/util/signature"
@@ -38,7 +40,7 @@ import (
 
 var (
 	// ErrArgumentTypeMismatch signals an invalid argument type
-	ErrArgumentTypeMismatch = func(i int, keyword string, fn functions.Fn, types []functions.ArgType) error {
+	ErrArgumentTypeMismatch = func(i int, keyword string, fn string, types []functions.ArgType) error {
 		argTypes := make([]string, len(types))
 		for i, typ := range types {
 			argTypes[i] = typ.String()
@@ -53,8 +55,53 @@ var (
 	ErrFunctionSignature = func(desc functions.FunctionDesc, givenArguments int) error {
 		return fmt.Errorf("%s function requires %d argument(s) but %d argument(s) given", desc.Name, desc.RequiredArgs(), givenArguments)
 	}
+	// ErrMacroSignature is thrown when the function macro signature is not satisfied
+	ErrMacroSignature = func(macro *config.Macro, givenArguments int) error {
+		return fmt.Errorf("%s function requires %d argument(s) but %d argument(s) given", macro.ID, len(macro.Params), givenArguments)
+	}
+	// ErrMacroShadowsFunction is thrown when the function macro is named after the built-in function
+	ErrMacroShadowsFunction = func(name string) error {
+		return fmt.Errorf("%s function macro shadows the built-in function", name)
+	}
+	// ErrMacroRecursion is thrown when the function macro invokes itself
+	ErrMacroRecursion = func(name string) error {
+		return fmt.Errorf("%s function macro is invoked recursively", name)
+	}
 )
 
+// valueArgTypes are the argument types whose value is only known at runtime.
+var valueArgTypes = []functions.ArgType{functions.Field, functions.BoundField, functions.BoundSegment, functions.BareBoundVariable, functions.Func}
+
+// macroParamArgTypes maps function macro parameter types to accepted argument
+// types. Parameters of any type accept arbitrary arguments.
+var macroParamArgTypes = map[string][]functions.ArgType{
+	config.MacroParamString:   append([]functions.ArgType{functions.String}, valueArgTypes...),
+	config.MacroParamNumber:   append([]functions.ArgType{functions.Number}, valueArgTypes...),
+	config.MacroParamIP:       append([]functions.ArgType{functions.IP}, valueArgTypes...),
+	config.MacroParamBool:     append([]functions.ArgType{functions.Bool, functions.Expression}, valueArgTypes...),
+	config.MacroParamList:     append([]functions.ArgType{functions.Slice}, valueArgTypes...),
+	config.MacroParamDateTime: append([]functions.ArgType{functions.DateTime, functions.String}, valueArgTypes...),
+	config.MacroParamField:    {functions.Field, functions.BoundField},
+}
+
+// validateMacroArgs checks the function macro arguments satisfy the signature.
+func validateMacroArgs(macro *config.Macro, args []Expr) error {
+	if len(args) != len(macro.Params) {
+		return ErrMacroSignature(macro, len(args))
+	}
+	for i, param := range macro.Params {
+		types, ok := macroParamArgTypes[param.Type]
+		if !ok {
+			continue
+		}
+		arg := functions.FunctionArgDesc{Keyword: param.Name, Types: types, Required: true}
+		if !arg.ContainsType(argType(args[i])) {
+			return ErrArgumentTypeMismatch(i, arg.Keyword, strings.ToUpper(macro.ID), arg.Types)
+		}
+	}
+	return nil
+}
+
 var funcs = map[string]FunctionDef{
 	functions.CIDRContainsFn.String(): &functions.CIDRContains{},
 	functions.MD5Fn.String():          &functions.MD5{},
@@ -82,6 +129,13 @@ var funcs = map[string]FunctionDef{
 	functions.YaraFn.String():         &functions.Yara{},
 	functions.ForeachFn.String():      &Foreach{},
 	functions.CountFn.String():        &functions.Count{},
+	functions.NowFn.String():          &functions.Now{},
+	functions.AgeFn.String():          &functions.Age{},
+	functions.TimeDiffFn.String():     &functions.TimeDiff{},
+	functions.LookupFn.String():       &functions.Lookup{},
+	functions.LookupListFn.String():   &functions.LookupList{},
+	functions.FirstSeenFn.String():    &functions.FirstSeen{},
+	functions.SeenCountFn.String():    &functions.SeenCount{},
 }
 
 // FunctionDef is the interface that all function definitions have to satisfy.
@@ -113,6 +167,63 @@ func (FunctionValuer) Call(name string, args []interface{}) (interface{}, bool)
 	return fn.Call(args)
 }
 
+// call invokes the function. Stateful functions record the observation
+// of their arguments once per event. The observation is stored in the
+// result cache, so the filters and functions observing the same values
+// against the same event share the observation instead of recording it
+// multiple times. Observations are not recorded when the expression is
+// explained.
+func (v *ValuerEval) call(valuer CallValuer, fn *Function, args []interface{}) interface{} {
+	observer, ok := funcs[strings.ToUpper(fn.Name)].(functions.Observer)
+	if !ok {
+		val, _ := valuer.Call(fn.Name, args)
+		return val
+	}
+	key := observationKey(args)
+	cache, ok := v.Valuer.(ResultCache)
+	if ok {
+		if obs, ok := cache.Result(key); ok {
+			return observer.Result(obs.(rarity.Observation))
+		}
+	}
+	obs, ok := observer.Observe(args, !v.explain)
+	if !ok {
+		return nil
+	}
+	if cache != nil && !v.explain {
+		cache.SetResult(key, obs)
+	}
+	return observer.Result(obs)
+}
+
+// observationKey identifies the observation in the result cache.
+func observationKey(args []interface{}) string {
+	if len(args) == 0 {
+		return ""
+	}
+	namespace, _ := args[0].(string)
+	return "observe:" + rarity.Key(namespace, args[1:]...)
+}
+
+// IsStateful determines if the expression calls stateful functions.
+func IsStateful(expr Expr) bool {
+	var stateful bool
+	WalkFunc(expr, func(n Node) {
+		if fn, ok := n.(*Function); ok && fn.IsStateful() {
+			stateful = true
+		}
+	})
+	return stateful
+}
+
+// init registers built-in function names, so function
+// macros shadowing them are rejected when macros are loaded.
+func init() {
+	for name := range funcs {
+		config.RegisterFunction(name)
+	}
+}
+
 func functionNames() []string {
 	names := make([]string, 0, len(funcs))
 	for _, f := range funcs {
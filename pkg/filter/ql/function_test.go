@@ -23,8 +23,12 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/rabbitstack/fibratus/pkg/filter/ql/functions"
+	"github.com/rabbitstack/fibratus/pkg/filter/rarity"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestParseFunction(t *testing.T) {
@@ -72,3 +76,46 @@ func TestParseFunction(t *testing.T) {
 		}
 	}
 }
+
+func TestArgType(t *testing.T) {
+	var tests = []struct {
+		expr Expr
+		typ  functions.ArgType
+	}{
+		{&IntegerLiteral{Value: 1}, functions.Number},
+		{&UnsignedLiteral{Value: 18446744073709551615}, functions.Number},
+		{&DecimalLiteral{Value: 1.5}, functions.Number},
+		{&StringLiteral{Value: "cmd.exe"}, functions.String},
+		{&DurationLiteral{Value: time.Second}, functions.Unknown},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.typ, argType(tt.expr), tt.expr.String())
+	}
+}
+
+func TestStatefulFunctionObservation(t *testing.T) {
+	first, err := NewParser("first_seen('observation_test', ps.parent.name, ps.name)").ParseExpr()
+	require.NoError(t, err)
+	count, err := NewParser("first_seen('observation_test', ps.parent.name, ps.name) and seen_count('observation_test', ps.parent.name, ps.name) = 1").ParseExpr()
+	require.NoError(t, err)
+	assert.True(t, IsStateful(count))
+
+	m := MapValuer{"ps.parent.name": "winword.exe", "ps.name": "cmd.exe"}
+	key := rarity.Key("observation_test", "winword.exe", "cmd.exe")
+
+	// filters evaluated against the same event share the observation
+	valuer := &resultCacheValuer{MapValuer: m, results: make(map[string]interface{})}
+	assert.True(t, EvalValuer(first, valuer, true, nil))
+	assert.True(t, EvalValuer(count, valuer, true, nil))
+	assert.Equal(t, 2, valuer.hits)
+
+	// explaining the expression doesn't record the observation
+	n := Explain(count, m, true)
+	assert.Equal(t, true, n.Value)
+	assert.Equal(t, 1, rarity.Default().Peek(key, time.Now()).Count)
+
+	valuer = &resultCacheValuer{MapValuer: m, results: make(map[string]interface{})}
+	assert.False(t, EvalValuer(first, valuer, true, nil))
+	assert.Equal(t, 2, rarity.Default().Peek(key, time.Now()).Count)
+}
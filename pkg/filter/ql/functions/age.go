@@ -0,0 +1,48 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package functions
+
+import "time"
+
+// Age returns the duration elapsed since the given timestamp.
+// Timestamps in the future yield negative durations.
+type Age struct{}
+
+func (f Age) Call(args []interface{}) (interface{}, bool) {
+	if len(args) < 1 {
+		return time.Duration(0), false
+	}
+	t, ok := ParseTime(args[0])
+	if !ok || t.IsZero() {
+		return time.Duration(0), false
+	}
+	return time.Since(t), true
+}
+
+func (f Age) Desc() FunctionDesc {
+	desc := FunctionDesc{
+		Name: AgeFn,
+		Args: []FunctionArgDesc{
+			{Keyword: "timestamp", Types: []ArgType{Field, BoundField, BareBoundVariable, Func, String, DateTime}, Required: true},
+		},
+	}
+	return desc
+}
+
+func (f Age) Name() Fn { return AgeFn }
@@ -0,0 +1,47 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package functions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAge(t *testing.T) {
+	f := Age{}
+
+	res, ok := f.Call([]interface{}{time.Now().Add(-48 * time.Hour)})
+	assert.True(t, ok)
+	assert.InDelta(t, float64(48*time.Hour), float64(res.(time.Duration)), float64(time.Minute))
+
+	res, ok = f.Call([]interface{}{time.Now().Add(-time.Hour).UnixNano()})
+	assert.True(t, ok)
+	assert.InDelta(t, float64(time.Hour), float64(res.(time.Duration)), float64(time.Minute))
+
+	res, ok = f.Call([]interface{}{time.Now().Add(time.Hour).Format("2006-01-02 15:04:05")})
+	assert.True(t, ok)
+	assert.True(t, res.(time.Duration) < 0)
+
+	_, ok = f.Call([]interface{}{"yesterday"})
+	assert.False(t, ok)
+	_, ok = f.Call([]interface{}{time.Time{}})
+	assert.False(t, ok)
+}
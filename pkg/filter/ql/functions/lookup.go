@@ -0,0 +1,102 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package functions
+
+import (
+	"fmt"
+
+	"github.com/rabbitstack/fibratus/pkg/filter/lookup"
+)
+
+// Lookup returns the value associated with the key in the lookup table.
+// If the table doesn't contain the key, the function yields no value,
+// so any comparison with the function result is not satisfied.
+type Lookup struct{}
+
+func (f Lookup) Call(args []interface{}) (interface{}, bool) {
+	if len(args) < 2 {
+		return nil, false
+	}
+	name, ok := args[0].(string)
+	if !ok {
+		return nil, false
+	}
+	t := lookup.Get(name)
+	if t == nil {
+		return nil, false
+	}
+	var key string
+	switch v := args[1].(type) {
+	case nil:
+		return nil, true
+	case string:
+		key = v
+	default:
+		key = fmt.Sprintf("%v", v)
+	}
+	val, ok := t.Lookup(key)
+	if !ok {
+		return nil, true
+	}
+	return val, true
+}
+
+func (f Lookup) Desc() FunctionDesc {
+	desc := FunctionDesc{
+		Name: LookupFn,
+		Args: []FunctionArgDesc{
+			{Keyword: "table", Types: []ArgType{String}, Required: true},
+			{Keyword: "key", Types: []ArgType{Field, BoundField, BoundSegment, BareBoundVariable, Func, String, Number}, Required: true},
+		},
+	}
+	return desc
+}
+
+func (f Lookup) Name() Fn { return LookupFn }
+
+// LookupList returns all keys of the lookup table. It is
+// typically used as the right-hand side of the in operator.
+type LookupList struct{}
+
+func (f LookupList) Call(args []interface{}) (interface{}, bool) {
+	if len(args) < 1 {
+		return nil, false
+	}
+	name, ok := args[0].(string)
+	if !ok {
+		return nil, false
+	}
+	t := lookup.Get(name)
+	if t == nil {
+		return nil, false
+	}
+	return t.Keys(), true
+}
+
+func (f LookupList) Desc() FunctionDesc {
+	desc := FunctionDesc{
+		Name: LookupListFn,
+		Args: []FunctionArgDesc{
+			{Keyword: "table", Types: []ArgType{String}, Required: true},
+		},
+	}
+	return desc
+}
+
+func (f LookupList) Name() Fn { return LookupListFn }
@@ -0,0 +1,101 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package functions
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rabbitstack/fibratus/pkg/config"
+	"github.com/rabbitstack/fibratus/pkg/filter/lookup"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadLookupTables(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ports.csv")
+	require.NoError(t, os.WriteFile(path, []byte("port,service\n443,https\n3389,rdp\nSMB,smb\n"), 0o644))
+	require.NoError(t, lookup.Load([]config.LookupTable{{Name: "ports", Path: path, IgnoreCase: true}}))
+}
+
+func TestLookup(t *testing.T) {
+	loadLookupTables(t)
+
+	var tests = []struct {
+		args     []interface{}
+		expected interface{}
+	}{
+		{
+			[]interface{}{"ports", "443"},
+			"https",
+		},
+		{
+			[]interface{}{"ports", uint16(3389)},
+			"rdp",
+		},
+		{
+			[]interface{}{"ports", "smb"},
+			"smb",
+		},
+		{
+			[]interface{}{"ports", "8080"},
+			nil,
+		},
+		{
+			[]interface{}{"ports", nil},
+			nil,
+		},
+		{
+			[]interface{}{"services", "443"},
+			nil,
+		},
+	}
+
+	for i, tt := range tests {
+		f := Lookup{}
+		res, _ := f.Call(tt.args)
+		assert.Equal(t, tt.expected, res, fmt.Sprintf("%d. result mismatch: exp=%v got=%v", i, tt.expected, res))
+	}
+}
+
+func TestLookupList(t *testing.T) {
+	loadLookupTables(t)
+
+	var tests = []struct {
+		args     []interface{}
+		expected interface{}
+	}{
+		{
+			[]interface{}{"ports"},
+			[]string{"443", "3389", "SMB"},
+		},
+		{
+			[]interface{}{"services"},
+			nil,
+		},
+	}
+
+	for i, tt := range tests {
+		f := LookupList{}
+		res, _ := f.Call(tt.args)
+		assert.Equal(t, tt.expected, res, fmt.Sprintf("%d. result mismatch: exp=%v got=%v", i, tt.expected, res))
+	}
+}
@@ -0,0 +1,127 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package functions
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rabbitstack/fibratus/pkg/filter/rarity"
+)
+
+// Observer is implemented by stateful functions that record the observation
+// of their arguments in the frequency store. Observe records the observation,
+// or only returns the current state of the arguments if the record flag is
+// false. Result derives the function result from the observation. Functions
+// observing the same arguments share the observation of a single event.
+type Observer interface {
+	Observe(args []interface{}, record bool) (rarity.Observation, bool)
+	Result(obs rarity.Observation) interface{}
+}
+
+// observe records the observation of the value combination in the namespace.
+func observe(args []interface{}, record bool) (rarity.Observation, bool) {
+	if len(args) < 2 {
+		return rarity.Observation{}, false
+	}
+	namespace, ok := args[0].(string)
+	if !ok {
+		return rarity.Observation{}, false
+	}
+	for _, arg := range args[1:] {
+		// the value combination is incomplete
+		if arg == nil {
+			return rarity.Observation{}, false
+		}
+	}
+	key := rarity.Key(namespace, args[1:]...)
+	if !record {
+		return rarity.Default().Peek(key, time.Now()), true
+	}
+	return rarity.Default().Observe(key, time.Now()), true
+}
+
+func observedArgs() []FunctionArgDesc {
+	args := []FunctionArgDesc{
+		{Keyword: "namespace", Types: []ArgType{String}, Required: true},
+		{Keyword: "value1", Types: []ArgType{Field, BoundField, BoundSegment, BareBoundVariable, Func, String, Number}, Required: true},
+	}
+	offset := len(args)
+	// add optional arguments
+	for i := offset; i < maxArgs; i++ {
+		args = append(args, FunctionArgDesc{Keyword: fmt.Sprintf("value%d", i), Types: []ArgType{Field, BoundField, BoundSegment, BareBoundVariable, Func, String, Number}})
+	}
+	return args
+}
+
+// FirstSeen records the observation of the value combination in the namespace
+// and returns true if the combination was observed for the first time. During
+// the learning period of the frequency store, the function returns false.
+type FirstSeen struct{}
+
+func (f FirstSeen) Call(args []interface{}) (interface{}, bool) {
+	obs, ok := f.Observe(args, true)
+	if !ok {
+		return false, false
+	}
+	return f.Result(obs), true
+}
+
+func (f FirstSeen) Observe(args []interface{}, record bool) (rarity.Observation, bool) {
+	return observe(args, record)
+}
+
+func (f FirstSeen) Result(obs rarity.Observation) interface{} { return obs.IsFirst() }
+
+func (f FirstSeen) Desc() FunctionDesc {
+	return FunctionDesc{Name: FirstSeenFn, Args: observedArgs()}
+}
+
+func (f FirstSeen) Name() Fn { return FirstSeenFn }
+
+// SeenCount records the observation of the value combination in the namespace
+// and returns the number of times the combination was observed, including the
+// current observation. During the learning period of the frequency store, the
+// function yields no value.
+type SeenCount struct{}
+
+func (f SeenCount) Call(args []interface{}) (interface{}, bool) {
+	obs, ok := f.Observe(args, true)
+	if !ok {
+		return nil, false
+	}
+	return f.Result(obs), true
+}
+
+func (f SeenCount) Observe(args []interface{}, record bool) (rarity.Observation, bool) {
+	return observe(args, record)
+}
+
+func (f SeenCount) Result(obs rarity.Observation) interface{} {
+	if obs.Learning {
+		return nil
+	}
+	return obs.Count
+}
+
+func (f SeenCount) Desc() FunctionDesc {
+	return FunctionDesc{Name: SeenCountFn, Args: observedArgs()}
+}
+
+func (f SeenCount) Name() Fn { return SeenCountFn }
@@ -0,0 +1,111 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package functions
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rabbitstack/fibratus/pkg/config"
+	"github.com/rabbitstack/fibratus/pkg/filter/rarity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFirstSeen(t *testing.T) {
+	rarity.Open(config.Rarity{})
+
+	var tests = []struct {
+		args     []interface{}
+		expected interface{}
+	}{
+		{
+			[]interface{}{"parent_child", "winword.exe", "cmd.exe"},
+			true,
+		},
+		{
+			[]interface{}{"parent_child", "winword.exe", "cmd.exe"},
+			false,
+		},
+		{
+			[]interface{}{"parent_child", "winword.exe", "powershell.exe"},
+			true,
+		},
+		{
+			[]interface{}{"ports", "svchost.exe", uint16(443)},
+			true,
+		},
+		{
+			[]interface{}{"parent_child", "winword.exe", nil},
+			false,
+		},
+	}
+
+	for i, tt := range tests {
+		f := FirstSeen{}
+		res, _ := f.Call(tt.args)
+		assert.Equal(t, tt.expected, res, fmt.Sprintf("%d. result mismatch: exp=%v got=%v", i, tt.expected, res))
+	}
+}
+
+func TestSeenCount(t *testing.T) {
+	rarity.Open(config.Rarity{})
+
+	var tests = []struct {
+		args     []interface{}
+		expected interface{}
+	}{
+		{
+			[]interface{}{"modules", "cmd.exe", "kernel32.dll"},
+			1,
+		},
+		{
+			[]interface{}{"modules", "cmd.exe", "kernel32.dll"},
+			2,
+		},
+		{
+			[]interface{}{"modules", "cmd.exe", "kernel32.dll"},
+			3,
+		},
+		{
+			[]interface{}{"modules", "cmd.exe", "evil.dll"},
+			1,
+		},
+		{
+			[]interface{}{"modules"},
+			nil,
+		},
+	}
+
+	for i, tt := range tests {
+		f := SeenCount{}
+		res, _ := f.Call(tt.args)
+		assert.Equal(t, tt.expected, res, fmt.Sprintf("%d. result mismatch: exp=%v got=%v", i, tt.expected, res))
+	}
+}
+
+func TestSeenLearningPeriod(t *testing.T) {
+	rarity.Open(config.Rarity{LearningPeriod: time.Hour})
+	defer rarity.Open(config.Rarity{})
+
+	res, _ := FirstSeen{}.Call([]interface{}{"parent_child", "explorer.exe", "cmd.exe"})
+	assert.Equal(t, false, res)
+	res, _ = SeenCount{}.Call([]interface{}{"parent_child", "explorer.exe", "cmd.exe"})
+	assert.Nil(t, res)
+}
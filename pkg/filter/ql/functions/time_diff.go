@@ -0,0 +1,56 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package functions
+
+import "time"
+
+// TimeDiff returns the absolute duration between two timestamps.
+type TimeDiff struct{}
+
+func (f TimeDiff) Call(args []interface{}) (interface{}, bool) {
+	if len(args) < 2 {
+		return time.Duration(0), false
+	}
+	t1, ok := ParseTime(args[0])
+	if !ok {
+		return time.Duration(0), false
+	}
+	t2, ok := ParseTime(args[1])
+	if !ok {
+		return time.Duration(0), false
+	}
+	d := t2.Sub(t1)
+	if d < 0 {
+		d = -d
+	}
+	return d, true
+}
+
+func (f TimeDiff) Desc() FunctionDesc {
+	desc := FunctionDesc{
+		Name: TimeDiffFn,
+		Args: []FunctionArgDesc{
+			{Keyword: "timestamp1", Types: []ArgType{Field, BoundField, BareBoundVariable, Func, String, DateTime}, Required: true},
+			{Keyword: "timestamp2", Types: []ArgType{Field, BoundField, BareBoundVariable, Func, String, DateTime}, Required: true},
+		},
+	}
+	return desc
+}
+
+func (f TimeDiff) Name() Fn { return TimeDiffFn }
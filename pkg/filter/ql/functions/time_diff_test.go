@@ -0,0 +1,74 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package functions
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeDiff(t *testing.T) {
+	ts := time.Date(2024, 6, 15, 10, 30, 0, 0, time.Local)
+	y, m, d := time.Now().Date()
+	today := time.Date(y, m, d, 10, 30, 0, 0, time.Local)
+
+	var tests = []struct {
+		args     []interface{}
+		expected interface{}
+	}{
+		{
+			[]interface{}{ts, ts.Add(10 * time.Second)},
+			10 * time.Second,
+		},
+		{
+			[]interface{}{ts.Add(10 * time.Second), ts},
+			10 * time.Second,
+		},
+		{
+			[]interface{}{ts.UnixNano(), ts.Add(250 * time.Millisecond).UnixNano()},
+			250 * time.Millisecond,
+		},
+		{
+			[]interface{}{"10:30:00", "10:32:05"},
+			2*time.Minute + 5*time.Second,
+		},
+		{
+			[]interface{}{"2024-06-15", ts},
+			10*time.Hour + 30*time.Minute,
+		},
+		{
+			// the time of day is anchored to the current date
+			[]interface{}{"10:30:00", today.Add(time.Hour)},
+			time.Hour,
+		},
+		{
+			[]interface{}{"10:30:00", "now"},
+			time.Duration(0),
+		},
+	}
+
+	for i, tt := range tests {
+		f := TimeDiff{}
+		res, _ := f.Call(tt.args)
+		assert.Equal(t, tt.expected, res, fmt.Sprintf("%d. result mismatch: exp=%v got=%v", i, tt.expected, res))
+	}
+}
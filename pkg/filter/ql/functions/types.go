@@ -18,6 +18,8 @@
 
 package functions
 
+import "time"
+
 const maxArgs = 1 << 5
 
 // Fn is the type alias for function definitions.
@@ -76,6 +78,20 @@ const (
 	ForeachFn
 	// CountFn reprsents the COUNT function
 	CountFn
+	// NowFn represents the NOW function
+	NowFn
+	// AgeFn represents the AGE function
+	AgeFn
+	// TimeDiffFn represents the TIME_DIFF function
+	TimeDiffFn
+	// LookupFn represents the LOOKUP function
+	LookupFn
+	// LookupListFn represents the LOOKUP_LIST function
+	LookupListFn
+	// FirstSeenFn represents the FIRST_SEEN function
+	FirstSeenFn
+	// SeenCountFn represents the SEEN_COUNT function
+	SeenCountFn
 )
 
 // ArgType is the type alias for the argument value type.
@@ -110,6 +126,8 @@ const (
 	BoundSegment
 	// BareBoundVariable represents the bare bound variable argument type.
 	BareBoundVariable
+	// DateTime represents the datetime literal argument type.
+	DateTime
 	// Unknown is the unknown argument type.
 	Unknown
 )
@@ -139,6 +157,8 @@ func (typ ArgType) String() string {
 		return "boundsegment"
 	case BareBoundVariable:
 		return "bareboundvar"
+	case DateTime:
+		return "datetime"
 	}
 	return "unknown"
 }
@@ -232,6 +252,20 @@ func (f Fn) String() string {
 		return "FOREACH"
 	case CountFn:
 		return "COUNT"
+	case NowFn:
+		return "NOW"
+	case AgeFn:
+		return "AGE"
+	case TimeDiffFn:
+		return "TIME_DIFF"
+	case LookupFn:
+		return "LOOKUP"
+	case LookupListFn:
+		return "LOOKUP_LIST"
+	case FirstSeenFn:
+		return "FIRST_SEEN"
+	case SeenCountFn:
+		return "SEEN_COUNT"
 	default:
 		return "UNDEFINED"
 	}
@@ -248,3 +282,45 @@ func parseString(index int, args []interface{}) string {
 	}
 	return s
 }
+
+// timeLayouts are the layouts recognized when parsing timestamps from strings.
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"}
+
+// timeOfDayLayout is the layout of the time of day, such as the evt.time field value.
+const timeOfDayLayout = "15:04:05"
+
+// ParseTime converts the value to the timestamp. Strings are parsed in local
+// time zone unless the zone is given explicitly, and signed integers are
+// interpreted as the number of nanoseconds elapsed since the Unix epoch.
+// The time of day is anchored to the current date in local time zone.
+func ParseTime(v interface{}) (time.Time, bool) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, true
+	case int64:
+		return time.Unix(0, v), true
+	case string:
+		if t, ok := ParseDateTime(v); ok {
+			return t, true
+		}
+		t, err := time.ParseInLocation(timeOfDayLayout, v, time.Local)
+		if err == nil {
+			y, m, d := time.Now().Date()
+			return time.Date(y, m, d, t.Hour(), t.Minute(), t.Second(), 0, time.Local), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// ParseDateTime parses the string given as the RFC3339 timestamp, date/time,
+// or date. The string is parsed in local time zone unless the zone is given
+// explicitly.
+func ParseDateTime(s string) (time.Time, bool) {
+	for _, layout := range timeLayouts {
+		t, err := time.ParseInLocation(layout, s, time.Local)
+		if err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -87,6 +87,13 @@ func (s *scanner) scan() (tok Token, pos int, lit string) {
 			return Neq, pos, ""
 		}
 		s.r.unread()
+		// bang is only recognized in front
+		// of the negated sequence expression
+		if ch1, _ := s.r.read(); ch1 == '|' {
+			s.r.unread()
+			return Bang, pos, ""
+		}
+		s.r.unread()
 	case '>':
 		if ch1, _ := s.r.read(); ch1 == '=' {
 			return Gte, pos, ""
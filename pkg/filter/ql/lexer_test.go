@@ -46,6 +46,7 @@ func TestScanner(t *testing.T) {
 		{s: `~=`, tok: IEq},
 		{s: `<>`, tok: Neq},
 		{s: `! `, tok: Illegal, lit: "!"},
+		{s: `!|`, tok: Bang},
 		{s: `<`, tok: Lt},
 		{s: `<=`, tok: Lte},
 		{s: `>`, tok: Gt},
@@ -19,6 +19,7 @@
 package ql
 
 import (
+	"fmt"
 	"net"
 	"reflect"
 	"strconv"
@@ -58,6 +59,11 @@ type DecimalLiteral struct {
 	Value float64
 }
 
+// DurationLiteral represents the time duration literal.
+type DurationLiteral struct {
+	Value time.Duration
+}
+
 // BoolLiteral represents the logical true/false literal.
 type BoolLiteral struct {
 	Value bool
@@ -119,6 +125,10 @@ func (d DecimalLiteral) String() string {
 	return strconv.FormatFloat(d.Value, 'e', -1, 64)
 }
 
+func (d DurationLiteral) String() string {
+	return formatDuration(d.Value)
+}
+
 func (b BoolLiteral) String() string {
 	return strconv.FormatBool(b.Value)
 }
@@ -163,6 +173,112 @@ func (s *ListLiteral) String() string {
 	return b.String()
 }
 
+// DateTimeLiteral represents the timestamp literal.
+type DateTimeLiteral struct {
+	Value time.Time
+}
+
+// String returns a string representation of the literal.
+func (d *DateTimeLiteral) String() string {
+	return "datetime('" + d.Value.Format(time.RFC3339Nano) + "')"
+}
+
+// RangeLiteral represents the inclusive range given by
+// the lower and upper bounds of the between operator.
+type RangeLiteral struct {
+	Low  Expr
+	High Expr
+}
+
+// String returns a string representation of the literal.
+func (r *RangeLiteral) String() string {
+	var b strings.Builder
+	low, high := r.Low.String(), r.High.String()
+	b.Grow(len(low) + len(high) + 5)
+	b.WriteString(low)
+	b.WriteString(" AND ")
+	b.WriteString(high)
+	return b.String()
+}
+
+// RangesLiteral represents the list of ranges of the in range operator.
+// Each range is either a numeric range with inclusive bounds separated
+// by the dash, e.g. 1024-2048, a single number, or the CIDR block.
+type RangesLiteral struct {
+	ListLiteral
+	ranges [][2]uint64
+	nets   []*net.IPNet
+}
+
+// newRangesLiteral parses ranges from the list of string values.
+func newRangesLiteral(values []string) (*RangesLiteral, error) {
+	r := &RangesLiteral{ListLiteral: ListLiteral{Values: values}}
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if strings.Contains(v, "/") {
+			_, n, err := net.ParseCIDR(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR range %q", v)
+			}
+			r.nets = append(r.nets, n)
+			continue
+		}
+		low, high, ok := strings.Cut(v, "-")
+		if !ok {
+			high = low
+		}
+		l, err := strconv.ParseUint(strings.TrimSpace(low), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid range %q", v)
+		}
+		h, err := strconv.ParseUint(strings.TrimSpace(high), 10, 64)
+		if err != nil || h < l {
+			return nil, fmt.Errorf("invalid range %q", v)
+		}
+		r.ranges = append(r.ranges, [2]uint64{l, h})
+	}
+	return r, nil
+}
+
+// contains determines if the numeric or IP address value
+// falls within any of the ranges.
+func (r *RangesLiteral) contains(v interface{}) bool {
+	if ip, ok := v.(net.IP); ok {
+		return r.ContainsIP(ip)
+	}
+	n, ok := numeric(v)
+	if !ok {
+		return false
+	}
+	switch n.kind {
+	case reflect.Int64:
+		return n.i >= 0 && r.ContainsNumber(uint64(n.i))
+	case reflect.Uint64:
+		return r.ContainsNumber(n.u)
+	}
+	return false
+}
+
+// ContainsNumber determines if the number falls within any of the numeric ranges.
+func (r *RangesLiteral) ContainsNumber(n uint64) bool {
+	for _, rng := range r.ranges {
+		if n >= rng[0] && n <= rng[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainsIP determines if the IP address pertains to any of the CIDR blocks.
+func (r *RangesLiteral) ContainsIP(ip net.IP) bool {
+	for _, n := range r.nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
 // Function represents a function call.
 type Function struct {
 	Name string
@@ -198,6 +314,20 @@ func (f *Function) IsForeach() bool {
 	return f.Name == "foreach" || f.Name == "FOREACH"
 }
 
+// IsLookup determines if the function resolves values from the lookup table.
+func (f *Function) IsLookup() bool {
+	name := strings.ToUpper(f.Name)
+	return name == "LOOKUP" || name == "LOOKUP_LIST"
+}
+
+// IsStateful determines if the function records observations
+// of its arguments, and thus the function result depends on the
+// events previously evaluated by the function.
+func (f *Function) IsStateful() bool {
+	_, ok := funcs[strings.ToUpper(f.Name)].(functions.Observer)
+	return ok
+}
+
 func (f *Function) IsBinaryExprArg(i int) bool {
 	_, ok := f.Args[i].(*BinaryExpr)
 	return ok
@@ -243,41 +373,45 @@ func (f *Function) validate() error {
 
 	for i, expr := range f.Args {
 		arg := fn.Desc().Args[i]
-		typ := functions.Unknown
-
-		switch reflect.TypeOf(expr) {
-		case reflect.TypeOf(&FieldLiteral{}):
-			typ = functions.Field
-		case reflect.TypeOf(&BoundFieldLiteral{}):
-			typ = functions.BoundField
-		case reflect.TypeOf(&BoundSegmentLiteral{}):
-			typ = functions.BoundSegment
-		case reflect.TypeOf(&BareBoundVariableLiteral{}):
-			typ = functions.BareBoundVariable
-		case reflect.TypeOf(&IPLiteral{}):
-			typ = functions.IP
-		case reflect.TypeOf(&StringLiteral{}):
-			typ = functions.String
-		case reflect.TypeOf(&IntegerLiteral{}):
-			typ = functions.Number
-		case reflect.TypeOf(&Function{}):
-			typ = functions.Func
-		case reflect.TypeOf(&ListLiteral{}):
-			typ = functions.Slice
-		case reflect.TypeOf(&BoolLiteral{}):
-			typ = functions.Bool
-		case reflect.TypeOf(&BinaryExpr{}), reflect.TypeOf(&ParenExpr{}), reflect.TypeOf(&NotExpr{}):
-			typ = functions.Expression
-		}
-
-		if !arg.ContainsType(typ) {
-			return ErrArgumentTypeMismatch(i, arg.Keyword, fn.Name(), arg.Types)
+		if !arg.ContainsType(argType(expr)) {
+			return ErrArgumentTypeMismatch(i, arg.Keyword, fn.Name().String(), arg.Types)
 		}
 	}
 
 	return nil
 }
 
+// argType determines the function argument type from the expression.
+func argType(expr Expr) functions.ArgType {
+	switch expr.(type) {
+	case *FieldLiteral:
+		return functions.Field
+	case *BoundFieldLiteral:
+		return functions.BoundField
+	case *BoundSegmentLiteral:
+		return functions.BoundSegment
+	case *BareBoundVariableLiteral:
+		return functions.BareBoundVariable
+	case *IPLiteral:
+		return functions.IP
+	case *StringLiteral:
+		return functions.String
+	case *IntegerLiteral, *UnsignedLiteral, *DecimalLiteral:
+		return functions.Number
+	case *Function:
+		return functions.Func
+	case *ListLiteral:
+		return functions.Slice
+	case *BoolLiteral:
+		return functions.Bool
+	case *DateTimeLiteral:
+		return functions.DateTime
+	case *BinaryExpr, *ParenExpr, *NotExpr:
+		return functions.Expression
+	}
+	return functions.Unknown
+}
+
 // SequenceExpr represents a single binary expression within the sequence.
 type SequenceExpr struct {
 	Expr Expr
@@ -287,6 +421,10 @@ type SequenceExpr struct {
 	BoundFields []*BoundFieldLiteral
 	// Alias represents the sequence expression alias when bound fields are used.
 	Alias string
+	// IsNegated indicates the expression must not match between the upstream
+	// and downstream expressions. If the negated expression is the last one in
+	// the sequence, the absence of the event is decided when the max span expires.
+	IsNegated bool
 
 	bitsets event.BitSets
 	types   []event.Type
@@ -463,3 +601,24 @@ func (s Sequence) incompatibleConstraints() bool {
 	}
 	return false
 }
+
+// Threshold represents the aggregation rule that fires when the
+// number of events matching the expression within the sliding
+// time window reaches the given count. Events are optionally
+// grouped by one or multiple fields, and each group maintains
+// its own window.
+type Threshold struct {
+	// Count is the number of matching events required to fire the rule.
+	Count uint64
+	// Within is the sliding time window in which matching events are counted.
+	Within time.Duration
+	// By contains the group-by fields or nil if all matching events are counted together.
+	By *SequenceLink
+	// Expr is the expression each event must satisfy to be counted.
+	Expr Expr
+}
+
+// IsGrouped determines if the threshold counts events per group.
+func (t Threshold) IsGrouped() bool {
+	return t.By != nil
+}
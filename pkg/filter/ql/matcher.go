@@ -0,0 +1,166 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ql
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rabbitstack/fibratus/pkg/util/ahocorasick"
+	"github.com/rabbitstack/fibratus/pkg/util/stringcase"
+	"github.com/rabbitstack/fibratus/pkg/util/wildcard"
+)
+
+// minMatcherValues is the minimum number of list values
+// for which the multi-pattern matcher is compiled. Shorter
+// lists are cheaper to evaluate linearly.
+const minMatcherValues = 8
+
+// listMatcher evaluates the operator against all values of
+// the list literal at once. The outcome is identical to the
+// linear evaluation of the operator for each list value.
+type listMatcher interface {
+	Match(s string) bool
+}
+
+// CompileMatcher precompiles the multi-pattern matcher if the binary
+// expression applies the string operator to a large list literal. The
+// in operators are turned into hash lookups, substring, prefix, and
+// suffix operators into Aho-Corasick automata, and wildcard operators
+// into the automaton that finds literal fragments of all patterns in a
+// single pass, so only patterns with matching fragments are evaluated.
+// The matcher is used when the left side of the expression evaluates to
+// a string.
+func (e *BinaryExpr) CompileMatcher() {
+	list, ok := e.RHS.(*ListLiteral)
+	if !ok || len(list.Values) < minMatcherValues {
+		return
+	}
+	if _, ok := e.LHS.(*QuantifierExpr); ok {
+		return
+	}
+
+	values := list.Values
+	switch e.Op {
+	case In:
+		e.matcher = newSetMatcher(values, false)
+	case IIn:
+		e.matcher = newSetMatcher(values, true)
+	case Contains:
+		e.matcher = ahocorasick.New(values, ahocorasick.Substring, false)
+	case IContains:
+		e.matcher = ahocorasick.New(values, ahocorasick.Substring, true)
+	case Startswith:
+		e.matcher = ahocorasick.New(values, ahocorasick.Prefix, false)
+	case IStartswith:
+		e.matcher = ahocorasick.New(values, ahocorasick.Prefix, true)
+	case Endswith:
+		e.matcher = ahocorasick.New(values, ahocorasick.Suffix, false)
+	case IEndswith:
+		e.matcher = ahocorasick.New(values, ahocorasick.Suffix, true)
+	case Matches:
+		e.matcher = newGlobMatcher(values, true)
+	case IMatches:
+		e.matcher = newGlobMatcher(values, false)
+	}
+}
+
+// setMatcher checks the string membership in the list values.
+// Case-insensitive sets store values in the case-folded form.
+type setMatcher struct {
+	values map[string]struct{}
+	fold   bool
+}
+
+func newSetMatcher(values []string, fold bool) *setMatcher {
+	m := &setMatcher{values: make(map[string]struct{}, len(values)), fold: fold}
+	for _, v := range values {
+		if fold {
+			v = stringcase.Fold(v)
+		}
+		m.values[v] = struct{}{}
+	}
+	return m
+}
+
+func (m *setMatcher) Match(s string) bool {
+	if m.fold {
+		s = stringcase.Fold(s)
+	}
+	_, ok := m.values[s]
+	return ok
+}
+
+// globMatcher matches wildcard patterns. The automaton finds
+// the longest literal fragment of every pattern in the string,
+// and only the patterns whose fragment is found are evaluated.
+// Patterns consisting solely of wildcards are always evaluated.
+type globMatcher struct {
+	patterns      []string
+	caseSensitive bool
+	// fragments matches the literal pattern fragments
+	fragments *ahocorasick.Matcher
+	// ids maps fragment indices to pattern indices
+	ids []int
+	// wildcards contains indices of the patterns without fragments
+	wildcards []int
+}
+
+func newGlobMatcher(patterns []string, caseSensitive bool) *globMatcher {
+	m := &globMatcher{patterns: patterns, caseSensitive: caseSensitive}
+	fragments := make([]string, 0, len(patterns))
+	for i, pat := range patterns {
+		frag := longestFragment(pat)
+		if frag == "" {
+			m.wildcards = append(m.wildcards, i)
+			continue
+		}
+		fragments = append(fragments, frag)
+		m.ids = append(m.ids, i)
+	}
+	m.fragments = ahocorasick.New(fragments, ahocorasick.Substring, !caseSensitive)
+	return m
+}
+
+func (m *globMatcher) Match(s string) bool {
+	for _, i := range m.wildcards {
+		if wildcard.Match(m.patterns[i], s, m.caseSensitive) {
+			return true
+		}
+	}
+	return m.fragments.Find(s, func(id int) bool {
+		return wildcard.Match(m.patterns[m.ids[id]], s, m.caseSensitive)
+	})
+}
+
+// longestFragment returns the longest run of literal characters in
+// the wildcard pattern. The replacement character is treated as the
+// wildcard, since the wildcard matcher decodes invalid UTF-8 sequences
+// in the string as the replacement character.
+func longestFragment(pattern string) string {
+	var longest string
+	for _, frag := range strings.FieldsFunc(pattern, func(r rune) bool {
+		return r == '*' || r == '?' || r == utf8.RuneError
+	}) {
+		if len(frag) > len(longest) {
+			longest = frag
+		}
+	}
+	return longest
+}
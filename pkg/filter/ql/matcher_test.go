@@ -0,0 +1,101 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ql
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileMatcher(t *testing.T) {
+	list := `('cmd.exe', 'powershell.exe', 'pwsh.exe', 'rundll32.exe', 'regsvr32.exe', 'mshta.exe', 'wscript.exe', 'cscript.exe', 'ſc.exe', '\\Windows\\Temp\\', 'C:\\Program Files\\')`
+	globs := `('?:\\Windows\\System32\\*.exe', '*\\AppData\\*\\Temp\\*', '*.ps?', '?:\\Users\\*\\Downloads\\*', '*\\Temp\\*.dll', '*', '?:\\ProgramData\\*', 'C:\\*\\svchost.exe')`
+
+	exprs := make([]string, 0)
+	for _, op := range []string{"in", "iin", "contains", "icontains", "startswith", "istartswith", "endswith", "iendswith"} {
+		exprs = append(exprs, fmt.Sprintf("ps.exe %s %s", op, list), fmt.Sprintf("ps.exe not %s %s", op, list))
+	}
+	for _, op := range []string{"matches", "imatches"} {
+		exprs = append(exprs, fmt.Sprintf("ps.exe %s %s", op, globs), fmt.Sprintf("ps.exe %s %s", op, strings.Replace(globs, `'*', `, "", 1)))
+	}
+
+	values := []any{
+		"cmd.exe", "CMD.EXE", "sc.exe", "SC.EXE", "Cmd.Exe ", "",
+		`C:\Windows\Temp\x.exe`, `c:\windows\temp\x.exe`, `C:\Program Files\app.exe`, `c:\program files\app.exe`,
+		`C:\Windows\System32\cmd.exe`, `c:\windows\system32\CMD.EXE`, `C:\Users\admin\AppData\Local\Temp\a.exe`,
+		`C:\Users\admin\Downloads\b.ps1`, `C:\Temp\evil.DLL`, `C:\ProgramData\x`, `C:\Windows\svchost.exe`,
+		`C:\Users\Åsa\Downloads\x.exe`, `C:\USERS\ÅSA\APPDATA\LOCAL\TEMP\x`, "\xff\xfe", nil, []string{"cmd.exe"}, uint32(1),
+	}
+
+	for _, s := range exprs {
+		expr, err := NewParser(s).ParseExpr()
+		require.NoError(t, err, s)
+
+		want := make([]bool, len(values))
+		for i, v := range values {
+			want[i] = Eval(expr, map[string]interface{}{"ps.exe": v}, false)
+		}
+
+		WalkFunc(expr, func(n Node) {
+			if b, ok := n.(*BinaryExpr); ok {
+				b.CompileMatcher()
+				require.NotNil(t, b.matcher, s)
+			}
+		})
+
+		for i, v := range values {
+			assert.Equal(t, want[i], Eval(expr, map[string]interface{}{"ps.exe": v}, false), "%s with %v", s, v)
+		}
+	}
+}
+
+func TestCompileMatcherSmallList(t *testing.T) {
+	expr, err := NewParser(`ps.name iin ('cmd.exe', 'powershell.exe')`).ParseExpr()
+	require.NoError(t, err)
+	expr.(*BinaryExpr).CompileMatcher()
+	assert.Nil(t, expr.(*BinaryExpr).matcher)
+}
+
+func BenchmarkListMatcher(b *testing.B) {
+	values := make([]string, 0, 200)
+	for i := 0; i < 200; i++ {
+		values = append(values, fmt.Sprintf("'?:\\\\Program Files\\\\Vendor%d\\\\*.exe'", i))
+	}
+	expr, err := NewParser(fmt.Sprintf("ps.exe imatches (%s)", strings.Join(values, ", "))).ParseExpr()
+	require.NoError(b, err)
+	m := map[string]interface{}{"ps.exe": `C:\Users\admin\AppData\Local\Microsoft\OneDrive\OneDrive.exe`}
+
+	b.Run("linear", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			Eval(expr, m, false)
+		}
+	})
+	b.Run("matcher", func(b *testing.B) {
+		b.ReportAllocs()
+		expr.(*BinaryExpr).CompileMatcher()
+		for i := 0; i < b.N; i++ {
+			Eval(expr, m, false)
+		}
+	})
+}
@@ -0,0 +1,416 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ql
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rabbitstack/fibratus/pkg/filter/fields"
+	"github.com/rabbitstack/fibratus/pkg/filter/ql/functions"
+)
+
+// Cost is the relative cost class of evaluating the expression.
+type Cost uint8
+
+const (
+	// LiteralCost is the cost of expressions whose value is known before evaluation.
+	LiteralCost Cost = iota
+	// EventCost is the cost of reading event parameters.
+	EventCost
+	// ProcessCost is the cost of resolving the process state from the snapshotter.
+	ProcessCost
+	// PECost is the cost of parsing PE metadata, verifying signatures,
+	// or accessing the file system and the registry.
+	PECost
+	// YaraCost is the cost of scanning the process or file with YARA rules.
+	YaraCost
+)
+
+var costNames = [...]string{"literal", "event", "process", "pe", "yara"}
+
+// String returns the cost class name.
+func (c Cost) String() string { return costNames[c] }
+
+// funcCosts contains the cost of functions that perform
+// I/O or scanning. Other functions operate on the values
+// of their arguments and are as cheap as event parameters.
+var funcCosts = map[string]Cost{
+	functions.YaraFn.String():        YaraCost,
+	functions.IsMinidumpFn.String():  PECost,
+	functions.GetRegValueFn.String(): PECost,
+	functions.SymlinkFn.String():     PECost,
+}
+
+// FieldCost returns the cost class of extracting the field value.
+// PE and signature fields may require parsing the executable or
+// verifying the signature, and process fields are resolved from
+// the process snapshotter. All other fields are read from event
+// parameters.
+func FieldCost(f fields.Field) Cost {
+	switch {
+	case f.IsPeField(), f.IsImageCert(), strings.Contains(string(f), ".signature."):
+		return PECost
+	case f.IsPsField():
+		return ProcessCost
+	default:
+		return EventCost
+	}
+}
+
+// ExprCost returns the cost class of evaluating the expression.
+// The cost of the composite expression is the cost of its most
+// expensive operand.
+func ExprCost(expr Expr) Cost {
+	switch e := expr.(type) {
+	case *FieldLiteral:
+		return FieldCost(e.Field)
+	case *BoundFieldLiteral:
+		return FieldCost(e.Field.Field)
+	case *QuantifierExpr:
+		return FieldCost(e.Field.Field)
+	case *BoundSegmentLiteral, *BareBoundVariableLiteral:
+		return EventCost
+	case *BinaryExpr:
+		return max(ExprCost(e.LHS), ExprCost(e.RHS))
+	case *NotExpr:
+		return ExprCost(e.Expr)
+	case *ParenExpr:
+		return ExprCost(e.Expr)
+	case *SharedExpr:
+		return ExprCost(e.Expr)
+	case *Function:
+		c, ok := funcCosts[strings.ToUpper(e.Name)]
+		if !ok {
+			c = EventCost
+		}
+		for _, arg := range e.Args {
+			c = max(c, ExprCost(arg))
+		}
+		return c
+	}
+	return LiteralCost
+}
+
+// Optimize rewrites the expression for cheaper evaluation. Predicates
+// comparing literals are folded to boolean constants, and the constants
+// are propagated through the and/or/not operators. Conjuncts that can't
+// be satisfied by the same event are folded to false. Finally, the
+// operands of and/or operators are reordered by their cost class, so the
+// cheap predicates short-circuit the evaluation of expensive ones. The
+// operands of the same cost class retain their written order. Function
+// arguments are not rewritten, and the and/or chains calling stateful
+// functions are neither folded nor reordered.
+func Optimize(expr Expr) Expr {
+	return optimize(expr, false)
+}
+
+// optimize rewrites the expression. The nil value of the predicate,
+// such as the one produced when the field is absent, and false are
+// interchangeable unless the predicate is negated. For this reason,
+// rewrites that may turn the false value into nil, like dropping the
+// true operand from the conjunction, are only applied outside of not
+// expressions.
+func optimize(expr Expr, negated bool) Expr {
+	switch e := expr.(type) {
+	case *ParenExpr:
+		return optimize(e.Expr, negated)
+	case *NotExpr:
+		n := optimize(e.Expr, true)
+		if b, ok := n.(*BoolLiteral); ok {
+			return &BoolLiteral{Value: !b.Value}
+		}
+		if _, ok := e.Expr.(*ParenExpr); ok {
+			n = &ParenExpr{Expr: n}
+		}
+		return &NotExpr{Expr: n}
+	case *BinaryExpr:
+		if e.Op == And || e.Op == Or {
+			return optimizeLogical(e, negated)
+		}
+		if isLiteral(e.LHS) && isLiteral(e.RHS) {
+			v := ValuerEval{Valuer: MapValuer{}}
+			if c, ok := v.Eval(e).(bool); ok {
+				return &BoolLiteral{Value: c}
+			}
+		}
+		if r, ok := e.RHS.(*RangeLiteral); ok && e.Op == Between {
+			low, lok := literalValue(r.Low)
+			high, hok := literalValue(r.High)
+			if lok && hok && isEmptyRange(low, high) {
+				return &BoolLiteral{Value: false}
+			}
+		}
+	}
+	return expr
+}
+
+// optimizeLogical folds constant operands of the and/or chain
+// and reorders the remaining operands by their cost class.
+func optimizeLogical(e *BinaryExpr, negated bool) Expr {
+	// the operand that determines the outcome
+	// of the chain regardless of other operands
+	absorbing := e.Op == Or
+
+	exprs := flatten(e, e.Op)
+
+	// stateful functions record observations when they are called,
+	// so the chain calling stateful functions is neither folded nor
+	// reordered to preserve the events the observations are recorded
+	// for
+	if slices.ContainsFunc(exprs, IsStateful) {
+		expr := group(optimize(exprs[0], negated))
+		for _, operand := range exprs[1:] {
+			expr = &BinaryExpr{Op: e.Op, LHS: expr, RHS: group(optimize(operand, negated))}
+		}
+		return expr
+	}
+
+	operands := make([]Expr, 0, len(exprs))
+	for _, expr := range exprs {
+		expr = optimize(expr, negated)
+		if b, ok := expr.(*BoolLiteral); ok {
+			if b.Value == absorbing {
+				return &BoolLiteral{Value: absorbing}
+			}
+			if !negated {
+				continue
+			}
+		}
+		operands = append(operands, expr)
+	}
+
+	switch {
+	case len(operands) == 0:
+		return &BoolLiteral{Value: !absorbing}
+	case e.Op == And && !negated && contradicts(operands):
+		return &BoolLiteral{Value: false}
+	case len(operands) == 1:
+		return operands[0]
+	}
+
+	slices.SortStableFunc(operands, func(a, b Expr) int {
+		return int(ExprCost(a)) - int(ExprCost(b))
+	})
+
+	expr := group(operands[0])
+	for _, operand := range operands[1:] {
+		expr = &BinaryExpr{Op: e.Op, LHS: expr, RHS: group(operand)}
+	}
+	return expr
+}
+
+// group parenthesizes the nested and/or expression.
+func group(expr Expr) Expr {
+	if e, ok := expr.(*BinaryExpr); ok && (e.Op == And || e.Op == Or) {
+		return &ParenExpr{Expr: e}
+	}
+	return expr
+}
+
+// SharedExpr represents the expression that appears in multiple
+// filters. The result of the shared expression is computed once
+// per event and stored in the result cache, if the valuer provides
+// one.
+type SharedExpr struct {
+	Expr Expr
+	// Key uniquely identifies the expression in the result cache.
+	Key string
+}
+
+// String returns a string representation of the shared expression.
+func (e *SharedExpr) String() string { return e.Expr.String() }
+
+// ResultCache is the interface implemented by valuers that
+// store the results of shared expressions evaluation.
+type ResultCache interface {
+	// Result returns the cached result of the shared expression.
+	Result(key string) (interface{}, bool)
+	// SetResult stores the result of the shared expression.
+	SetResult(key string, v interface{})
+}
+
+// Share finds subexpressions that appear more than once in the given
+// expressions and replaces them with shared expressions. Parenthesized
+// and/or expressions and predicates that match the field against the
+// list of values or patterns are subject to sharing. All occurrences
+// of the same subexpression are replaced with the identical shared
+// expression node. Subexpressions that reference bound fields or
+// variables or call stateful functions, and function arguments are
+// not shared, because their values depend on the state other than
+// the event. Returns the number of distinct shared expressions.
+func Share(exprs ...*Expr) int {
+	counts := make(map[string]int)
+	for _, expr := range exprs {
+		visitShareable(*expr, func(e Expr) { counts[exprKey(e)]++ })
+	}
+	s := sharer{counts: counts, shared: make(map[string]*SharedExpr)}
+	for _, expr := range exprs {
+		*expr = s.share(*expr)
+	}
+	return len(s.shared)
+}
+
+// visitShareable calls the function for each shareable
+// subexpression outside of function arguments.
+func visitShareable(expr Expr, fn func(Expr)) {
+	if isShareable(expr) {
+		fn(expr)
+	}
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		visitShareable(e.LHS, fn)
+		visitShareable(e.RHS, fn)
+	case *NotExpr:
+		visitShareable(e.Expr, fn)
+	case *ParenExpr:
+		visitShareable(e.Expr, fn)
+	}
+}
+
+type sharer struct {
+	counts map[string]int
+	shared map[string]*SharedExpr
+}
+
+func (s *sharer) share(expr Expr) Expr {
+	if isShareable(expr) {
+		key := exprKey(expr)
+		if shared, ok := s.shared[key]; ok {
+			return shared
+		}
+		if s.counts[key] > 1 {
+			s.rewrite(expr)
+			shared := &SharedExpr{Expr: expr, Key: key}
+			s.shared[key] = shared
+			return shared
+		}
+	}
+	s.rewrite(expr)
+	return expr
+}
+
+// rewrite shares the subexpressions of the expression.
+func (s *sharer) rewrite(expr Expr) {
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		e.LHS = s.share(e.LHS)
+		e.RHS = s.share(e.RHS)
+	case *NotExpr:
+		e.Expr = s.share(e.Expr)
+	case *ParenExpr:
+		e.Expr = s.share(e.Expr)
+	}
+}
+
+// isShareable determines if the expression is the parenthesized
+// and/or chain or the predicate with list or pattern operands, and
+// its outcome only depends on the event.
+func isShareable(expr Expr) bool {
+	switch e := expr.(type) {
+	case *ParenExpr:
+		b, ok := unparen(e).(*BinaryExpr)
+		if !ok || (b.Op != And && b.Op != Or) {
+			return false
+		}
+	case *BinaryExpr:
+		switch e.Op {
+		case And, Or:
+			return false
+		case Matches, IMatches, Fuzzy, IFuzzy, Fuzzynorm, IFuzzynorm:
+		default:
+			if _, ok := e.RHS.(*ListLiteral); !ok {
+				return false
+			}
+		}
+	default:
+		return false
+	}
+	shareable := true
+	WalkFunc(expr, func(n Node) {
+		switch n := n.(type) {
+		case *BoundFieldLiteral, *BoundSegmentLiteral, *BareBoundVariableLiteral:
+			shareable = false
+		case *Function:
+			if n.IsStateful() {
+				shareable = false
+			}
+		}
+	})
+	return shareable
+}
+
+// exprKey renders the expression unambiguously. Unlike the
+// expression string representation, string literals are quoted
+// to tell them apart from fields and other literals.
+func exprKey(expr Expr) string {
+	var b strings.Builder
+	writeKey(&b, expr)
+	return b.String()
+}
+
+func writeKey(b *strings.Builder, expr Expr) {
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		writeKey(b, e.LHS)
+		b.WriteByte(' ')
+		b.WriteString(e.Op.String())
+		b.WriteByte(' ')
+		writeKey(b, e.RHS)
+	case *ParenExpr:
+		b.WriteByte('(')
+		writeKey(b, e.Expr)
+		b.WriteByte(')')
+	case *SharedExpr:
+		writeKey(b, e.Expr)
+	case *NotExpr:
+		b.WriteString("NOT ")
+		writeKey(b, e.Expr)
+	case *Function:
+		b.WriteString(e.Name)
+		b.WriteByte('(')
+		for i, arg := range e.Args {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			writeKey(b, arg)
+		}
+		b.WriteByte(')')
+	case *StringLiteral:
+		b.WriteString(strconv.Quote(e.Value))
+	case *ListLiteral:
+		writeList(b, e.Values)
+	case *RangesLiteral:
+		writeList(b, e.Values)
+	default:
+		b.WriteString(expr.String())
+	}
+}
+
+func writeList(b *strings.Builder, values []string) {
+	b.WriteByte('(')
+	for i, v := range values {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(strconv.Quote(v))
+	}
+	b.WriteByte(')')
+}
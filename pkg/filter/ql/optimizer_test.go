@@ -0,0 +1,175 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ql
+
+import (
+	"testing"
+
+	"github.com/rabbitstack/fibratus/pkg/filter/fields"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptimize(t *testing.T) {
+	var tests = []struct {
+		expr string
+		want string
+	}{
+		{"pe.is_signed = false and ps.name = 'cmd.exe' and evt.name = 'CreateProcess'", "evt.name = CreateProcess AND ps.name = cmd.exe AND pe.is_signed = false"},
+		{"is_minidump(file.path) and ps.name = 'cmd.exe' and evt.name = 'CreateFile'", "evt.name = CreateFile AND ps.name = cmd.exe AND is_minidump(file.path)"},
+		{"ps.name = 'cmd.exe' or (evt.name = 'CreateFile' and file.name = 'a.exe')", "(evt.name = CreateFile AND file.name = a.exe) OR ps.name = cmd.exe"},
+		{"ps.name = 'cmd.exe' and (ps.pid = 4 or evt.pid = 4)", "ps.name = cmd.exe AND (evt.pid = 4 OR ps.pid = 4)"},
+		{"1 = 1 and evt.name = 'CreateFile'", "evt.name = CreateFile"},
+		{"ps.name = 'cmd.exe' and 1 = 2", "false"},
+		{"ps.name = 'cmd.exe' or 1 = 1", "true"},
+		{"ps.name = 'cmd.exe' and ps.name = 'powershell.exe'", "false"},
+		{"ps.pid between 10 and 5 or evt.name = 'CreateFile'", "evt.name = CreateFile"},
+		{"ps.name = 'cmd.exe' and not (false and evt.pid = 4)", "ps.name = cmd.exe"},
+		{"not (true and ps.name = 'cmd.exe')", "NOT (true AND ps.name = cmd.exe)"},
+		{"not (ps.name = 'cmd.exe' and ps.name = 'powershell.exe')", "NOT (ps.name = cmd.exe AND ps.name = powershell.exe)"},
+		{"first_seen('parent_child', ps.parent.name, ps.name) and evt.name = 'CreateProcess'", "first_seen(parent_child, ps.parent.name, ps.name) AND evt.name = CreateProcess"},
+		{"seen_count('modules', ps.name) < 3 and 1 = 2", "seen_count(modules, ps.name) < 3 AND false"},
+		{"evt.name = 'CreateProcess' and (1 = 1 and ps.name = 'cmd.exe' or first_seen('procs', ps.name))", "evt.name = CreateProcess AND (ps.name = cmd.exe OR first_seen(procs, ps.name))"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr, err := NewParser(tt.expr).ParseExpr()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, Optimize(expr).String())
+		})
+	}
+}
+
+func TestOptimizePreservesOutcome(t *testing.T) {
+	exprs := []string{
+		"ps.name in ('cmd.exe') and evt.pid = 4 or ps.pid > 10",
+		"ps.pid > 10 and (evt.pid = 4 or ps.name = 'cmd.exe')",
+		"not (evt.pid = 4 and ps.name = 'cmd.exe')",
+		"not (true and ps.name in ('cmd.exe'))",
+		"not (ps.name in ('cmd.exe') and ps.name in ('svchost.exe'))",
+	}
+	valuers := []map[string]interface{}{
+		{},
+		{"ps.name": "cmd.exe", "evt.pid": uint32(4), "ps.pid": uint32(20)},
+		{"ps.name": "svchost.exe", "evt.pid": uint32(8)},
+	}
+
+	for _, s := range exprs {
+		expr, err := NewParser(s).ParseExpr()
+		require.NoError(t, err)
+		opt := Optimize(expr)
+		for i, m := range valuers {
+			assert.Equal(t, Eval(expr, m, false), Eval(opt, m, false), "%s: valuer %d", s, i)
+		}
+	}
+}
+
+func TestExprCost(t *testing.T) {
+	assert.Equal(t, EventCost, FieldCost(fields.EvtPID))
+	assert.Equal(t, EventCost, FieldCost(fields.FileName))
+	assert.Equal(t, ProcessCost, FieldCost(fields.PsName))
+	assert.Equal(t, PECost, FieldCost(fields.PeIsSigned))
+	assert.Equal(t, PECost, FieldCost(fields.PsSignatureTrusted))
+	assert.Equal(t, PECost, FieldCost(fields.ModuleSignatureExists))
+	assert.Equal(t, PECost, FieldCost(fields.ImageCertIssuer))
+	assert.Equal(t, PECost, FieldCost(fields.ThreadCallstackFinalUserModuleSignatureExists))
+
+	var tests = []struct {
+		expr string
+		cost Cost
+	}{
+		{"1 = 1", LiteralCost},
+		{"evt.name = 'CreateFile'", EventCost},
+		{"evt.name = 'CreateFile' and ps.name = 'cmd.exe'", ProcessCost},
+		{"not (ps.pe.is_dotnet)", PECost},
+		{"is_minidump(file.path)", PECost},
+		{"base(ps.exe) = 'cmd.exe'", ProcessCost},
+	}
+
+	for _, tt := range tests {
+		expr, err := NewParser(tt.expr).ParseExpr()
+		require.NoError(t, err)
+		assert.Equal(t, tt.cost, ExprCost(expr), tt.expr)
+	}
+}
+
+type resultCacheValuer struct {
+	MapValuer
+	results map[string]interface{}
+	hits    int
+}
+
+func (v *resultCacheValuer) Result(key string) (interface{}, bool) {
+	val, ok := v.results[key]
+	if ok {
+		v.hits++
+	}
+	return val, ok
+}
+
+func (v *resultCacheValuer) SetResult(key string, val interface{}) { v.results[key] = val }
+
+func TestShare(t *testing.T) {
+	parse := func(s string) Expr {
+		expr, err := NewParser(s).ParseExpr()
+		require.NoError(t, err)
+		return expr
+	}
+
+	a := parse("evt.name = 'CreateFile' and (file.name = 'a.exe' or file.extension = '.dll')")
+	b := parse("ps.name = 'cmd.exe' and (file.name = 'a.exe' or file.extension = '.dll')")
+	c := parse("evt.name = 'CreateFile' and (file.name = 'b.exe' or file.extension = '.dll')")
+
+	require.Equal(t, 1, Share(&a, &b, &c))
+
+	shared, ok := a.(*BinaryExpr).RHS.(*SharedExpr)
+	require.True(t, ok)
+	assert.Same(t, shared, b.(*BinaryExpr).RHS)
+	assert.IsType(t, &ParenExpr{}, c.(*BinaryExpr).RHS)
+	assert.Equal(t, "evt.name = CreateFile AND (file.name = a.exe OR file.extension = .dll)", a.String())
+
+	valuer := &resultCacheValuer{
+		MapValuer: MapValuer{"evt.name": "CreateFile", "ps.name": "cmd.exe", "file.name": "a.exe", "file.extension": ".exe"},
+		results:   make(map[string]interface{}),
+	}
+	assert.True(t, EvalValuer(a, valuer, false, nil))
+	assert.True(t, EvalValuer(b, valuer, true, nil))
+	assert.False(t, EvalValuer(c, valuer, false, nil))
+	assert.Equal(t, 1, valuer.hits)
+
+	// string literals are not confused with fields
+	d := parse("evt.pid = 4 and (file.name = 'ps.name' or file.extension = '.dll')")
+	e := parse("evt.pid = 4 and (file.name = ps.name or file.extension = '.dll')")
+	assert.Equal(t, 0, Share(&d, &e))
+
+	// predicates with pattern or list operands are shared
+	f := parse("evt.name = 'CreateFile' and file.path imatches '*.dll' and file.extension in ('.dll', '.exe')")
+	g := parse("ps.name = 'cmd.exe' and file.path imatches '*.dll'")
+	h := parse("ps.name = 'rundll32.exe' and file.extension not in ('.dll', '.exe')")
+	require.Equal(t, 2, Share(&f, &g, &h))
+	assert.Same(t, g.(*BinaryExpr).RHS, f.(*BinaryExpr).LHS.(*BinaryExpr).RHS)
+	assert.IsType(t, &SharedExpr{}, h.(*BinaryExpr).RHS.(*NotExpr).Expr)
+	assert.Equal(t, "ps.name = rundll32.exe AND NOT file.extension IN (.dll, .exe)", h.String())
+
+	// subexpressions calling stateful functions are not shared
+	i := parse("evt.name = 'CreateProcess' and (ps.name = 'cmd.exe' or first_seen('procs', ps.name))")
+	j := parse("evt.name = 'LoadModule' and (ps.name = 'cmd.exe' or first_seen('procs', ps.name))")
+	assert.Equal(t, 0, Share(&i, &j))
+}
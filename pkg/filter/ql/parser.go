@@ -24,12 +24,14 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/rabbitstack/fibratus/pkg/config"
 	"github.com/rabbitstack/fibratus/pkg/filter/fields"
+	"github.com/rabbitstack/fibratus/pkg/filter/ql/functions"
 	"github.com/rabbitstack/fibratus/pkg/util/multierror"
 )
 
@@ -38,6 +40,22 @@ type Parser struct {
 	s    *bufScanner
 	c    *config.Filters
 	expr string
+	// args contains arguments bound to the parameters
+	// of the function macro being expanded
+	args map[string]macroArg
+	// calls is the stack of function macros being
+	// expanded used to detect recursive invocations
+	calls []string
+}
+
+// macroArg represents the argument given to the function macro.
+// The argument source is parsed each time the parameter is referenced
+// in the macro expression, so the expanded expressions don't share
+// nodes. Arguments are parsed in the scope of the caller.
+type macroArg struct {
+	src   string
+	args  map[string]macroArg
+	calls []string
 }
 
 // NewParser builds a new parser instance from the expression string.
@@ -50,9 +68,42 @@ func NewParserWithConfig(expr string, config *config.Filters) *Parser {
 	return &Parser{s: newBufScanner(strings.NewReader(expr)), expr: expr, c: config}
 }
 
+// sequences returns the sequence limits or nil
+// if the parser is not initialized with config.
+func (p *Parser) sequences() *config.Sequences {
+	if p.c == nil {
+		return nil
+	}
+	return &p.c.Sequences
+}
+
+// thresholds returns the threshold limits or nil
+// if the parser is not initialized with config.
+func (p *Parser) thresholds() *config.Thresholds {
+	if p.c == nil {
+		return nil
+	}
+	return &p.c.Thresholds
+}
+
+// formatDuration strips zero minutes and seconds
+// units from the duration string, e.g. 4h0m0s is
+// formatted as 4h.
+func formatDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
 // ParseSequence parses the collection of binary expressions with possible join
-// statements and time frame constraints. This method assumes the SEQUENCE token
-// has already been consumed.
+// statements and time frame constraints. Expressions prefixed with the bang
+// denote the absence of the event between the adjacent expressions. This method
+// assumes the SEQUENCE token has already been consumed.
 func (p *Parser) ParseSequence() (*Sequence, error) {
 	seq := &Sequence{}
 	var exprs []SequenceExpr
@@ -65,8 +116,8 @@ func (p *Parser) ParseSequence() (*Sequence, error) {
 		if err != nil {
 			return nil, err
 		}
-		if seq.MaxSpan > time.Hour*4 {
-			return nil, fmt.Errorf("maximum span %v cannot be greater than 4h", seq.MaxSpan)
+		if maxSpan := p.sequences().GetMaxSpan(); seq.MaxSpan > maxSpan {
+			return nil, fmt.Errorf("maximum span %v cannot be greater than %s", seq.MaxSpan, formatDuration(maxSpan))
 		}
 	} else {
 		p.unscan()
@@ -75,38 +126,11 @@ func (p *Parser) ParseSequence() (*Sequence, error) {
 	// parse optional global link
 	tok, _, _ = p.scanIgnoreWhitespace()
 	if tok == By {
-		tok, pos, lit := p.scanIgnoreWhitespace()
-		if !fields.IsField(lit) {
-			return nil, newParseError(tokstr(tok, lit), []string{"field"}, pos, p.expr)
-		}
 		var err error
-		field, err := p.parseField(lit)
+		seq.By, err = p.parseLink()
 		if err != nil {
 			return nil, err
 		}
-
-		seqLink := &SequenceLink{Fields: []*FieldLiteral{field}}
-
-		// handle multiple join fields separated by comma
-		for {
-			if tok, _, _ := p.scanIgnoreWhitespace(); tok != Comma {
-				p.unscan()
-				break
-			}
-
-			tok, pos, lit := p.scanIgnoreWhitespace()
-			if !fields.IsField(lit) {
-				return nil, newParseError(tokstr(tok, lit), []string{"field"}, pos, p.expr)
-			}
-			field, err := p.parseField(lit)
-			if err != nil {
-				return nil, err
-			}
-
-			seqLink.Fields = append(seqLink.Fields, field)
-		}
-
-		seq.By = seqLink
 	} else {
 		p.unscan()
 	}
@@ -118,10 +142,16 @@ func (p *Parser) ParseSequence() (*Sequence, error) {
 				return nil, fmt.Errorf("%s: sequences require at least two expressions", p.expr)
 			}
 
-			const maxExpressions = 5
-			if len(exprs) > maxExpressions {
+			if len(exprs) > p.sequences().GetMaxExpressions() {
 				return nil, fmt.Errorf("%s: maximum number of expressions reached", p.expr)
 			}
+			if exprs[0].IsNegated {
+				return nil, fmt.Errorf("%s: the first expression in the sequence can't be negated", p.expr)
+			}
+			if exprs[len(exprs)-1].IsNegated && seq.MaxSpan == 0 {
+				return nil, fmt.Errorf("%s: negated trailing expression requires the 'maxspan' statement", p.expr)
+			}
+
 			seq.Expressions = exprs
 			if seq.impairBy() {
 				return nil, fmt.Errorf("%s: all expressions require the 'by' statement", p.expr)
@@ -136,7 +166,14 @@ func (p *Parser) ParseSequence() (*Sequence, error) {
 		}
 		p.unscan()
 
+		// the bang in front of the expression
+		// denotes the absence of the event
+		var isNegated bool
 		tok, posStart, lit := p.scanIgnoreWhitespace()
+		if tok == Bang {
+			isNegated = true
+			tok, posStart, lit = p.scan()
+		}
 		if tok != Pipe {
 			return nil, newParseError(tokstr(tok, lit), []string{"|"}, posStart, p.expr)
 		}
@@ -155,53 +192,107 @@ func (p *Parser) ParseSequence() (*Sequence, error) {
 		tok, _, _ = p.scanIgnoreWhitespace()
 		switch tok {
 		case By:
-			tok, pos, lit := p.scanIgnoreWhitespace()
-			if !fields.IsField(lit) {
-				return nil, newParseError(tokstr(tok, lit), []string{"field"}, pos, p.expr)
-			}
-			field, err := p.parseField(lit)
+			seqLink, err := p.parseLink()
 			if err != nil {
 				return nil, err
 			}
-
-			seqLink := &SequenceLink{Fields: []*FieldLiteral{field}}
-
-			// handle multiple join fields separated by comma
-			for {
-				if tok, _, _ := p.scanIgnoreWhitespace(); tok != Comma {
-					p.unscan()
-					break
-				}
-
-				tok, pos, lit := p.scanIgnoreWhitespace()
-				if !fields.IsField(lit) {
-					return nil, newParseError(tokstr(tok, lit), []string{"field"}, pos, p.expr)
-				}
-				field, err := p.parseField(lit)
-				if err != nil {
-					return nil, err
-				}
-
-				seqLink.Fields = append(seqLink.Fields, field)
-			}
 			seqexpr = SequenceExpr{Expr: expr, By: seqLink}
 		case As:
 			tok, pos, lit := p.scanIgnoreWhitespace()
 			if tok != Ident {
 				return nil, newParseError(tokstr(tok, lit), []string{"identifier"}, pos, p.expr)
 			}
+			if isNegated {
+				return nil, fmt.Errorf("%s: negated expressions can't be aliased", p.expr)
+			}
 			seqexpr = SequenceExpr{Expr: expr, Alias: lit}
 		default:
 			seqexpr = SequenceExpr{Expr: expr}
 			p.unscan()
 		}
 
+		seqexpr.IsNegated = isNegated
 		seqexpr.init()
 		seqexpr.walk()
 		exprs = append(exprs, seqexpr)
 	}
 }
 
+// ParseThreshold parses the threshold expression with the required
+// event count and time window, optional group-by fields, and the
+// expression enclosed in pipes. This method assumes the THRESHOLD
+// token has already been consumed.
+func (p *Parser) ParseThreshold() (*Threshold, error) {
+	threshold := &Threshold{}
+
+	// parse the number of events
+	tok, pos, lit := p.scanIgnoreWhitespace()
+	if tok != Integer {
+		return nil, newParseError(tokstr(tok, lit), []string{"integer"}, pos, p.expr)
+	}
+	n, err := strconv.ParseUint(lit, 10, 64)
+	if err != nil || n == 0 {
+		return nil, &ParseError{Message: "threshold count must be a positive integer", Pos: pos}
+	}
+	threshold.Count = n
+
+	// parse the time window
+	tok, pos, lit = p.scanIgnoreWhitespace()
+	if tok != Within {
+		return nil, newParseError(tokstr(tok, lit), []string{"within"}, pos, p.expr)
+	}
+	threshold.Within, err = p.parseDuration()
+	if err != nil {
+		return nil, err
+	}
+	if threshold.Within <= 0 {
+		return nil, fmt.Errorf("%s: threshold time window must be greater than zero", p.expr)
+	}
+	if maxWindow := p.thresholds().GetMaxWindow(); threshold.Within > maxWindow {
+		return nil, fmt.Errorf("%s: threshold time window %v cannot be greater than %s", p.expr, threshold.Within, formatDuration(maxWindow))
+	}
+
+	// parse optional group-by fields
+	tok, _, _ = p.scanIgnoreWhitespace()
+	if tok == By {
+		threshold.By, err = p.parseLink()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		p.unscan()
+	}
+
+	tok, pos, lit = p.scanIgnoreWhitespace()
+	if tok != Pipe {
+		return nil, newParseError(tokstr(tok, lit), []string{"|"}, pos, p.expr)
+	}
+	threshold.Expr, err = p.ParseExpr()
+	if err != nil {
+		return nil, err
+	}
+	tok, pos, lit = p.scanIgnoreWhitespace()
+	if tok != Pipe {
+		return nil, newParseError(tokstr(tok, lit), []string{"|"}, pos, p.expr)
+	}
+
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != EOF {
+		return nil, newParseError(tokstr(tok, lit), []string{"EOF"}, pos, p.expr)
+	}
+
+	return threshold, nil
+}
+
+// IsThreshold checks whether the expression given to the parser is a threshold.
+func (p *Parser) IsThreshold() bool {
+	tok, _, _ := p.scanIgnoreWhitespace()
+	if tok == Thresh {
+		return true
+	}
+	p.unscan()
+	return false
+}
+
 // IsSequence checks whether the expression given to the parser is a sequence.
 func (p *Parser) IsSequence() bool {
 	tok, _, _ := p.scanIgnoreWhitespace()
@@ -236,10 +327,12 @@ func (p *Parser) ParseExpr() (Expr, error) {
 		}
 
 		if op == In || op == IIn {
+			op = p.scanInRange(op)
 			// expect LPAREN after in
 			tok, pos, lit := p.scanIgnoreWhitespace()
 			p.unscan()
-			if tok != Lparen && (p.c != nil && !p.c.IsMacroList(lit)) {
+			_, isParam := p.args[lit]
+			if tok != Lparen && !isParam && (p.c != nil && !p.c.IsMacroList(lit)) {
 				return nil, newParseError(tokstr(op, lit), []string{"'('"}, pos, p.expr)
 			}
 		}
@@ -250,7 +343,8 @@ func (p *Parser) ParseExpr() (Expr, error) {
 			if !op1.isOperator() {
 				return nil, newParseError(tokstr(op1, lit), []string{"operator"}, pos, p.expr)
 			}
-			rhs, err := p.parseUnaryExpr()
+			op1 = p.scanInRange(op1)
+			rhs, err := p.parseOperand(op1)
 			if err != nil {
 				return nil, err
 			}
@@ -266,7 +360,7 @@ func (p *Parser) ParseExpr() (Expr, error) {
 			continue
 		}
 
-		rhs, err := p.parseUnaryExpr()
+		rhs, err := p.parseOperand(op)
 		if err != nil {
 			return nil, err
 		}
@@ -287,6 +381,75 @@ func (p *Parser) ParseExpr() (Expr, error) {
 	}
 }
 
+// scanInRange turns the in operator into the in range
+// operator if the operator is followed by the range keyword.
+func (p *Parser) scanInRange(op Token) Token {
+	if op != In {
+		return op
+	}
+	tok, _, lit := p.scanIgnoreWhitespace()
+	if tok == Ident && strings.EqualFold(lit, "range") {
+		return InRange
+	}
+	p.unscan()
+	return op
+}
+
+// parseOperand parses the right-hand side of the binary expression.
+// The between operator expects lower and upper bounds separated by
+// the AND keyword, while the in range operator requires the list of
+// numeric ranges or CIDR blocks.
+func (p *Parser) parseOperand(op Token) (Expr, error) {
+	switch op {
+	case Between:
+		low, err := p.parseRangeBound()
+		if err != nil {
+			return nil, err
+		}
+		if tok, pos, lit := p.scanIgnoreWhitespace(); tok != And {
+			return nil, newParseError(tokstr(tok, lit), []string{"and"}, pos, p.expr)
+		}
+		high, err := p.parseRangeBound()
+		if err != nil {
+			return nil, err
+		}
+		return &RangeLiteral{Low: low, High: high}, nil
+	case InRange:
+		tok, pos, lit := p.scanIgnoreWhitespace()
+		p.unscan()
+		expr, err := p.parseUnaryExpr()
+		if err != nil {
+			return nil, err
+		}
+		list, ok := expr.(*ListLiteral)
+		if !ok {
+			return nil, newParseError(tokstr(tok, lit), []string{"list of ranges"}, pos, p.expr)
+		}
+		ranges, err := newRangesLiteral(list.Values)
+		if err != nil {
+			return nil, &ParseError{Message: err.Error(), Pos: pos}
+		}
+		return ranges, nil
+	}
+	return p.parseUnaryExpr()
+}
+
+// parseRangeBound parses the bound of the between operator.
+// Bounds are numbers, durations, or strings.
+func (p *Parser) parseRangeBound() (Expr, error) {
+	tok, pos, lit := p.scanIgnoreWhitespace()
+	p.unscan()
+	expr, err := p.parseUnaryExpr()
+	if err != nil {
+		return nil, err
+	}
+	switch expr.(type) {
+	case *IntegerLiteral, *UnsignedLiteral, *DecimalLiteral, *DurationLiteral, *DateTimeLiteral, *StringLiteral:
+		return expr, nil
+	}
+	return nil, newParseError(tokstr(tok, lit), []string{"number", "duration", "datetime", "string"}, pos, p.expr)
+}
+
 // parseUnaryExpr parses an non-binary expression.
 func (p *Parser) parseUnaryExpr() (Expr, error) {
 	// If the first token is a LPAREN then parse it as its own grouped expression.
@@ -335,11 +498,24 @@ func (p *Parser) parseUnaryExpr() (Expr, error) {
 	tok, pos, lit := p.scanIgnoreWhitespace()
 	switch tok {
 	case Ident:
+		// substitute the function macro parameter
+		if arg, ok := p.args[lit]; ok {
+			return p.parseMacroArg(arg)
+		}
+
 		if fields.IsField(lit) {
 			return p.parseField(lit)
 		}
 
 		if tok0, _, _ := p.scan(); tok0 == Lparen {
+			if strings.EqualFold(lit, "datetime") {
+				return p.parseDateTime()
+			}
+			if p.c != nil {
+				if macro := p.c.GetMacro(lit); macro != nil && macro.IsFunction() {
+					return p.parseFunctionMacro(macro)
+				}
+			}
 			return p.parseFunction(lit)
 		}
 		// unscan lparen token
@@ -349,6 +525,9 @@ func (p *Parser) parseUnaryExpr() (Expr, error) {
 		if p.c != nil {
 			macro := p.c.GetMacro(lit)
 			if macro != nil {
+				if macro.IsFunction() {
+					return nil, newParseError(tokstr(tok, lit), []string{"'(' after function macro"}, pos, p.expr)
+				}
 				if macro.Expr != "" {
 					p := NewParserWithConfig(macro.Expr, p.c)
 					expr, err := p.ParseExpr()
@@ -390,6 +569,23 @@ func (p *Parser) parseUnaryExpr() (Expr, error) {
 		return nil, newParseError(tokstr(tok, lit), []string{"field/segment after bound ref"}, pos+n, p.expr)
 	case True, False:
 		return &BoolLiteral{Value: tok == True}, nil
+	case Any, All:
+		// the quantifier must be followed by the field
+		tok0, pos0, lit0 := p.scanIgnoreWhitespace()
+		if tok0 != Ident || !fields.IsField(lit0) {
+			return nil, newParseError(tokstr(tok0, lit0), []string{"field"}, pos0, p.expr)
+		}
+		field, err := p.parseField(lit0)
+		if err != nil {
+			return nil, err
+		}
+		return &QuantifierExpr{Quantifier: tok, Field: field}, nil
+	case Duration:
+		d, err := parseDuration(lit)
+		if err != nil {
+			return nil, &ParseError{Message: err.Error(), Pos: pos}
+		}
+		return &DurationLiteral{Value: d}, nil
 	case Integer:
 		v, err := strconv.ParseInt(lit, 10, 64)
 		if err != nil {
@@ -420,6 +616,43 @@ func (p *Parser) parseUnaryExpr() (Expr, error) {
 	return nil, newParseError(tokstr(tok, lit), expectations, pos, p.expr)
 }
 
+// parseLink parses one or multiple comma-separated fields
+// that follow the BY keyword. This method assumes the BY
+// token has already been consumed.
+func (p *Parser) parseLink() (*SequenceLink, error) {
+	tok, pos, lit := p.scanIgnoreWhitespace()
+	if !fields.IsField(lit) {
+		return nil, newParseError(tokstr(tok, lit), []string{"field"}, pos, p.expr)
+	}
+	field, err := p.parseField(lit)
+	if err != nil {
+		return nil, err
+	}
+
+	link := &SequenceLink{Fields: []*FieldLiteral{field}}
+
+	// handle multiple join fields separated by comma
+	for {
+		if tok, _, _ := p.scanIgnoreWhitespace(); tok != Comma {
+			p.unscan()
+			break
+		}
+
+		tok, pos, lit := p.scanIgnoreWhitespace()
+		if !fields.IsField(lit) {
+			return nil, newParseError(tokstr(tok, lit), []string{"field"}, pos, p.expr)
+		}
+		field, err := p.parseField(lit)
+		if err != nil {
+			return nil, err
+		}
+
+		link.Fields = append(link.Fields, field)
+	}
+
+	return link, nil
+}
+
 // parseField parses the field and its argument. This method
 // assumes the field name has been consumed.
 func (p *Parser) parseField(name string) (*FieldLiteral, error) {
@@ -486,57 +719,136 @@ func (p *Parser) parseList() ([]string, error) {
 	}
 }
 
+// parseDateTime parses the datetime literal given as the quoted
+// RFC3339 timestamp, date/time, or date string. This method assumes
+// the datetime keyword and LPAREN have been consumed.
+func (p *Parser) parseDateTime() (*DateTimeLiteral, error) {
+	tok, pos, lit := p.scanIgnoreWhitespace()
+	if tok != Str {
+		return nil, newParseError(tokstr(tok, lit), []string{"datetime string"}, pos, p.expr)
+	}
+	t, ok := functions.ParseDateTime(lit)
+	if !ok {
+		return nil, &ParseError{Message: fmt.Sprintf("invalid datetime %q", lit), Pos: pos}
+	}
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != Rparen {
+		return nil, newParseError(tokstr(tok, lit), []string{")"}, pos, p.expr)
+	}
+	return &DateTimeLiteral{Value: t}, nil
+}
+
 // parseFunction parses a function call. This method assumes
 // the function name and LPAREN have been consumed.
 func (p *Parser) parseFunction(name string) (*Function, error) {
-	name = strings.ToLower(name)
-	args := make([]Expr, 0)
+	args, _, err := p.parseArgs()
+	if err != nil {
+		return nil, err
+	}
+
+	fn := &Function{Name: strings.ToLower(name), Args: args}
+
+	if err := fn.validate(); err != nil {
+		return nil, err
+	}
+
+	return fn, nil
+}
 
+// parseArgs parses the comma-separated list of function arguments
+// followed by the right parentheses. Along with parsed arguments, it
+// returns the source string of each argument.
+func (p *Parser) parseArgs() ([]Expr, []string, error) {
 	// If there's a right paren then just return immediately.
 	// This is the case for functions without arguments
 	if tok, _, _ := p.scan(); tok == Rparen {
-		fn := &Function{Name: name}
-		if err := fn.validate(); err != nil {
-			return nil, err
-		}
-		return fn, nil
+		return nil, nil, nil
 	}
 	p.unscan()
 
-	arg, err := p.ParseExpr()
-	if err != nil {
-		return nil, err
-	}
-	args = append(args, arg)
+	args := make([]Expr, 0)
+	srcs := make([]string, 0)
+	expr := []rune(p.expr)
 
-	// Parse additional function arguments if there is a comma.
 	for {
-		// If there's not a comma, stop parsing arguments.
-		if tok, _, _ := p.scanIgnoreWhitespace(); tok != Comma {
-			p.unscan()
-			break
-		}
+		_, start, _ := p.scanIgnoreWhitespace()
+		p.unscan()
 
 		// Parse an expression argument.
 		arg, err := p.ParseExpr()
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		args = append(args, arg)
+
+		// If there's not a comma, stop parsing arguments.
+		tok, end, _ := p.scanIgnoreWhitespace()
+		if start < end && end <= len(expr) {
+			srcs = append(srcs, strings.TrimSpace(string(expr[start:end])))
+		} else {
+			srcs = append(srcs, "")
+		}
+		if tok != Comma {
+			p.unscan()
+			break
+		}
 	}
 
 	// There should be a right parentheses at the end.
 	if tok, pos, lit := p.scan(); tok != Rparen {
-		return nil, newParseError(tokstr(tok, lit), []string{")"}, pos, p.expr)
+		return nil, nil, newParseError(tokstr(tok, lit), []string{")"}, pos, p.expr)
 	}
 
-	fn := &Function{Name: name, Args: args}
+	return args, srcs, nil
+}
 
-	if err := fn.validate(); err != nil {
+// parseFunctionMacro expands the function macro. Arguments are checked
+// against the parameter types, and the macro expression is parsed with
+// the parameters bound to arguments. This method assumes the function
+// name and LPAREN have been consumed.
+func (p *Parser) parseFunctionMacro(macro *config.Macro) (Expr, error) {
+	if _, ok := funcs[strings.ToUpper(macro.ID)]; ok {
+		return nil, ErrMacroShadowsFunction(macro.ID)
+	}
+	if slices.Contains(p.calls, macro.ID) {
+		return nil, ErrMacroRecursion(macro.ID)
+	}
+
+	args, srcs, err := p.parseArgs()
+	if err != nil {
+		return nil, err
+	}
+	if err := validateMacroArgs(macro, args); err != nil {
 		return nil, err
 	}
 
-	return fn, nil
+	parser := NewParserWithConfig(macro.Expr, p.c)
+	parser.args = make(map[string]macroArg, len(macro.Params))
+	parser.calls = append(slices.Clone(p.calls), macro.ID)
+	for i, param := range macro.Params {
+		parser.args[param.Name] = macroArg{src: srcs[i], args: p.args, calls: p.calls}
+	}
+
+	expr, err := parser.ParseExpr()
+	if err != nil {
+		return nil, multierror.WrapWithSeparator("\n", fmt.Errorf("syntax error in %q function macro", macro.ID), err)
+	}
+	return &ParenExpr{Expr: expr}, nil
+}
+
+// parseMacroArg parses the function macro argument. Binary
+// expressions are parenthesized to retain the precedence
+// of the argument in the macro expression.
+func (p *Parser) parseMacroArg(arg macroArg) (Expr, error) {
+	parser := NewParserWithConfig(arg.src, p.c)
+	parser.args, parser.calls = arg.args, arg.calls
+	expr, err := parser.ParseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := expr.(*BinaryExpr); ok {
+		return &ParenExpr{Expr: expr}, nil
+	}
+	return expr, nil
 }
 
 // parseDuration parses a string and returns a duration literal.
@@ -21,6 +21,8 @@ package ql
 import (
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -60,7 +62,7 @@ func TestParser(t *testing.T) {
 		{expr: "ps.none = 'cmd.exe'", err: errors.New("ps.none = 'cmd.exe'\n╭^\n|\n|\n╰─────────────────── expected field, bound field, string, number, bool, ip, function")},
 
 		{expr: "ps.name = 'cmd.exe' AND ps.name IN ('exe') ps.name", err: errors.New("ps.name = 'cmd.exe' AND ps.name IN ('exe') ps.name\n╭──────────────────────────────────────────^\n|\n|\n╰─────────────────── expected operator, ')', ',', '|'")},
-		{expr: "ip_cidr(net.dip) = '24'", err: errors.New("ip_cidr function is undefined. Did you mean one of BASE|CIDR_CONTAINS|CONCAT|COUNT|DIR|ENTROPY|EXT|FOREACH|GET_REG_VALUE|GLOB|INDEXOF|IS_ABS|IS_MINIDUMP|LENGTH|LOWER|LTRIM|MD5|REGEX|REPLACE|RTRIM|SPLIT|SUBSTR|UNDEFINED|UPPER|VOLUME|YARA?")},
+		{expr: "ip_cidr(net.dip) = '24'", err: errors.New("ip_cidr function is undefined. Did you mean one of AGE|BASE|CIDR_CONTAINS|CONCAT|COUNT|DIR|ENTROPY|EXT|FOREACH|GET_REG_VALUE|GLOB|INDEXOF|IS_ABS|IS_MINIDUMP|LENGTH|LOWER|LTRIM|MD5|NOW|REGEX|REPLACE|RTRIM|SPLIT|SUBSTR|TIME_DIFF|UNDEFINED|UPPER|VOLUME|YARA?")},
 
 		{expr: "ps.name = 'cmd.exe' and not cidr_contains(net.sip, '172.14.0.0')"},
 		{expr: "ps.name = 'cmd.exe' and ps.exe not imatches '?:\\\\Windows'"},
@@ -76,6 +78,15 @@ func TestParser(t *testing.T) {
 		{expr: `evt.arg[Name$] = 'svchost.exe'`, err: errors.New("evt.arg[Name$] = 'svchost.exe'\n╭───────^\n|\n|\n╰─────────────────── expected a valid field argument matching the pattern [a-z0-9_]+")},
 		{expr: `ps.ancestor[0] = 'svchost.exe'`},
 		{expr: `ps.ancestor[l0l] = 'svchost.exe'`, err: errors.New("ps.ancestor[l0l] = 'svchost.exe'\n╭───────────^\n|\n|\n╰─────────────────── expected a valid field argument matching the pattern [0-9]+")},
+		{expr: "ps.pid between 100 and 200"},
+		{expr: "evt.time between '2024-01-01' and '2024-12-31 23:59:59'"},
+		{expr: "ps.pid not between 100 and 200"},
+		{expr: "net.dport in range ('1-1024', '8080')"},
+		{expr: "net.dip not in range ('10.0.0.0/8', '192.168.0.0/16')"},
+		{expr: "any ps.modules imatches '?:\\\\Windows\\\\System32\\\\*.dll'"},
+		{expr: "all ps.modules iendswith ('.dll', '.exe')"},
+		{expr: "ps.pid between 100", err: errors.New("ps.pid between 100\n╭──────────────────^\n|\n|\n╰─────────────────── expected and")},
+		{expr: "ps.pid between ps.ppid and 200", err: errors.New("ps.pid between ps.ppid and 200\n╭──────────────^\n|\n|\n╰─────────────────── expected number, duration, datetime, string")},
 	}
 
 	for i, tt := range tests {
@@ -230,6 +241,128 @@ func TestExpandMacros(t *testing.T) {
 	}
 }
 
+func TestExpandFunctionMacros(t *testing.T) {
+	c := config.FiltersWithMacros(map[string]*config.Macro{
+		"spawn_process": {Expr: "evt.name = 'CreateProcess'"},
+		"lolbins":       {List: []string{"rundll32.exe", "regsvr32.exe"}},
+		"is_lolbin": {
+			ID:       "is_lolbin",
+			Function: "is_lolbin(path)",
+			Expr:     "base(path) iin lolbins",
+			Params:   []config.MacroParam{{Name: "path", Type: "any"}},
+		},
+		"in_port_range": {
+			ID:       "in_port_range",
+			Function: "in_port_range(port number, low number, high number)",
+			Expr:     "port >= low and port <= high",
+			Params:   []config.MacroParam{{Name: "port", Type: "number"}, {Name: "low", Type: "number"}, {Name: "high", Type: "number"}},
+		},
+		"lolbin_spawned": {
+			ID:       "lolbin_spawned",
+			Function: "lolbin_spawned(exe field)",
+			Expr:     "spawn_process and is_lolbin(exe)",
+			Params:   []config.MacroParam{{Name: "exe", Type: "field"}},
+		},
+		"either": {
+			ID:       "either",
+			Function: "either(a bool, b bool)",
+			Expr:     "a or b",
+			Params:   []config.MacroParam{{Name: "a", Type: "bool"}, {Name: "b", Type: "bool"}},
+		},
+		"loop": {
+			ID:       "loop",
+			Function: "loop(x)",
+			Expr:     "loop(x) = 'a'",
+			Params:   []config.MacroParam{{Name: "x", Type: "any"}},
+		},
+		"upper": {
+			ID:       "upper",
+			Function: "upper(x)",
+			Expr:     "x = 'A'",
+			Params:   []config.MacroParam{{Name: "x", Type: "any"}},
+		},
+	})
+
+	var tests = []struct {
+		expr         string
+		expectedExpr string
+		err          error
+	}{
+		{
+			"is_lolbin(ps.exe) and ps.name = 'cmd.exe'",
+			"(base(ps.exe) IIN (rundll32.exe, regsvr32.exe)) AND ps.name = cmd.exe",
+			nil,
+		},
+		{
+			"in_port_range(net.dport, 1, 1024)",
+			"(net.dport >= 1 AND net.dport <= 1024)",
+			nil,
+		},
+		{
+			"lolbin_spawned(ps.exe)",
+			"(evt.name = CreateProcess AND (base(ps.exe) IIN (rundll32.exe, regsvr32.exe)))",
+			nil,
+		},
+		{
+			"either(ps.name = 'cmd.exe' and ps.pid > 4, ps.name = 'pwsh.exe')",
+			"((ps.name = cmd.exe AND ps.pid > 4) OR (ps.name = pwsh.exe))",
+			nil,
+		},
+		{
+			"in_port_range(net.dport, 1.5, 1024)",
+			"(net.dport >= 1.5e+00 AND net.dport <= 1024)",
+			nil,
+		},
+		{
+			"lolbin_spawned('cmd.exe')",
+			"",
+			errors.New("argument #1 (exe) in function LOLBIN_SPAWNED should be one of: field|boundfield"),
+		},
+		{
+			"in_port_range(net.dport, 1)",
+			"",
+			errors.New("in_port_range function requires 3 argument(s) but 2 argument(s) given"),
+		},
+		{
+			"loop(ps.exe)",
+			"",
+			errors.New("syntax error in \"loop\" function macro\nloop function macro is invoked recursively"),
+		},
+		{
+			"upper(ps.exe)",
+			"",
+			errors.New("upper function macro shadows the built-in function"),
+		},
+		{
+			"is_lolbin and ps.name = 'cmd.exe'",
+			"",
+			errors.New("is_lolbin and ps.name = 'cmd.exe'\n╭^\n|\n|\n╰─────────────────── expected '(' after function macro"),
+		},
+	}
+
+	for i, tt := range tests {
+		p := NewParserWithConfig(tt.expr, c)
+		expr, err := p.ParseExpr()
+		if tt.err != nil {
+			require.Error(t, err, "%d. %s", i, tt.expr)
+			assert.EqualError(t, err, tt.err.Error())
+			continue
+		}
+		require.NoError(t, err, "%d. %s", i, tt.expr)
+		assert.Equal(t, tt.expectedExpr, expr.String())
+	}
+}
+
+func TestLoadFunctionMacroShadowingFunction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "macros.yml")
+	require.NoError(t, os.WriteFile(path, []byte("- function: base(path)\n  expr: path = 'cmd.exe'\n"), 0644))
+
+	filters := &config.Filters{Macros: config.Macros{FromPaths: []string{path}}}
+	err := filters.LoadMacros()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "base function macro shadows the built-in function")
+}
+
 func TestParseSequence(t *testing.T) {
 	var tests = []struct {
 		expr          string
@@ -392,6 +525,57 @@ func TestParseSequence(t *testing.T) {
 			time.Minute * 2,
 			true,
 		},
+		{
+
+			`maxspan 2m
+			 by ps.uuid
+			 |evt.name = 'CreateProcess'|
+			 !|evt.name = 'LoadModule' and module.signature.type != 'NONE'|
+			 |evt.name = 'Connect'|
+			`,
+			nil,
+			time.Minute * 2,
+			true,
+		},
+		{
+
+			`maxspan 5m
+			 |evt.name = 'CreateFile'| by file.path
+			 !|evt.name = 'DeleteFile'| by file.path
+			`,
+			nil,
+			time.Minute * 5,
+			true,
+		},
+		{
+
+			`!|evt.name = 'CreateFile'|
+			 |evt.name = 'DeleteFile'|
+			`,
+			errors.New("the first expression in the sequence can't be negated"),
+			time.Duration(0),
+			false,
+		},
+		{
+
+			`|evt.name = 'CreateFile'|
+			 !|evt.name = 'DeleteFile'|
+			`,
+			errors.New("negated trailing expression requires the 'maxspan' statement"),
+			time.Duration(0),
+			false,
+		},
+		{
+
+			`maxspan 1m
+			 |evt.name = 'CreateFile'|
+			 !|evt.name = 'DeleteFile'| as e1
+			 |evt.name = 'CreateProcess'|
+			`,
+			errors.New("negated expressions can't be aliased"),
+			time.Minute,
+			false,
+		},
 	}
 
 	for i, tt := range tests {
@@ -418,6 +602,33 @@ func TestParseSequence(t *testing.T) {
 	}
 }
 
+func TestParseSequenceLimits(t *testing.T) {
+	expr := `maxspan 2d
+|evt.name = 'CreateProcess'| by ps.uuid
+|evt.name = 'CreateFile'| by ps.uuid
+|evt.name = 'RegSetValue'| by ps.uuid
+|evt.name = 'LoadImage'| by ps.uuid
+|evt.name = 'OpenProcess'| by ps.uuid
+|evt.name = 'Connect'| by ps.uuid
+`
+	_, err := NewParser(expr).ParseSequence()
+	require.EqualError(t, err, "maximum span 48h0m0s cannot be greater than 4h")
+
+	c := &config.Filters{Sequences: config.Sequences{MaxSpan: time.Hour * 72, MaxExpressions: 6}}
+	seq, err := NewParserWithConfig(expr, c).ParseSequence()
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour*48, seq.MaxSpan)
+	assert.Len(t, seq.Expressions, 6)
+
+	c.Sequences.MaxExpressions = 5
+	_, err = NewParserWithConfig(expr, c).ParseSequence()
+	require.ErrorContains(t, err, "maximum number of expressions reached")
+
+	c.Sequences.MaxSpan = time.Hour * 24
+	_, err = NewParserWithConfig(expr, c).ParseSequence()
+	require.EqualError(t, err, "maximum span 48h0m0s cannot be greater than 24h")
+}
+
 func TestIsSequenceUnordered(t *testing.T) {
 	var tests = []struct {
 		expr        string
@@ -490,3 +701,124 @@ func TestIsSequenceUnordered(t *testing.T) {
 		}
 	}
 }
+
+func TestParseThreshold(t *testing.T) {
+	var tests = []struct {
+		expr      string
+		err       error
+		count     uint64
+		within    time.Duration
+		isGrouped bool
+	}{
+		{
+			`50 within 30s by ps.uuid |evt.name = 'CreateFile' and file.extension = '.encrypted'|`,
+			nil,
+			50,
+			time.Second * 30,
+			true,
+		},
+		{
+			`10 within 1m |evt.name = 'CreateFile'|`,
+			nil,
+			10,
+			time.Minute,
+			false,
+		},
+		{
+			`10 within 1m by ps.exe, file.name |evt.name = 'CreateFile'|`,
+			nil,
+			10,
+			time.Minute,
+			true,
+		},
+		{
+			`within 1m |evt.name = 'CreateFile'|`,
+			errors.New("expected integer"),
+			0,
+			0,
+			false,
+		},
+		{
+			`0 within 1m |evt.name = 'CreateFile'|`,
+			errors.New("threshold count must be a positive integer"),
+			0,
+			0,
+			false,
+		},
+		{
+			`10 |evt.name = 'CreateFile'|`,
+			errors.New("expected within"),
+			0,
+			0,
+			false,
+		},
+		{
+			`10 within 5h |evt.name = 'CreateFile'|`,
+			errors.New("threshold time window 5h0m0s cannot be greater than 4h"),
+			0,
+			0,
+			false,
+		},
+		{
+			`10 within 1m by ps.uid |evt.name = 'CreateFile'|`,
+			errors.New("expected field"),
+			0,
+			0,
+			false,
+		},
+		{
+			`10 within 1m |evt.name = 'CreateFile'`,
+			errors.New("expected |"),
+			0,
+			0,
+			false,
+		},
+		{
+			`10 within 1m |evt.name = 'CreateFile'| |evt.name = 'DeleteFile'|`,
+			errors.New("expected EOF"),
+			0,
+			0,
+			false,
+		},
+	}
+
+	for i, tt := range tests {
+		p := NewParser(tt.expr)
+		threshold, err := p.ParseThreshold()
+		if err == nil && tt.err != nil {
+			t.Errorf("%d. exp=%s expected error=\n%v", i, tt.expr, tt.err)
+		} else if err != nil && tt.err == nil {
+			t.Errorf("%d. exp=%s got error=\n%v", i, tt.expr, err)
+		}
+
+		if err != nil && tt.err != nil {
+			assert.True(t, strings.Contains(err.Error(), tt.err.Error()), fmt.Sprintf("error '%v' should contain '%v'", err, tt.err))
+		}
+
+		if threshold != nil {
+			assert.Equal(t, tt.count, threshold.Count)
+			assert.Equal(t, tt.within, threshold.Within)
+			assert.Equal(t, tt.isGrouped, threshold.IsGrouped())
+		}
+	}
+}
+
+func TestParseThresholdWithLimits(t *testing.T) {
+	expr := `10 within 8h |evt.name = 'CreateFile'|`
+	_, err := NewParser(expr).ParseThreshold()
+	require.EqualError(t, err, expr+": threshold time window 8h0m0s cannot be greater than 4h")
+
+	c := &config.Filters{Thresholds: config.Thresholds{MaxWindow: time.Hour * 12}}
+	threshold, err := NewParserWithConfig(expr, c).ParseThreshold()
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour*8, threshold.Within)
+
+	c.Thresholds.MaxWindow = time.Hour * 6
+	_, err = NewParserWithConfig(expr, c).ParseThreshold()
+	require.EqualError(t, err, expr+": threshold time window 8h0m0s cannot be greater than 6h")
+}
+
+func TestIsThreshold(t *testing.T) {
+	assert.True(t, NewParser(`threshold 5 within 1m |evt.name = 'CreateFile'|`).IsThreshold())
+	assert.False(t, NewParser(`evt.name = 'CreateFile'`).IsThreshold())
+}
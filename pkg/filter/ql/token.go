@@ -63,6 +63,8 @@ const (
 	IFuzzynorm  // ifuzzynorm
 	Intersects  // intersects
 	IIntersects // iintersects
+	Between     // between
+	InRange     // in range
 	Eq          // =
 	IEq         // ~=
 	Neq         // !=
@@ -79,11 +81,18 @@ const (
 	Pipe     // |
 	LBracket // [
 	RBracket // ]
+	Bang     // !
 
 	Seq     // SEQUENCE
 	MaxSpan // MAXSPAN
 	By      // BY
 	As      // AS
+
+	Thresh // THRESHOLD
+	Within // WITHIN
+
+	Any // ANY
+	All // ALL
 )
 
 var keywords map[string]Token
@@ -93,7 +102,8 @@ func init() {
 	for _, tok := range []Token{And, Or, Contains, IContains, In,
 		IIn, Not, Startswith, IStartswith, Endswith, IEndswith,
 		Matches, IMatches, Fuzzy, IFuzzy, Fuzzynorm, IFuzzynorm,
-		Intersects, IIntersects, Seq, MaxSpan, By, As} {
+		Intersects, IIntersects, Between, Seq, MaxSpan, By, As, Thresh, Within,
+		Any, All} {
 		keywords[strings.ToLower(tokens[tok])] = tok
 	}
 	keywords["true"] = True
@@ -137,6 +147,8 @@ var tokens = [...]string{
 	IFuzzynorm:  "IFUZZYNORM",
 	Intersects:  "INTERSECTS",
 	IIntersects: "IINTERSECTS",
+	Between:     "BETWEEN",
+	InRange:     "IN RANGE",
 
 	Eq:  "=",
 	IEq: "~=",
@@ -153,16 +165,26 @@ var tokens = [...]string{
 	Pipe:     "|",
 	LBracket: "[",
 	RBracket: "]",
+	Bang:     "!",
 
 	Seq:     "SEQUENCE",
 	MaxSpan: "MAXSPAN",
 	By:      "BY",
 	As:      "AS",
+
+	Thresh: "THRESHOLD",
+	Within: "WITHIN",
+
+	Any: "ANY",
+	All: "ALL",
 }
 
 // isOperator determines whether the current token is an operator.
 func (tok Token) isOperator() bool { return tok > opBeg && tok < opEnd }
 
+// isQuantifier determines whether the current token is a quantifier.
+func (tok Token) isQuantifier() bool { return tok == Any || tok == All }
+
 // String returns the string representation of the token.
 func (tok Token) String() string {
 	if tok >= 0 && tok < Token(len(tokens)) {
@@ -180,10 +202,10 @@ func (tok Token) precedence() int {
 		return 2
 	case Not:
 		return 3
-	case Eq, IEq, Neq, Lt, Lte, Gt, Gte:
+	case Eq, IEq, Neq, Lt, Lte, Gt, Gte, Between:
 		return 4
 	case In, IIn, Contains, IContains, Startswith, IStartswith, Endswith, IEndswith,
-		Matches, IMatches, Fuzzy, IFuzzy, Fuzzynorm, IFuzzynorm, Intersects, IIntersects:
+		Matches, IMatches, Fuzzy, IFuzzy, Fuzzynorm, IFuzzynorm, Intersects, IIntersects, InRange:
 		return 5
 	}
 	return 0
@@ -44,6 +44,10 @@ func Walk(v Visitor, node Node) {
 		}
 	case *ParenExpr:
 		Walk(v, n.Expr)
+	case *SharedExpr:
+		Walk(v, n.Expr)
+	case *QuantifierExpr:
+		Walk(v, n.Field)
 	}
 }
 
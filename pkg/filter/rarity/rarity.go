@@ -0,0 +1,351 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package rarity maintains the bounded frequency store that keeps track of
+// how often combinations of values are observed. The store backs stateful
+// rule functions that detect values seen for the first time or rarely seen
+// values. Entries not observed within the TTL are forgotten, and when the
+// store is full, the least recently observed entries are evicted. The store
+// is optionally persisted to the state file, so the learned frequencies
+// survive agent restarts.
+package rarity
+
+import (
+	"bufio"
+	"container/list"
+	"encoding/gob"
+	"expvar"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rabbitstack/fibratus/pkg/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// stateVersion is the version of the frequency store state format
+const stateVersion = 1
+
+var (
+	// storeEntries represents the number of entries in the frequency store
+	storeEntries = expvar.NewInt("rarity.store.entries")
+	// storeEvictions counts the number of entries evicted due to the store size limit
+	storeEvictions = expvar.NewInt("rarity.store.evictions")
+	// storeExpirations counts the number of entries forgotten due to the TTL
+	storeExpirations = expvar.NewInt("rarity.store.expirations")
+	// storeFlushErrors counts the number of failed frequency store flushes
+	storeFlushErrors = expvar.NewInt("rarity.store.flush.errors")
+)
+
+var (
+	mu    sync.RWMutex
+	store = New(config.Rarity{})
+)
+
+// Observation describes the state of the value combination
+// after the observation was recorded in the store.
+type Observation struct {
+	// Count is the number of times the value combination was observed
+	// within the TTL, including the current observation.
+	Count int
+	// Learning indicates if the store is in the learning period.
+	Learning bool
+}
+
+// IsFirst determines if the value combination was observed for the
+// first time. The observation is never the first during the learning.
+func (o Observation) IsFirst() bool { return o.Count == 1 && !o.Learning }
+
+// entry is the frequency store entry.
+type entry struct {
+	Key       string
+	FirstSeen time.Time
+	LastSeen  time.Time
+	Count     int
+}
+
+// state is the persisted state of the frequency store.
+type state struct {
+	Version uint8
+	// Since is the time when the store was first created
+	Since   time.Time
+	Entries []entry
+}
+
+// Store is the bounded frequency store. Entries are kept in the
+// recency list, so the least recently observed entries are evicted
+// first when the store reaches the maximum number of entries.
+type Store struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	config  config.Rarity
+	// since is the time when the store was first created.
+	// It determines the end of the learning period.
+	since time.Time
+	dirty bool
+
+	ticker *time.Ticker
+	quit   chan struct{}
+}
+
+// New creates the in-memory frequency store.
+func New(c config.Rarity) *Store {
+	return &Store{
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		config:  c,
+		since:   time.Now(),
+	}
+}
+
+// Open creates the frequency store and makes it the store used by rule
+// functions. If the state file is configured, the store is restored from
+// the state file and it is periodically flushed to the state file. The
+// store is usable even if the state file can't be restored.
+func Open(c config.Rarity) *Store {
+	s := New(c)
+	if c.StateFile != "" {
+		if err := s.restore(); err != nil {
+			log.Warnf("unable to restore frequency store: %v", err)
+		}
+		s.startFlusher()
+	}
+	mu.Lock()
+	store = s
+	mu.Unlock()
+	return s
+}
+
+// Default returns the frequency store used by rule functions.
+func Default() *Store {
+	mu.RLock()
+	defer mu.RUnlock()
+	return store
+}
+
+// Key builds the store key from the namespace and values.
+func Key(namespace string, values ...any) string {
+	var b strings.Builder
+	b.WriteString(namespace)
+	for _, v := range values {
+		b.WriteByte(0)
+		switch v := v.(type) {
+		case string:
+			b.WriteString(v)
+		default:
+			fmt.Fprintf(&b, "%v", v)
+		}
+	}
+	return b.String()
+}
+
+// Observe records the observation of the key at the given time.
+func (s *Store) Observe(key string, now time.Time) Observation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dirty = true
+
+	obs := Observation{Learning: now.Before(s.since.Add(s.config.LearningPeriod))}
+	if elem, ok := s.entries[key]; ok {
+		e := elem.Value.(*entry)
+		if now.Sub(e.LastSeen) > s.config.GetTTL() {
+			storeExpirations.Add(1)
+			e.FirstSeen, e.Count = now, 0
+		}
+		e.LastSeen = now
+		e.Count++
+		s.lru.MoveToFront(elem)
+		obs.Count = e.Count
+		return obs
+	}
+
+	s.entries[key] = s.lru.PushFront(&entry{Key: key, FirstSeen: now, LastSeen: now, Count: 1})
+	for s.lru.Len() > s.config.GetMaxEntries() {
+		oldest := s.lru.Back()
+		delete(s.entries, oldest.Value.(*entry).Key)
+		s.lru.Remove(oldest)
+		storeEvictions.Add(1)
+	}
+	storeEntries.Set(int64(s.lru.Len()))
+	obs.Count = 1
+	return obs
+}
+
+// Peek returns the state of the key without recording the observation.
+// The zero observation is returned if the key was never observed or the
+// key expired.
+func (s *Store) Peek(key string, now time.Time) Observation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obs := Observation{Learning: now.Before(s.since.Add(s.config.LearningPeriod))}
+	if elem, ok := s.entries[key]; ok {
+		e := elem.Value.(*entry)
+		if now.Sub(e.LastSeen) <= s.config.GetTTL() {
+			obs.Count = e.Count
+		}
+	}
+	return obs
+}
+
+// Len returns the number of entries in the store.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Len()
+}
+
+// Close stops flushing the store and writes the final
+// state to the state file if the state file is configured.
+// If the store is used by rule functions, they are switched
+// to the fresh in-memory store, so the closed store is not
+// observed after the engine that opened it is closed.
+func (s *Store) Close() error {
+	mu.Lock()
+	if store == s {
+		store = New(config.Rarity{})
+	}
+	mu.Unlock()
+	if s.ticker == nil {
+		return nil
+	}
+	s.ticker.Stop()
+	close(s.quit)
+	s.ticker = nil
+	return s.Flush()
+}
+
+func (s *Store) startFlusher() {
+	s.ticker = time.NewTicker(s.config.GetFlushInterval())
+	s.quit = make(chan struct{})
+	go func(t *time.Ticker, quit chan struct{}) {
+		for {
+			select {
+			case <-t.C:
+				if err := s.Flush(); err != nil {
+					storeFlushErrors.Add(1)
+					log.Warnf("unable to flush frequency store: %v", err)
+				}
+			case <-quit:
+				return
+			}
+		}
+	}(s.ticker, s.quit)
+}
+
+// Flush writes the store to the state file if the store was modified
+// since the last flush. Expired entries are not written. The state is
+// written to the temporary file which replaces the state file, so the
+// state file is never left partially written.
+func (s *Store) Flush() error {
+	path := s.config.StateFile
+	if path == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	if !s.dirty {
+		s.mu.Unlock()
+		return nil
+	}
+	now := time.Now()
+	st := state{Version: stateVersion, Since: s.since, Entries: make([]entry, 0, s.lru.Len())}
+	// write from the least to the most recently observed
+	// entry, so the recency order is kept on restore
+	for elem := s.lru.Back(); elem != nil; elem = elem.Prev() {
+		e := elem.Value.(*entry)
+		if now.Sub(e.LastSeen) > s.config.GetTTL() {
+			continue
+		}
+		st.Entries = append(st.Entries, *e)
+	}
+	s.dirty = false
+	s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err := gob.NewEncoder(w).Encode(st); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	log.Debugf("flushed %d frequency store entries to %s", len(st.Entries), path)
+
+	return os.Rename(tmp, path)
+}
+
+// restore restores the store from the state file. Expired
+// entries and entries exceeding the store size are dropped.
+func (s *Store) restore() error {
+	path := s.config.StateFile
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	var st state
+	if err := gob.NewDecoder(bufio.NewReader(f)).Decode(&st); err != nil {
+		return fmt.Errorf("unable to decode frequency store from %s: %v", path, err)
+	}
+	if st.Version != stateVersion {
+		return fmt.Errorf("unsupported frequency store version: %d", st.Version)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.since = st.Since
+	now := time.Now()
+	for i := range st.Entries {
+		e := st.Entries[i]
+		if now.Sub(e.LastSeen) > s.config.GetTTL() {
+			continue
+		}
+		s.entries[e.Key] = s.lru.PushFront(&e)
+	}
+	for s.lru.Len() > s.config.GetMaxEntries() {
+		oldest := s.lru.Back()
+		delete(s.entries, oldest.Value.(*entry).Key)
+		s.lru.Remove(oldest)
+	}
+	storeEntries.Set(int64(s.lru.Len()))
+
+	log.Infof("restored %d frequency store entries from %s", s.lru.Len(), path)
+
+	return nil
+}
@@ -0,0 +1,108 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rarity
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rabbitstack/fibratus/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserve(t *testing.T) {
+	s := New(config.Rarity{MaxEntries: 2, TTL: time.Hour})
+	now := time.Now()
+
+	k1 := Key("parent_child", "explorer.exe", "cmd.exe")
+	k2 := Key("parent_child", "explorer.exe", "powershell.exe")
+	k3 := Key("parent_child", "winword.exe", "cmd.exe")
+
+	obs := s.Observe(k1, now)
+	assert.Equal(t, 1, obs.Count)
+	assert.True(t, obs.IsFirst())
+	obs = s.Observe(k1, now.Add(time.Minute))
+	assert.Equal(t, 2, obs.Count)
+	assert.False(t, obs.IsFirst())
+	assert.Equal(t, 2, s.Peek(k1, now.Add(time.Minute)).Count)
+
+	// the least recently observed entry is evicted
+	s.Observe(k2, now.Add(time.Minute*2))
+	s.Observe(k1, now.Add(time.Minute*3))
+	s.Observe(k3, now.Add(time.Minute*4))
+	assert.Equal(t, 2, s.Len())
+	assert.Zero(t, s.Peek(k2, now.Add(time.Minute*4)).Count)
+	assert.Equal(t, 3, s.Peek(k1, now.Add(time.Minute*4)).Count)
+
+	// the entry is forgotten once the TTL elapses
+	assert.Zero(t, s.Peek(k1, now.Add(time.Hour*2)).Count)
+	obs = s.Observe(k1, now.Add(time.Hour*2))
+	assert.True(t, obs.IsFirst())
+}
+
+func TestObserveLearningPeriod(t *testing.T) {
+	s := New(config.Rarity{LearningPeriod: time.Hour})
+	now := time.Now()
+	k := Key("modules", "cmd.exe", `C:\Windows\System32\kernel32.dll`)
+
+	obs := s.Observe(k, now)
+	assert.True(t, obs.Learning)
+	assert.False(t, obs.IsFirst())
+
+	obs = s.Observe(k, now.Add(time.Hour*2))
+	assert.False(t, obs.Learning)
+	assert.Equal(t, 2, obs.Count)
+
+	obs = s.Observe(Key("modules", "cmd.exe", `C:\Temp\evil.dll`), now.Add(time.Hour*2))
+	assert.True(t, obs.IsFirst())
+}
+
+func TestKey(t *testing.T) {
+	assert.NotEqual(t, Key("ns", "ab", "c"), Key("ns", "a", "bc"))
+	assert.Equal(t, Key("ports", "443"), Key("ports", uint16(443)))
+	assert.NotEqual(t, Key("ns1", "a"), Key("ns2", "a"))
+}
+
+func TestFlushRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rarity.state")
+	c := config.Rarity{StateFile: path, MaxEntries: 2, LearningPeriod: time.Hour}
+
+	s := Open(c)
+	require.Same(t, s, Default())
+	now := time.Now()
+	s.Observe(Key("ns", "a"), now)
+	s.Observe(Key("ns", "b"), now)
+	s.Observe(Key("ns", "a"), now)
+	s.Observe(Key("ns", "c"), now)
+	require.NoError(t, s.Close())
+	require.NotSame(t, s, Default())
+	assert.Zero(t, Default().Len())
+
+	r := Open(c)
+	defer r.Close()
+	assert.Equal(t, 2, r.Len())
+	assert.Equal(t, 2, r.Peek(Key("ns", "a"), now).Count)
+	assert.Equal(t, 1, r.Peek(Key("ns", "c"), now).Count)
+	assert.Zero(t, r.Peek(Key("ns", "b"), now).Count)
+	// the learning period continues since the store was first created
+	assert.True(t, r.since.Equal(s.since))
+	assert.False(t, r.Observe(Key("ns", "d"), now.Add(time.Hour*2)).Learning)
+}
@@ -19,20 +19,41 @@
 package filter
 
 import (
+	"expvar"
 	"sync"
+	"time"
 
+	"github.com/rabbitstack/fibratus/pkg/event"
 	"github.com/rabbitstack/fibratus/pkg/filter/ql"
 )
 
+// sharedExprHits counts the evaluations of shared expressions served from the cache
+var sharedExprHits = expvar.NewInt("filter.shared.exprs.hits")
+
 // ValuerCache caches extracted field values for a single event's lifetime.
 type ValuerCache struct {
 	valuer ql.MapValuer
+	// results contains the results of shared
+	// expressions evaluated by any filter
+	results map[string]any
+	// profile, if set, accumulates the time spent
+	// in field extraction and function calls
+	profile *EvalProfile
+}
+
+// EvalProfile records the cost of a single filter evaluation
+// broken down by the time spent in accessors extracting field
+// values and the time spent in function calls.
+type EvalProfile struct {
+	Accessors time.Duration
+	Functions time.Duration
 }
 
 var valuerCachePool = sync.Pool{
 	New: func() any {
 		return &ValuerCache{
-			valuer: make(ql.MapValuer),
+			valuer:  make(ql.MapValuer),
+			results: make(map[string]any),
 		}
 	},
 }
@@ -43,12 +64,68 @@ func AcquireValuerCache() *ValuerCache {
 
 func (c *ValuerCache) Release() {
 	clear(c.valuer)
+	clear(c.results)
+	c.profile = nil
 	valuerCachePool.Put(c)
 }
 
+// SetProfile sets the profile that accumulates the evaluation cost
+// of subsequent filter evaluations. Passing nil disables profiling.
+// Field values already present in the cache are not extracted again,
+// so the accessor cost is attributed to the filter that first
+// requested the field.
+func (c *ValuerCache) SetProfile(p *EvalProfile) {
+	c.profile = p
+}
+
 func (c *ValuerCache) populateValuer(f Field, extract func() any) {
 	n := f.String()
-	if _, ok := c.valuer[n]; !ok {
+	if _, ok := c.valuer[n]; ok {
+		return
+	}
+	if c.profile == nil {
 		c.valuer[n] = extract()
+		return
 	}
+	start := time.Now()
+	c.valuer[n] = extract()
+	c.profile.Accessors += time.Since(start)
+}
+
+// lazyValuer extracts the field value from accessors when the
+// field is first accessed by the expression. Fields referenced
+// by predicates that are skipped due to the short-circuiting of
+// and/or operators are never extracted. The extracted values
+// are stored in the valuer cache, so they are reused by other
+// filters evaluated against the same event.
+type lazyValuer struct {
+	f     *filter
+	evt   *event.Event
+	cache *ValuerCache
+}
+
+func (v lazyValuer) Value(key string) (any, bool) {
+	if val, ok := v.cache.valuer[key]; ok {
+		return val, true
+	}
+	field, ok := v.f.fieldIndex[key]
+	if !ok {
+		return nil, false
+	}
+	v.cache.populateValuer(field, func() any {
+		return v.f.extractField(field, v.evt)
+	})
+	return v.cache.valuer[key], true
+}
+
+func (v lazyValuer) Result(key string) (any, bool) {
+	val, ok := v.cache.results[key]
+	if ok {
+		sharedExprHits.Add(1)
+	}
+	return val, ok
+}
+
+func (v lazyValuer) SetResult(key string, val any) {
+	v.cache.results[key] = val
 }
@@ -86,6 +86,7 @@ func AddFlags(flags *pflag.FlagSet) {
 	flags.String(amqpUsername, "", "The username for the plain authentication method")
 	flags.String(amqpPassword, "", "The password for the plain authentication method")
 	outputs.AddTLSFlags(flags, outputs.AMQP)
+	outputs.AddRoutingFlags(flags, outputs.AMQP)
 }
 
 func (c Config) amqpHeaders() amqp.Table {
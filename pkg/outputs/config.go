@@ -20,13 +20,65 @@ package outputs
 
 import (
 	"fmt"
+	"github.com/rabbitstack/fibratus/pkg/event"
 	"github.com/spf13/pflag"
 )
 
+// DefaultQueueSize is the default number of batches that can be
+// enqueued for the output before the aggregator blocks, or drops
+// the batches if the output opted in to drop on the full queue.
+const DefaultQueueSize = 256
+
+// Predicate decides whether the event is routed to the output.
+type Predicate interface {
+	// Eval returns true if the event should be published to the output.
+	Eval(evt *event.Event) bool
+}
+
+// FieldValuer extracts the value of the filter field, such as ps.uuid,
+// from the event. It returns nil if the field can't be resolved.
+type FieldValuer func(evt *event.Event, field string) any
+
 // Config contains the output configuration.
 type Config struct {
-	Type   Type
-	Output interface{}
+	Type   Type        `mapstructure:"-"`
+	Output interface{} `mapstructure:"-"`
+	// Filter is the optional filter expression for routing events to the output.
+	// All events are published to the output if the filter is not specified.
+	Filter string `mapstructure:"filter"`
+	// QueueSize determines the maximum number of pending batches for the output.
+	QueueSize int `mapstructure:"queue-size"`
+	// DropOnFull indicates if batches are dropped when the output
+	// queue is full. By default, the aggregator blocks until the
+	// output catches up, so no events are lost.
+	DropOnFull bool `mapstructure:"drop-on-full"`
+	// Predicate is the compiled routing filter.
+	Predicate Predicate `mapstructure:"-"`
+	// Valuer resolves filter field values for outputs that
+	// derive message attributes from event fields.
+	Valuer FieldValuer `mapstructure:"-"`
+}
+
+// GetQueueSize returns the maximum number of pending batches for the output.
+func (c Config) GetQueueSize() int {
+	if c.QueueSize <= 0 {
+		return DefaultQueueSize
+	}
+	return c.QueueSize
+}
+
+// Route returns the subset of batch events satisfying the output routing predicate.
+func (c Config) Route(b *event.Batch) *event.Batch {
+	if c.Predicate == nil {
+		return b
+	}
+	evts := make([]*event.Event, 0, len(b.Events))
+	for _, evt := range b.Events {
+		if c.Predicate.Eval(evt) {
+			evts = append(evts, evt)
+		}
+	}
+	return event.NewBatch(evts...)
 }
 
 // TLSConfig stores the client TLS parameters.
@@ -43,10 +95,17 @@ type TLSConfig struct {
 
 // AddTLSFlags register the TLS flags for the specified output type.
 func AddTLSFlags(flags *pflag.FlagSet, typ Type) {
-	flags.String(tlsForOutput("tls-ca", typ), "", "Represents the path of the certificate file that is associated with the Certification Authority (CA)")
-	flags.String(tlsForOutput("tls-cert", typ), "", "Path to certificate file")
-	flags.String(tlsForOutput("tls-key", typ), "", "Path to the public/private key file")
-	flags.Bool(tlsForOutput("tls-insecure-skip-verify", typ), false, "Indicates if the chain and host verification stage is skipped")
+	flags.String(flagForOutput("tls-ca", typ), "", "Represents the path of the certificate file that is associated with the Certification Authority (CA)")
+	flags.String(flagForOutput("tls-cert", typ), "", "Path to certificate file")
+	flags.String(flagForOutput("tls-key", typ), "", "Path to the public/private key file")
+	flags.Bool(flagForOutput("tls-insecure-skip-verify", typ), false, "Indicates if the chain and host verification stage is skipped")
+}
+
+// AddRoutingFlags registers the routing flags for the specified output type.
+func AddRoutingFlags(flags *pflag.FlagSet, typ Type) {
+	flags.String(flagForOutput("filter", typ), "", "Filter expression that determines which events are routed to the output")
+	flags.Int(flagForOutput("queue-size", typ), DefaultQueueSize, "Maximum number of pending batches before the aggregator blocks, or the batches are spooled if the spool is enabled")
+	flags.Bool(flagForOutput("drop-on-full", typ), false, "Indicates if batches are dropped instead of blocking when the output queue is full and the spool is disabled")
 }
 
-func tlsForOutput(name string, typ Type) string { return fmt.Sprintf("output.%s.%s", typ, name) }
+func flagForOutput(name string, typ Type) string { return fmt.Sprintf("output.%s.%s", typ, name) }
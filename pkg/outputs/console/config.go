@@ -18,7 +18,10 @@
 
 package console
 
-import "github.com/spf13/pflag"
+import (
+	"github.com/rabbitstack/fibratus/pkg/outputs"
+	"github.com/spf13/pflag"
+)
 
 const (
 	frmt             = "output.console.format"
@@ -44,4 +47,5 @@ func AddFlags(flags *pflag.FlagSet) {
 	flags.String(tmpl, "", "Event formatting template")
 	flags.Bool(enabled, true, "Indicates if the console output is enabled")
 	flags.Bool(colorize, true, "Indicates if the console output is colorized")
+	outputs.AddRoutingFlags(flags, outputs.Console)
 }
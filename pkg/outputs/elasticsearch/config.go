@@ -98,4 +98,5 @@ func AddFlags(flags *pflag.FlagSet) {
 	flags.String(esIndexName, "fibratus", "Represents the target index for kernel events. It allows time specifiers to create indices per time frame")
 	flags.String(esTemplateConfig, "", "Contains the full JSON body of the index template")
 	flags.Bool(esGzipCompression, false, "Specifies if gzip compression is enabled")
+	outputs.AddRoutingFlags(flags, outputs.Elasticsearch)
 }
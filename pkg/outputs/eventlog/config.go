@@ -20,6 +20,7 @@ package eventlog
 
 import (
 	"github.com/rabbitstack/fibratus/pkg/event"
+	"github.com/rabbitstack/fibratus/pkg/outputs"
 	"text/template"
 
 	"github.com/spf13/pflag"
@@ -58,4 +59,5 @@ func AddFlags(flags *pflag.FlagSet) {
 	flags.String(level, "info", "Specifies the eventlog level. Deprecated")
 	flags.String(remoteHost, "", "Address of the remote eventlog intake")
 	flags.Bool(enabled, false, "Indicates if the eventlog output is enabled")
+	outputs.AddRoutingFlags(flags, outputs.Eventlog)
 }
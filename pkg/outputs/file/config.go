@@ -0,0 +1,83 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/rabbitstack/fibratus/pkg/outputs"
+	"github.com/rabbitstack/fibratus/pkg/util/log/rotate"
+)
+
+const (
+	fileEnabled        = "output.file.enabled"
+	filePath           = "output.file.path"
+	fileMaxSize        = "output.file.max-size"
+	fileRotateInterval = "output.file.rotate-interval"
+	fileMaxBackups     = "output.file.max-backups"
+	fileMaxTotalSize   = "output.file.max-total-size"
+	fileCompression    = "output.file.compression"
+)
+
+// Config contains the options that influence the behaviour of the file output.
+type Config struct {
+	// Enabled indicates if the file output is enabled.
+	Enabled bool `mapstructure:"enabled"`
+	// Path is the path of the events file. The file name may contain
+	// %Y, %y, %m, %d, and %H time specifiers rendered in UTC.
+	Path string `mapstructure:"path"`
+	// MaxSize is the size in megabytes after which the file is rotated.
+	MaxSize int `mapstructure:"max-size"`
+	// RotateInterval is the period after which the file is rotated.
+	RotateInterval time.Duration `mapstructure:"rotate-interval"`
+	// MaxBackups is the maximum number of rotated files to retain.
+	MaxBackups int `mapstructure:"max-backups"`
+	// MaxTotalSize is the maximum total size in megabytes of rotated files.
+	MaxTotalSize int `mapstructure:"max-total-size"`
+	// Compression is the compression algorithm applied to rotated files.
+	Compression string `mapstructure:"compression"`
+}
+
+// AddFlags registers persistent flags for the file output.
+func AddFlags(flags *pflag.FlagSet) {
+	flags.Bool(fileEnabled, false, "Indicates if the file output is enabled")
+	flags.String(filePath, filepath.Join(os.Getenv("PROGRAMFILES"), "Fibratus", "Events", "fibratus-%Y.%m.%d.jsonl"), "Path of the events file. The file name may contain %Y, %y, %m, %d, and %H time specifiers rendered in UTC")
+	flags.Int(fileMaxSize, 100, "Specifies the size in megabytes after which the file is rotated")
+	flags.Duration(fileRotateInterval, 0, "Specifies the period after which the file is rotated")
+	flags.Int(fileMaxBackups, 0, "Specifies the maximum number of rotated files to retain")
+	flags.Int(fileMaxTotalSize, 1024, "Specifies the maximum total size in megabytes of rotated files")
+	flags.String(fileCompression, rotate.CompressionGzip, "Specifies the compression algorithm applied to rotated files. Choose between gzip|zstd|none")
+	outputs.AddRoutingFlags(flags, outputs.File)
+}
+
+// writerConfig builds the rotating writer configuration.
+func (c Config) writerConfig() rotate.WriterConfig {
+	return rotate.WriterConfig{
+		Filename:     c.Path,
+		MaxSize:      c.MaxSize,
+		Interval:     c.RotateInterval,
+		MaxBackups:   c.MaxBackups,
+		MaxTotalSize: int64(c.MaxTotalSize) * 1024 * 1024,
+		Compression:  c.Compression,
+	}
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package file
+
+import (
+	"bytes"
+	"expvar"
+
+	"github.com/rabbitstack/fibratus/pkg/event"
+	"github.com/rabbitstack/fibratus/pkg/outputs"
+	"github.com/rabbitstack/fibratus/pkg/util/log/rotate"
+)
+
+var (
+	// fileErrors counts file write errors
+	fileErrors = expvar.NewInt("output.file.publish.errors")
+	// fileEvents counts the total number of written events
+	fileEvents = expvar.NewInt("output.file.publish.events")
+)
+
+type file struct {
+	config Config
+	w      *rotate.Writer
+	buf    bytes.Buffer
+}
+
+func init() {
+	outputs.Register(outputs.File, initFile)
+}
+
+func initFile(config outputs.Config) (outputs.OutputGroup, error) {
+	cfg, ok := config.Output.(Config)
+	if !ok {
+		return outputs.Fail(outputs.ErrInvalidConfig(outputs.File, config.Output))
+	}
+	return outputs.Success(&file{config: cfg}), nil
+}
+
+func (f *file) Connect() error {
+	w, err := rotate.NewWriter(f.config.writerConfig())
+	if err != nil {
+		return err
+	}
+	f.w = w
+	return nil
+}
+
+func (f *file) Close() error {
+	if f.w == nil {
+		return nil
+	}
+	return f.w.Close()
+}
+
+// Publish writes each event in the batch as a JSON line. The whole
+// batch is written at once, so the file is only rotated between
+// batches.
+func (f *file) Publish(batch *event.Batch) error {
+	f.buf.Reset()
+	for _, evt := range batch.Events {
+		f.buf.Write(evt.MarshalJSON())
+		f.buf.WriteByte('\n')
+	}
+	if _, err := f.w.Write(f.buf.Bytes()); err != nil {
+		fileErrors.Add(1)
+		return err
+	}
+	fileEvents.Add(batch.Len())
+	return nil
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package file
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rabbitstack/fibratus/pkg/event"
+	"github.com/rabbitstack/fibratus/pkg/event/params"
+	"github.com/rabbitstack/fibratus/pkg/outputs"
+	pstypes "github.com/rabbitstack/fibratus/pkg/ps/types"
+	"github.com/rabbitstack/fibratus/pkg/util/log/rotate"
+)
+
+func TestPublishFile(t *testing.T) {
+	dir := t.TempDir()
+	group, err := initFile(outputs.Config{
+		Type: outputs.File,
+		Output: Config{
+			Path:        filepath.Join(dir, "events.jsonl"),
+			MaxSize:     100,
+			Compression: rotate.CompressionNone,
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, group.Clients, 1)
+	f := group.Clients[0]
+
+	require.NoError(t, f.Connect())
+	require.NoError(t, f.Publish(event.NewBatch(getEvent(1), getEvent(2))))
+	require.NoError(t, f.Publish(event.NewBatch(getEvent(3))))
+	require.NoError(t, f.Close())
+
+	fd, err := os.Open(filepath.Join(dir, "events.jsonl"))
+	require.NoError(t, err)
+	defer fd.Close()
+
+	var seqs []uint64
+	scanner := bufio.NewScanner(fd)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var evt struct {
+			Seq uint64 `json:"seq"`
+		}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &evt))
+		seqs = append(seqs, evt.Seq)
+	}
+	require.NoError(t, scanner.Err())
+	assert.Equal(t, []uint64{1, 2, 3}, seqs)
+}
+
+func TestConnectInvalidPath(t *testing.T) {
+	group, err := initFile(outputs.Config{
+		Type:   outputs.File,
+		Output: Config{Path: filepath.Join(t.TempDir(), "%Y", "events.jsonl")},
+	})
+	require.NoError(t, err)
+	require.Error(t, group.Clients[0].Connect())
+}
+
+func getEvent(seq uint64) *event.Event {
+	return &event.Event{
+		Type:      event.CreateFile,
+		Tid:       2484,
+		PID:       2436,
+		CPU:       1,
+		Seq:       seq,
+		Name:      "CreateFile",
+		Timestamp: time.Now(),
+		Category:  event.File,
+		Host:      "archrabbit",
+		Params: event.Params{
+			params.FilePath: {Name: params.FilePath, Type: params.UnicodeString, Value: `C:\Windows\system32\user32.dll`},
+		},
+		Metadata: make(map[event.MetadataKey]any),
+		PS: &pstypes.PS{
+			PID:  2436,
+			Ppid: 6304,
+			Name: "notepad.exe",
+			Exe:  `C:\Windows\notepad.exe`,
+		},
+	}
+}
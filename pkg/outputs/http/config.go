@@ -83,4 +83,5 @@ func AddFlags(flags *pflag.FlagSet) {
 	flags.Bool(httpEnableGzip, false, "Indicates whether the gzip compression is enabled")
 	flags.String(httpSerializer, string(outputs.JSON), "Indicates the event serializer type")
 	outputs.AddTLSFlags(flags, outputs.HTTP)
+	outputs.AddRoutingFlags(flags, outputs.HTTP)
 }
@@ -0,0 +1,132 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafka
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/spf13/pflag"
+
+	"github.com/rabbitstack/fibratus/pkg/outputs"
+)
+
+const (
+	kafkaEnabled         = "output.kafka.enabled"
+	kafkaBrokers         = "output.kafka.brokers"
+	kafkaTopic           = "output.kafka.topic"
+	kafkaPartitionKey    = "output.kafka.partition-key"
+	kafkaAcks            = "output.kafka.acks"
+	kafkaCompression     = "output.kafka.compression"
+	kafkaTimeout         = "output.kafka.timeout"
+	kafkaClientID        = "output.kafka.client-id"
+	kafkaVersion         = "output.kafka.version"
+	kafkaMaxMessageBytes = "output.kafka.max-message-bytes"
+	kafkaSASLMechanism   = "output.kafka.sasl-mechanism"
+	kafkaUsername        = "output.kafka.username"
+	kafkaPassword        = "output.kafka.password"
+	kafkaTLSEnabled      = "output.kafka.tls-enabled"
+)
+
+// Config contains the options that influence the behaviour of the Kafka output.
+type Config struct {
+	outputs.TLSConfig
+	// Enabled indicates if the Kafka output is enabled.
+	Enabled bool `mapstructure:"enabled"`
+	// Brokers contains the list of bootstrap broker addresses.
+	Brokers []string `mapstructure:"brokers"`
+	// Topic is the topic name template. It may contain field
+	// modifiers, e.g. %evt.category, that are replaced with
+	// the event field values.
+	Topic string `mapstructure:"topic"`
+	// PartitionKey is the field whose value is used as the message
+	// key. Events with the same key land in the same partition.
+	PartitionKey string `mapstructure:"partition-key"`
+	// Acks determines the level of acknowledgement reliability.
+	Acks string `mapstructure:"acks"`
+	// Compression is the codec used to compress message sets.
+	Compression string `mapstructure:"compression"`
+	// Timeout specifies the network and produce request timeout.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// ClientID is the identifier sent to brokers on every request.
+	ClientID string `mapstructure:"client-id"`
+	// Version is the Kafka protocol version the producer speaks.
+	Version string `mapstructure:"version"`
+	// MaxMessageBytes is the maximum permitted size of the message.
+	MaxMessageBytes int `mapstructure:"max-message-bytes"`
+	// SASLMechanism designates the SASL authentication mechanism.
+	SASLMechanism string `mapstructure:"sasl-mechanism"`
+	// Username is the username for the SASL authentication.
+	Username string `mapstructure:"username"`
+	// Password is the password for the SASL authentication.
+	Password string `mapstructure:"password"`
+	// TLSEnabled enables TLS connections to brokers.
+	TLSEnabled bool `mapstructure:"tls-enabled"`
+}
+
+// AddFlags registers persistent flags for the Kafka output.
+func AddFlags(flags *pflag.FlagSet) {
+	flags.Bool(kafkaEnabled, false, "Indicates if the Kafka output is enabled")
+	flags.StringSlice(kafkaBrokers, []string{"localhost:9092"}, "A comma-separated list of bootstrap broker addresses")
+	flags.String(kafkaTopic, "fibratus", "Topic name template. Field modifiers, e.g. %evt.category, are replaced with event field values")
+	flags.String(kafkaPartitionKey, "ps.uuid", "Field whose value is used as the message partition key")
+	flags.String(kafkaAcks, "leader", "Determines the level of acknowledgement reliability (none, leader, all)")
+	flags.String(kafkaCompression, "none", "Specifies the message compression codec (none, gzip, snappy, lz4, zstd)")
+	flags.Duration(kafkaTimeout, time.Second*10, "Specifies the network and produce request timeout")
+	flags.String(kafkaClientID, "fibratus", "Identifier sent to brokers on every request")
+	flags.String(kafkaVersion, "", "Kafka protocol version the producer speaks, e.g. 2.8.0")
+	flags.Int(kafkaMaxMessageBytes, 1000000, "Maximum permitted size of the message")
+	flags.String(kafkaSASLMechanism, "", "SASL authentication mechanism (plain, scram-sha-256, scram-sha-512)")
+	flags.String(kafkaUsername, "", "Username for the SASL authentication")
+	flags.String(kafkaPassword, "", "Password for the SASL authentication")
+	flags.Bool(kafkaTLSEnabled, false, "Indicates if TLS connections to brokers are enabled")
+	outputs.AddTLSFlags(flags, outputs.Kafka)
+	outputs.AddRoutingFlags(flags, outputs.Kafka)
+}
+
+func (c Config) acks() (sarama.RequiredAcks, error) {
+	switch c.Acks {
+	case "none":
+		return sarama.NoResponse, nil
+	case "", "leader":
+		return sarama.WaitForLocal, nil
+	case "all":
+		return sarama.WaitForAll, nil
+	default:
+		return 0, fmt.Errorf("unknown acks value %q. Expected none, leader, or all", c.Acks)
+	}
+}
+
+func (c Config) compression() (sarama.CompressionCodec, error) {
+	switch c.Compression {
+	case "", "none":
+		return sarama.CompressionNone, nil
+	case "gzip":
+		return sarama.CompressionGZIP, nil
+	case "snappy":
+		return sarama.CompressionSnappy, nil
+	case "lz4":
+		return sarama.CompressionLZ4, nil
+	case "zstd":
+		return sarama.CompressionZSTD, nil
+	default:
+		return 0, fmt.Errorf("unknown compression codec %q", c.Compression)
+	}
+}
@@ -0,0 +1,192 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafka
+
+import (
+	gotls "crypto/tls"
+	"errors"
+	"expvar"
+	"fmt"
+
+	"github.com/IBM/sarama"
+
+	"github.com/rabbitstack/fibratus/pkg/event"
+	"github.com/rabbitstack/fibratus/pkg/outputs"
+	"github.com/rabbitstack/fibratus/pkg/util/tls"
+)
+
+var (
+	// kafkaErrors counts failed produce requests
+	kafkaErrors = expvar.NewInt("output.kafka.publish.errors")
+	// kafkaMessages counts the total number of produced messages
+	kafkaMessages = expvar.NewInt("output.kafka.publish.messages")
+)
+
+// errNoBrokers is raised when the broker list is empty
+var errNoBrokers = errors.New("at least one Kafka broker address is required")
+
+type kafka struct {
+	config   Config
+	sconfig  *sarama.Config
+	producer sarama.SyncProducer
+	topic    *topic
+	valuer   outputs.FieldValuer
+}
+
+func init() {
+	outputs.Register(outputs.Kafka, initKafka)
+}
+
+func initKafka(config outputs.Config) (outputs.OutputGroup, error) {
+	cfg, ok := config.Output.(Config)
+	if !ok {
+		return outputs.Fail(outputs.ErrInvalidConfig(outputs.Kafka, config.Output))
+	}
+	if len(cfg.Brokers) == 0 {
+		return outputs.Fail(errNoBrokers)
+	}
+	sconfig, err := newSaramaConfig(cfg)
+	if err != nil {
+		return outputs.Fail(err)
+	}
+	k := &kafka{
+		config:  cfg,
+		sconfig: sconfig,
+		topic:   parseTopic(cfg.Topic),
+		valuer:  config.Valuer,
+	}
+	return outputs.Success(k), nil
+}
+
+// newSaramaConfig builds the producer configuration from the output config.
+func newSaramaConfig(cfg Config) (*sarama.Config, error) {
+	c := sarama.NewConfig()
+	if cfg.ClientID != "" {
+		c.ClientID = cfg.ClientID
+	}
+	if cfg.Version != "" {
+		version, err := sarama.ParseKafkaVersion(cfg.Version)
+		if err != nil {
+			return nil, err
+		}
+		c.Version = version
+	}
+
+	acks, err := cfg.acks()
+	if err != nil {
+		return nil, err
+	}
+	codec, err := cfg.compression()
+	if err != nil {
+		return nil, err
+	}
+	// sync producer requires successes to be reported back
+	c.Producer.Return.Successes = true
+	c.Producer.RequiredAcks = acks
+	c.Producer.Compression = codec
+	if cfg.MaxMessageBytes > 0 {
+		c.Producer.MaxMessageBytes = cfg.MaxMessageBytes
+	}
+	if cfg.Timeout > 0 {
+		c.Producer.Timeout = cfg.Timeout
+		c.Net.DialTimeout = cfg.Timeout
+		c.Net.ReadTimeout = cfg.Timeout
+		c.Net.WriteTimeout = cfg.Timeout
+	}
+
+	tlsConfig, err := tls.MakeConfig(cfg.TLSCert, cfg.TLSKey, cfg.TLSCA, cfg.TLSInsecureSkipVerify)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.TLSEnabled && tlsConfig == nil {
+		// rely on system root certificates
+		tlsConfig = &gotls.Config{InsecureSkipVerify: cfg.TLSInsecureSkipVerify}
+	}
+	if tlsConfig != nil {
+		c.Net.TLS.Enable = true
+		c.Net.TLS.Config = tlsConfig
+	}
+
+	if cfg.SASLMechanism != "" {
+		c.Net.SASL.Enable = true
+		c.Net.SASL.User = cfg.Username
+		c.Net.SASL.Password = cfg.Password
+		switch cfg.SASLMechanism {
+		case "plain":
+			c.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+		case "scram-sha-256":
+			c.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA256
+			c.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient { return &scramClient{hash: sha256Hash} }
+		case "scram-sha-512":
+			c.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA512
+			c.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient { return &scramClient{hash: sha512Hash} }
+		default:
+			return nil, fmt.Errorf("unsupported SASL mechanism %q", cfg.SASLMechanism)
+		}
+	}
+
+	return c, c.Validate()
+}
+
+func (k *kafka) Connect() error {
+	producer, err := sarama.NewSyncProducer(k.config.Brokers, k.sconfig)
+	if err != nil {
+		return fmt.Errorf("unable to connect to Kafka brokers: %v", err)
+	}
+	k.producer = producer
+	return nil
+}
+
+func (k *kafka) Close() error {
+	if k.producer == nil {
+		return nil
+	}
+	return k.producer.Close()
+}
+
+// Publish produces a message for each event in the batch. The message
+// value is the JSON event payload and the key is the value of the partition
+// key field. If any of the messages fails to be produced, the error is
+// returned, and the whole batch is retried by the aggregator.
+func (k *kafka) Publish(batch *event.Batch) error {
+	msgs := make([]*sarama.ProducerMessage, 0, len(batch.Events))
+	for _, evt := range batch.Events {
+		msgs = append(msgs, k.message(evt))
+	}
+	if err := k.producer.SendMessages(msgs); err != nil {
+		kafkaErrors.Add(1)
+		return err
+	}
+	kafkaMessages.Add(int64(len(msgs)))
+	return nil
+}
+
+func (k *kafka) message(evt *event.Event) *sarama.ProducerMessage {
+	msg := &sarama.ProducerMessage{
+		Topic:     k.topic.render(evt, k.valuer),
+		Value:     sarama.ByteEncoder(evt.MarshalJSON()),
+		Timestamp: evt.Timestamp,
+	}
+	if k.config.PartitionKey != "" && k.valuer != nil {
+		if key := k.valuer(evt, k.config.PartitionKey); key != nil {
+			msg.Key = sarama.StringEncoder(fmt.Sprintf("%v", key))
+		}
+	}
+	return msg
+}
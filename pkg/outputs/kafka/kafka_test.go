@@ -0,0 +1,226 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafka
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rabbitstack/fibratus/pkg/event"
+	"github.com/rabbitstack/fibratus/pkg/event/params"
+	"github.com/rabbitstack/fibratus/pkg/outputs"
+	pstypes "github.com/rabbitstack/fibratus/pkg/ps/types"
+)
+
+// valuer resolves a handful of fields for tests
+// without depending on the filter package.
+func valuer(evt *event.Event, field string) any {
+	switch field {
+	case "evt.category":
+		return evt.Category
+	case "evt.rule.name":
+		return evt.GetMeta(event.RuleNameKey)
+	case "ps.pid":
+		if evt.PS == nil {
+			return nil
+		}
+		return evt.PS.PID
+	}
+	return nil
+}
+
+func TestTopicRender(t *testing.T) {
+	evt := getEvent(1, event.File)
+	evt.AddMeta(event.RuleNameKey, "LSASS memory dumping")
+
+	var tests = []struct {
+		tmpl  string
+		topic string
+	}{
+		{"fibratus", "fibratus"},
+		{"fibratus-%evt.category", "fibratus-file"},
+		{"%evt.category", "file"},
+		{"alerts-%evt.rule.name", "alerts-LSASS_memory_dumping"},
+		{"fibratus-%evt.desc", "fibratus-unknown"},
+		{"%evt.category-%ps.pid", "file-2436"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tmpl, func(t *testing.T) {
+			assert.Equal(t, tt.topic, parseTopic(tt.tmpl).render(evt, valuer))
+		})
+	}
+}
+
+func TestSaramaConfig(t *testing.T) {
+	var tests = []struct {
+		name string
+		cfg  Config
+		err  bool
+	}{
+		{"defaults", Config{}, false},
+		{"acks all", Config{Acks: "all", Compression: "zstd", Version: "2.8.0"}, false},
+		{"invalid acks", Config{Acks: "some"}, true},
+		{"invalid compression", Config{Compression: "brotli"}, true},
+		{"invalid version", Config{Version: "x.y"}, true},
+		{"scram", Config{SASLMechanism: "scram-sha-512", Username: "fibratus", Password: "secret"}, false},
+		{"invalid SASL mechanism", Config{SASLMechanism: "kerberos"}, true},
+		{"tls", Config{TLSEnabled: true}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := newSaramaConfig(tt.cfg)
+			if tt.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, c.Producer.Return.Successes)
+			assert.Equal(t, tt.cfg.TLSEnabled, c.Net.TLS.Enable)
+			assert.Equal(t, tt.cfg.SASLMechanism != "", c.Net.SASL.Enable)
+		})
+	}
+}
+
+func TestInitKafka(t *testing.T) {
+	_, err := initKafka(outputs.Config{Type: outputs.Kafka, Output: Config{}})
+	require.ErrorIs(t, err, errNoBrokers)
+
+	group, err := initKafka(outputs.Config{Type: outputs.Kafka, Output: Config{Brokers: []string{"localhost:9092"}}})
+	require.NoError(t, err)
+	require.Len(t, group.Clients, 1)
+}
+
+func TestPublishMessages(t *testing.T) {
+	producer := mocks.NewSyncProducer(t, nil)
+	k := &kafka{
+		config:   Config{PartitionKey: "ps.pid"},
+		producer: producer,
+		topic:    parseTopic("fibratus-%evt.category"),
+		valuer:   valuer,
+	}
+
+	check := func(topic string, seq uint64) mocks.MessageChecker {
+		return func(msg *sarama.ProducerMessage) error {
+			if msg.Topic != topic {
+				return fmt.Errorf("expected %s topic but got %s", topic, msg.Topic)
+			}
+			key, err := msg.Key.Encode()
+			if err != nil {
+				return err
+			}
+			if string(key) != "2436" {
+				return fmt.Errorf("unexpected partition key %s", key)
+			}
+			b, err := msg.Value.Encode()
+			if err != nil {
+				return err
+			}
+			var evt map[string]any
+			if err := json.Unmarshal(b, &evt); err != nil {
+				return err
+			}
+			if evt["seq"] != float64(seq) {
+				return fmt.Errorf("unexpected event sequence %v", evt["seq"])
+			}
+			return nil
+		}
+	}
+
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(check("fibratus-file", 1))
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(check("fibratus-registry", 2))
+
+	require.NoError(t, k.Publish(event.NewBatch(getEvent(1, event.File), getEvent(2, event.Registry))))
+	require.NoError(t, k.Close())
+
+	failing := mocks.NewSyncProducer(t, nil)
+	k.producer = failing
+	failing.ExpectSendMessageAndFail(errors.New("leader not available"))
+	require.Error(t, k.Publish(event.NewBatch(getEvent(3, event.File))))
+	require.NoError(t, k.Close())
+}
+
+func TestPublishMockBroker(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("fibratus", 0, broker.BrokerID()),
+		"ProduceRequest": sarama.NewMockProduceResponse(t),
+	})
+
+	group, err := initKafka(outputs.Config{
+		Type: outputs.Kafka,
+		Output: Config{
+			Brokers: []string{broker.Addr()},
+			Topic:   "fibratus",
+			Timeout: time.Second * 5,
+		},
+	})
+	require.NoError(t, err)
+	k := group.Clients[0]
+
+	require.NoError(t, k.Connect())
+	defer k.Close()
+
+	require.NoError(t, k.Publish(event.NewBatch(getEvent(1, event.File), getEvent(2, event.File))))
+
+	var produced bool
+	for _, rr := range broker.History() {
+		if _, ok := rr.Request.(*sarama.ProduceRequest); ok {
+			produced = true
+		}
+	}
+	assert.True(t, produced)
+}
+
+func getEvent(seq uint64, category event.Category) *event.Event {
+	return &event.Event{
+		Type:      event.CreateFile,
+		Tid:       2484,
+		PID:       2436,
+		CPU:       1,
+		Seq:       seq,
+		Name:      "CreateFile",
+		Timestamp: time.Now(),
+		Category:  category,
+		Host:      "archrabbit",
+		Params: event.Params{
+			params.FilePath: {Name: params.FilePath, Type: params.UnicodeString, Value: "C:\\Windows\\system32\\user32.dll"},
+		},
+		Metadata: make(map[event.MetadataKey]any),
+		PS: &pstypes.PS{
+			PID:  2436,
+			Ppid: 6304,
+			Name: "firefox.exe",
+			Exe:  `C:\Program Files\Mozilla Firefox\firefox.exe`,
+		},
+	}
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafka
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/rabbitstack/fibratus/pkg/event"
+	"github.com/rabbitstack/fibratus/pkg/outputs"
+)
+
+// fieldModifierRegexp matches field modifiers, e.g. %evt.category, in the topic template.
+var fieldModifierRegexp = regexp.MustCompile(`%([a-z0-9A-Z\[\]._]+)`)
+
+// maxTopicLength is the maximum length of the topic name accepted by brokers.
+const maxTopicLength = 249
+
+// unknownField replaces the field modifier that couldn't be resolved from the event.
+const unknownField = "unknown"
+
+// segment is either the literal portion of the
+// topic template or the field modifier.
+type segment struct {
+	literal string
+	field   string
+}
+
+// topic renders topic names from the template.
+type topic struct {
+	segments []segment
+	static   bool
+}
+
+// parseTopic splits the topic template into literal and field segments.
+func parseTopic(tmpl string) *topic {
+	t := &topic{}
+	off := 0
+	for _, m := range fieldModifierRegexp.FindAllStringSubmatchIndex(tmpl, -1) {
+		if m[0] > off {
+			t.segments = append(t.segments, segment{literal: tmpl[off:m[0]]})
+		}
+		t.segments = append(t.segments, segment{field: tmpl[m[2]:m[3]]})
+		off = m[1]
+	}
+	if off < len(tmpl) {
+		t.segments = append(t.segments, segment{literal: tmpl[off:]})
+	}
+	t.static = len(t.segments) <= 1 && (len(t.segments) == 0 || t.segments[0].field == "")
+	return t
+}
+
+// render produces the topic name for the given event. Characters
+// not permitted in topic names are replaced with the underscore.
+func (t *topic) render(evt *event.Event, valuer outputs.FieldValuer) string {
+	if t.static {
+		if len(t.segments) == 0 {
+			return ""
+		}
+		return t.segments[0].literal
+	}
+	var b strings.Builder
+	for _, seg := range t.segments {
+		if seg.field == "" {
+			b.WriteString(seg.literal)
+			continue
+		}
+		var val string
+		if valuer != nil {
+			if v := valuer(evt, seg.field); v != nil {
+				val = fmt.Sprintf("%v", v)
+			}
+		}
+		if val == "" {
+			val = unknownField
+		}
+		b.WriteString(sanitize(val))
+	}
+	name := b.String()
+	if len(name) > maxTopicLength {
+		name = name[:maxTopicLength]
+	}
+	return name
+}
+
+// sanitize replaces characters that are not legal in topic names.
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
@@ -49,6 +49,12 @@ const (
 	HTTP
 	// Eventlog denotes the eventlog output.
 	Eventlog
+	// Kafka denotes the Kafka output.
+	Kafka
+	// Syslog denotes the syslog output.
+	Syslog
+	// File denotes the file output.
+	File
 	// Null is the null output.
 	Null
 	// Unknown is an undefined output type.
@@ -68,6 +74,12 @@ func (t Type) String() string {
 		return "http"
 	case Eventlog:
 		return "eventlog"
+	case Kafka:
+		return "kafka"
+	case Syslog:
+		return "syslog"
+	case File:
+		return "file"
 	case Null:
 		return "null"
 	default:
@@ -88,6 +100,12 @@ func TypeFromString(s string) Type {
 		return HTTP
 	case "eventlog":
 		return Eventlog
+	case "kafka":
+		return Kafka
+	case "syslog":
+		return Syslog
+	case "file":
+		return File
 	case "null":
 		return Null
 	default:
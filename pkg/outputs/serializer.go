@@ -24,4 +24,8 @@ type Serializer string
 const (
 	// JSON represents the JSON serializer type.
 	JSON Serializer = "json"
+	// CEF represents the ArcSight Common Event Format serializer type.
+	CEF Serializer = "cef"
+	// LEEF represents the QRadar Log Event Extended Format serializer type.
+	LEEF Serializer = "leef"
 )
@@ -0,0 +1,268 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package syslog
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rabbitstack/fibratus/pkg/event"
+	"github.com/rabbitstack/fibratus/pkg/outputs"
+	"github.com/rabbitstack/fibratus/pkg/util/version"
+)
+
+const (
+	vendor  = "Fibratus"
+	product = "Fibratus"
+	// leefTimeFormat is the devTime layout announced by the devTimeFormat attribute
+	leefTimeFormat = "MMM dd yyyy HH:mm:ss.SSS z"
+)
+
+// cefFields is the default mapping of CEF extension keys to filter fields.
+var cefFields = map[string]string{
+	"cat":      "evt.category",
+	"dvchost":  "evt.host",
+	"spid":     "ps.pid",
+	"sproc":    "ps.name",
+	"suser":    "ps.username",
+	"sntdom":   "ps.domain",
+	"filePath": "file.path",
+	"src":      "net.sip",
+	"dst":      "net.dip",
+	"spt":      "net.sport",
+	"dpt":      "net.dport",
+}
+
+// leefFields is the default mapping of LEEF attribute keys to filter fields.
+var leefFields = map[string]string{
+	"cat":           "evt.category",
+	"identHostName": "evt.host",
+	"usrName":       "ps.username",
+	"domain":        "ps.domain",
+	"src":           "net.sip",
+	"dst":           "net.dip",
+	"srcPort":       "net.sport",
+	"dstPort":       "net.dport",
+	"pid":           "ps.pid",
+	"proc":          "ps.name",
+	"exe":           "ps.exe",
+	"cmdline":       "ps.cmdline",
+	"file":          "file.path",
+	"rule":          "evt.rule.name",
+}
+
+// cefKeys contains the standard CEF extension keys. The configuration
+// loader lowercases map keys, so they are restored to canonical case.
+var cefKeys = []string{
+	"act", "app", "cat", "cnt", "cs1", "cs1Label", "cs2", "cs2Label", "cs3", "cs3Label",
+	"cs4", "cs4Label", "cs5", "cs5Label", "cs6", "cs6Label", "cn1", "cn1Label", "cn2",
+	"cn2Label", "cn3", "cn3Label", "deviceCustomDate1", "deviceCustomDate1Label",
+	"deviceExternalId", "deviceProcessName", "dhost", "dntdom", "dpid", "dpriv", "dproc",
+	"dpt", "dst", "duid", "duser", "dvc", "dvchost", "dvcpid", "externalId", "fileHash",
+	"filePath", "fname", "fsize", "msg", "oldFileName", "outcome", "proto", "reason",
+	"request", "requestMethod", "shost", "sntdom", "spid", "spriv", "sproc", "spt", "src",
+	"suid", "suser", "flexString1", "flexString1Label", "flexString2", "flexString2Label",
+}
+
+// leefKeys contains the predefined LEEF attribute keys and the
+// custom keys of the default mapping.
+var leefKeys = []string{
+	"cat", "sev", "devTime", "devTimeFormat", "proto", "src", "dst", "srcPort", "dstPort",
+	"srcPreNAT", "dstPreNAT", "srcPostNAT", "dstPostNAT", "srcMAC", "dstMAC", "srcBytes",
+	"dstBytes", "totalBytes", "usrName", "accountName", "identHostName", "identSrc",
+	"identNetBios", "identGrpName", "domain", "policy", "resource", "role", "url",
+	"pid", "proc", "exe", "cmdline", "file", "rule",
+}
+
+// ruleSeverityLevels maps rule severities to CEF/LEEF severity levels.
+var ruleSeverityLevels = map[string]int{
+	"low":      3,
+	"medium":   5,
+	"high":     8,
+	"critical": 10,
+}
+
+// encoder serializes the event into the message body.
+type encoder interface {
+	encode(buf *bytes.Buffer, evt *event.Event)
+}
+
+func newEncoder(c Config, valuer outputs.FieldValuer) encoder {
+	switch c.Serializer {
+	case outputs.CEF:
+		return cef{mapping: newMapping(cefFields, c.Fields, cefKeys), valuer: valuer}
+	case outputs.LEEF:
+		return leef{mapping: newMapping(leefFields, c.Fields, leefKeys), valuer: valuer}
+	default:
+		return jsonEncoder{}
+	}
+}
+
+type jsonEncoder struct{}
+
+func (jsonEncoder) encode(buf *bytes.Buffer, evt *event.Event) {
+	buf.Write(evt.MarshalJSON())
+}
+
+// mapping associates CEF extension or LEEF attribute keys with
+// filter fields. Keys are sorted to produce deterministic output.
+type mapping struct {
+	keys   []string
+	fields map[string]string
+}
+
+// newMapping merges the user-defined field mapping into the defaults.
+// The key is removed from the mapping if the user-defined field is empty.
+// User-defined keys matching any of the known keys are restored to the
+// canonical case.
+func newMapping(defaults, fields map[string]string, known []string) mapping {
+	m := mapping{fields: make(map[string]string, len(defaults)+len(fields))}
+	for k, v := range defaults {
+		m.fields[k] = v
+	}
+	canonical := make(map[string]string, len(known))
+	for _, k := range known {
+		canonical[strings.ToLower(k)] = k
+	}
+	for k, v := range fields {
+		if key, ok := canonical[strings.ToLower(k)]; ok {
+			k = key
+		}
+		if v == "" {
+			delete(m.fields, k)
+			continue
+		}
+		m.fields[k] = v
+	}
+	for k := range m.fields {
+		m.keys = append(m.keys, k)
+	}
+	sort.Strings(m.keys)
+	return m
+}
+
+// each invokes the callback for every key with the resolved
+// field value. Keys whose field values can't be resolved
+// are skipped. Single-quoted fields are treated as literals.
+func (m mapping) each(evt *event.Event, valuer outputs.FieldValuer, fn func(key, val string)) {
+	for _, key := range m.keys {
+		field := m.fields[key]
+		if len(field) > 1 && field[0] == '\'' && field[len(field)-1] == '\'' {
+			fn(key, field[1:len(field)-1])
+			continue
+		}
+		if valuer == nil {
+			continue
+		}
+		v := valuer(evt, field)
+		if v == nil {
+			continue
+		}
+		val := fmt.Sprintf("%v", v)
+		if val == "" {
+			continue
+		}
+		fn(key, val)
+	}
+}
+
+// cef serializes the event in ArcSight Common Event Format.
+type cef struct {
+	mapping mapping
+	valuer  outputs.FieldValuer
+}
+
+var (
+	cefHeaderEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ")
+	cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r", `\r`, "\n", `\n`)
+)
+
+func (e cef) encode(buf *bytes.Buffer, evt *event.Event) {
+	buf.WriteString("CEF:0|")
+	for _, s := range []string{vendor, product, version.Get(), evt.Name, eventName(evt)} {
+		buf.WriteString(cefHeaderEscaper.Replace(s))
+		buf.WriteByte('|')
+	}
+	buf.WriteString(strconv.Itoa(severityLevel(evt)))
+	buf.WriteString("|rt=")
+	buf.WriteString(strconv.FormatInt(evt.Timestamp.UnixMilli(), 10))
+	e.mapping.each(evt, e.valuer, func(key, val string) {
+		buf.WriteByte(' ')
+		buf.WriteString(key)
+		buf.WriteByte('=')
+		buf.WriteString(cefExtensionEscaper.Replace(val))
+	})
+}
+
+// leef serializes the event in QRadar Log Event Extended Format.
+type leef struct {
+	mapping mapping
+	valuer  outputs.FieldValuer
+}
+
+var (
+	leefHeaderEscaper    = strings.NewReplacer(`|`, `\|`, "\t", " ", "\r", " ", "\n", " ")
+	leefAttributeEscaper = strings.NewReplacer("\t", " ", "\r", " ", "\n", " ")
+)
+
+func (e leef) encode(buf *bytes.Buffer, evt *event.Event) {
+	buf.WriteString("LEEF:1.0|")
+	for _, s := range []string{vendor, product, version.Get(), evt.Name} {
+		buf.WriteString(leefHeaderEscaper.Replace(s))
+		buf.WriteByte('|')
+	}
+	buf.WriteString("devTime=")
+	buf.WriteString(evt.Timestamp.Format("Jan 02 2006 15:04:05.000 MST"))
+	buf.WriteString("\tdevTimeFormat=")
+	buf.WriteString(leefTimeFormat)
+	buf.WriteString("\tsev=")
+	buf.WriteString(strconv.Itoa(severityLevel(evt)))
+	e.mapping.each(evt, e.valuer, func(key, val string) {
+		buf.WriteByte('\t')
+		buf.WriteString(key)
+		buf.WriteByte('=')
+		buf.WriteString(leefAttributeEscaper.Replace(val))
+	})
+}
+
+// eventName returns the rule name for events matched
+// by rules. Otherwise, the event name is returned.
+func eventName(evt *event.Event) string {
+	if name := evt.GetMetaAsString(event.RuleNameKey); name != "" {
+		return name
+	}
+	return evt.Name
+}
+
+// severityLevel returns the 0-10 severity level. Events
+// matched by rules are assigned the level derived from the
+// rule severity.
+func severityLevel(evt *event.Event) int {
+	if evt.GetMeta(event.RuleNameKey) == nil {
+		return 1
+	}
+	level, ok := ruleSeverityLevels[strings.ToLower(evt.GetMetaAsString(event.RuleSeverityKey))]
+	if !ok {
+		return ruleSeverityLevels["low"]
+	}
+	return level
+}
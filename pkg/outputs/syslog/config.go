@@ -0,0 +1,144 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package syslog
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/rabbitstack/fibratus/pkg/outputs"
+)
+
+const (
+	syslogEnabled    = "output.syslog.enabled"
+	syslogNetwork    = "output.syslog.network"
+	syslogAddress    = "output.syslog.address"
+	syslogTimeout    = "output.syslog.timeout"
+	syslogFormat     = "output.syslog.format"
+	syslogFraming    = "output.syslog.framing"
+	syslogSerializer = "output.syslog.serializer"
+	syslogFacility   = "output.syslog.facility"
+	syslogSeverity   = "output.syslog.severity"
+	syslogAppName    = "output.syslog.app-name"
+	syslogHostname   = "output.syslog.hostname"
+)
+
+const (
+	// UDP sends each message in a separate datagram.
+	UDP = "udp"
+	// TCP sends messages over the plain TCP stream.
+	TCP = "tcp"
+	// TLS sends messages over the TLS-encrypted TCP stream.
+	TLS = "tls"
+)
+
+const (
+	// RFC5424 is the IETF syslog message format.
+	RFC5424 = "rfc5424"
+	// RFC3164 is the legacy BSD syslog message format.
+	RFC3164 = "rfc3164"
+)
+
+const (
+	// OctetCounting prepends each message with its length.
+	OctetCounting = "octet-counting"
+	// NonTransparent terminates each message with the line feed.
+	NonTransparent = "non-transparent"
+)
+
+// Config contains the options that influence the behaviour of the syslog output.
+type Config struct {
+	outputs.TLSConfig
+	// Enabled indicates if the syslog output is enabled.
+	Enabled bool `mapstructure:"enabled"`
+	// Network is the transport protocol used to deliver messages (udp, tcp, tls).
+	Network string `mapstructure:"network"`
+	// Address is the host:port address of the syslog receiver.
+	Address string `mapstructure:"address"`
+	// Timeout specifies the connection and write timeout.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// Format determines the syslog message format (rfc5424, rfc3164).
+	Format string `mapstructure:"format"`
+	// Framing determines how messages are delimited on stream transports (octet-counting, non-transparent).
+	Framing string `mapstructure:"framing"`
+	// Serializer indicates the serializer for the message body (json, cef, leef).
+	Serializer outputs.Serializer `mapstructure:"serializer"`
+	// Facility is the syslog facility name, e.g. local0.
+	Facility string `mapstructure:"facility"`
+	// Severity is the syslog severity of events not matched by rules.
+	Severity string `mapstructure:"severity"`
+	// AppName identifies the application that emits messages.
+	AppName string `mapstructure:"app-name"`
+	// Hostname overrides the event host name in the message header.
+	Hostname string `mapstructure:"hostname"`
+	// Fields maps CEF extension or LEEF attribute keys to filter fields.
+	// Single-quoted values are emitted as literals, and empty values
+	// remove the key from the default mapping.
+	Fields map[string]string `mapstructure:"fields"`
+}
+
+// AddFlags registers persistent flags for the syslog output.
+func AddFlags(flags *pflag.FlagSet) {
+	flags.Bool(syslogEnabled, false, "Indicates if the syslog output is enabled")
+	flags.String(syslogNetwork, UDP, "Transport protocol used to deliver messages (udp, tcp, tls)")
+	flags.String(syslogAddress, "localhost:514", "Represents the host:port address of the syslog receiver")
+	flags.Duration(syslogTimeout, time.Second*5, "Specifies the connection and write timeout")
+	flags.String(syslogFormat, RFC5424, "Determines the syslog message format (rfc5424, rfc3164)")
+	flags.String(syslogFraming, OctetCounting, "Determines how messages are delimited on TCP and TLS transports (octet-counting, non-transparent)")
+	flags.String(syslogSerializer, string(outputs.JSON), "Indicates the serializer for the message body (json, cef, leef)")
+	flags.String(syslogFacility, "local0", "Syslog facility name")
+	flags.String(syslogSeverity, "info", "Syslog severity of events not matched by rules")
+	flags.String(syslogAppName, "fibratus", "Identifies the application that emits messages")
+	flags.String(syslogHostname, "", "Overrides the event host name in the message header")
+	outputs.AddTLSFlags(flags, outputs.Syslog)
+	outputs.AddRoutingFlags(flags, outputs.Syslog)
+}
+
+// validate checks that the enumerated options have legal values.
+func (c Config) validate() error {
+	switch c.Network {
+	case UDP, TCP, TLS:
+	default:
+		return fmt.Errorf("unsupported network %q. Expected udp, tcp, or tls", c.Network)
+	}
+	switch c.Format {
+	case RFC5424, RFC3164:
+	default:
+		return fmt.Errorf("unsupported message format %q. Expected rfc5424 or rfc3164", c.Format)
+	}
+	switch c.Framing {
+	case OctetCounting, NonTransparent:
+	default:
+		return fmt.Errorf("unsupported framing %q. Expected octet-counting or non-transparent", c.Framing)
+	}
+	switch c.Serializer {
+	case outputs.JSON, outputs.CEF, outputs.LEEF:
+	default:
+		return fmt.Errorf("unsupported serializer %q. Expected json, cef, or leef", c.Serializer)
+	}
+	if _, ok := facilities[c.Facility]; !ok {
+		return fmt.Errorf("unknown facility %q", c.Facility)
+	}
+	if _, ok := severities[c.Severity]; !ok {
+		return fmt.Errorf("unknown severity %q", c.Severity)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package syslog
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rabbitstack/fibratus/pkg/event"
+)
+
+// facilities maps facility names to their numerical codes.
+var facilities = map[string]int{
+	"kern":     0,
+	"user":     1,
+	"mail":     2,
+	"daemon":   3,
+	"auth":     4,
+	"syslog":   5,
+	"lpr":      6,
+	"news":     7,
+	"uucp":     8,
+	"cron":     9,
+	"authpriv": 10,
+	"ftp":      11,
+	"local0":   16,
+	"local1":   17,
+	"local2":   18,
+	"local3":   19,
+	"local4":   20,
+	"local5":   21,
+	"local6":   22,
+	"local7":   23,
+}
+
+// severities maps severity names to their numerical codes.
+var severities = map[string]int{
+	"emerg":   0,
+	"alert":   1,
+	"crit":    2,
+	"err":     3,
+	"warning": 4,
+	"notice":  5,
+	"info":    6,
+	"debug":   7,
+}
+
+// ruleSeverities maps rule severity levels to syslog severities.
+var ruleSeverities = map[string]int{
+	"low":      severities["notice"],
+	"medium":   severities["warning"],
+	"high":     severities["err"],
+	"critical": severities["crit"],
+}
+
+// nilValue denotes the absent header field in RFC 5424 messages.
+const nilValue = "-"
+
+// header writes syslog message headers in the configured format.
+type header struct {
+	format   string
+	facility int
+	severity int
+	appName  string
+	hostname string
+	procID   string
+}
+
+func newHeader(c Config) header {
+	return header{
+		format:   c.Format,
+		facility: facilities[c.Facility],
+		severity: severities[c.Severity],
+		appName:  c.AppName,
+		hostname: c.Hostname,
+		procID:   strconv.Itoa(os.Getpid()),
+	}
+}
+
+// priority computes the PRI part of the message. Events
+// matched by rules are assigned the severity derived from
+// the rule severity.
+func (h header) priority(evt *event.Event) int {
+	severity := h.severity
+	if evt.GetMeta(event.RuleNameKey) != nil {
+		sev, ok := ruleSeverities[strings.ToLower(evt.GetMetaAsString(event.RuleSeverityKey))]
+		if !ok {
+			sev = ruleSeverities["low"]
+		}
+		severity = sev
+	}
+	return h.facility*8 + severity
+}
+
+// write appends the message header for the event to the buffer.
+func (h header) write(buf *bytes.Buffer, evt *event.Event) {
+	hostname := h.hostname
+	if hostname == "" {
+		hostname = evt.Host
+	}
+	buf.WriteByte('<')
+	buf.WriteString(strconv.Itoa(h.priority(evt)))
+	buf.WriteByte('>')
+
+	switch h.format {
+	case RFC3164:
+		buf.WriteString(evt.Timestamp.Format(time.Stamp))
+		buf.WriteByte(' ')
+		buf.WriteString(printable(hostname, 255, "localhost"))
+		buf.WriteByte(' ')
+		buf.WriteString(printable(h.appName, 32, "fibratus"))
+		buf.WriteByte('[')
+		buf.WriteString(h.procID)
+		buf.WriteString("]: ")
+	default:
+		buf.WriteString("1 ")
+		buf.WriteString(evt.Timestamp.Format("2006-01-02T15:04:05.000000Z07:00"))
+		buf.WriteByte(' ')
+		buf.WriteString(printable(hostname, 255, nilValue))
+		buf.WriteByte(' ')
+		buf.WriteString(printable(h.appName, 48, nilValue))
+		buf.WriteByte(' ')
+		buf.WriteString(printable(h.procID, 128, nilValue))
+		buf.WriteByte(' ')
+		buf.WriteString(printable(evt.Name, 32, nilValue))
+		// no structured data
+		buf.WriteString(" - ")
+	}
+}
+
+// printable strips characters not permitted in header
+// fields and truncates the value to the maximum length.
+func printable(s string, maxLen int, def string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, s)
+	if s == "" {
+		return def
+	}
+	if len(s) > maxLen {
+		return s[:maxLen]
+	}
+	return s
+}
@@ -0,0 +1,184 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package syslog
+
+import (
+	"bytes"
+	gotls "crypto/tls"
+	"expvar"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rabbitstack/fibratus/pkg/event"
+	"github.com/rabbitstack/fibratus/pkg/outputs"
+	"github.com/rabbitstack/fibratus/pkg/util/tls"
+)
+
+var (
+	// syslogErrors counts message delivery errors
+	syslogErrors = expvar.NewInt("output.syslog.publish.errors")
+	// syslogMessages counts the total number of sent messages
+	syslogMessages = expvar.NewInt("output.syslog.publish.messages")
+)
+
+type syslog struct {
+	config    Config
+	tlsConfig *gotls.Config
+	header    header
+	encoder   encoder
+
+	mu   sync.Mutex
+	conn net.Conn
+	buf  bytes.Buffer
+}
+
+func init() {
+	outputs.Register(outputs.Syslog, initSyslog)
+}
+
+func initSyslog(config outputs.Config) (outputs.OutputGroup, error) {
+	cfg, ok := config.Output.(Config)
+	if !ok {
+		return outputs.Fail(outputs.ErrInvalidConfig(outputs.Syslog, config.Output))
+	}
+	if err := cfg.validate(); err != nil {
+		return outputs.Fail(err)
+	}
+	s := &syslog{
+		config:  cfg,
+		header:  newHeader(cfg),
+		encoder: newEncoder(cfg, config.Valuer),
+	}
+	if cfg.Network == TLS {
+		tlsConfig, err := tls.MakeConfig(cfg.TLSCert, cfg.TLSKey, cfg.TLSCA, cfg.TLSInsecureSkipVerify)
+		if err != nil {
+			return outputs.Fail(err)
+		}
+		if tlsConfig == nil {
+			// rely on system root certificates
+			tlsConfig = &gotls.Config{InsecureSkipVerify: cfg.TLSInsecureSkipVerify}
+		}
+		s.tlsConfig = tlsConfig
+	}
+	return outputs.Success(s), nil
+}
+
+func (s *syslog) Connect() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dial()
+}
+
+func (s *syslog) dial() error {
+	var (
+		conn net.Conn
+		err  error
+	)
+	dialer := &net.Dialer{Timeout: s.config.Timeout}
+	switch s.config.Network {
+	case TLS:
+		conn, err = gotls.DialWithDialer(dialer, "tcp", s.config.Address, s.tlsConfig)
+	default:
+		conn, err = dialer.Dial(s.config.Network, s.config.Address)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to connect to syslog receiver %s: %v", s.config.Address, err)
+	}
+	s.conn = conn
+	return nil
+}
+
+func (s *syslog) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// Publish sends a syslog message for each event in the batch. On
+// stream transports, the messages are framed and written in a single
+// call. If the write fails, the connection is reestablished on the
+// next publish attempt.
+func (s *syslog) Publish(batch *event.Batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		if err := s.dial(); err != nil {
+			syslogErrors.Add(1)
+			return err
+		}
+	}
+	if err := s.send(batch); err != nil {
+		syslogErrors.Add(1)
+		_ = s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	syslogMessages.Add(int64(len(batch.Events)))
+	return nil
+}
+
+func (s *syslog) send(batch *event.Batch) error {
+	s.buf.Reset()
+	if s.config.Timeout > 0 {
+		if err := s.conn.SetWriteDeadline(time.Now().Add(s.config.Timeout)); err != nil {
+			return err
+		}
+	}
+	if s.config.Network == UDP {
+		// each message is sent in a separate datagram
+		for _, evt := range batch.Events {
+			s.buf.Reset()
+			s.format(&s.buf, evt)
+			if _, err := s.conn.Write(s.buf.Bytes()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var msg bytes.Buffer
+	for _, evt := range batch.Events {
+		msg.Reset()
+		s.format(&msg, evt)
+		switch s.config.Framing {
+		case NonTransparent:
+			s.buf.Write(msg.Bytes())
+			s.buf.WriteByte('\n')
+		default:
+			s.buf.WriteString(strconv.Itoa(msg.Len()))
+			s.buf.WriteByte(' ')
+			s.buf.Write(msg.Bytes())
+		}
+	}
+	_, err := s.conn.Write(s.buf.Bytes())
+	return err
+}
+
+// format writes the syslog message for the event to the buffer.
+func (s *syslog) format(buf *bytes.Buffer, evt *event.Event) {
+	s.header.write(buf, evt)
+	s.encoder.encode(buf, evt)
+}
@@ -0,0 +1,306 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package syslog
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rabbitstack/fibratus/pkg/event"
+	"github.com/rabbitstack/fibratus/pkg/event/params"
+	"github.com/rabbitstack/fibratus/pkg/outputs"
+	pstypes "github.com/rabbitstack/fibratus/pkg/ps/types"
+)
+
+var ts = time.Date(2024, time.March, 5, 14, 22, 33, 123456000, time.UTC)
+
+// valuer resolves a handful of fields for tests
+// without depending on the filter package.
+func valuer(evt *event.Event, field string) any {
+	switch field {
+	case "evt.category":
+		return evt.Category
+	case "evt.host":
+		return evt.Host
+	case "evt.rule.name":
+		return evt.GetMeta(event.RuleNameKey)
+	case "ps.pid":
+		return evt.PS.PID
+	case "ps.name":
+		return evt.PS.Name
+	case "ps.exe":
+		return evt.PS.Exe
+	case "ps.cmdline":
+		return evt.PS.Cmdline
+	case "ps.username":
+		return evt.PS.Username
+	case "file.path":
+		return evt.GetParamAsString(params.FilePath)
+	}
+	return nil
+}
+
+func newConfig(network string) Config {
+	return Config{
+		Network:    network,
+		Timeout:    time.Second * 5,
+		Format:     RFC5424,
+		Framing:    OctetCounting,
+		Serializer: outputs.JSON,
+		Facility:   "local0",
+		Severity:   "info",
+		AppName:    "fibratus",
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	require.NoError(t, newConfig(TCP).validate())
+
+	c := newConfig("quic")
+	require.Error(t, c.validate())
+
+	c = newConfig(UDP)
+	c.Format = "rfc6587"
+	require.Error(t, c.validate())
+
+	c = newConfig(UDP)
+	c.Serializer = "xml"
+	require.Error(t, c.validate())
+
+	c = newConfig(UDP)
+	c.Facility = "local9"
+	require.Error(t, c.validate())
+
+	c = newConfig(UDP)
+	c.Severity = "fatal"
+	require.Error(t, c.validate())
+
+	_, err := initSyslog(outputs.Config{Type: outputs.Syslog, Output: newConfig("quic")})
+	require.Error(t, err)
+}
+
+func TestHeader(t *testing.T) {
+	evt := getEvent(1)
+
+	var tests = []struct {
+		name   string
+		format string
+		rule   bool
+		sev    string
+		prefix string
+	}{
+		{"rfc5424", RFC5424, false, "", "<134>1 2024-03-05T14:22:33.123456Z archrabbit fibratus %d CreateFile - "},
+		{"rfc5424 high severity rule", RFC5424, true, "high", "<131>1 2024-03-05T14:22:33.123456Z archrabbit fibratus %d CreateFile - "},
+		{"rfc5424 critical severity rule", RFC5424, true, "critical", "<130>1 2024-03-05T14:22:33.123456Z archrabbit fibratus %d CreateFile - "},
+		{"rfc3164", RFC3164, false, "", "<134>Mar  5 14:22:33 archrabbit fibratus[%d]: "},
+		{"rfc3164 rule without severity", RFC3164, true, "", "<133>Mar  5 14:22:33 archrabbit fibratus[%d]: "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newConfig(UDP)
+			c.Format = tt.format
+			h := newHeader(c)
+			evt := getEvent(1)
+			if tt.rule {
+				evt.AddMeta(event.RuleNameKey, "Suspicious DLL load")
+				if tt.sev != "" {
+					evt.AddMeta(event.RuleSeverityKey, tt.sev)
+				}
+			}
+			var buf bytes.Buffer
+			h.write(&buf, evt)
+			assert.Equal(t, strings.Replace(tt.prefix, "%d", h.procID, 1), buf.String())
+		})
+	}
+
+	c := newConfig(UDP)
+	c.Hostname = "dc 01"
+	var buf bytes.Buffer
+	newHeader(c).write(&buf, evt)
+	assert.Contains(t, buf.String(), " dc01 ")
+}
+
+func TestCEF(t *testing.T) {
+	c := newConfig(UDP)
+	c.Serializer = outputs.CEF
+	c.Fields = map[string]string{"cs1": "ps.cmdline", "cs1label": "'CommandLine'", "dvchost": ""}
+	e := newEncoder(c, valuer)
+
+	evt := getEvent(1)
+	evt.AddMeta(event.RuleNameKey, "Suspicious|DLL load")
+	evt.AddMeta(event.RuleSeverityKey, "high")
+
+	var buf bytes.Buffer
+	e.encode(&buf, evt)
+
+	assert.Equal(t, `CEF:0|Fibratus|Fibratus|dev|CreateFile|Suspicious\|DLL load|8|rt=1709648553123 `+
+		`cat=file cs1=C:\\Windows\\notepad.exe --path\=C:\\Temp\\a.txt cs1Label=CommandLine `+
+		`filePath=C:\\Windows\\system32\\user32.dll spid=2436 sproc=notepad.exe suser=SYSTEM`, buf.String())
+}
+
+func TestLEEF(t *testing.T) {
+	c := newConfig(UDP)
+	c.Serializer = outputs.LEEF
+	c.Fields = map[string]string{"cmdline": "", "exe": "", "domain": ""}
+	e := newEncoder(c, valuer)
+
+	evt := getEvent(1)
+
+	var buf bytes.Buffer
+	e.encode(&buf, evt)
+
+	assert.Equal(t, "LEEF:1.0|Fibratus|Fibratus|dev|CreateFile|devTime=Mar 05 2024 14:22:33.123 UTC\t"+
+		"devTimeFormat=MMM dd yyyy HH:mm:ss.SSS z\tsev=1\tcat=file\t"+
+		`file=C:\Windows\system32\user32.dll`+"\tidentHostName=archrabbit\tpid=2436\tproc=notepad.exe\tusrName=SYSTEM", buf.String())
+}
+
+func TestPublishTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	msgs := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go readOctetCounted(conn, msgs)
+		}
+	}()
+
+	c := newConfig(TCP)
+	c.Address = l.Addr().String()
+	group, err := initSyslog(outputs.Config{Type: outputs.Syslog, Output: c, Valuer: valuer})
+	require.NoError(t, err)
+	s := group.Clients[0]
+
+	require.NoError(t, s.Connect())
+	defer s.Close()
+
+	require.NoError(t, s.Publish(event.NewBatch(getEvent(1), getEvent(2))))
+
+	for _, seq := range []string{`"seq":1`, `"seq":2`} {
+		select {
+		case msg := <-msgs:
+			assert.True(t, strings.HasPrefix(msg, "<134>1 2024-03-05T14:22:33.123456Z archrabbit fibratus "))
+			assert.Contains(t, msg, seq)
+		case <-time.After(time.Second * 5):
+			t.Fatal("timeout waiting for syslog message")
+		}
+	}
+
+	// the connection is reestablished after the write failure
+	s.(*syslog).conn.Close()
+	require.Error(t, s.Publish(event.NewBatch(getEvent(3))))
+	require.NoError(t, s.Publish(event.NewBatch(getEvent(4))))
+	select {
+	case msg := <-msgs:
+		assert.Contains(t, msg, `"seq":4`)
+	case <-time.After(time.Second * 5):
+		t.Fatal("timeout waiting for syslog message")
+	}
+}
+
+func TestPublishUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer pc.Close()
+
+	c := newConfig(UDP)
+	c.Address = pc.LocalAddr().String()
+	c.Format = RFC3164
+	c.Serializer = outputs.CEF
+	group, err := initSyslog(outputs.Config{Type: outputs.Syslog, Output: c, Valuer: valuer})
+	require.NoError(t, err)
+	s := group.Clients[0]
+
+	require.NoError(t, s.Connect())
+	defer s.Close()
+
+	require.NoError(t, s.Publish(event.NewBatch(getEvent(1), getEvent(2))))
+
+	b := make([]byte, 65535)
+	for i := 0; i < 2; i++ {
+		require.NoError(t, pc.SetReadDeadline(time.Now().Add(time.Second*5)))
+		n, _, err := pc.ReadFrom(b)
+		require.NoError(t, err)
+		msg := string(b[:n])
+		assert.True(t, strings.HasPrefix(msg, "<134>Mar  5 14:22:33 archrabbit fibratus["))
+		assert.Contains(t, msg, "CEF:0|Fibratus|Fibratus|dev|CreateFile|CreateFile|1|rt=1709648553123")
+	}
+}
+
+// readOctetCounted reads octet-counted frames from the connection.
+func readOctetCounted(conn net.Conn, msgs chan<- string) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		l, err := r.ReadString(' ')
+		if err != nil {
+			return
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(l))
+		if err != nil {
+			return
+		}
+		msg := make([]byte, n)
+		if _, err := io.ReadFull(r, msg); err != nil {
+			return
+		}
+		msgs <- string(msg)
+	}
+}
+
+func getEvent(seq uint64) *event.Event {
+	return &event.Event{
+		Type:      event.CreateFile,
+		Tid:       2484,
+		PID:       2436,
+		CPU:       1,
+		Seq:       seq,
+		Name:      "CreateFile",
+		Timestamp: ts,
+		Category:  event.File,
+		Host:      "archrabbit",
+		Params: event.Params{
+			params.FilePath: {Name: params.FilePath, Type: params.UnicodeString, Value: `C:\Windows\system32\user32.dll`},
+		},
+		Metadata: make(map[event.MetadataKey]any),
+		PS: &pstypes.PS{
+			PID:      2436,
+			Ppid:     6304,
+			Name:     "notepad.exe",
+			Exe:      `C:\Windows\notepad.exe`,
+			Cmdline:  `C:\Windows\notepad.exe --path=C:\Temp\a.txt`,
+			Username: "SYSTEM",
+		},
+	}
+}
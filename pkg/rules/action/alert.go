@@ -24,6 +24,7 @@ import (
 	"github.com/rabbitstack/fibratus/pkg/config"
 	"github.com/rabbitstack/fibratus/pkg/util/markdown"
 	log "github.com/sirupsen/logrus"
+	"maps"
 	"strings"
 )
 
@@ -52,6 +53,11 @@ func Alert(ctx *config.ActionContext, title string, text string, severity string
 		alert.ID = ctx.Filter.ID
 		alert.Events = ctx.Events
 		alert.Labels = ctx.Filter.Labels
+		if len(ctx.Labels) > 0 {
+			alert.Labels = make(map[string]string, len(ctx.Filter.Labels)+len(ctx.Labels))
+			maps.Copy(alert.Labels, ctx.Filter.Labels)
+			maps.Copy(alert.Labels, ctx.Labels)
+		}
 		alert.Description = ctx.Filter.Description
 
 		// strip markdown if not supported by the sender
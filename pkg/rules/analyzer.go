@@ -0,0 +1,197 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/rabbitstack/fibratus/pkg/config"
+	"github.com/rabbitstack/fibratus/pkg/filter"
+	"github.com/rabbitstack/fibratus/pkg/filter/fields"
+	"github.com/rabbitstack/fibratus/pkg/filter/ql"
+)
+
+// Diagnostic codes of the issues found in the rule metadata.
+const (
+	// DeprecatedField is reported when the rule references a deprecated field.
+	DeprecatedField = "deprecated-field"
+	// MissingLabel is reported when the rule lacks any of the MITRE ATT&CK labels.
+	MissingLabel = "missing-label"
+)
+
+// mitreLabels are the labels every rule is expected to declare.
+var mitreLabels = []string{"tactic.id", "tactic.name", "tactic.ref", "technique.id", "technique.name", "technique.ref"}
+
+// diagnosticDescs contains short descriptions of all diagnostic codes.
+var diagnosticDescs = map[string]string{
+	ql.TypeMismatch:    "Operator or operand is not applicable to the field type",
+	ql.AlwaysTrue:      "Expression matches every event",
+	ql.AlwaysFalse:     "Expression never matches",
+	ql.RedundantBranch: "Or branch is already covered by another branch",
+	ql.UnreachableStep: "Sequence step can never be reached",
+	ql.UnusedMacro:     "Macro is not referenced by any rule",
+	DeprecatedField:    "Rule references a deprecated field",
+	MissingLabel:       "Rule lacks the MITRE ATT&CK label",
+}
+
+// Finding is the diagnostic attributed to the rule. Findings
+// of unused macros don't pertain to any rule.
+type Finding struct {
+	ql.Diagnostic
+	// Rule is the rule name.
+	Rule string `json:"rule,omitempty"`
+	// RuleID is the rule identifier.
+	RuleID string `json:"rule_id,omitempty"`
+	// Resource is the file the rule was loaded from.
+	Resource string `json:"resource,omitempty"`
+}
+
+// Report contains findings of the static analysis of the ruleset.
+type Report struct {
+	// Rules is the number of analyzed rules.
+	Rules    int       `json:"rules"`
+	Findings []Finding `json:"findings"`
+}
+
+// Count returns the number of findings with the given severity.
+func (r *Report) Count(sev ql.Severity) int {
+	var n int
+	for _, f := range r.Findings {
+		if f.Severity == sev {
+			n++
+		}
+	}
+	return n
+}
+
+// HasErrors determines if any of the findings has the error severity.
+func (r *Report) HasErrors() bool { return r.Count(ql.Error) > 0 }
+
+// JSON encodes the report in JSON format.
+func (r *Report) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+func (r *Report) add(rule *config.FilterConfig, diags ...ql.Diagnostic) {
+	for _, d := range diags {
+		f := Finding{Diagnostic: d}
+		if rule != nil {
+			f.Rule, f.RuleID, f.Resource = rule.Name, rule.ID, rule.Resource()
+		}
+		r.Findings = append(r.Findings, f)
+	}
+}
+
+// Analyze compiles all loaded rules and runs the static analysis
+// pass over rule expressions. Field types are resolved from the field
+// metadata to report type mismatches. Besides expression diagnostics,
+// the report contains deprecated field references, missing MITRE
+// labels, and macros that are not referenced by any rule. The error
+// is returned if any of the rules fails to compile.
+func Analyze(cfg *config.Config) (*Report, error) {
+	report := &Report{Findings: make([]Finding, 0)}
+	refs := make(map[string]bool)
+
+	for _, rule := range cfg.GetFilters() {
+		f := filter.New(rule.Condition, cfg)
+		if err := f.Compile(); err != nil {
+			return nil, ErrInvalidFilter(rule.Name, err)
+		}
+		report.Rules++
+
+		switch {
+		case f.IsSequence():
+			report.add(rule, ql.AnalyzeSequence(f.GetSequence())...)
+		case f.IsThreshold():
+			report.add(rule, ql.Analyze(f.GetThreshold().Expr)...)
+		default:
+			report.add(rule, ql.Analyze(f.Expr())...)
+		}
+
+		for _, fld := range f.GetFields() {
+			if isDeprecated, dep := fields.IsDeprecated(fld.Name); isDeprecated {
+				report.add(rule, ql.Diagnostic{
+					Severity: ql.Warning,
+					Code:     DeprecatedField,
+					Message:  fmt.Sprintf("%s field deprecated in favor of %v", fld.Name.String(), dep.Fields),
+				})
+			}
+		}
+		for _, label := range mitreLabels {
+			if !rule.HasLabel(label) {
+				report.add(rule, ql.Diagnostic{
+					Severity: ql.Warning,
+					Code:     MissingLabel,
+					Message:  label + " label is missing",
+				})
+			}
+		}
+
+		for _, id := range ql.Identifiers(rule.Condition) {
+			refs[id] = true
+		}
+	}
+
+	report.add(nil, unusedMacros(cfg.Filters.GetMacros(), refs)...)
+
+	return report, nil
+}
+
+// unusedMacros reports macros that are not referenced by rules,
+// neither directly nor through other macros referenced by rules.
+func unusedMacros(macros map[string]*config.Macro, refs map[string]bool) []ql.Diagnostic {
+	queue := make([]string, 0, len(refs))
+	for id := range refs {
+		queue = append(queue, id)
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		macro, ok := macros[id]
+		if !ok || macro.Expr == "" {
+			continue
+		}
+		for _, ref := range ql.Identifiers(macro.Expr) {
+			if !refs[ref] {
+				refs[ref] = true
+				queue = append(queue, ref)
+			}
+		}
+	}
+
+	ids := make([]string, 0)
+	for id := range macros {
+		if !refs[id] {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	diags := make([]ql.Diagnostic, 0, len(ids))
+	for _, id := range ids {
+		diags = append(diags, ql.Diagnostic{
+			Severity: ql.Info,
+			Code:     ql.UnusedMacro,
+			Message:  fmt.Sprintf("macro %s is not referenced by any rule", id),
+		})
+	}
+	return diags
+}
@@ -0,0 +1,146 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rules
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/rabbitstack/fibratus/pkg/filter/ql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAnalyzerReport(t *testing.T) *Report {
+	c := newConfig("_fixtures/analyzer/rules/*.yml")
+	c.Filters.Macros.FromPaths = []string{"_fixtures/analyzer/macros.yml"}
+	require.NoError(t, c.Filters.LoadMacros())
+	require.NoError(t, c.Filters.LoadFilters())
+
+	report, err := Analyze(c)
+	require.NoError(t, err)
+	return report
+}
+
+func findings(report *Report, rule string) []Finding {
+	res := make([]Finding, 0)
+	for _, f := range report.Findings {
+		if f.Rule == rule {
+			res = append(res, f)
+		}
+	}
+	return res
+}
+
+func TestAnalyze(t *testing.T) {
+	report := newAnalyzerReport(t)
+
+	assert.Equal(t, 2, report.Rules)
+	assert.True(t, report.HasErrors())
+	assert.Equal(t, 3, report.Count(ql.Error))
+
+	lolbin := findings(report, "LOLBin spawned by Office process")
+	require.Len(t, lolbin, 1)
+	assert.Equal(t, ql.Error, lolbin[0].Severity)
+	assert.Equal(t, ql.TypeMismatch, lolbin[0].Code)
+	assert.Equal(t, "numeric field ps.pid can't be compared to string value 4", lolbin[0].Message)
+	assert.Equal(t, "0a5e7f3c-2b3c-4f0e-9d5a-0f8a7e1c3b21", lolbin[0].RuleID)
+	assert.True(t, strings.HasSuffix(lolbin[0].Resource, "type_mismatch.yml"))
+
+	seq := findings(report, "Executable dropped by spawned process")
+	codes := make(map[string]int)
+	for _, f := range seq {
+		codes[f.Code]++
+	}
+	assert.Equal(t, map[string]int{ql.AlwaysFalse: 1, ql.UnreachableStep: 1, MissingLabel: 6}, codes)
+
+	macros := findings(report, "")
+	require.Len(t, macros, 1)
+	assert.Equal(t, ql.Info, macros[0].Severity)
+	assert.Equal(t, ql.UnusedMacro, macros[0].Code)
+	assert.Equal(t, "macro write_file is not referenced by any rule", macros[0].Message)
+}
+
+func TestReportJSON(t *testing.T) {
+	report := newAnalyzerReport(t)
+
+	b, err := report.JSON()
+	require.NoError(t, err)
+
+	var out struct {
+		Rules    int              `json:"rules"`
+		Findings []map[string]any `json:"findings"`
+	}
+	require.NoError(t, json.Unmarshal(b, &out))
+	assert.Equal(t, 2, out.Rules)
+	require.Len(t, out.Findings, len(report.Findings))
+
+	for _, f := range out.Findings {
+		if f["code"] == ql.TypeMismatch {
+			assert.Equal(t, "error", f["severity"])
+			assert.Equal(t, "ps.pid != 4", f["expr"])
+			assert.Equal(t, "LOLBin spawned by Office process", f["rule"])
+		}
+	}
+}
+
+func TestReportSARIF(t *testing.T) {
+	report := newAnalyzerReport(t)
+
+	b, err := report.SARIF()
+	require.NoError(t, err)
+
+	var log sarifLog
+	require.NoError(t, json.Unmarshal(b, &log))
+	assert.Equal(t, "2.1.0", log.Version)
+	require.Len(t, log.Runs, 1)
+
+	run := log.Runs[0]
+	assert.Equal(t, "fibratus", run.Tool.Driver.Name)
+	ids := make([]string, 0)
+	for _, r := range run.Tool.Driver.Rules {
+		ids = append(ids, r.ID)
+		assert.NotEmpty(t, r.ShortDescription.Text)
+	}
+	assert.Equal(t, []string{ql.AlwaysFalse, MissingLabel, ql.TypeMismatch, ql.UnreachableStep, ql.UnusedMacro}, ids)
+	require.Len(t, run.Results, len(report.Findings))
+
+	for _, res := range run.Results {
+		switch res.RuleID {
+		case ql.TypeMismatch:
+			assert.Equal(t, "error", res.Level)
+			require.Len(t, res.Locations, 1)
+			assert.Equal(t, "_fixtures/analyzer/rules/type_mismatch.yml", res.Locations[0].PhysicalLocation.ArtifactLocation.URI)
+			assert.Equal(t, "LOLBin spawned by Office process", res.Locations[0].LogicalLocations[0].Name)
+			assert.Equal(t, "ps.pid != 4", res.Properties["expr"])
+		case MissingLabel:
+			assert.Equal(t, "warning", res.Level)
+		case ql.UnusedMacro:
+			assert.Equal(t, "note", res.Level)
+			assert.Empty(t, res.Locations)
+		}
+	}
+}
+
+func TestArtifactURI(t *testing.T) {
+	assert.Equal(t, "https://rules.fibratus.io/rule.yml", artifactURI("https://rules.fibratus.io/rule.yml"))
+	assert.Equal(t, "file:///C:/Program%20Files/Fibratus/Rules/rule.yml", artifactURI("C:\\Program Files\\Fibratus\\Rules\\rule.yml"))
+	assert.Equal(t, "rules/rule.yml", artifactURI("rules\\rule.yml"))
+}
@@ -0,0 +1,364 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rules
+
+import (
+	"bufio"
+	"encoding/gob"
+	"expvar"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rabbitstack/fibratus/pkg/event"
+	"github.com/rabbitstack/fibratus/pkg/util/hashers"
+	log "github.com/sirupsen/logrus"
+)
+
+// checkpointVersion is the version of the sequence state checkpoint format
+const checkpointVersion = 1
+
+var (
+	// checkpointErrors counts the number of failed sequence state checkpoints and restores
+	checkpointErrors = expvar.NewInt("sequence.checkpoint.errors")
+	// checkpointRestoredRules counts the number of sequence rules restored from the checkpoint
+	checkpointRestoredRules = expvar.NewInt("sequence.checkpoint.restored.rules")
+	// checkpointDiscardedRules counts the number of stale sequence rules discarded on restore
+	checkpointDiscardedRules = expvar.NewInt("sequence.checkpoint.discarded.rules")
+)
+
+func init() {
+	// sequence links may contain IP addresses
+	gob.Register(net.IP{})
+}
+
+// checkpoint is the persisted state of all sequence rules.
+type checkpoint struct {
+	Version   uint8
+	Timestamp time.Time
+	Sequences []sequenceSnapshot
+}
+
+// sequenceSnapshot is the persisted state of the sequence rule.
+type sequenceSnapshot struct {
+	// ID, Version, and Fingerprint identify the rule revision
+	ID          string
+	Version     string
+	Fingerprint uint64
+	// State is the current state of the state machine
+	State int
+	// Matches contains the indices of matched expressions
+	Matches []int
+	// LastMatch is the timestamp of the last matched event
+	LastMatch time.Time
+	// Deadlines contains max span deadlines indexed by the expression
+	Deadlines map[int]time.Time
+	Partials  []partialSnapshot
+}
+
+// partialSnapshot is the persisted sequence partial. The event
+// is stored in the raw format as found in capture files.
+type partialSnapshot struct {
+	Slot  int
+	Event []byte
+	Links []any
+	IsOOO bool
+	// Deadline is the absence deadline of the partial
+	// preceding the trailing negated expression
+	Deadline time.Time
+}
+
+func newPartialSnapshot(seqID int, e *event.Event) partialSnapshot {
+	return partialSnapshot{
+		Slot:  seqID,
+		Event: e.MarshalRaw(),
+		Links: e.SequenceLinks(),
+		IsOOO: e.ContainsMeta(event.RuleSequenceOOOKey),
+	}
+}
+
+// snapshot captures the partials, matched expressions, and max
+// span deadlines of the sequence. Returns nil if the sequence
+// has no in-flight state.
+func (s *sequenceState) snapshot() *sequenceSnapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.smu.RLock()
+	defer s.smu.RUnlock()
+
+	// terminal, deadline, and expired states are transient
+	state, ok := s.currentState().(int)
+	if !ok {
+		return nil
+	}
+
+	snap := &sequenceSnapshot{
+		State:     state,
+		LastMatch: s.lastMatch,
+		Matches:   make([]int, 0, len(s.states)),
+		Deadlines: make(map[int]time.Time),
+		Partials:  make([]partialSnapshot, 0),
+	}
+	for st, matched := range s.states {
+		if seqID, ok := st.(int); ok && matched {
+			snap.Matches = append(snap.Matches, seqID)
+		}
+	}
+	for st, deadline := range s.deadlines {
+		if seqID, ok := st.(int); ok {
+			snap.Deadlines[seqID] = deadline
+		}
+	}
+	for seqID, partials := range s.partials {
+		for _, e := range partials {
+			p := newPartialSnapshot(seqID, e)
+			if d, ok := s.absences[e]; ok {
+				p.Deadline = d.at
+			}
+			snap.Partials = append(snap.Partials, p)
+		}
+	}
+	if s.spill != nil {
+		spilled, err := s.spill.peek()
+		if err != nil {
+			log.Warnf("unable to read spilled partials of sequence %s: %v", s.name, err)
+		}
+		for seqID, partials := range spilled {
+			for _, e := range partials {
+				snap.Partials = append(snap.Partials, newPartialSnapshot(seqID, e))
+			}
+		}
+	}
+
+	if len(snap.Partials) == 0 {
+		return nil
+	}
+
+	return snap
+}
+
+// restore rebuilds the sequence state from the snapshot. Partials
+// that outlived their lifetime are discarded. Returns false if the
+// snapshot is stale, that is, any of the max span deadlines elapsed
+// or partials of matched expressions are gone.
+func (s *sequenceState) restore(snap *sequenceSnapshot) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.smu.Lock()
+	defer s.smu.Unlock()
+
+	nexprs := len(s.seq.Expressions)
+	if snap.State < 0 || snap.State >= nexprs {
+		return false
+	}
+
+	now := time.Now()
+	for _, deadline := range snap.Deadlines {
+		if !deadline.After(now) {
+			return false
+		}
+	}
+
+	lifetime := s.partialLifetime()
+	partials := make(map[int][]*event.Event)
+	absences := make(map[*event.Event]time.Time)
+	for _, p := range snap.Partials {
+		if p.Slot < 0 || p.Slot >= nexprs {
+			return false
+		}
+		e, err := decodePartial(p.Event, p.Links, p.IsOOO)
+		if err != nil {
+			log.Warnf("unable to restore partial of sequence %s: %v", s.name, err)
+			continue
+		}
+		if now.Sub(e.Timestamp) > lifetime {
+			continue
+		}
+		_, e.PS = s.psnap.Find(e.PID)
+		partials[p.Slot] = append(partials[p.Slot], e)
+		absences[e] = p.Deadline
+	}
+
+	for _, seqID := range snap.Matches {
+		if seqID < 0 || seqID >= nexprs {
+			return false
+		}
+		if !s.seq.Expressions[seqID].IsNegated && len(partials[seqID]) == 0 {
+			return false
+		}
+	}
+
+	s.initFSM(snap.State)
+	s.configureFSM()
+
+	for _, seqID := range snap.Matches {
+		s.states[seqID] = true
+	}
+	s.lastMatch = snap.LastMatch
+	for seqID, evts := range partials {
+		for _, e := range evts {
+			s.appendPartial(seqID, e)
+		}
+	}
+	for seqID, deadline := range snap.Deadlines {
+		if s.isTrailingNegated(seqID) {
+			continue
+		}
+		s.scheduleMaxSpanDeadline(seqID, time.Until(deadline))
+	}
+	if slot := s.absenceSlot(); slot >= 0 {
+		for _, e := range s.partials[slot] {
+			maxSpan := s.maxSpan
+			if deadline := absences[e]; !deadline.IsZero() {
+				maxSpan = max(time.Until(deadline), 0)
+			}
+			s.scheduleAbsenceDeadline(e, maxSpan)
+		}
+	}
+
+	return true
+}
+
+// ruleFingerprint returns the hash of the rule condition
+// and the compiled sequence expressions.
+func ruleFingerprint(fltr *compiledFilter) uint64 {
+	return hashers.FnvUint64([]byte(fingerprint(fltr.config, fltr.filter)))
+}
+
+// startCheckpointer starts periodically writing the sequence
+// state to the state file if the persistence is enabled.
+func (e *Engine) startCheckpointer() {
+	if e.config.Filters.Sequences.StateFile == "" || e.checkpointer != nil {
+		return
+	}
+	t := time.NewTicker(e.config.Filters.Sequences.GetCheckpointInterval())
+	e.checkpointer = t
+	go func() {
+		for {
+			select {
+			case <-t.C:
+				if err := e.checkpoint(); err != nil {
+					checkpointErrors.Add(1)
+					log.Warnf("unable to checkpoint sequence state: %v", err)
+				}
+			case <-e.quit:
+				return
+			}
+		}
+	}()
+}
+
+// checkpoint writes the state of all sequence rules to the
+// state file. The state is written to the temporary file which
+// replaces the state file, so the state file is never left
+// partially written.
+func (e *Engine) checkpoint() error {
+	e.cmu.Lock()
+	defer e.cmu.Unlock()
+
+	cp := checkpoint{Version: checkpointVersion, Timestamp: time.Now()}
+	e.rmu.RLock()
+	for _, fltr := range e.rules {
+		if fltr.ss == nil {
+			continue
+		}
+		snap := fltr.ss.snapshot()
+		if snap == nil {
+			continue
+		}
+		snap.ID = fltr.config.ID
+		snap.Version = fltr.config.Version
+		snap.Fingerprint = ruleFingerprint(fltr)
+		cp.Sequences = append(cp.Sequences, *snap)
+	}
+	e.rmu.RUnlock()
+
+	path := e.config.Filters.Sequences.StateFile
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err := gob.NewEncoder(w).Encode(cp); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	log.Debugf("checkpointed state of %d sequence rule(s) to %s", len(cp.Sequences), path)
+
+	return os.Rename(tmp, path)
+}
+
+// restoreSequences restores the sequence state from the state file.
+// The state of rules that were removed or whose condition or version
+// changed since the checkpoint is discarded.
+func (e *Engine) restoreSequences(rs *ruleset) error {
+	path := e.config.Filters.Sequences.StateFile
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	var cp checkpoint
+	if err := gob.NewDecoder(bufio.NewReader(f)).Decode(&cp); err != nil {
+		return fmt.Errorf("unable to decode sequence state from %s: %v", path, err)
+	}
+	if cp.Version != checkpointVersion {
+		return fmt.Errorf("unsupported sequence state version: %d", cp.Version)
+	}
+
+	var restored int
+	for i := range cp.Sequences {
+		snap := &cp.Sequences[i]
+		fltr, ok := rs.rules[snap.ID]
+		if !ok || fltr.ss == nil || fltr.config.Version != snap.Version || ruleFingerprint(fltr) != snap.Fingerprint {
+			log.Debugf("discarding state of sequence rule %s. The rule was modified or removed", snap.ID)
+			checkpointDiscardedRules.Add(1)
+			continue
+		}
+		if !fltr.ss.restore(snap) {
+			log.Debugf("discarding stale state of sequence rule %q", fltr.config.Name)
+			checkpointDiscardedRules.Add(1)
+			continue
+		}
+		restored++
+	}
+	checkpointRestoredRules.Add(int64(restored))
+
+	log.Infof("restored state of %d sequence rule(s) checkpointed at %s", restored, cp.Timestamp.Format(time.RFC3339))
+
+	return nil
+}
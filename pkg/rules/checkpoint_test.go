@@ -0,0 +1,97 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rules
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rabbitstack/fibratus/pkg/event"
+	"github.com/rabbitstack/fibratus/pkg/event/params"
+	"github.com/rabbitstack/fibratus/pkg/ps"
+	pstypes "github.com/rabbitstack/fibratus/pkg/ps/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSequenceCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sequence.yml")
+	writeReloadRule(t, path, "C:\\\\a.txt", "first output")
+
+	newEngine := func(psnap ps.Snapshotter) *Engine {
+		c := newConfig(path)
+		c.Filters.Sequences.StateFile = filepath.Join(dir, "sequences.state")
+		e := NewEngine(psnap, c)
+		compileRules(t, e)
+		return e
+	}
+
+	e := newEngine(new(ps.SnapshotterMock))
+	require.False(t, wrapProcessEvent(newFileEvent(event.CreateFile, 1, "C:\\a.txt", time.Now()), e.ProcessEvent))
+	require.Len(t, e.sequences[0].partials[0], 1)
+	require.NoError(t, e.Close())
+	require.FileExists(t, filepath.Join(dir, "sequences.state"))
+
+	psnap := new(ps.SnapshotterMock)
+	psnap.On("Find", uint32(1)).Return(true, &pstypes.PS{PID: 1, Name: "cmd.exe"})
+
+	// the partials, matched expressions, and
+	// deadlines are restored from the checkpoint
+	e = newEngine(psnap)
+	ss := e.sequences[0]
+	require.Len(t, ss.partials[0], 1)
+	assert.Equal(t, "C:\\a.txt", ss.partials[0][0].GetParamAsString(params.FilePath))
+	assert.Equal(t, 1, ss.currentState())
+	assert.True(t, ss.states[0])
+	assert.Contains(t, ss.spanDeadlines, 1)
+	require.True(t, wrapProcessEvent(newFileEvent(event.CreateFile, 1, "C:\\b.txt", time.Now().Add(time.Second)), e.ProcessEvent))
+	require.NoError(t, e.Close())
+
+	e = newEngine(new(ps.SnapshotterMock))
+	require.False(t, wrapProcessEvent(newFileEvent(event.CreateFile, 1, "C:\\a.txt", time.Now()), e.ProcessEvent))
+	require.NoError(t, e.Close())
+
+	// the state of the modified rule is discarded
+	writeReloadRule(t, path, "C:\\\\c.txt", "first output")
+	e = newEngine(new(ps.SnapshotterMock))
+	assert.Len(t, e.sequences[0].partials[0], 0)
+	assert.True(t, e.sequences[0].isInitialState())
+	require.NoError(t, e.Close())
+}
+
+func TestSequenceRestoreElapsedDeadline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sequence.yml")
+	writeReloadRule(t, path, "C:\\\\a.txt", "first output")
+
+	e := NewEngine(new(ps.SnapshotterMock), newConfig(path))
+	compileRules(t, e)
+	require.False(t, wrapProcessEvent(newFileEvent(event.CreateFile, 1, "C:\\a.txt", time.Now()), e.ProcessEvent))
+
+	snap := e.sequences[0].snapshot()
+	require.NotNil(t, snap)
+	require.Len(t, snap.Partials, 1)
+	snap.Deadlines[1] = time.Now().Add(-time.Minute)
+
+	e = NewEngine(new(ps.SnapshotterMock), newConfig(path))
+	compileRules(t, e)
+	assert.False(t, e.sequences[0].restore(snap))
+	assert.True(t, e.sequences[0].isInitialState())
+}
@@ -21,6 +21,7 @@ package rules
 import (
 	"expvar"
 	"fmt"
+	"maps"
 	"slices"
 	"strings"
 
@@ -30,6 +31,7 @@ import (
 	"github.com/rabbitstack/fibratus/pkg/event/params"
 	"github.com/rabbitstack/fibratus/pkg/filter"
 	"github.com/rabbitstack/fibratus/pkg/filter/fields"
+	"github.com/rabbitstack/fibratus/pkg/filter/lookup"
 	"github.com/rabbitstack/fibratus/pkg/filter/ql"
 	"github.com/rabbitstack/fibratus/pkg/ps"
 	"github.com/rabbitstack/fibratus/pkg/util/version"
@@ -39,6 +41,8 @@ import (
 var (
 	// filtersCount computes the total number of filters in the ruleset
 	filtersCount = expvar.NewInt("filter.filters.count")
+	// sharedExprsCount computes the number of distinct subexpressions shared across filters
+	sharedExprsCount = expvar.NewInt("filter.shared.exprs.count")
 
 	ErrInvalidFilter = func(rule string, err error) error {
 		return fmt.Errorf("syntax error in rule %q: \n%v", rule, err)
@@ -55,12 +59,28 @@ var (
 	ErrUnknownCategoryName = func(rule, name string) error {
 		return fmt.Errorf("rule %s references an invalid event category %q in the evt.category field", rule, name)
 	}
+	ErrUnknownThrottleField = func(rule, field string) error {
+		return fmt.Errorf("rule %s references an invalid field %q in the throttle group-by fields", rule, field)
+	}
+	ErrUnknownLookupTable = func(rule, table string) error {
+		return fmt.Errorf("rule %s references an unknown lookup table %q", rule, table)
+	}
+	ErrInvalidException = func(rule string, err error) error {
+		return fmt.Errorf("syntax error in exception for rule %s: \n%v", rule, err)
+	}
 )
 
 type compiler struct {
 	psnap     ps.Snapshotter
 	config    *config.Config
 	approvers config.Approvers
+	// filters, if set, contains the rules compiled
+	// instead of the rules loaded from the config
+	filters []*config.FilterConfig
+	// tables contains the lookup tables read by the
+	// compiler. They are published once the ruleset
+	// compiles successfully
+	tables lookup.Tables
 }
 
 func newCompiler(psnap ps.Snapshotter, cfg *config.Config) *compiler {
@@ -77,13 +97,21 @@ func (c *compiler) compile() (map[*config.FilterConfig]filter.Filter, *config.Ru
 	if err := c.config.Filters.LoadMacros(); err != nil {
 		return nil, nil, err
 	}
-	if err := c.config.Filters.LoadFilters(); err != nil {
+	tables, err := lookup.Read(c.config.Filters.Lookups.Tables)
+	if err != nil {
 		return nil, nil, err
 	}
+	c.tables = tables
+	if c.filters == nil {
+		if err := c.config.Filters.LoadFilters(); err != nil {
+			return nil, nil, err
+		}
+	}
 
 	filters := make(map[*config.FilterConfig]filter.Filter)
+	filtersCount.Set(0)
 
-	for _, f := range c.config.GetFilters() {
+	for _, f := range c.rules() {
 		if f.IsDisabled() {
 			log.Warnf("[%s] rule is disabled", f.Name)
 			continue
@@ -92,7 +120,11 @@ func (c *compiler) compile() (map[*config.FilterConfig]filter.Filter, *config.Ru
 		filtersCount.Add(1)
 
 		// compile the filter
-		fltr := filter.New(f.Condition, c.config, filter.WithPSnapshotter(c.psnap))
+		opts := []filter.Option{filter.WithPSnapshotter(c.psnap)}
+		if c.config.Filters.Rules.Optimize {
+			opts = append(opts, filter.WithOptimizer())
+		}
+		fltr := filter.New(f.Condition, c.config, opts...)
 		err := fltr.Compile()
 		if err != nil {
 			return nil, nil, ErrInvalidFilter(f.Name, err)
@@ -108,6 +140,15 @@ func (c *compiler) compile() (map[*config.FilterConfig]filter.Filter, *config.Ru
 			}
 		}
 
+		// validate throttle group-by fields
+		if f.Throttle != nil {
+			for _, field := range f.Throttle.By {
+				if !fields.IsField(trimFieldModifiers(field)) {
+					return nil, nil, ErrUnknownThrottleField(f.Name, field)
+				}
+			}
+		}
+
 		// output warning for deprecated fields
 		for _, field := range fltr.GetFields() {
 			deprecated, d := fields.IsDeprecated(field.Name)
@@ -137,13 +178,23 @@ func (c *compiler) compile() (map[*config.FilterConfig]filter.Filter, *config.Ru
 		}
 
 		// visit filter or sequence expressions
-		// to extract approver predicates
-		expr := fltr.Expr()
-		if expr != nil {
-			c.visitApproverPredicates(expr)
-		} else {
+		// to extract approver predicates and
+		// validate referenced lookup tables
+		var exprs []ql.Expr
+		switch {
+		case fltr.Expr() != nil:
+			exprs = append(exprs, fltr.Expr())
+		case fltr.IsThreshold():
+			exprs = append(exprs, fltr.GetThreshold().Expr)
+		default:
 			for _, expr := range fltr.GetSequence().Expressions {
-				c.visitApproverPredicates(expr.Expr)
+				exprs = append(exprs, expr.Expr)
+			}
+		}
+		for _, expr := range exprs {
+			c.visitApproverPredicates(expr)
+			if table := unknownLookupTable(expr, c.tables); table != "" {
+				return nil, nil, ErrUnknownLookupTable(f.Name, table)
 			}
 		}
 
@@ -154,6 +205,13 @@ func (c *compiler) compile() (map[*config.FilterConfig]filter.Filter, *config.Ru
 		return filters, nil, nil
 	}
 
+	// evaluate subexpressions shared by
+	// multiple rules once per event
+	sharedExprsCount.Set(0)
+	if c.config.Filters.Rules.Optimize {
+		sharedExprsCount.Set(int64(filter.Share(slices.Collect(maps.Values(filters)))))
+	}
+
 	r := c.buildCompileResult(filters)
 	if r != nil {
 		r.Approvers = c.approvers
@@ -162,6 +220,59 @@ func (c *compiler) compile() (map[*config.FilterConfig]filter.Filter, *config.Ru
 	return filters, r, nil
 }
 
+// rules returns the rules subject to compilation.
+func (c *compiler) rules() []*config.FilterConfig {
+	if c.filters != nil {
+		return c.filters
+	}
+	return c.config.GetFilters()
+}
+
+// trimFieldModifiers removes the leading sequence ordinal
+// and the trailing argument from the field name, e.g.
+// 2.evt.arg[exe] yields evt.arg.
+func trimFieldModifiers(field string) string {
+	if len(field) > 2 && field[0] >= '1' && field[0] <= '9' && field[1] == '.' {
+		field = field[2:]
+	}
+	if n := strings.Index(field, "["); n > 0 {
+		field = field[:n]
+	}
+	return field
+}
+
+// compileExceptions loads rule exceptions and compiles their
+// conditions. Exceptions are indexed by the rule identifier.
+// Exceptions referencing unknown rules are discarded.
+func (c *compiler) compileExceptions() (exceptions, error) {
+	if err := c.config.Filters.LoadExceptions(); err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]bool)
+	for _, f := range c.rules() {
+		ids[f.ID] = true
+	}
+
+	excs := make(exceptions)
+	for _, exc := range c.config.Filters.GetExceptions() {
+		if !ids[exc.RuleID] {
+			log.Warnf("exception owned by %s references unknown rule %s", exc.Owner, exc.RuleID)
+			continue
+		}
+		fltr := filter.New(exc.Condition, c.config, filter.WithPSnapshotter(c.psnap))
+		if err := fltr.Compile(); err != nil {
+			return nil, ErrInvalidException(exc.RuleID, err)
+		}
+		if fltr.IsSequence() || fltr.IsThreshold() {
+			return nil, ErrInvalidException(exc.RuleID, fmt.Errorf("exception condition must be a simple expression"))
+		}
+		excs[exc.RuleID] = append(excs[exc.RuleID], newException(fltr, exc))
+	}
+
+	return excs, nil
+}
+
 func (c *compiler) visitApproverPredicates(node ql.Node) {
 	walk := func(n ql.Node) {
 		expr, ok := n.(*ql.BinaryExpr)
@@ -220,6 +331,24 @@ func (c *compiler) visitApproverPredicates(node ql.Node) {
 	ql.WalkFunc(node, walk)
 }
 
+// unknownLookupTable returns the name of the first
+// lookup table referenced in the expression that is
+// not present in the given lookup tables.
+func unknownLookupTable(root ql.Node, tables lookup.Tables) string {
+	var table string
+	ql.WalkFunc(root, func(n ql.Node) {
+		fn, ok := n.(*ql.Function)
+		if !ok || !fn.IsLookup() || len(fn.Args) == 0 || table != "" {
+			return
+		}
+		name, ok := fn.Args[0].(*ql.StringLiteral)
+		if ok && tables[name.Value] == nil {
+			table = name.Value
+		}
+	})
+	return table
+}
+
 // referencesTargetEvents checks whether the rule AST contains
 // an event type filter for high-volume events we want to approve.
 func (c *compiler) referencesApproverEvents(root ql.Node) bool {
@@ -21,6 +21,8 @@ package rules
 import (
 	"expvar"
 	"fmt"
+	"reflect"
+	"strconv"
 	"sync"
 	"time"
 
@@ -28,6 +30,8 @@ import (
 	"github.com/rabbitstack/fibratus/pkg/event"
 	"github.com/rabbitstack/fibratus/pkg/filter"
 	"github.com/rabbitstack/fibratus/pkg/filter/fields"
+	"github.com/rabbitstack/fibratus/pkg/filter/lookup"
+	"github.com/rabbitstack/fibratus/pkg/filter/rarity"
 	"github.com/rabbitstack/fibratus/pkg/ps"
 	"github.com/rabbitstack/fibratus/pkg/rules/action"
 	log "github.com/sirupsen/logrus"
@@ -53,19 +57,72 @@ var (
 // the collection of compiled filters that are derived
 // from the loaded ruleset.
 type Engine struct {
-	filters *filterset
-	config  *config.Config
-	psnap   ps.Snapshotter
+	*ruleset
+	// rmu guards the ruleset which is
+	// swapped when the rules are reloaded
+	rmu sync.RWMutex
+	// reloadMu serializes ruleset reloads
+	reloadMu sync.Mutex
 
-	matches   []*ruleMatch
-	mmu       sync.Mutex // guards the rule matches slice
-	sequences []*sequenceState
+	config *config.Config
+	psnap  ps.Snapshotter
+
+	matches []*ruleMatch
+	mmu     sync.Mutex // guards the rule matches slice
 
 	scavenger *time.Ticker
+	// checkpointer periodically writes
+	// the sequence state to the state file
+	checkpointer *time.Ticker
+	// cmu serializes sequence state checkpoints
+	cmu  sync.Mutex
+	quit chan struct{}
+	// lookups periodically reloads
+	// the modified lookup tables
+	lookups *lookup.Refresher
+	// rarity is the frequency store backing
+	// the first_seen/seen_count functions
+	rarity *rarity.Store
 
 	compiler *compiler
+	// compileResult is the result of the initial
+	// ruleset compilation that determines enabled
+	// event providers
+	compileResult *config.RulesCompileResult
 
 	matchFunc RuleMatchFunc
+	// dryRun disables alerting and rule actions
+	dryRun bool
+}
+
+// ruleset contains the compiled filters along with
+// the state of sequence and threshold rules, rule
+// exceptions and alert throttles.
+type ruleset struct {
+	filters    *filterset
+	sequences  []*sequenceState
+	thresholds []*thresholdState
+	exceptions exceptions
+	throttles  map[string]*throttle
+	// rules contains compiled filters indexed by rule ID
+	rules map[string]*compiledFilter
+	// carried contains the compiled filters of unchanged
+	// rules that preserve their state from the previous
+	// ruleset. The rule config of the carried filter is
+	// replaced when the ruleset is swapped
+	carried map[*compiledFilter]*config.FilterConfig
+}
+
+func newRuleset() *ruleset {
+	return &ruleset{
+		filters:    newFilterset(),
+		sequences:  make([]*sequenceState, 0),
+		thresholds: make([]*thresholdState, 0),
+		exceptions: make(exceptions),
+		throttles:  make(map[string]*throttle),
+		rules:      make(map[string]*compiledFilter),
+		carried:    make(map[*compiledFilter]*config.FilterConfig),
+	}
 }
 
 type ruleMatch struct {
@@ -76,12 +133,21 @@ type compiledFilter struct {
 	filter filter.Filter
 	config *config.FilterConfig
 	ss     *sequenceState
+	ts     *thresholdState
+	// prof records the evaluation cost
+	// if the rule profiling is enabled
+	prof *ruleProfile
 }
 
 // filterset contains compiled filters indexed by event type and category.
 type filterset struct {
 	types      map[event.Type][]*compiledFilter
 	categories map[uint8][]*compiledFilter
+	// typeIndex and categoryIndex contain the filters of each
+	// event type and category indexed by field predicates. The
+	// index is only built if the rule optimizer is enabled
+	typeIndex     map[event.Type]*bucket
+	categoryIndex map[uint8]*bucket
 }
 
 func newFilterset() *filterset {
@@ -103,8 +169,8 @@ func (f *filterset) collect(e *event.Event) []*compiledFilter {
 	return append(f.types[e.Type], f.categories[e.Category.Index()]...)
 }
 
-func newCompiledFilter(f filter.Filter, c *config.FilterConfig, ss *sequenceState) *compiledFilter {
-	return &compiledFilter{filter: f, config: c, ss: ss}
+func newCompiledFilter(f filter.Filter, c *config.FilterConfig, ss *sequenceState, ts *thresholdState) *compiledFilter {
+	return &compiledFilter{filter: f, config: c, ss: ss, ts: ts}
 }
 
 // isScoped determines if this filter is scoped, i.e. it has the event name or category
@@ -122,23 +188,43 @@ func (f *compiledFilter) isSequence() bool {
 	return f.ss != nil
 }
 
+func (f *compiledFilter) isThreshold() bool {
+	return f.ts != nil
+}
+
 func (f *compiledFilter) eval(e *event.Event, valuer *filter.ValuerCache) bool {
+	if f.prof == nil {
+		return f.evalFilter(e, valuer)
+	}
+	var prof filter.EvalProfile
+	valuer.SetProfile(&prof)
+	defer valuer.SetProfile(nil)
+	start := time.Now()
+	match := f.evalFilter(e, valuer)
+	f.prof.record(time.Since(start), prof, match)
+	return match
+}
+
+func (f *compiledFilter) evalFilter(e *event.Event, valuer *filter.ValuerCache) bool {
 	if f.ss != nil {
 		return f.ss.evalSequence(e, valuer)
 	}
+	if f.ts != nil {
+		return f.ts.evalThreshold(e, valuer)
+	}
 	return f.filter.EvalWithValuer(e, valuer)
 }
 
 // NewEngine builds a fresh rules engine instance.
 func NewEngine(psnap ps.Snapshotter, config *config.Config) *Engine {
 	e := &Engine{
-		filters:   newFilterset(),
+		ruleset:   newRuleset(),
 		matches:   make([]*ruleMatch, 0),
-		sequences: make([]*sequenceState, 0),
 		psnap:     psnap,
 		config:    config,
 		scavenger: time.NewTicker(sequenceGcInterval),
 		compiler:  newCompiler(psnap, config),
+		quit:      make(chan struct{}),
 	}
 
 	go e.gcSequences()
@@ -146,11 +232,26 @@ func NewEngine(psnap ps.Snapshotter, config *config.Config) *Engine {
 	return e
 }
 
+// gcSequences periodically prunes stale sequence
+// partials, threshold group windows, and alert
+// throttle groups until the engine is closed.
 func (e *Engine) gcSequences() {
 	for {
-		<-e.scavenger.C
-		for _, seq := range e.sequences {
-			seq.gc()
+		select {
+		case <-e.scavenger.C:
+			e.rmu.RLock()
+			for _, seq := range e.sequences {
+				seq.gc()
+			}
+			for _, ts := range e.thresholds {
+				ts.gc(time.Now())
+			}
+			for _, t := range e.throttles {
+				t.gc(time.Now())
+			}
+			e.rmu.RUnlock()
+		case <-e.quit:
+			return
 		}
 	}
 }
@@ -160,21 +261,120 @@ func (e *Engine) gcSequences() {
 // converted into a filter. The filter is indexed by either the
 // event name or event category.
 func (e *Engine) Compile() (*config.RulesCompileResult, error) {
-	filters, rs, err := e.compiler.compile()
+	rs, r, err := e.compile(e.compiler, nil)
 	if err != nil {
 		return nil, err
 	}
+	if e.config.Filters.Sequences.StateFile != "" {
+		if err := e.restoreSequences(rs); err != nil {
+			checkpointErrors.Add(1)
+			log.Warnf("unable to restore sequence state: %v", err)
+		}
+	}
+	e.rmu.Lock()
+	e.ruleset = rs
+	e.compileResult = r
+	e.rmu.Unlock()
+	e.startCheckpointer()
+	if len(e.config.Filters.Lookups.Tables) > 0 && e.lookups == nil {
+		e.lookups = lookup.NewRefresher(e.config.Filters.Lookups.GetRefreshInterval())
+	}
+	if e.rarity == nil {
+		e.rarity = rarity.Open(e.config.Filters.Rarity)
+	}
+	return r, nil
+}
+
+// Close stops the engine background tasks. If the sequence
+// state persistence is enabled, the final checkpoint of the
+// sequence state is written to the state file.
+func (e *Engine) Close() error {
+	select {
+	case <-e.quit:
+		return nil
+	default:
+	}
+	e.scavenger.Stop()
+	close(e.quit)
+	if e.lookups != nil {
+		e.lookups.Stop()
+		e.lookups = nil
+	}
+	if e.rarity != nil {
+		if err := e.rarity.Close(); err != nil {
+			log.Warnf("unable to flush frequency store: %v", err)
+		}
+		e.rarity = nil
+	}
+	if e.checkpointer == nil {
+		return nil
+	}
+	e.checkpointer.Stop()
+	e.checkpointer = nil
+	return e.checkpoint()
+}
+
+// compile builds a new ruleset. If the previous ruleset is
+// given, sequence and threshold rules with unchanged conditions
+// carry over their compiled filters, and thus their state, to
+// the new ruleset.
+func (e *Engine) compile(c *compiler, prev *ruleset) (*ruleset, *config.RulesCompileResult, error) {
+	filters, r, err := c.compile()
+	if err != nil {
+		return nil, nil, err
+	}
+	excs, err := c.compileExceptions()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rs := newRuleset()
+	rs.exceptions = excs
 
 	for c, f := range filters {
-		var ss *sequenceState
-		if f.IsSequence() {
-			ss = newSequenceState(f, c, e.psnap)
+		var fltr *compiledFilter
+		if prev != nil {
+			fltr = prev.carry(c, f)
+		}
+		if fltr != nil {
+			rs.carried[fltr] = c
+		} else {
+			var ss *sequenceState
+			var ts *thresholdState
+			switch {
+			case f.IsSequence():
+				ss = newSequenceState(f, c, e.psnap)
+				ss.setLimits(e.config.Filters.Sequences)
+			case f.IsThreshold():
+				ts = newThresholdState(f, c)
+			}
+			fltr = newCompiledFilter(f, c, ss, ts)
+			if e.config.Filters.Rules.Profile {
+				fltr.prof = profiles.get(c)
+			}
+			if ss != nil {
+				ss.onAbsenceMatch = func(evts []*event.Event) { e.processAbsenceMatch(fltr, evts) }
+			}
 		}
-		fltr := newCompiledFilter(f, c, ss)
-		if ss != nil {
+		rs.rules[c.ID] = fltr
+
+		if fltr.ss != nil {
 			// store the sequences in engine
 			// for more convenient tracking
-			e.sequences = append(e.sequences, ss)
+			rs.sequences = append(rs.sequences, fltr.ss)
+		}
+		if fltr.ts != nil {
+			rs.thresholds = append(rs.thresholds, fltr.ts)
+		}
+		if c.Throttle != nil {
+			var t *throttle
+			if prev != nil {
+				t = prev.throttles[c.ID]
+			}
+			if t == nil || !reflect.DeepEqual(t.config, c.Throttle) {
+				t = newThrottle(c)
+			}
+			rs.throttles[c.ID] = t
 		}
 
 		if !fltr.isScoped() {
@@ -192,22 +392,28 @@ func (e *Engine) Compile() (*config.RulesCompileResult, error) {
 		// the event type from the filter field name expression.
 		// We end up with a map of rules indexed by event type
 		// or event category
-		for name, values := range f.GetStringFields() {
+		for name, values := range fltr.filter.GetStringFields() {
 			for _, v := range values {
 				switch name {
 				case fields.EvtName:
 					for _, typ := range event.NameToTypes(v) {
-						e.filters.types[typ] = append(e.filters.types[typ], fltr)
+						rs.filters.types[typ] = append(rs.filters.types[typ], fltr)
 					}
 				case fields.EvtCategory:
 					category := event.Category(v)
-					e.filters.categories[category.Index()] = append(e.filters.categories[category.Index()], fltr)
+					rs.filters.categories[category.Index()] = append(rs.filters.categories[category.Index()], fltr)
 				}
 			}
 		}
 	}
 
-	return rs, nil
+	if e.config.Filters.Rules.Optimize {
+		rs.filters.buildIndex()
+	}
+
+	lookup.Publish(c.tables)
+
+	return rs, r, nil
 }
 
 func (e *Engine) RegisterMatchFunc(fn RuleMatchFunc) {
@@ -218,9 +424,13 @@ func (*Engine) CanEnqueue() bool { return true }
 
 // ProcessEvent processes the system event against compiled filters.
 // Filter is the internal lingo that designates a rule condition.
-// Filters can be simple direct-event matchers or sequence states that
-// track an ordered series of events over a short period of time.
+// Filters can be simple direct-event matchers, sequence states that
+// track an ordered series of events over a short period of time, or
+// thresholds that count matching events within a sliding time window.
 func (e *Engine) ProcessEvent(evt *event.Event) (bool, error) {
+	e.rmu.RLock()
+	defer e.rmu.RUnlock()
+
 	if e.filters.empty() {
 		return true, nil
 	}
@@ -234,12 +444,12 @@ func (e *Engine) ProcessEvent(evt *event.Event) (bool, error) {
 		}
 	}
 
-	filters := e.filters.collect(evt)
-
 	// acquire valuer cache
 	valuer := filter.AcquireValuerCache()
 	defer valuer.Release()
 
+	filters := e.filters.narrow(evt, valuer)
+
 	// assert event against compiled ruleset
 	var matches bool
 	for _, f := range filters {
@@ -247,12 +457,23 @@ func (e *Engine) ProcessEvent(evt *event.Event) (bool, error) {
 		if !match {
 			continue
 		}
-		if f.isSequence() {
-			e.appendMatch(f.config, f.ss.events()...)
+		var evts []*event.Event
+		switch {
+		case f.isSequence():
+			evts = f.ss.events()
 			f.ss.clearLocked()
-		} else {
-			e.appendMatch(f.config, evt)
+		case f.isThreshold():
+			evts = f.ts.events()
+		default:
+			evts = []*event.Event{evt}
 		}
+		// the rule match is discarded if
+		// any of the rule exceptions is
+		// satisfied by the matched events
+		if e.exceptions.suppress(f.config, evts) {
+			continue
+		}
+		e.appendMatch(f.config, evts...)
 		err := e.processActions()
 		if err != nil {
 			log.Errorf("unable to execute rule action: %v", err)
@@ -268,6 +489,25 @@ func (e *Engine) ProcessEvent(evt *event.Event) (bool, error) {
 	return matches, nil
 }
 
+// processAbsenceMatch is invoked when the sequence with the trailing
+// negated expression matches as a consequence of the max span deadline.
+// Since there is no event that drives the match, the rule actions are
+// executed out of the event processing loop.
+func (e *Engine) processAbsenceMatch(f *compiledFilter, evts []*event.Event) {
+	if len(evts) == 0 {
+		return
+	}
+	e.rmu.RLock()
+	defer e.rmu.RUnlock()
+	if e.exceptions.suppress(f.config, evts) {
+		return
+	}
+	e.appendMatch(f.config, evts...)
+	if err := e.processActions(); err != nil {
+		log.Errorf("unable to execute rule action: %v", err)
+	}
+}
+
 // processActions executes rule actions
 // on behalf of rule matches. Actions are
 // categorized into implicit and explicit
@@ -276,18 +516,25 @@ func (e *Engine) ProcessEvent(evt *event.Event) (bool, error) {
 // carried out each time there is a rule
 // match. Other actions are executed if
 // declared in the rule definition.
+// Matches are swapped out under the lock
+// before they are processed, so matches
+// appended concurrently by the absence
+// match path are not lost.
 func (e *Engine) processActions() error {
-	defer e.clearMatches()
 	e.mmu.Lock()
-	defer e.mmu.Unlock()
+	matches := e.matches
+	e.matches = make([]*ruleMatch, 0)
+	e.mmu.Unlock()
+	if e.dryRun {
+		return nil
+	}
 
-	for _, m := range e.matches {
-		f, evts := m.ctx.Filter, m.ctx.Events
+	for _, m := range matches {
+		f := m.ctx.Filter
 		filterMatches.Add(f.Name, 1)
 		log.Debugf("[%s] rule matched", f.Name)
-		err := action.Alert(m.ctx, f.Name, filter.InterpolateFields(f.Output, evts), f.Severity, f.Tags)
-		if err != nil {
-			return ErrRuleAction(f.Name, err)
+		if err := e.alert(m.ctx); err != nil {
+			return err
 		}
 
 		actions, err := f.DecodeActions()
@@ -314,9 +561,41 @@ func (e *Engine) processActions() error {
 	return nil
 }
 
+// alert emits the rule alert unless the alert is
+// suppressed by the rule throttle. In summarize mode,
+// the number of suppressed alerts is reported in the
+// alert text and labels.
+func (e *Engine) alert(ctx *config.ActionContext) error {
+	f, evts := ctx.Filter, ctx.Events
+	text := filter.InterpolateFields(f.Output, evts)
+	if t, ok := e.throttles[f.ID]; ok {
+		allow, suppressed := t.allow(evts, time.Now())
+		if !allow {
+			return nil
+		}
+		if suppressed > 0 {
+			ctx.AddLabel(throttleSuppressedLabel, strconv.FormatUint(suppressed, 10))
+			text += fmt.Sprintf("\n\n%d similar alert(s) suppressed since the last alert", suppressed)
+		}
+	}
+	if e.config.Filters.Rules.Explain {
+		if fltr, ok := e.rules[f.ID]; ok {
+			ctx.AddLabel(explainLabel, explainMatch(fltr.filter, evts))
+		}
+	}
+	err := action.Alert(ctx, f.Name, text, f.Severity, f.Tags)
+	if err != nil {
+		return ErrRuleAction(f.Name, err)
+	}
+	return nil
+}
+
 func (e *Engine) appendMatch(f *config.FilterConfig, evts ...*event.Event) {
 	for _, evt := range evts {
 		evt.AddMeta(event.RuleNameKey, f.Name)
+		if f.Severity != "" {
+			evt.AddMeta(event.RuleSeverityKey, f.Severity)
+		}
 		for k, v := range f.Labels {
 			evt.AddMeta(event.MetadataKey(k), v)
 		}
@@ -332,9 +611,3 @@ func (e *Engine) appendMatch(f *config.FilterConfig, evts ...*event.Event) {
 		e.matchFunc(f, evts...)
 	}
 }
-
-func (e *Engine) clearMatches() {
-	e.mmu.Lock()
-	defer e.mmu.Unlock()
-	e.matches = make([]*ruleMatch, 0)
-}
@@ -19,6 +19,7 @@
 package rules
 
 import (
+	"fmt"
 	"net"
 	"os"
 	"testing"
@@ -519,7 +520,45 @@ func BenchmarkRunRules(b *testing.B) {
 
 	b.ResetTimer()
 
-	evts := []*event.Event{
+	evts := benchmarkEvents()
+
+	for i := 0; i < b.N; i++ {
+		for _, evt := range evts {
+			_, _ = e.ProcessEvent(evt)
+		}
+	}
+}
+
+// BenchmarkRunShippedRules compares the evaluation of
+// the shipped ruleset with and without the rule optimizer.
+func BenchmarkRunShippedRules(b *testing.B) {
+	for _, optimize := range []bool{false, true} {
+		b.Run(fmt.Sprintf("optimize=%t", optimize), func(b *testing.B) {
+			b.ReportAllocs()
+			c := newConfig("../../rules/*.yml")
+			c.Filters.Macros.FromPaths = []string{"../../rules/macros/*.yml"}
+			c.Filters.Rules.Optimize = optimize
+			e := NewEngine(new(ps.SnapshotterMock), c)
+			e.dryRun = true
+			rs, err := e.Compile()
+			require.NoError(b, err)
+			require.NotNil(b, rs)
+
+			b.ResetTimer()
+
+			evts := benchmarkEvents()
+
+			for i := 0; i < b.N; i++ {
+				for _, evt := range evts {
+					_, _ = e.ProcessEvent(evt)
+				}
+			}
+		})
+	}
+}
+
+func benchmarkEvents() []*event.Event {
+	return []*event.Event{
 		{
 			Type:     event.ConnectTCPv4,
 			Name:     "Recv",
@@ -571,10 +610,4 @@ func BenchmarkRunRules(b *testing.B) {
 			Metadata: make(map[event.MetadataKey]any),
 		},
 	}
-
-	for i := 0; i < b.N; i++ {
-		for _, evt := range evts {
-			_, _ = e.ProcessEvent(evt)
-		}
-	}
 }
@@ -0,0 +1,95 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rules
+
+import (
+	"expvar"
+	"sync/atomic"
+	"time"
+
+	"github.com/rabbitstack/fibratus/pkg/config"
+	"github.com/rabbitstack/fibratus/pkg/event"
+	"github.com/rabbitstack/fibratus/pkg/filter"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// exceptionSuppressions counts the number of rule matches suppressed by exceptions
+	exceptionSuppressions = expvar.NewMap("filter.exceptions.suppressions")
+	// exceptionExpirations counts the number of expired exceptions per rule
+	exceptionExpirations = expvar.NewMap("filter.exceptions.expired")
+)
+
+// exception is the compiled rule exception. The exception
+// condition is evaluated against the events that matched
+// the rule.
+type exception struct {
+	filter filter.Filter
+	config *config.Exception
+	// isExpired indicates whether the exception
+	// expiration was already reported
+	isExpired atomic.Bool
+}
+
+func newException(f filter.Filter, c *config.Exception) *exception {
+	return &exception{filter: f, config: c}
+}
+
+// expired determines if the exception is expired. When the
+// exception is found to be expired for the first time, the
+// expiration is logged and counted.
+func (e *exception) expired(now time.Time) bool {
+	if !e.config.IsExpired(now) {
+		return false
+	}
+	if e.isExpired.CompareAndSwap(false, true) {
+		log.Warnf("exception for rule %s owned by %s expired on %s. "+
+			"The exception is not enforced anymore", e.config.RuleID, e.config.Owner, e.config.Expires)
+		exceptionExpirations.Add(e.config.RuleID, 1)
+	}
+	return true
+}
+
+// exceptions contains compiled exceptions indexed by rule identifier.
+type exceptions map[string][]*exception
+
+// suppress determines if the rule match should be suppressed. The
+// match is suppressed if any of the unexpired rule exceptions is
+// satisfied by any of the events that matched the rule.
+func (e exceptions) suppress(c *config.FilterConfig, evts []*event.Event) bool {
+	excs, ok := e[c.ID]
+	if !ok {
+		return false
+	}
+	now := time.Now()
+	for _, exc := range excs {
+		if exc.expired(now) {
+			continue
+		}
+		for _, evt := range evts {
+			if !exc.filter.Eval(evt) {
+				continue
+			}
+			log.Debugf("[%s] rule match suppressed by exception owned by %s: %s", c.Name, exc.config.Owner, exc.config.Reason)
+			exceptionSuppressions.Add(c.Name, 1)
+			return true
+		}
+	}
+	return false
+}
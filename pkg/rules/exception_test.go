@@ -0,0 +1,109 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rules
+
+import (
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/rabbitstack/fibratus/pkg/config"
+	"github.com/rabbitstack/fibratus/pkg/event"
+	"github.com/rabbitstack/fibratus/pkg/ps"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuleExceptions(t *testing.T) {
+	var tests = []struct {
+		exceptions string
+		matches    bool
+	}{
+		{"", true},
+		{"_fixtures/exceptions/exceptions.yml", false},
+		{"_fixtures/exceptions/expired_exceptions.yml", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.exceptions, func(t *testing.T) {
+			c := newConfig("_fixtures/exceptions/rule.yml")
+			if tt.exceptions != "" {
+				c.Filters.Exceptions.FromPaths = []string{tt.exceptions}
+			}
+			assert.Equal(t, tt.matches, fireRules(t, c))
+		})
+	}
+}
+
+func TestRuleExceptionsSuppressThresholdMatch(t *testing.T) {
+	c := newConfig("_fixtures/threshold_rule.yml")
+	c.Filters.Exceptions.FromPaths = []string{"_fixtures/exceptions/threshold_exceptions.yml"}
+	e := NewEngine(new(ps.SnapshotterMock), c)
+	compileRules(t, e)
+	require.Len(t, e.exceptions["2d6c1a5e-8c2b-4f3e-9a51-7e4b0c9d3f21"], 1)
+
+	var matches int
+	e.RegisterMatchFunc(func(f *config.FilterConfig, evts ...*event.Event) {
+		matches++
+	})
+
+	now := time.Now()
+	// one of the events in the window satisfies the exception
+	for i := 0; i < 4; i++ {
+		require.False(t, wrapProcessEvent(newCreateFileEvent(1, "C:\\Users\\a.encrypted", now.Add(time.Millisecond*time.Duration(i))), e.ProcessEvent))
+	}
+	require.False(t, wrapProcessEvent(newCreateFileEvent(1, "C:\\Backup\\a.encrypted", now.Add(time.Millisecond*5)), e.ProcessEvent))
+	assert.Equal(t, 0, matches)
+
+	// the window was reset and refilled without any excepted event
+	for i := 0; i < 4; i++ {
+		require.False(t, wrapProcessEvent(newCreateFileEvent(1, "C:\\Users\\b.encrypted", now.Add(time.Millisecond*time.Duration(10+i))), e.ProcessEvent))
+	}
+	require.True(t, wrapProcessEvent(newCreateFileEvent(1, "C:\\Users\\c.encrypted", now.Add(time.Millisecond*15)), e.ProcessEvent))
+	assert.Equal(t, 1, matches)
+}
+
+func TestExceptionExpired(t *testing.T) {
+	c := newConfig("_fixtures/exceptions/rule.yml")
+	c.Filters.Exceptions.FromPaths = []string{"_fixtures/exceptions/expired_exceptions.yml"}
+	e := NewEngine(new(ps.SnapshotterMock), c)
+	compileRules(t, e)
+
+	excs := e.exceptions["60ffc2a8-0bde-45c4-9e20-46158250fa91"]
+	require.Len(t, excs, 1)
+	exc := excs[0]
+	expired := expvarInt(exceptionExpirations, "60ffc2a8-0bde-45c4-9e20-46158250fa91")
+
+	assert.False(t, exc.expired(time.Date(2021, 6, 30, 12, 0, 0, 0, time.Local)))
+	assert.False(t, exc.isExpired.Load())
+	assert.True(t, exc.expired(time.Now()))
+	assert.True(t, exc.isExpired.Load())
+	assert.Equal(t, expired+1, expvarInt(exceptionExpirations, "60ffc2a8-0bde-45c4-9e20-46158250fa91"))
+	// the expiration is counted only once
+	assert.True(t, exc.expired(time.Now()))
+	assert.Equal(t, expired+1, expvarInt(exceptionExpirations, "60ffc2a8-0bde-45c4-9e20-46158250fa91"))
+}
+
+func expvarInt(m *expvar.Map, key string) int64 {
+	v, ok := m.Get(key).(*expvar.Int)
+	if !ok {
+		return 0
+	}
+	return v.Value()
+}
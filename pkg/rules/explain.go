@@ -0,0 +1,161 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rules
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rabbitstack/fibratus/pkg/config"
+	"github.com/rabbitstack/fibratus/pkg/event"
+	"github.com/rabbitstack/fibratus/pkg/filter"
+	"github.com/rabbitstack/fibratus/pkg/filter/ql"
+	"github.com/rabbitstack/fibratus/pkg/ps"
+	"gopkg.in/yaml.v3"
+)
+
+// explainLabel is the alert label that contains the explanation of the rule match
+const explainLabel = "rule.explain"
+
+// explainMatch renders the annotated expression trees for the events
+// that matched the rule. Sequence events are explained against the
+// sequence expression they matched. Negated sequence expressions are
+// not matched by any event, so they are omitted from the explanation.
+// Threshold rules are explained for the event that crossed the threshold.
+func explainMatch(f filter.Filter, evts []*event.Event) string {
+	if len(evts) == 0 {
+		return ""
+	}
+	if !f.IsSequence() {
+		nodes := f.Explain(evts[len(evts)-1])
+		if len(nodes) == 0 {
+			return ""
+		}
+		return nodes[0].String()
+	}
+
+	var b strings.Builder
+	var n int
+	for i, expr := range f.GetSequence().Expressions {
+		if expr.IsNegated {
+			continue
+		}
+		if n >= len(evts) {
+			break
+		}
+		nodes := f.Explain(evts[n])
+		n++
+		if i >= len(nodes) {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte('\n')
+		}
+		fmt.Fprintf(&b, "|%d| %s\n", i+1, nodes[i].String())
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Explainer evaluates the single rule against events and reveals
+// the outcome of each predicate in the rule condition.
+type Explainer struct {
+	e *Engine
+	f *compiledFilter
+}
+
+// NewExplainer loads the ruleset and compiles the rule identified by
+// the rule ID or name. The process snapshotter is used by accessors
+// to resolve the process state of explained events. All accessors
+// are enabled regardless of the event source config.
+func NewExplainer(cfg *config.Config, psnap ps.Snapshotter, rule string) (*Explainer, error) {
+	if err := cfg.Filters.LoadFilters(); err != nil {
+		return nil, err
+	}
+	var fc *config.FilterConfig
+	for _, f := range cfg.GetFilters() {
+		if f.ID == rule || f.Name == rule {
+			fc = f
+			break
+		}
+	}
+	if fc == nil {
+		return nil, fmt.Errorf("%q rule not found", rule)
+	}
+	if fc.IsDisabled() {
+		return nil, fmt.Errorf("%q rule is disabled", fc.Name)
+	}
+
+	// the condition is explained in the written
+	// order rather than the optimized order
+	c := allAccessorsConfig(cfg)
+	c.Filters.Rules.Optimize = false
+
+	e := NewEngine(psnap, c)
+	e.scavenger.Stop()
+	e.compiler.filters = []*config.FilterConfig{fc}
+	e.dryRun = true
+	if _, err := e.Compile(); err != nil {
+		return nil, err
+	}
+
+	return &Explainer{e: e, f: e.rules[fc.ID]}, nil
+}
+
+// Rule returns the config of the explained rule.
+func (x *Explainer) Rule() *config.FilterConfig { return x.f.config }
+
+// IsSequence determines if the explained rule is a sequence.
+func (x *Explainer) IsSequence() bool { return x.f.isSequence() }
+
+// InScope determines if the event is in the scope of the rule, that
+// is, the rule is evaluated by the engine when the event arrives.
+func (x *Explainer) InScope(evt *event.Event) bool {
+	for _, f := range x.e.filters.collect(evt) {
+		if f == x.f {
+			return true
+		}
+	}
+	return false
+}
+
+// Explain evaluates the rule against the event and returns the
+// annotated expression tree. Sequence rules yield a tree for each
+// sequence expression.
+func (x *Explainer) Explain(evt *event.Event) []*ql.ExplainNode {
+	return x.f.filter.Explain(evt)
+}
+
+// DecodeEvents decodes the list of events from the YAML or JSON
+// document. Events are declared in the same format as the events
+// of rule test cases. The returned process snapshotter resolves
+// the process state declared in events.
+func DecodeEvents(b []byte) ([]*event.Event, ps.Snapshotter, error) {
+	var defs []config.TestEvent
+	if err := yaml.Unmarshal(b, &defs); err != nil {
+		return nil, nil, err
+	}
+	if len(defs) == 0 {
+		return nil, nil, fmt.Errorf("no events found")
+	}
+	evts, err := newTestEvents(defs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return evts, newTestSnapshotter(evts), nil
+}
@@ -0,0 +1,111 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rules
+
+import (
+	"testing"
+
+	"github.com/rabbitstack/fibratus/pkg/alertsender"
+	"github.com/rabbitstack/fibratus/pkg/event"
+	"github.com/rabbitstack/fibratus/pkg/filter"
+	"github.com/rabbitstack/fibratus/pkg/ps"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplainer(t *testing.T) {
+	evts, psnap, err := DecodeEvents([]byte(`
+- name: Recv
+  params:
+    dip: 216.58.201.174
+    dport: 8443
+  ps:
+    pid: 1234
+    name: chrome.exe
+- name: CreateProcess
+  ps:
+    pid: 1234
+    name: chrome.exe
+`))
+	require.NoError(t, err)
+	require.Len(t, evts, 2)
+
+	x, err := NewExplainer(newConfig("_fixtures/simple_matches.yml"), psnap, "match https connections")
+	require.NoError(t, err)
+	assert.Equal(t, "60ffc2a8-0bde-45c4-9e20-46158250fa91", x.Rule().ID)
+	assert.False(t, x.IsSequence())
+
+	assert.True(t, x.InScope(evts[0]))
+	assert.False(t, x.InScope(evts[1]))
+
+	nodes := x.Explain(evts[0])
+	require.Len(t, nodes, 1)
+	assert.False(t, nodes[0].Matched())
+	require.Len(t, nodes[0].Children, 2)
+	assert.True(t, nodes[0].Children[0].Matched())
+	assert.False(t, nodes[0].Children[1].Matched())
+	assert.Equal(t, uint16(8443), nodes[0].Children[1].Children[0].Value)
+
+	_, err = NewExplainer(newConfig("_fixtures/simple_matches.yml"), psnap, "match http connections")
+	require.EqualError(t, err, `"match http connections" rule not found`)
+
+	_, _, err = DecodeEvents([]byte(`[]`))
+	require.Error(t, err)
+}
+
+func TestExplainMatch(t *testing.T) {
+	f := filter.New(`sequence
+maxspan 1m
+|evt.name = 'CreateProcess'| by ps.pid
+|evt.name = 'CreateFile'| by ps.pid
+!|evt.name = 'DeleteFile'| by ps.pid
+|evt.name = 'Recv' and net.dport = 443| by ps.pid
+`, newConfig())
+	require.NoError(t, f.Compile())
+
+	evts := []*event.Event{
+		{Type: event.CreateProcess, Name: "CreateProcess", Category: event.Process},
+		{Type: event.CreateFile, Name: "CreateFile", Category: event.File},
+		newRecvEvent("cmd.exe", "216.58.201.174"),
+	}
+
+	s := explainMatch(f, evts)
+	assert.Contains(t, s, "|1| [true] evt.name = CreateProcess")
+	assert.Contains(t, s, "|2| [true] evt.name = CreateFile")
+	assert.Contains(t, s, "|4| [true] evt.name = Recv AND net.dport = 443")
+	assert.NotContains(t, s, "|3|")
+}
+
+func TestExplainAlerts(t *testing.T) {
+	require.NoError(t, alertsender.LoadAll([]alertsender.Config{{Type: alertsender.Noop}}))
+	c := newConfig("_fixtures/simple_matches.yml")
+	c.Filters.Rules.Explain = true
+	e := NewEngine(new(ps.SnapshotterMock), c)
+	compileRules(t, e)
+
+	emitAlert = nil
+	require.True(t, wrapProcessEvent(newRecvEvent("cmd.exe", "216.58.201.174"), e.ProcessEvent))
+	require.NotNil(t, emitAlert)
+	assert.Equal(t, `[true] evt.name = Recv AND net.dport = 443
+  [true] evt.name = Recv
+    evt.name = "Recv"
+  [true] net.dport = 443
+    net.dport = 443`, emitAlert.Labels[explainLabel])
+	emitAlert = nil
+}
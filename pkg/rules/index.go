@@ -0,0 +1,280 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rules
+
+import (
+	"expvar"
+	"slices"
+
+	"github.com/rabbitstack/fibratus/pkg/event"
+	"github.com/rabbitstack/fibratus/pkg/event/params"
+	"github.com/rabbitstack/fibratus/pkg/filter"
+	"github.com/rabbitstack/fibratus/pkg/filter/fields"
+	"github.com/rabbitstack/fibratus/pkg/filter/ql"
+	"github.com/rabbitstack/fibratus/pkg/util/stringcase"
+)
+
+var (
+	// indexedFiltersCount represents the number of filters indexed by predicate values
+	indexedFiltersCount = expvar.NewInt("filter.index.filters.count")
+	// indexSkippedFilters counts the filters left out of evaluation by the predicate index
+	indexSkippedFilters = expvar.NewInt("filter.index.skipped.filters")
+)
+
+// bucket contains the filters collected for the event type
+// or category. Filters with the top-level condition requiring
+// the field to be equal to one of the string literals are
+// indexed by these literals. When the event arrives, the field
+// value is extracted once and only the filters indexed by the
+// value are evaluated along with the filters lacking indexable
+// predicates.
+type bucket struct {
+	filters []*compiledFilter
+	fields  []*fieldIndex
+}
+
+// fieldIndex maps the case-folded field values to
+// positions of the filters in the bucket.
+type fieldIndex struct {
+	field string
+	// extractor is any of the indexed filters. All filters
+	// share the valuer cache, so the field value is extracted
+	// once and reused by the filter evaluation
+	extractor filter.Filter
+	values    map[string][]int
+	positions []int
+}
+
+// predicate is the top-level filter conjunct that
+// is only satisfied if the field value equals to
+// one of the string literals.
+type predicate struct {
+	field  string
+	values []string
+}
+
+// collect returns the bucket filters that may match the event.
+func (b *bucket) collect(e *event.Event, valuer *filter.ValuerCache) []*compiledFilter {
+	if b == nil {
+		return nil
+	}
+	if len(b.fields) == 0 {
+		return b.filters
+	}
+
+	skip := make([]bool, len(b.filters))
+	for _, idx := range b.fields {
+		v, _ := idx.extractor.Value(e, valuer, idx.field)
+		if v != nil {
+			if _, ok := v.(string); !ok {
+				// can't reason about non-string values
+				continue
+			}
+		}
+		for _, pos := range idx.positions {
+			skip[pos] = true
+		}
+		if s, ok := v.(string); ok {
+			for _, pos := range idx.values[stringcase.Fold(s)] {
+				skip[pos] = false
+			}
+		}
+	}
+
+	filters := make([]*compiledFilter, 0, len(b.filters))
+	for i, f := range b.filters {
+		if skip[i] {
+			continue
+		}
+		filters = append(filters, f)
+	}
+	indexSkippedFilters.Add(int64(len(b.filters) - len(filters)))
+
+	return filters
+}
+
+// narrow returns the filters for the event like collect does, but
+// leaves out the filters whose indexed predicate can't match the
+// event. If the predicate index is not built, all filters for the
+// event type and category are returned.
+func (f *filterset) narrow(e *event.Event, valuer *filter.ValuerCache) []*compiledFilter {
+	if f.typeIndex == nil {
+		return f.collect(e)
+	}
+	filters := f.typeIndex[e.Type].collect(e, valuer)
+	if len(f.categories) == 0 {
+		return filters
+	}
+	return slices.Concat(filters, f.categoryIndex[e.Category.Index()].collect(e, valuer))
+}
+
+// buildIndex indexes the filters of each event type and category by
+// the field predicates. When the filter condition has multiple indexable
+// predicates, the field referenced by most filters across the ruleset
+// is chosen, so fewer field values are extracted for every event.
+func (f *filterset) buildIndex() {
+	preds := make(map[*compiledFilter][]predicate)
+	freq := make(map[string]int)
+	visit := func(filters []*compiledFilter) {
+		for _, flt := range filters {
+			if _, ok := preds[flt]; ok {
+				continue
+			}
+			preds[flt] = flt.indexablePredicates()
+			for _, p := range preds[flt] {
+				freq[p.field]++
+			}
+		}
+	}
+	for _, filters := range f.types {
+		visit(filters)
+	}
+	for _, filters := range f.categories {
+		visit(filters)
+	}
+
+	indexed := make(map[*compiledFilter]bool)
+	newBucket := func(filters []*compiledFilter) *bucket {
+		b := &bucket{filters: filters}
+		for pos, flt := range filters {
+			var pred *predicate
+			for i, p := range preds[flt] {
+				if pred == nil || freq[p.field] > freq[pred.field] {
+					pred = &preds[flt][i]
+				}
+			}
+			if pred == nil {
+				continue
+			}
+			indexed[flt] = true
+			var idx *fieldIndex
+			for _, fi := range b.fields {
+				if fi.field == pred.field {
+					idx = fi
+					break
+				}
+			}
+			if idx == nil {
+				idx = &fieldIndex{field: pred.field, extractor: flt.filter, values: make(map[string][]int)}
+				b.fields = append(b.fields, idx)
+			}
+			idx.positions = append(idx.positions, pos)
+			for _, v := range pred.values {
+				key := stringcase.Fold(v)
+				if !slices.Contains(idx.values[key], pos) {
+					idx.values[key] = append(idx.values[key], pos)
+				}
+			}
+		}
+		return b
+	}
+
+	f.typeIndex = make(map[event.Type]*bucket, len(f.types))
+	for typ, filters := range f.types {
+		f.typeIndex[typ] = newBucket(filters)
+	}
+	f.categoryIndex = make(map[uint8]*bucket, len(f.categories))
+	for cat, filters := range f.categories {
+		f.categoryIndex[cat] = newBucket(filters)
+	}
+
+	indexedFiltersCount.Set(int64(len(indexed)))
+}
+
+// indexablePredicates returns the top-level conjuncts of the filter
+// condition that are only satisfied if the string field equals to one
+// of the literal values. Sequence filters are never indexed, since the
+// evaluation drives the state of sequence partials. For the same reason,
+// filters calling stateful functions are not indexed. Event name and
+// category predicates are already resolved by the filterset.
+func (f *compiledFilter) indexablePredicates() []predicate {
+	if f.isSequence() {
+		return nil
+	}
+	expr := f.filter.Expr()
+	if f.isThreshold() {
+		expr = f.filter.GetThreshold().Expr
+	}
+	if expr == nil || ql.IsStateful(expr) {
+		return nil
+	}
+
+	preds := make([]predicate, 0)
+	for _, conj := range conjuncts(expr) {
+		b, ok := conj.(*ql.BinaryExpr)
+		if !ok {
+			continue
+		}
+		switch b.Op {
+		case ql.Eq, ql.IEq, ql.In, ql.IIn:
+		default:
+			continue
+		}
+		lhs, ok := b.LHS.(*ql.FieldLiteral)
+		if !ok || !isIndexableField(lhs) {
+			continue
+		}
+		values, ok := rhsToStrings(b.RHS)
+		if !ok {
+			continue
+		}
+		preds = append(preds, predicate{field: lhs.Value, values: values})
+	}
+
+	return preds
+}
+
+// isIndexableField determines if the field value can be used
+// as the index key. Only cheap string fields are considered,
+// so the eager extraction never exceeds the cost of process
+// state lookups.
+func isIndexableField(f *ql.FieldLiteral) bool {
+	if f.Arg != "" || f.Field == fields.EvtName || f.Field == fields.EvtCategory {
+		return false
+	}
+	switch f.Field.Type() {
+	case params.UnicodeString, params.AnsiString:
+	default:
+		return false
+	}
+	return ql.FieldCost(f.Field) <= ql.ProcessCost
+}
+
+// conjuncts splits the expression into the
+// operands of the top-level AND operator.
+func conjuncts(expr ql.Expr) []ql.Expr {
+	expr = unwrapExpr(expr)
+	if b, ok := expr.(*ql.BinaryExpr); ok && b.Op == ql.And {
+		return append(conjuncts(b.LHS), conjuncts(b.RHS)...)
+	}
+	return []ql.Expr{expr}
+}
+
+func unwrapExpr(expr ql.Expr) ql.Expr {
+	for {
+		switch e := expr.(type) {
+		case *ql.ParenExpr:
+			expr = e.Expr
+		case *ql.SharedExpr:
+			expr = e.Expr
+		default:
+			return expr
+		}
+	}
+}
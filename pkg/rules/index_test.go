@@ -0,0 +1,97 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rules
+
+import (
+	"testing"
+
+	"github.com/rabbitstack/fibratus/pkg/config"
+	"github.com/rabbitstack/fibratus/pkg/event"
+	"github.com/rabbitstack/fibratus/pkg/filter"
+	"github.com/rabbitstack/fibratus/pkg/ps"
+	"github.com/rabbitstack/fibratus/pkg/ps/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPredicateIndex(t *testing.T) {
+	c := newConfig("_fixtures/index/*.yml")
+	c.Filters.Rules.Optimize = true
+	e := NewEngine(new(ps.SnapshotterMock), c)
+	compileRules(t, e)
+
+	require.NotNil(t, e.filters.typeIndex)
+	require.Contains(t, e.filters.typeIndex, event.CreateProcess)
+	b := e.filters.typeIndex[event.CreateProcess]
+	require.Len(t, b.fields, 1)
+	assert.Equal(t, "ps.name", b.fields[0].field)
+	assert.Len(t, b.fields[0].positions, 2)
+
+	var tests = []struct {
+		name  string
+		ps    *types.PS
+		wants []string
+	}{
+		{"Cmd.exe", &types.PS{Name: "Cmd.exe"}, []string{"command shell spawned", "explorer or temp process spawned"}},
+		{"winword.exe", &types.PS{Name: "winword.exe"}, []string{"office process spawned with macro arguments", "explorer or temp process spawned"}},
+		{"svchost.exe", &types.PS{Name: "svchost.exe"}, []string{"explorer or temp process spawned", "service host process activity"}},
+		{"no process", nil, []string{"explorer or temp process spawned"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evt := &event.Event{
+				Type:     event.CreateProcess,
+				Name:     "CreateProcess",
+				Category: event.Process,
+				PS:       tt.ps,
+			}
+			valuer := filter.AcquireValuerCache()
+			defer valuer.Release()
+			names := make([]string, 0)
+			for _, f := range e.filters.narrow(evt, valuer) {
+				names = append(names, f.config.Name)
+			}
+			assert.ElementsMatch(t, tt.wants, names)
+			assert.Len(t, e.filters.collect(evt), 4)
+		})
+	}
+}
+
+func TestPredicateIndexMatches(t *testing.T) {
+	c := newConfig("_fixtures/index/*.yml")
+	c.Filters.Rules.Optimize = true
+	c.Filters.MatchAll = true
+	e := NewEngine(new(ps.SnapshotterMock), c)
+	matches := make([]string, 0)
+	e.RegisterMatchFunc(func(f *config.FilterConfig, evts ...*event.Event) {
+		matches = append(matches, f.Name)
+	})
+	compileRules(t, e)
+
+	evt := &event.Event{
+		Type:     event.CreateProcess,
+		Name:     "CreateProcess",
+		Category: event.Process,
+		PS:       &types.PS{Name: "WinWord.exe", Cmdline: "WINWORD.EXE /m Macro1"},
+		Metadata: make(map[event.MetadataKey]any),
+	}
+	assert.True(t, wrapProcessEvent(evt, e.ProcessEvent))
+	assert.Equal(t, []string{"office process spawned with macro arguments"}, matches)
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rabbitstack/fibratus/pkg/alertsender"
+	"github.com/rabbitstack/fibratus/pkg/config"
+	"github.com/rabbitstack/fibratus/pkg/event"
+	"github.com/rabbitstack/fibratus/pkg/ps"
+	"github.com/rabbitstack/fibratus/pkg/ps/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newLookupConfig(fromFiles ...string) *config.Config {
+	c := newConfig(fromFiles...)
+	c.Filters.Lookups = config.Lookups{
+		Tables: []config.LookupTable{
+			{Name: "approved_software", Path: "_fixtures/lookup/tables/approved.csv", Key: "exe", Value: "vendor", IgnoreCase: true},
+		},
+		RefreshInterval: time.Hour,
+	}
+	return c
+}
+
+func TestLookupRules(t *testing.T) {
+	require.NoError(t, alertsender.LoadAll([]alertsender.Config{{Type: alertsender.Noop}}))
+	e := NewEngine(new(ps.SnapshotterMock), newLookupConfig("_fixtures/lookup/rules/*.yml"))
+	compileRules(t, e)
+	defer e.Close()
+	require.NotNil(t, e.lookups)
+
+	var tests = []struct {
+		exe  string
+		text string
+	}{
+		{`C:\Windows\System32\notepad.exe`, `C:\Windows\System32\notepad.exe process from Microsoft started`},
+		{`C:\WINDOWS\System32\Notepad.exe`, `C:\WINDOWS\System32\Notepad.exe process from Microsoft started`},
+		{`C:\Temp\dropper.exe`, `C:\Temp\dropper.exe process started`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.exe, func(t *testing.T) {
+			emitAlert = nil
+			evt := &event.Event{
+				Type:      event.CreateProcess,
+				Timestamp: time.Now(),
+				Category:  event.Process,
+				Name:      "CreateProcess",
+				Tid:       2484,
+				PID:       859,
+				PS: &types.PS{
+					Name: "cmd.exe",
+					Exe:  tt.exe,
+				},
+				Metadata: make(map[event.MetadataKey]any),
+			}
+			require.True(t, wrapProcessEvent(evt, e.ProcessEvent))
+			require.NotNil(t, emitAlert)
+			assert.Equal(t, tt.text, emitAlert.Text)
+		})
+	}
+
+	// approved software in different case is not reported
+	emitAlert = nil
+	evt := &event.Event{
+		Type:      event.CreateProcess,
+		Timestamp: time.Now(),
+		Category:  event.Process,
+		Name:      "CreateProcess",
+		PID:       859,
+		PS: &types.PS{
+			Name: "chrome.exe",
+			Exe:  `C:\Program Files\Google\Chrome\Application\CHROME.exe`,
+		},
+		Metadata: make(map[event.MetadataKey]any),
+	}
+	require.False(t, wrapProcessEvent(evt, e.ProcessEvent))
+	require.Nil(t, emitAlert)
+}
+
+func TestLookupUnknownTable(t *testing.T) {
+	e := NewEngine(new(ps.SnapshotterMock), newLookupConfig("_fixtures/lookup/unknown_table.yml"))
+	_, err := e.Compile()
+	require.EqualError(t, err, ErrUnknownLookupTable("unknown lookup table", "unknown_table").Error())
+}
@@ -0,0 +1,186 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rules
+
+import (
+	"expvar"
+	"math"
+	"math/bits"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rabbitstack/fibratus/pkg/config"
+	"github.com/rabbitstack/fibratus/pkg/filter"
+)
+
+// profiles accumulates the per-rule evaluation cost when
+// the rule profiling is enabled. Profiles are keyed by rule
+// identifier and survive ruleset reloads.
+var profiles = newProfiler()
+
+func init() {
+	expvar.Publish("rules.profile", expvar.Func(func() any { return profiles.Stats() }))
+}
+
+// histogram buckets are arranged as power of two ranges
+// split into histSubBuckets linear sub-buckets, which keeps
+// the relative error of the computed quantiles under 25%
+const (
+	histSubBuckets = 4
+	histBuckets    = 64 * histSubBuckets
+)
+
+// histogram is a lock-free, log-linear histogram of durations.
+type histogram struct {
+	counts [histBuckets]atomic.Uint64
+}
+
+func histBucket(d time.Duration) int {
+	n := uint64(max(d, 0))
+	if n < histSubBuckets {
+		return int(n)
+	}
+	e := bits.Len64(n) - 1
+	sub := (n >> (e - 2)) & (histSubBuckets - 1)
+	return histSubBuckets*(e-1) + int(sub)
+}
+
+// histBucketUpper returns the upper bound of the bucket range.
+func histBucketUpper(i int) time.Duration {
+	if i < histSubBuckets {
+		return time.Duration(i)
+	}
+	e := i/histSubBuckets + 1
+	sub := uint64(i % histSubBuckets)
+	lower := (histSubBuckets + sub) << (e - 2)
+	return time.Duration(lower + (1 << (e - 2)) - 1)
+}
+
+func (h *histogram) record(d time.Duration) {
+	h.counts[histBucket(d)].Add(1)
+}
+
+// quantile returns the upper bound of the bucket where
+// the q-th quantile of recorded durations falls into.
+func (h *histogram) quantile(q float64) time.Duration {
+	var total uint64
+	for i := range h.counts {
+		total += h.counts[i].Load()
+	}
+	if total == 0 {
+		return 0
+	}
+	rank := max(uint64(math.Ceil(q*float64(total))), 1)
+	var n uint64
+	for i := range h.counts {
+		n += h.counts[i].Load()
+		if n >= rank {
+			return histBucketUpper(i)
+		}
+	}
+	return histBucketUpper(histBuckets - 1)
+}
+
+// ruleProfile records the evaluation cost of a single rule.
+type ruleProfile struct {
+	evals     atomic.Uint64
+	matches   atomic.Uint64
+	total     atomic.Int64
+	accessors atomic.Int64
+	functions atomic.Int64
+	hist      histogram
+}
+
+func (p *ruleProfile) record(elapsed time.Duration, prof filter.EvalProfile, match bool) {
+	p.evals.Add(1)
+	if match {
+		p.matches.Add(1)
+	}
+	p.total.Add(int64(elapsed))
+	p.accessors.Add(int64(prof.Accessors))
+	p.functions.Add(int64(prof.Functions))
+	p.hist.record(elapsed)
+}
+
+// RuleProfile contains the evaluation statistics of a single rule.
+// All durations are expressed in nanoseconds.
+type RuleProfile struct {
+	ID        string        `json:"id"`
+	Name      string        `json:"name"`
+	Evals     uint64        `json:"evals"`
+	Matches   uint64        `json:"matches"`
+	Total     time.Duration `json:"total"`
+	Avg       time.Duration `json:"avg"`
+	P99       time.Duration `json:"p99"`
+	Accessors time.Duration `json:"accessors"`
+	Functions time.Duration `json:"functions"`
+}
+
+// profiler keeps track of rule profiles.
+type profiler struct {
+	mu    sync.RWMutex
+	names map[string]string
+	rules map[string]*ruleProfile
+}
+
+func newProfiler() *profiler {
+	return &profiler{
+		names: make(map[string]string),
+		rules: make(map[string]*ruleProfile),
+	}
+}
+
+// get returns the profile for the given rule. The profile is
+// created if it doesn't exist.
+func (p *profiler) get(c *config.FilterConfig) *ruleProfile {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.names[c.ID] = c.Name
+	prof, ok := p.rules[c.ID]
+	if !ok {
+		prof = &ruleProfile{}
+		p.rules[c.ID] = prof
+	}
+	return prof
+}
+
+// Stats returns the statistics of all profiled rules.
+func (p *profiler) Stats() []RuleProfile {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	stats := make([]RuleProfile, 0, len(p.rules))
+	for id, prof := range p.rules {
+		s := RuleProfile{
+			ID:        id,
+			Name:      p.names[id],
+			Evals:     prof.evals.Load(),
+			Matches:   prof.matches.Load(),
+			Total:     time.Duration(prof.total.Load()),
+			P99:       prof.hist.quantile(0.99),
+			Accessors: time.Duration(prof.accessors.Load()),
+			Functions: time.Duration(prof.functions.Load()),
+		}
+		if s.Evals > 0 {
+			s.Avg = s.Total / time.Duration(s.Evals)
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rabbitstack/fibratus/pkg/event"
+	"github.com/rabbitstack/fibratus/pkg/ps"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistogramQuantile(t *testing.T) {
+	var h histogram
+	assert.Equal(t, time.Duration(0), h.quantile(0.99))
+
+	for range 990 {
+		h.record(time.Microsecond)
+	}
+	for range 10 {
+		h.record(time.Millisecond)
+	}
+
+	p99 := h.quantile(0.99)
+	assert.GreaterOrEqual(t, p99, time.Microsecond)
+	assert.Less(t, p99, time.Microsecond*5/4)
+
+	h.record(time.Millisecond)
+	p99 = h.quantile(0.99)
+	assert.GreaterOrEqual(t, p99, time.Millisecond)
+	assert.Less(t, p99, time.Millisecond*5/4)
+}
+
+func TestHistogramBuckets(t *testing.T) {
+	for _, d := range []time.Duration{0, 1, 3, 4, 7, 8, 9, 100, 1023, 1024, time.Second, time.Hour} {
+		i := histBucket(d)
+		require.Less(t, i, histBuckets)
+		assert.GreaterOrEqual(t, histBucketUpper(i), d)
+		if i > 0 {
+			assert.Less(t, histBucketUpper(i-1), d)
+		}
+	}
+}
+
+func TestProfileRules(t *testing.T) {
+	c := newConfig("_fixtures/profile.yml")
+	c.Filters.Rules.Profile = true
+	e := NewEngine(new(ps.SnapshotterMock), c)
+	compileRules(t, e)
+
+	for _, evt := range []*event.Event{
+		newRecvEvent("chrome.exe", "216.58.201.174"),
+		newRecvEvent("chrome.exe", "216.58.201.175"),
+		newRecvEvent("chrome.exe", "140.82.121.4"),
+	} {
+		_, err := e.ProcessEvent(evt)
+		require.NoError(t, err)
+	}
+
+	var prof *RuleProfile
+	for _, p := range profiles.Stats() {
+		if p.ID == "5d2e9b4c-8f3a-4e71-b0a6-3c1f7d9e2a58" {
+			prof = &p
+		}
+	}
+	require.NotNil(t, prof)
+
+	assert.Equal(t, "match https connections to Google ranges", prof.Name)
+	assert.Equal(t, uint64(3), prof.Evals)
+	assert.Equal(t, uint64(2), prof.Matches)
+	assert.Greater(t, prof.Total, time.Duration(0))
+	assert.GreaterOrEqual(t, prof.Total, prof.Accessors+prof.Functions)
+	assert.GreaterOrEqual(t, prof.P99, prof.Avg/2)
+}
+
+func TestProfileRulesDisabled(t *testing.T) {
+	e := NewEngine(new(ps.SnapshotterMock), newConfig("_fixtures/simple_matches.yml"))
+	compileRules(t, e)
+
+	for _, f := range e.rules {
+		assert.Nil(t, f.prof)
+	}
+}
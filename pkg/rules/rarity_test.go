@@ -0,0 +1,97 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rules
+
+import (
+	"expvar"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rabbitstack/fibratus/pkg/alertsender"
+	"github.com/rabbitstack/fibratus/pkg/config"
+	"github.com/rabbitstack/fibratus/pkg/event"
+	"github.com/rabbitstack/fibratus/pkg/filter/rarity"
+	"github.com/rabbitstack/fibratus/pkg/ps"
+	"github.com/rabbitstack/fibratus/pkg/ps/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSpawnEvent(parent, name string) *event.Event {
+	return &event.Event{
+		Type:      event.CreateProcess,
+		Timestamp: time.Now(),
+		Category:  event.Process,
+		Name:      "CreateProcess",
+		Tid:       2484,
+		PID:       859,
+		PS: &types.PS{
+			Name:   name,
+			Parent: &types.PS{Name: parent},
+		},
+		Metadata: make(map[event.MetadataKey]any),
+	}
+}
+
+func ruleMatches(name string) int64 {
+	v, ok := filterMatches.Get(name).(*expvar.Int)
+	if !ok {
+		return 0
+	}
+	return v.Value()
+}
+
+func TestRarityRules(t *testing.T) {
+	require.NoError(t, alertsender.LoadAll([]alertsender.Config{{Type: alertsender.Noop}}))
+
+	for _, optimize := range []bool{false, true} {
+		stateFile := filepath.Join(t.TempDir(), "rarity.state")
+		c := newConfig("_fixtures/rarity/*.yml")
+		c.Filters.MatchAll = true
+		c.Filters.Rules.Optimize = optimize
+		c.Filters.Rarity = config.Rarity{StateFile: stateFile}
+		e := NewEngine(new(ps.SnapshotterMock), c)
+		compileRules(t, e)
+
+		first, rare := "first seen parent child pair", "rare parent child pair"
+		n1, n2 := ruleMatches(first), ruleMatches(rare)
+
+		// both rules share the observation of the event
+		require.True(t, wrapProcessEvent(newSpawnEvent("winword.exe", "cmd.exe"), e.ProcessEvent))
+		assert.Equal(t, n1+1, ruleMatches(first))
+		assert.Equal(t, n2+1, ruleMatches(rare))
+
+		require.True(t, wrapProcessEvent(newSpawnEvent("winword.exe", "cmd.exe"), e.ProcessEvent))
+		assert.Equal(t, n1+1, ruleMatches(first))
+		assert.Equal(t, n2+2, ruleMatches(rare))
+
+		require.False(t, wrapProcessEvent(newSpawnEvent("winword.exe", "cmd.exe"), e.ProcessEvent))
+
+		require.True(t, wrapProcessEvent(newSpawnEvent("winword.exe", "powershell.exe"), e.ProcessEvent))
+		assert.Equal(t, n1+2, ruleMatches(first))
+
+		// the learned frequencies are persisted and rule
+		// functions no longer observe the closed store
+		s := rarity.Default()
+		require.NoError(t, e.Close())
+		assert.FileExists(t, stateFile)
+		assert.NotSame(t, s, rarity.Default())
+	}
+}
@@ -0,0 +1,167 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rules
+
+import (
+	"expvar"
+	"strings"
+
+	"github.com/rabbitstack/fibratus/pkg/config"
+	"github.com/rabbitstack/fibratus/pkg/filter"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// reloadCount counts the number of successful ruleset reloads
+	reloadCount = expvar.NewInt("rules.reload.count")
+	// reloadFailures counts the number of failed ruleset reloads
+	reloadFailures = expvar.NewInt("rules.reload.failures")
+	// reloadCarriedRules counts the number of rules that preserved their state across reloads
+	reloadCarriedRules = expvar.NewInt("rules.reload.carried.rules")
+	// reloadDrainedRules counts the number of rules whose state was discarded on reload
+	reloadDrainedRules = expvar.NewInt("rules.reload.drained.rules")
+)
+
+// Reload recompiles macros, rules, and exceptions and swaps the
+// new ruleset in the engine. Sequence and threshold rules that
+// didn't change preserve the in-flight partials and group windows,
+// while the state of modified or removed rules is drained. If the
+// compilation fails, the error is returned and the engine keeps
+// running with the previous ruleset. Macros, rules, exceptions,
+// and lookup tables are loaded into a copy of the filters config
+// and only published after the new ruleset compiles successfully.
+func (e *Engine) Reload() error {
+	e.reloadMu.Lock()
+	defer e.reloadMu.Unlock()
+
+	e.rmu.RLock()
+	prev := e.ruleset
+	e.rmu.RUnlock()
+
+	log.Info("reloading rules")
+
+	// load the rule resources into a copy of
+	// the filters config, so the failed reload
+	// doesn't clobber the currently loaded set
+	filters := *e.config.Filters
+	cfg := *e.config
+	cfg.Filters = &filters
+
+	rs, r, err := e.compile(newCompiler(e.psnap, &cfg), prev)
+	if err != nil {
+		reloadFailures.Add(1)
+		log.Errorf("unable to reload rules. Keeping the previous ruleset: %v", err)
+		return err
+	}
+
+	e.warnUnsubscribedEvents(r)
+
+	e.rmu.Lock()
+	for fltr, c := range rs.carried {
+		fltr.config = c
+	}
+	e.ruleset = rs
+	e.config.Filters.Publish(cfg.Filters)
+	e.rmu.Unlock()
+
+	prev.drain(rs)
+	reloadCount.Add(1)
+
+	log.Infof("rules reloaded. %d rule(s) carried over the state", len(rs.carried))
+
+	return nil
+}
+
+// warnUnsubscribedEvents emits a warning if the reloaded ruleset
+// uses events that weren't required by the initial ruleset. The
+// event providers are set up on startup, so these events may not
+// be delivered to the engine until the agent is restarted.
+func (e *Engine) warnUnsubscribedEvents(r *config.RulesCompileResult) {
+	if r == nil {
+		return
+	}
+	missing := make([]string, 0)
+	for _, typ := range r.UsedEvents {
+		if e.compileResult == nil || !e.compileResult.ContainsEvent(typ) {
+			missing = append(missing, typ.String())
+		}
+	}
+	if len(missing) > 0 {
+		log.Warnf("reloaded rules use events [%s] not required by the initial ruleset. "+
+			"These events may not be collected until the restart", strings.Join(missing, ", "))
+	}
+}
+
+// carry returns the compiled filter of the sequence
+// or threshold rule from this ruleset if the rule
+// identified by the same ID has the unchanged condition.
+func (rs *ruleset) carry(c *config.FilterConfig, f filter.Filter) *compiledFilter {
+	fltr, ok := rs.rules[c.ID]
+	if !ok || (fltr.ss == nil && fltr.ts == nil) {
+		return nil
+	}
+	if fingerprint(fltr.config, fltr.filter) != fingerprint(c, f) {
+		return nil
+	}
+	reloadCarriedRules.Add(1)
+	return fltr
+}
+
+// drain discards the state of sequence and threshold
+// rules that were not carried over to the next ruleset.
+func (rs *ruleset) drain(next *ruleset) {
+	for _, fltr := range rs.rules {
+		if _, ok := next.carried[fltr]; ok {
+			continue
+		}
+		switch {
+		case fltr.ss != nil:
+			fltr.ss.drain()
+		case fltr.ts != nil:
+			fltr.ts.drain()
+		default:
+			continue
+		}
+		reloadDrainedRules.Add(1)
+	}
+}
+
+// fingerprint returns the rule condition along with
+// the string representation of compiled expressions.
+// The expressions have all macros expanded, so the
+// fingerprint changes when any of the macros that
+// the rule uses change.
+func fingerprint(c *config.FilterConfig, f filter.Filter) string {
+	var b strings.Builder
+	b.WriteString(c.Condition)
+	switch {
+	case f.IsSequence():
+		for _, expr := range f.GetSequence().Expressions {
+			b.WriteByte(0)
+			b.WriteString(expr.Expr.String())
+		}
+	case f.IsThreshold():
+		b.WriteByte(0)
+		b.WriteString(f.GetThreshold().Expr.String())
+	case f.Expr() != nil:
+		b.WriteByte(0)
+		b.WriteString(f.Expr().String())
+	}
+	return b.String()
+}
@@ -0,0 +1,158 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rules
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rabbitstack/fibratus/pkg/config"
+	"github.com/rabbitstack/fibratus/pkg/event"
+	"github.com/rabbitstack/fibratus/pkg/ps"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const reloadSequenceRule = `
+name: Sequential file writes
+id: 7b0f3c2a-9d41-4e6b-8a52-3f1d0c6e9b27
+version: 1.0.0
+condition: >
+  sequence
+  maxspan 1h
+    |evt.name = 'CreateFile' and file.path = '%s'|
+    |evt.name = 'CreateFile' and file.path = 'C:\\b.txt'|
+output: %s
+min-engine-version: 2.0.0
+`
+
+func writeReloadRule(t *testing.T, path, file, output string) {
+	require.NoError(t, os.WriteFile(path, []byte(fmt.Sprintf(reloadSequenceRule, file, output)), 0o600))
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sequence.yml")
+	writeReloadRule(t, path, "C:\\\\a.txt", "first output")
+
+	e := NewEngine(new(ps.SnapshotterMock), newConfig(path))
+	compileRules(t, e)
+	require.Len(t, e.sequences, 1)
+
+	ss := e.sequences[0]
+	now := time.Now()
+	require.False(t, wrapProcessEvent(newFileEvent(event.CreateFile, 1, "C:\\a.txt", now), e.ProcessEvent))
+	require.Len(t, ss.partials[0], 1)
+
+	// the condition is not changed and the
+	// sequence partials are carried over
+	writeReloadRule(t, path, "C:\\\\a.txt", "second output")
+	require.NoError(t, e.Reload())
+	require.Len(t, e.sequences, 1)
+	assert.Equal(t, ss, e.sequences[0])
+	assert.Len(t, ss.partials[0], 1)
+	assert.Equal(t, "second output", e.rules["7b0f3c2a-9d41-4e6b-8a52-3f1d0c6e9b27"].config.Output)
+
+	// syntax errors keep the previous ruleset
+	require.NoError(t, os.WriteFile(path, []byte(`
+name: Sequential file writes
+id: 7b0f3c2a-9d41-4e6b-8a52-3f1d0c6e9b27
+version: 1.0.0
+condition: evt.name = 'CreateFile' and file.path =
+min-engine-version: 2.0.0
+`), 0o600))
+	require.Error(t, e.Reload())
+	assert.Equal(t, ss, e.sequences[0])
+	assert.Len(t, e.filters.collect(&event.Event{Type: event.CreateFile}), 1)
+	// the loaded rules are not replaced by the broken set
+	require.Len(t, e.config.GetFilters(), 1)
+	assert.Equal(t, "second output", e.config.GetFilters()[0].Output)
+
+	// the condition is changed and the
+	// sequence partials are drained
+	writeReloadRule(t, path, "C:\\\\c.txt", "second output")
+	require.NoError(t, e.Reload())
+	require.Len(t, e.sequences, 1)
+	assert.NotEqual(t, ss, e.sequences[0])
+	assert.Len(t, ss.partials[0], 0)
+	require.Len(t, e.config.GetFilters(), 1)
+	assert.Contains(t, e.config.GetFilters()[0].Condition, "C:\\\\c.txt")
+
+	require.False(t, wrapProcessEvent(newFileEvent(event.CreateFile, 1, "C:\\c.txt", now.Add(time.Second)), e.ProcessEvent))
+	require.True(t, wrapProcessEvent(newFileEvent(event.CreateFile, 1, "C:\\b.txt", now.Add(time.Second*2)), e.ProcessEvent))
+}
+
+func TestWatcherMatches(t *testing.T) {
+	w := NewWatcher(nil, newConfig())
+	w.patterns = []string{"c:\\rules\\*.yml", "c:\\rules\\macros\\*"}
+
+	assert.True(t, w.matches("C:\\Rules\\credential_access.yml"))
+	assert.True(t, w.matches("C:\\Rules\\Macros\\macros.yaml"))
+	assert.False(t, w.matches("C:\\Rules\\Macros\\macros.yml.swp"))
+	assert.False(t, w.matches("C:\\Rules\\credential_access.yaml"))
+	assert.False(t, w.matches("C:\\Temp\\credential_access.yml"))
+}
+
+func TestWatcherDirs(t *testing.T) {
+	dir := t.TempDir()
+	for _, d := range []string{"credential_access", "defense_evasion"} {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, d), 0o700))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "macros.yml"), nil, 0o600))
+
+	assert.Equal(t, []string{dir}, watchDirs(filepath.Join(dir, "*.yml")))
+	assert.Equal(t, []string{
+		filepath.Join(dir, "credential_access"),
+		filepath.Join(dir, "defense_evasion"),
+	}, watchDirs(filepath.Join(dir, "*", "*.yml")))
+}
+
+func TestWatcherURLRevisions(t *testing.T) {
+	etag := `"v1"`
+	var notModified int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		_, _ = w.Write([]byte("rule"))
+	}))
+	defer srv.Close()
+
+	c := &config.Config{Filters: &config.Filters{Rules: config.Rules{FromURLs: []string{srv.URL}}}}
+	w := NewWatcher(nil, c)
+
+	rev, err := w.revision(srv.URL)
+	require.NoError(t, err)
+	assert.Equal(t, `"v1"`, rev)
+	w.revisions[srv.URL] = rev
+
+	assert.False(t, w.urlsChanged())
+	assert.Equal(t, 1, notModified)
+
+	etag = `"v2"`
+	assert.True(t, w.urlsChanged())
+	assert.False(t, w.urlsChanged())
+}
@@ -0,0 +1,177 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rules
+
+import (
+	"encoding/json"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rabbitstack/fibratus/pkg/filter/ql"
+	"github.com/rabbitstack/fibratus/pkg/util/version"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// sarifLog is the root object of the SARIF 2.1.0 log file.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string            `json:"ruleId"`
+	Level      string            `json:"level"`
+	Message    sarifMessage      `json:"message"`
+	Locations  []sarifLocation   `json:"locations,omitempty"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName,omitempty"`
+}
+
+// sarifLevel maps the diagnostic severity to the SARIF result level.
+func sarifLevel(sev ql.Severity) string {
+	switch sev {
+	case ql.Error:
+		return "error"
+	case ql.Warning:
+		return "warning"
+	}
+	return "note"
+}
+
+// artifactURI converts the rule resource to the URI. Absolute
+// file system paths are converted to file URIs, whereas relative
+// paths are made slash-separated.
+func artifactURI(resource string) string {
+	if strings.HasPrefix(resource, "http://") || strings.HasPrefix(resource, "https://") {
+		return resource
+	}
+	if filepath.IsAbs(resource) {
+		u := url.URL{Scheme: "file", Path: "/" + strings.TrimPrefix(filepath.ToSlash(resource), "/")}
+		return u.String()
+	}
+	return filepath.ToSlash(resource)
+}
+
+// SARIF encodes the report in the Static Analysis Results
+// Interchange Format (SARIF) version 2.1.0. Each diagnostic
+// code is described as the reporting descriptor of the tool.
+func (r *Report) SARIF() ([]byte, error) {
+	codes := make(map[string]bool)
+	results := make([]sarifResult, 0, len(r.Findings))
+
+	for _, f := range r.Findings {
+		codes[f.Code] = true
+		res := sarifResult{
+			RuleID:  f.Code,
+			Level:   sarifLevel(f.Severity),
+			Message: sarifMessage{Text: f.Message},
+		}
+		if f.Resource != "" || f.Rule != "" {
+			var loc sarifLocation
+			if f.Resource != "" {
+				loc.PhysicalLocation = &sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: artifactURI(f.Resource)},
+				}
+			}
+			if f.Rule != "" {
+				loc.LogicalLocations = []sarifLogicalLocation{{Name: f.Rule, FullyQualifiedName: f.RuleID}}
+			}
+			res.Locations = []sarifLocation{loc}
+		}
+		if f.Expr != "" {
+			res.Properties = map[string]string{"expr": f.Expr}
+		}
+		results = append(results, res)
+	}
+
+	rules := make([]sarifRule, 0, len(codes))
+	for code := range codes {
+		rules = append(rules, sarifRule{ID: code, ShortDescription: sarifMessage{Text: diagnosticDescs[code]}})
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+
+	log := sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{
+			{
+				Tool: sarifTool{
+					Driver: sarifDriver{
+						Name:           "fibratus",
+						Version:        version.Get(),
+						InformationURI: "https://www.fibratus.io",
+						Rules:          rules,
+					},
+				},
+				Results: results,
+			},
+		},
+	}
+
+	return json.MarshalIndent(log, "", "  ")
+}
@@ -21,12 +21,15 @@ package rules
 import (
 	"context"
 	"expvar"
+	"slices"
 	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
+	"unsafe"
 
 	fsm "github.com/qmuntal/stateless"
+	"github.com/rabbitstack/fibratus/pkg/callstack"
 	"github.com/rabbitstack/fibratus/pkg/config"
 	"github.com/rabbitstack/fibratus/pkg/event"
 	"github.com/rabbitstack/fibratus/pkg/event/params"
@@ -39,13 +42,25 @@ import (
 const (
 	// maxOutstandingPartials determines the maximum number of partials per sequence index
 	maxOutstandingPartials = 1000
+	// eventSize is the size of the event structure without the referenced data
+	eventSize = int64(unsafe.Sizeof(event.Event{}))
+	// paramSize is the size of the parameter structure without the referenced data
+	paramSize = int64(unsafe.Sizeof(event.Param{}))
+	// frameSize is the size of the stack frame without the referenced data
+	frameSize = int64(unsafe.Sizeof(callstack.Frame{}))
 )
 
 var (
 	partialsPerSequence   = expvar.NewMap("sequence.partials.count")
 	partialExpirations    = expvar.NewMap("sequence.partial.expirations")
 	partialBreaches       = expvar.NewMap("sequence.partial.breaches")
+	partialCancellations  = expvar.NewMap("sequence.partial.cancellations")
+	absenceMatches        = expvar.NewMap("sequence.absence.matches")
 	matchTransitionErrors = expvar.NewInt("sequence.match.transition.errors")
+	partialsBytes         = expvar.NewMap("sequence.partials.bytes")
+	partialEvictions      = expvar.NewMap("sequence.partial.evictions")
+	partialSpills         = expvar.NewMap("sequence.partial.spills")
+	partialSpillErrors    = expvar.NewInt("sequence.partial.spill.errors")
 
 	// maxSequencePartialLifetime indicates the maximum time for the
 	// partial to exist in the sequence state. If the partial has been
@@ -89,6 +104,13 @@ type sequenceState struct {
 	partials map[int][]*event.Event
 	// mu guards the partials map
 	mu sync.RWMutex
+	// partialsSize is the estimated memory in bytes occupied by partials
+	partialsSize int64
+
+	// limits bounds the number and memory of partials
+	limits config.Sequences
+	// spill stores partials evicted under memory pressure
+	spill *spillStore
 
 	// matches stores only the event that matched
 	// the upstream partials. These events will
@@ -101,8 +123,15 @@ type sequenceState struct {
 
 	// exprs stores the expression index to
 	// its respective string representation
-	exprs              map[int]string
-	spanDeadlines      map[fsm.State]*time.Timer
+	exprs         map[int]string
+	spanDeadlines map[fsm.State]*time.Timer
+	deadlines     map[fsm.State]time.Time
+	// absences keeps the max span deadline of each partial
+	// in the slot preceding the trailing negated expression.
+	// Every partial, and thus every link key, completes the
+	// sequence independently when its own max span elapses.
+	// Guarded by the partials lock
+	absences           map[*event.Event]*absenceDeadline
 	inDeadline         atomic.Bool
 	inExpired          atomic.Bool
 	initialState       fsm.State
@@ -121,6 +150,12 @@ type sequenceState struct {
 	lastMatch time.Time
 
 	psnap ps.Snapshotter
+
+	// onAbsenceMatch is invoked when the sequence with the
+	// trailing negated expression matches after the max span
+	// elapsed without observing the forbidden event. It
+	// receives the matched events
+	onAbsenceMatch func([]*event.Event)
 }
 
 func newSequenceState(f filter.Filter, c *config.FilterConfig, psnap ps.Snapshotter) *sequenceState {
@@ -134,17 +169,58 @@ func newSequenceState(f filter.Filter, c *config.FilterConfig, psnap ps.Snapshot
 		matches:       make(map[int]*event.Event),
 		exprs:         make(map[int]string),
 		spanDeadlines: make(map[fsm.State]*time.Timer),
+		deadlines:     make(map[fsm.State]time.Time),
+		absences:      make(map[*event.Event]*absenceDeadline),
 		initialState:  sequenceInitialState,
 		psnap:         psnap,
 	}
 
-	ss.initFSM()
+	ss.initFSM(ss.initialState)
 
 	ss.configureFSM()
 
 	return ss
 }
 
+// setLimits applies the limits that bound the number of partials and
+// the memory they occupy. If the spill directory is given, partials
+// evicted under memory pressure are spilled to disk instead of discarded.
+func (s *sequenceState) setLimits(limits config.Sequences) {
+	s.limits = limits
+	if limits.SpillDir == "" || limits.MaxPartialsMemory == 0 {
+		return
+	}
+	spill, err := newSpillStore(limits.SpillDir)
+	if err != nil {
+		log.Warnf("unable to create spill store for sequence %s: %v", s.name, err)
+		return
+	}
+	s.spill = spill
+}
+
+// maxPartials returns the maximum number of partials per sequence slot.
+func (s *sequenceState) maxPartials() int {
+	if s.limits.MaxPartials > 0 {
+		return s.limits.MaxPartials
+	}
+	return maxOutstandingPartials
+}
+
+// partialLifetime returns the time the partial is allowed
+// to stay in the sequence state. It is given by the max span
+// of the sequence, or the configured max span upper bound if
+// the sequence has no max span.
+func (s *sequenceState) partialLifetime() time.Duration {
+	switch {
+	case s.maxSpan != 0:
+		return s.maxSpan
+	case s.limits.MaxSpan != 0:
+		return s.limits.MaxSpan
+	default:
+		return maxSequencePartialLifetime
+	}
+}
+
 func (s *sequenceState) events() []*event.Event {
 	s.mmu.RLock()
 	defer s.mmu.RUnlock()
@@ -157,14 +233,16 @@ func (s *sequenceState) events() []*event.Event {
 }
 
 func (s *sequenceState) isStateSchedulable(state fsm.State) bool {
-	return state != s.initialState && state != sequenceTerminalState && state != sequenceExpiredState && state != sequenceDeadlineState
+	// the trailing negated state is not scheduled, as absence
+	// deadlines are tracked per partial instead of per state
+	return state != s.initialState && state != sequenceTerminalState && state != sequenceExpiredState && state != sequenceDeadlineState && !s.isTrailingNegated(state)
 }
 
-// initFSM initializes the state machine and installs transition callbacks
-// that are triggered when the expression in the sequence matches, it expires
-// or the deadline occurs.
-func (s *sequenceState) initFSM() {
-	s.fsm = fsm.NewStateMachine(s.initialState)
+// initFSM initializes the state machine in the given state and installs
+// transition callbacks that are triggered when the expression in the
+// sequence matches, it expires or the deadline occurs.
+func (s *sequenceState) initFSM(state fsm.State) {
+	s.fsm = fsm.NewStateMachine(state)
 	s.fsm.OnTransitioned(func(ctx context.Context, transition fsm.Transition) {
 		// schedule span deadline for the current state unless initial/meta states
 		if s.maxSpan != 0 && s.isStateSchedulable(s.currentState()) {
@@ -191,6 +269,7 @@ func (s *sequenceState) initFSM() {
 				log.Debugf("stopped max span deadline for expression [%s] of sequence [%s]", s.expr(transition.Source), s.name)
 				span.Stop()
 				delete(s.spanDeadlines, transition.Source)
+				delete(s.deadlines, transition.Source)
 			}
 			// save expression match
 			s.states[transition.Source] = true
@@ -268,8 +347,23 @@ func (s *sequenceState) matchTransition(seqID int, e *event.Event) error {
 	s.smu.Lock()
 	defer s.smu.Unlock()
 	shouldFire := !s.states[seqID]
-	if shouldFire {
-		return s.fsm.Fire(matchTransition, e)
+	if !shouldFire {
+		return nil
+	}
+	if err := s.fsm.Fire(matchTransition, e); err != nil {
+		return err
+	}
+	// negated expressions, except the trailing one, are
+	// passed through as soon as the upstream expression
+	// matches. The absence of the forbidden event is then
+	// checked until the downstream expression matches
+	for i := seqID + 1; i < len(s.seq.Expressions)-1 && s.seq.Expressions[i].IsNegated; i++ {
+		if s.currentState() != i {
+			break
+		}
+		if err := s.fsm.Fire(matchTransition, e); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -311,19 +405,12 @@ func (s *sequenceState) expr(state fsm.State) string {
 
 // addPartial appends the event that matched the expression at the
 // sequence index. If the event arrived out of order, then the isOOO
-// parameter is equal to false.
+// parameter is equal to false. When the slot or the link key reach
+// the partials limit, the eviction policy decides whether the oldest
+// partial is evicted or the incoming partial is dropped.
 func (s *sequenceState) addPartial(seqID int, e *event.Event, isOOO bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if len(s.partials[seqID]) > maxOutstandingPartials {
-		partialBreaches.Add(s.name, 1)
-		if !s.isPartialsBreached.Load() {
-			log.Warnf("max partials encountered in sequence %s slot [%d]. "+
-				"Dropping incoming partial: %s", s.name, seqID, e)
-		}
-		s.isPartialsBreached.Store(true)
-		return
-	}
 	key := e.PartialKey()
 	if key != 0 {
 		for _, p := range s.partials[seqID] {
@@ -333,15 +420,176 @@ func (s *sequenceState) addPartial(seqID int, e *event.Event, isOOO bool) {
 			}
 		}
 	}
+	if len(s.partials[seqID]) > s.maxPartials() {
+		if !s.limits.IsEvictOldest() {
+			s.breach(seqID, e, "max partials encountered")
+			return
+		}
+		s.evictPartial(seqID, 0, false)
+	}
+	if limit := s.limits.MaxPartialsPerKey; limit > 0 {
+		if links := e.SequenceLinks(); len(links) > 0 {
+			var oldest, n int
+			for i := len(s.partials[seqID]) - 1; i >= 0; i-- {
+				if filter.CompareSeqLinks(s.partials[seqID][i].SequenceLinks(), links) {
+					oldest = i
+					n++
+				}
+			}
+			if n >= limit {
+				if !s.limits.IsEvictOldest() {
+					s.breach(seqID, e, "max partials per link key encountered")
+					return
+				}
+				s.evictPartial(seqID, oldest, false)
+			}
+		}
+	}
 	if isOOO {
 		e.AddMeta(event.RuleSequenceOOOKey, true)
 	}
 	log.Debugf("adding partial to sequence [%s] slot [%d] for expression %q, ooo: %t: %s", s.name, seqID, s.expr(seqID), isOOO, e)
+	s.appendPartial(seqID, e)
+}
+
+// appendPartial stores the partial in the slot and
+// accounts for the memory occupied by the partial.
+func (s *sequenceState) appendPartial(seqID int, e *event.Event) {
+	size := partialSize(e)
+	s.partialsSize += size
+	partialsBytes.Add(s.name, size)
 	partialsPerSequence.Add(s.name, 1)
 	s.partials[seqID] = append(s.partials[seqID], e)
 	sort.Slice(s.partials[seqID], func(n, m int) bool { return s.partials[seqID][n].Timestamp.Before(s.partials[seqID][m].Timestamp) })
 }
 
+// removePartial removes the partial at the given
+// index from the slot. The caller must hold the
+// partials lock.
+func (s *sequenceState) removePartial(seqID, i int) *event.Event {
+	e := s.partials[seqID][i]
+	s.partials[seqID] = append(
+		s.partials[seqID][:i],
+		s.partials[seqID][i+1:]...)
+	if d, ok := s.absences[e]; ok {
+		d.timer.Stop()
+		delete(s.absences, e)
+	}
+	size := partialSize(e)
+	s.partialsSize -= size
+	partialsBytes.Add(s.name, -size)
+	partialsPerSequence.Add(s.name, -1)
+	return e
+}
+
+// evictPartial removes the partial from the slot. If the
+// spill parameter is true and the spill store is available,
+// the partial is written to the spill store.
+func (s *sequenceState) evictPartial(seqID, i int, spill bool) {
+	e := s.removePartial(seqID, i)
+	partialEvictions.Add(s.name, 1)
+	if !spill || s.spill == nil {
+		log.Debugf("evicted partial %s from sequence [%s] slot [%d]", e, s.name, seqID)
+		return
+	}
+	if err := s.spill.put(seqID, e); err != nil {
+		partialSpillErrors.Add(1)
+		log.Warnf("unable to spill partial %s of sequence %s: %v", e, s.name, err)
+		return
+	}
+	partialSpills.Add(s.name, 1)
+	log.Debugf("spilled partial %s from sequence [%s] slot [%d]", e, s.name, seqID)
+}
+
+// breach records the incoming partial was dropped
+// due to exceeding the partials limit.
+func (s *sequenceState) breach(seqID int, e *event.Event, reason string) {
+	partialBreaches.Add(s.name, 1)
+	if !s.isPartialsBreached.Load() {
+		log.Warnf("%s in sequence %s slot [%d]. "+
+			"Dropping incoming partial: %s", reason, s.name, seqID, e)
+	}
+	s.isPartialsBreached.Store(true)
+}
+
+// shrink evicts partials until the memory occupied by partials
+// drops below the memory limit. The eviction policy determines
+// whether the oldest or the newest partials are evicted first.
+// Evicted partials are spilled to disk if the spill store is
+// available.
+func (s *sequenceState) shrink() {
+	limit := int64(s.limits.MaxPartialsMemory) * 1024 * 1024
+	if limit == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.partialsSize > limit {
+		seqID, i := -1, -1
+		for slot, partials := range s.partials {
+			if len(partials) == 0 {
+				continue
+			}
+			if s.limits.IsEvictOldest() {
+				if seqID == -1 || partials[0].Timestamp.Before(s.partials[seqID][i].Timestamp) {
+					seqID, i = slot, 0
+				}
+				continue
+			}
+			n := len(partials) - 1
+			if seqID == -1 || partials[n].Timestamp.After(s.partials[seqID][i].Timestamp) {
+				seqID, i = slot, n
+			}
+		}
+		if seqID == -1 {
+			return
+		}
+		s.evictPartial(seqID, i, true)
+	}
+}
+
+// restorePartials faults the spilled partials of all
+// slots preceding the given sequence slot back into
+// the sequence state.
+func (s *sequenceState) restorePartials(seqID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for n := range seqID {
+		evts, err := s.spill.load(n)
+		if err != nil {
+			partialSpillErrors.Add(1)
+			log.Warnf("unable to restore spilled partials of sequence %s: %v", s.name, err)
+		}
+		for _, e := range evts {
+			_, e.PS = s.psnap.Find(e.PID)
+			s.appendPartial(n, e)
+		}
+	}
+}
+
+// partialSize estimates the memory occupied by the partial.
+// The process state is shared among events and thus ignored.
+func partialSize(e *event.Event) int64 {
+	size := eventSize + int64(len(e.Name)+len(e.Description)+len(e.Host))
+	for name, par := range e.Params {
+		size += paramSize + int64(len(name))
+		switch v := par.Value.(type) {
+		case string:
+			size += int64(len(v))
+		case []byte:
+			size += int64(len(v))
+		case []string:
+			for _, s := range v {
+				size += int64(len(s))
+			}
+		}
+	}
+	for _, f := range e.Callstack {
+		size += frameSize + int64(len(f.Symbol)+len(f.Module))
+	}
+	return size
+}
+
 // gc prunes the sequence partial if it remained
 // more time than specified by max span or if max
 // span is omitted, the partial is allowed to remain
@@ -349,31 +597,44 @@ func (s *sequenceState) addPartial(seqID int, e *event.Event, isOOO bool) {
 func (s *sequenceState) gc() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	dur := s.maxSpan
-	if dur == 0 {
-		dur = maxSequencePartialLifetime
-	}
+	dur := s.partialLifetime()
 	for idx := range s.exprs {
 		for i := len(s.partials[idx]) - 1; i >= 0; i-- {
+			if _, ok := s.absences[s.partials[idx][i]]; ok {
+				// completed or discarded by the absence deadline
+				continue
+			}
 			if len(s.partials[idx]) > 0 && time.Since(s.partials[idx][i].Timestamp) > dur {
 				log.Debugf("garbage collecting partial: [%s] of sequence [%s]", s.partials[idx][i], s.name)
 				// remove partial event from the corresponding slot
-				s.partials[idx] = append(
-					s.partials[idx][:i],
-					s.partials[idx][i+1:]...)
-				partialsPerSequence.Add(s.name, -1)
+				s.removePartial(idx, i)
 			}
 		}
 	}
+	if s.spill != nil {
+		if n := s.spill.gc(dur); n > 0 {
+			log.Debugf("garbage collected %d spilled partials of sequence [%s]", n, s.name)
+		}
+	}
 }
 
 func (s *sequenceState) clear() {
+	for _, d := range s.absences {
+		d.timer.Stop()
+	}
+	s.absences = make(map[*event.Event]*absenceDeadline)
 	s.partials = make(map[int][]*event.Event)
 	s.matches = make(map[int]*event.Event)
 	s.states = make(map[fsm.State]bool)
 	s.spanDeadlines = make(map[fsm.State]*time.Timer)
+	s.deadlines = make(map[fsm.State]time.Time)
 	s.isPartialsBreached.Store(false)
+	s.partialsSize = 0
 	partialsPerSequence.Delete(s.name)
+	partialsBytes.Delete(s.name)
+	if s.spill != nil {
+		s.spill.reset()
+	}
 	s.lastMatch = time.Time{}
 }
 
@@ -387,6 +648,29 @@ func (s *sequenceState) clearLocked() {
 	s.clear()
 }
 
+// drain stops all pending max span deadlines and
+// discards the sequence partials. It is called when
+// the sequence rule is modified or removed from the
+// ruleset on reload.
+func (s *sequenceState) drain() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.smu.Lock()
+	defer s.smu.Unlock()
+	s.mmu.Lock()
+	defer s.mmu.Unlock()
+	for _, t := range s.spanDeadlines {
+		t.Stop()
+	}
+	s.clear()
+	if s.spill != nil {
+		if err := s.spill.close(); err != nil {
+			log.Warnf("unable to remove spill store of sequence %s: %v", s.name, err)
+		}
+		s.spill = nil
+	}
+}
+
 // next determines whether the next expression in the
 // sequence should be evaluated. The expression is evaluated
 // if all its upstream sequence expression produced a match and
@@ -413,31 +697,314 @@ func (s *sequenceState) next(seqID int) bool {
 func (s *sequenceState) scheduleMaxSpanDeadline(seqID fsm.State, maxSpan time.Duration) {
 	t := time.AfterFunc(maxSpan, func() {
 		inState, _ := s.fsm.IsInState(seqID)
-		if inState {
-			log.Debugf("max span of %v exceded for expression [%s] of sequence [%s]", maxSpan, s.expr(seqID), s.name)
-			s.inDeadline.Store(true)
-			s.mu.Lock()
-			defer s.mu.Unlock()
-			s.smu.Lock()
-			defer s.smu.Unlock()
-			// transitions to deadline state
-			err := s.cancelTransition(seqID)
-			if err != nil {
-				s.inDeadline.Store(false)
-				log.Warnf("deadline transition failed: %v", err)
-			}
-			// transitions from deadline state to initial state
-			err = s.fsm.Fire(resetTransition)
-			if err != nil {
-				log.Warnf("unable to transition to initial state: %v", err)
-			}
+		if !inState {
+			return
+		}
+		log.Debugf("max span of %v exceded for expression [%s] of sequence [%s]", maxSpan, s.expr(seqID), s.name)
+		s.inDeadline.Store(true)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.smu.Lock()
+		defer s.smu.Unlock()
+		// transitions to deadline state
+		err := s.cancelTransition(seqID)
+		if err != nil {
+			s.inDeadline.Store(false)
+			log.Warnf("deadline transition failed: %v", err)
+		}
+		// transitions from deadline state to initial state
+		err = s.fsm.Fire(resetTransition)
+		if err != nil {
+			log.Warnf("unable to transition to initial state: %v", err)
 		}
 	})
 	s.spanDeadlines[seqID] = t
+	s.deadlines[seqID] = time.Now().Add(maxSpan)
+}
+
+// absenceDeadline is the max span deadline of the partial
+// awaiting the absence of the trailing negated expression.
+type absenceDeadline struct {
+	timer *time.Timer
+	at    time.Time
+}
+
+// absenceSlot returns the index of the last non-negated
+// expression if the sequence ends with the negated expression
+// and has the max span. Otherwise, returns -1.
+func (s *sequenceState) absenceSlot() int {
+	n := len(s.seq.Expressions)
+	if s.maxSpan == 0 || !s.isTrailingNegated(n-1) {
+		return -1
+	}
+	for i := n - 1; i >= 0; i-- {
+		if !s.seq.Expressions[i].IsNegated {
+			return i
+		}
+	}
+	return -1
+}
+
+// scheduleAbsenceDeadline schedules the max span deadline for the
+// partial that matched the expression preceding the trailing negated
+// expression. If the forbidden event hasn't been observed when the
+// deadline fires, the sequence matches for the partial. The caller
+// must hold the partials lock.
+func (s *sequenceState) scheduleAbsenceDeadline(e *event.Event, maxSpan time.Duration) {
+	if _, ok := s.absences[e]; ok {
+		return
+	}
+	log.Debugf("scheduling absence deadline of %v for partial %s of sequence [%s]", maxSpan, e, s.name)
+	s.absences[e] = &absenceDeadline{
+		timer: time.AfterFunc(maxSpan, func() {
+			// the forbidden event hasn't been observed
+			// during the max span, and thus the sequence
+			// matches for the partial
+			evts := s.completeAbsence(e)
+			if len(evts) > 0 && s.onAbsenceMatch != nil {
+				s.onAbsenceMatch(evts)
+			}
+		}),
+		at: time.Now().Add(maxSpan),
+	}
+}
+
+// isTrailingNegated determines if the state
+// pertains to the negated expression that is
+// the last in the sequence.
+func (s *sequenceState) isTrailingNegated(state fsm.State) bool {
+	seqID, ok := state.(int)
+	if !ok {
+		return false
+	}
+	return seqID == len(s.seq.Expressions)-1 && s.seq.Expressions[seqID].IsNegated
+}
+
+// isAbsenceGuarded determines if the negated expression
+// is watching for the forbidden event. This is the case
+// when all upstream expressions have matched, but the
+// downstream expression hasn't matched yet.
+func (s *sequenceState) isAbsenceGuarded(seqID int) bool {
+	if !s.next(seqID) {
+		return false
+	}
+	s.smu.RLock()
+	defer s.smu.RUnlock()
+	for i := seqID + 1; i < len(s.seq.Expressions); i++ {
+		if s.seq.Expressions[i].IsNegated {
+			continue
+		}
+		return !s.states[i]
+	}
+	return !s.states[seqID]
+}
+
+// cancelPartials is triggered when the event forbidden by
+// the negated expression arrives. If the forbidden event is
+// linked to upstream partials, only the linked partials are
+// discarded. The whole sequence is canceled when any of the
+// upstream slots is left without partials or the forbidden
+// event is not linked.
+func (s *sequenceState) cancelPartials(seqID int, e *event.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.smu.Lock()
+	defer s.smu.Unlock()
+
+	partialCancellations.Add(s.name, 1)
+
+	if links := e.SequenceLinks(); links != nil {
+		isEmpty := false
+		for i := range seqID {
+			if s.seq.Expressions[i].IsNegated {
+				continue
+			}
+			for j := len(s.partials[i]) - 1; j >= 0; j-- {
+				if !filter.CompareSeqLinks(s.partials[i][j].SequenceLinks(), links) {
+					continue
+				}
+				log.Debugf("removing partial %s from sequence [%s] slot [%d] "+
+					"due to forbidden event: %s", s.partials[i][j], s.name, i, e)
+				s.removePartial(i, j)
+			}
+			if len(s.partials[i]) == 0 {
+				isEmpty = true
+			}
+		}
+		if !isEmpty {
+			return
+		}
+	}
+
+	log.Debugf("%q sequence canceled by forbidden event "+
+		"of expression [%s]: %s", s.name, s.expr(seqID), e)
+
+	// stop pending deadlines as they would
+	// otherwise fire for the fresh sequence
+	for _, t := range s.spanDeadlines {
+		t.Stop()
+	}
+	// transitions to deadline state
+	err := s.cancelTransition(s.currentState())
+	if err != nil {
+		log.Warnf("cancel transition failed: %v", err)
+		return
+	}
+	// transitions from deadline state to initial state
+	err = s.fsm.Fire(resetTransition)
+	if err != nil {
+		log.Warnf("unable to transition to initial state: %v", err)
+	}
+}
+
+// completeAbsence is triggered when the max span of the partial
+// awaiting the absence of the trailing negated expression elapses.
+// The partial is joined with the upstream partials sharing the same
+// link, and these partials are removed from the sequence state. The
+// partials of other link keys remain intact and complete on their
+// own deadlines. The state machine is reset when no partials are
+// left. Returns the matched events or nil if the partial was
+// discarded in the meantime.
+func (s *sequenceState) completeAbsence(e *event.Event) []*event.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.smu.Lock()
+	defer s.smu.Unlock()
+
+	delete(s.absences, e)
+	slot := s.absenceSlot()
+	if slot < 0 || !slices.Contains(s.partials[slot], e) {
+		return nil
+	}
+
+	links := e.SequenceLinks()
+	isLinked := func(p *event.Event) bool {
+		if p == e {
+			return true
+		}
+		if links != nil {
+			return filter.CompareSeqLinks(p.SequenceLinks(), links)
+		}
+		return !s.seq.IsConstrained() && !p.ContainsMeta(event.RuleSequenceLinks)
+	}
+
+	// join the partial with the earliest linked
+	// partial of each upstream slot
+	evts := make([]*event.Event, 0, slot+1)
+	for seqID := 0; seqID < slot; seqID++ {
+		if s.seq.Expressions[seqID].IsNegated {
+			continue
+		}
+		for _, p := range s.partials[seqID] {
+			if isLinked(p) {
+				evts = append(evts, p)
+				break
+			}
+		}
+	}
+	evts = append(evts, e)
+
+	// discard the partials of the completed link key. If
+	// the sequence is not linked, only joined partials
+	// are removed
+	for seqID := 0; seqID <= slot; seqID++ {
+		for i := len(s.partials[seqID]) - 1; i >= 0; i-- {
+			p := s.partials[seqID][i]
+			if (links != nil && isLinked(p)) || slices.Contains(evts, p) {
+				s.removePartial(seqID, i)
+			}
+		}
+	}
+
+	log.Debugf("%q sequence matched due to absence of "+
+		"expression [%s]", s.name, s.expr(len(s.seq.Expressions)-1))
+	absenceMatches.Add(s.name, 1)
+
+	// reset the state machine if the sequence
+	// has no other in-flight partials
+	for seqID := 0; seqID <= slot; seqID++ {
+		if len(s.partials[seqID]) > 0 {
+			return evts
+		}
+	}
+	if err := s.fsm.Fire(matchTransition); err != nil {
+		matchTransitionErrors.Add(1)
+		log.Warnf("absence match transition failure: %v", err)
+	} else if err := s.fsm.Fire(resetTransition); err != nil {
+		log.Warnf("unable to transition to initial state: %v", err)
+	}
+	s.mmu.Lock()
+	defer s.mmu.Unlock()
+	s.clear()
+
+	return evts
+}
+
+// joinMatches collects the events from partials of adjacent
+// slots that are joined by the sequence link, or are not
+// linked at all if the sequence is unconstrained. Negated
+// slots never store partials and are skipped over. The caller
+// must hold the partials lock.
+func (s *sequenceState) joinMatches() {
+	setMatch := func(seqID int, e *event.Event) {
+		s.mmu.Lock()
+		defer s.mmu.Unlock()
+		if s.matches[seqID] == nil {
+			s.matches[seqID] = e
+		}
+	}
+
+	slots := make([]int, 0, len(s.seq.Expressions))
+	for seqID, expr := range s.seq.Expressions {
+		if !expr.IsNegated {
+			slots = append(slots, seqID)
+		}
+	}
+
+	// the sequence is only formed of a
+	// single expression and negated ones
+	if len(slots) == 1 {
+		if partials := s.partials[slots[0]]; len(partials) > 0 {
+			setMatch(slots[0], partials[0])
+		}
+		return
+	}
+
+	for n := 0; n < len(slots)-1; n++ {
+		seqID, next := slots[n], slots[n+1]
+		for _, outer := range s.partials[seqID] {
+			for _, inner := range s.partials[next] {
+				switch {
+				case filter.CompareSeqLinks(outer.SequenceLinks(), inner.SequenceLinks()):
+					setMatch(seqID, outer)
+					setMatch(next, inner)
+				case !s.seq.IsConstrained() && !outer.ContainsMeta(event.RuleSequenceLinks) && !inner.ContainsMeta(event.RuleSequenceLinks):
+					setMatch(seqID, outer)
+					setMatch(next, inner)
+				}
+			}
+		}
+	}
 }
 
 func (s *sequenceState) evalSequence(e *event.Event, v *filter.ValuerCache) bool {
 	for i, expr := range s.seq.Expressions {
+		// negated expressions cancel the sequence
+		// if the forbidden event arrives after the
+		// upstream expressions have matched
+		if expr.IsNegated {
+			if !s.isAbsenceGuarded(i) || (!s.lastMatch.IsZero() && !e.Timestamp.After(s.lastMatch)) {
+				continue
+			}
+			s.mu.RLock()
+			forbidden := expr.IsEvaluable(e) && s.filter.EvalSequence(e, v, i, s.partials, false)
+			s.mu.RUnlock()
+			if forbidden {
+				s.cancelPartials(i, e)
+			}
+			continue
+		}
+
 		// only try to evaluate the expression
 		// if upstream expressions have matched
 		if !s.next(i) {
@@ -461,6 +1028,18 @@ func (s *sequenceState) evalSequence(e *event.Event, v *filter.ValuerCache) bool
 			continue
 		}
 
+		// if upstream partials were spilled to disk, fault
+		// them back in when the event could potentially join
+		// the partials from upstream slots
+		if i > 0 && s.spill != nil && s.spill.hasUpstream(i) {
+			s.mu.RLock()
+			ok := expr.IsEvaluable(e) && s.filter.EvalSequence(e, v, i, s.partials, true)
+			s.mu.RUnlock()
+			if ok {
+				s.restorePartials(i)
+			}
+		}
+
 		s.mu.RLock()
 		matches := expr.IsEvaluable(e) && s.filter.EvalSequence(e, v, i, s.partials, false)
 		s.mu.RUnlock()
@@ -476,6 +1055,13 @@ func (s *sequenceState) evalSequence(e *event.Event, v *filter.ValuerCache) bool
 
 		// append the partial and transition state machine
 		s.addPartial(i, e, false)
+		if i == s.absenceSlot() {
+			s.mu.Lock()
+			if slices.Contains(s.partials[i], e) {
+				s.scheduleAbsenceDeadline(e, s.maxSpan)
+			}
+			s.mu.Unlock()
+		}
 		err := s.matchTransition(i, e)
 		if err != nil {
 			matchTransitionErrors.Add(1)
@@ -526,34 +1112,14 @@ func (s *sequenceState) evalSequence(e *event.Event, v *filter.ValuerCache) bool
 		// in the rule match
 		isTerminal := s.isTerminalState()
 		if isTerminal {
-			setMatch := func(seqID int, e *event.Event) {
-				s.mmu.Lock()
-				defer s.mmu.Unlock()
-				if s.matches[seqID] == nil {
-					s.matches[seqID] = e
-				}
-			}
-
 			s.mu.RLock()
-			for seqID := 0; seqID < len(s.partials); seqID++ {
-				for _, outer := range s.partials[seqID] {
-					for _, inner := range s.partials[seqID+1] {
-						switch {
-						case filter.CompareSeqLinks(outer.SequenceLinks(), inner.SequenceLinks()):
-							setMatch(seqID, outer)
-							setMatch(seqID+1, inner)
-						case !s.seq.IsConstrained() && !outer.ContainsMeta(event.RuleSequenceLinks) && !inner.ContainsMeta(event.RuleSequenceLinks):
-							setMatch(seqID, outer)
-							setMatch(seqID+1, inner)
-						}
-					}
-				}
-			}
+			s.joinMatches()
 			s.mu.RUnlock()
 
 			return true
 		}
 	}
+	s.shrink()
 	return false
 }
 
@@ -598,10 +1164,7 @@ func (s *sequenceState) expire(e *event.Event) bool {
 				s.name,
 				idx)
 			// remove partial event from the corresponding slot
-			s.partials[idx] = append(
-				s.partials[idx][:i],
-				s.partials[idx][i+1:]...)
-			partialsPerSequence.Add(s.name, -1)
+			s.removePartial(idx, i)
 
 			if len(s.partials[idx]) == 0 {
 				partialExpirations.Add(s.name, 1)
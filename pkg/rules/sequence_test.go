@@ -21,6 +21,7 @@ package rules
 import (
 	"net"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -1143,3 +1144,327 @@ func TestIsExpressionEvaluable(t *testing.T) {
 	assert.False(t, ss.filter.GetSequence().Expressions[0].IsEvaluable(e2))
 	assert.True(t, ss.filter.GetSequence().Expressions[0].IsEvaluable(e1))
 }
+
+func newFileEvent(typ event.Type, pid uint32, path string, ts time.Time) *event.Event {
+	return &event.Event{
+		Type:      typ,
+		Name:      typ.String(),
+		Category:  event.File,
+		Tid:       2484,
+		PID:       pid,
+		Timestamp: ts,
+		PS: &pstypes.PS{
+			Name: "cmd.exe",
+			Exe:  "C:\\Windows\\system32\\cmd.exe",
+		},
+		Params: event.Params{
+			params.FilePath: {Name: params.FilePath, Type: params.UnicodeString, Value: path},
+		},
+		Metadata: make(map[event.MetadataKey]any),
+	}
+}
+
+func TestSequenceNegatedExpression(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	c := &config.FilterConfig{Name: "Dropped executable followed by library without cleanup"}
+	f := filter.New(`
+	sequence
+	maxspan 1m
+	by ps.pid
+	|evt.name = 'CreateFile' and file.extension = '.exe'|
+	!|evt.name = 'DeleteFile'|
+	|evt.name = 'CreateFile' and file.extension = '.dll'|
+	`, &config.Config{EventSource: config.EventSourceConfig{EnableFileIOEvents: true}, Filters: &config.Filters{}})
+	require.NoError(t, f.Compile())
+
+	ss := newSequenceState(f, c, new(ps.SnapshotterMock))
+	now := time.Now()
+
+	// the forbidden event is not observed
+	require.False(t, runSequence(ss, newFileEvent(event.CreateFile, 1, "C:\\Temp\\dropper.exe", now)))
+	assert.True(t, ss.states[0])
+	assert.True(t, ss.states[1])
+	assert.Equal(t, 2, ss.currentState())
+	require.True(t, runSequence(ss, newFileEvent(event.CreateFile, 1, "C:\\Temp\\payload.dll", now.Add(time.Second))))
+	assert.Len(t, ss.events(), 2)
+	ss.clearLocked()
+
+	// the forbidden event arrives between the expressions
+	require.False(t, runSequence(ss, newFileEvent(event.CreateFile, 1, "C:\\Temp\\dropper.exe", now.Add(time.Second*2))))
+	// forbidden event for the unrelated process is ignored
+	require.False(t, runSequence(ss, newFileEvent(event.DeleteFile, 2, "C:\\Temp\\dropper.exe", now.Add(time.Second*3))))
+	assert.Len(t, ss.partials[0], 1)
+	require.False(t, runSequence(ss, newFileEvent(event.DeleteFile, 1, "C:\\Temp\\dropper.exe", now.Add(time.Second*4))))
+	assert.Len(t, ss.partials[0], 0)
+	assert.True(t, ss.isInitialState())
+	require.False(t, runSequence(ss, newFileEvent(event.CreateFile, 1, "C:\\Temp\\payload.dll", now.Add(time.Second*5))))
+}
+
+func TestSequenceTrailingNegatedExpression(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	c := &config.FilterConfig{Name: "File created and not deleted"}
+	f := filter.New(`
+	sequence
+	maxspan 100ms
+	by ps.pid
+	|evt.name = 'CreateFile' and file.extension = '.exe'|
+	!|evt.name = 'DeleteFile'|
+	`, &config.Config{EventSource: config.EventSourceConfig{EnableFileIOEvents: true}, Filters: &config.Filters{}})
+	require.NoError(t, f.Compile())
+
+	ss := newSequenceState(f, c, new(ps.SnapshotterMock))
+	matches := make(chan []*event.Event, 1)
+	ss.onAbsenceMatch = func(evts []*event.Event) {
+		matches <- evts
+	}
+
+	require.False(t, runSequence(ss, newFileEvent(event.CreateFile, 1, "C:\\Temp\\dropper.exe", time.Now())))
+	assert.Equal(t, 1, ss.currentState())
+
+	select {
+	case evts := <-matches:
+		require.Len(t, evts, 1)
+		assert.Equal(t, "C:\\Temp\\dropper.exe", evts[0].GetParamAsString(params.FilePath))
+	case <-time.After(time.Second):
+		t.Fatal("expected absence match")
+	}
+	assert.True(t, ss.isInitialState())
+
+	// the forbidden event cancels the sequence
+	require.False(t, runSequence(ss, newFileEvent(event.CreateFile, 1, "C:\\Temp\\dropper.exe", time.Now())))
+	require.False(t, runSequence(ss, newFileEvent(event.DeleteFile, 1, "C:\\Temp\\dropper.exe", time.Now().Add(time.Millisecond))))
+	assert.True(t, ss.isInitialState())
+
+	select {
+	case <-matches:
+		t.Fatal("unexpected absence match")
+	case <-time.After(time.Millisecond * 300):
+	}
+}
+
+func TestSequenceTrailingNegatedExpressionPerLinkKey(t *testing.T) {
+	c := &config.FilterConfig{Name: "File created and not deleted"}
+	f := filter.New(`
+	sequence
+	maxspan 300ms
+	by ps.pid
+	|evt.name = 'CreateFile' and file.extension = '.exe'|
+	!|evt.name = 'DeleteFile'|
+	`, &config.Config{EventSource: config.EventSourceConfig{EnableFileIOEvents: true}, Filters: &config.Filters{}})
+	require.NoError(t, f.Compile())
+
+	ss := newSequenceState(f, c, new(ps.SnapshotterMock))
+	matches := make(chan []*event.Event, 2)
+	ss.onAbsenceMatch = func(evts []*event.Event) {
+		matches <- evts
+	}
+
+	start1 := time.Now()
+	require.False(t, runSequence(ss, newFileEvent(event.CreateFile, 1, "C:\\Temp\\dropper.exe", start1)))
+	time.Sleep(time.Millisecond * 150)
+	start2 := time.Now()
+	require.False(t, runSequence(ss, newFileEvent(event.CreateFile, 2, "C:\\Temp\\loader.exe", start2)))
+
+	// the first link key fires after its own max
+	// span, while the partial of the second link
+	// key stays in the sequence state
+	select {
+	case evts := <-matches:
+		require.Len(t, evts, 1)
+		assert.Equal(t, uint32(1), evts[0].PID)
+		assert.GreaterOrEqual(t, time.Since(start1), time.Millisecond*300)
+		assert.Less(t, time.Since(start2), time.Millisecond*300)
+	case <-time.After(time.Second):
+		t.Fatal("expected absence match for the first link key")
+	}
+	ss.mu.RLock()
+	require.Len(t, ss.partials[0], 1)
+	assert.Equal(t, uint32(2), ss.partials[0][0].PID)
+	ss.mu.RUnlock()
+	assert.False(t, ss.isInitialState())
+
+	select {
+	case evts := <-matches:
+		require.Len(t, evts, 1)
+		assert.Equal(t, uint32(2), evts[0].PID)
+		assert.GreaterOrEqual(t, time.Since(start2), time.Millisecond*300)
+	case <-time.After(time.Second):
+		t.Fatal("expected absence match for the second link key")
+	}
+	assert.True(t, ss.isInitialState())
+
+	// the forbidden event only discards the partial of its link key
+	require.False(t, runSequence(ss, newFileEvent(event.CreateFile, 1, "C:\\Temp\\dropper.exe", time.Now())))
+	require.False(t, runSequence(ss, newFileEvent(event.CreateFile, 2, "C:\\Temp\\loader.exe", time.Now().Add(time.Millisecond))))
+	require.False(t, runSequence(ss, newFileEvent(event.DeleteFile, 1, "C:\\Temp\\dropper.exe", time.Now().Add(time.Millisecond*2))))
+
+	select {
+	case evts := <-matches:
+		require.Len(t, evts, 1)
+		assert.Equal(t, uint32(2), evts[0].PID)
+	case <-time.After(time.Second):
+		t.Fatal("expected absence match for the second link key")
+	}
+	select {
+	case <-matches:
+		t.Fatal("unexpected absence match")
+	case <-time.After(time.Millisecond * 400):
+	}
+	assert.True(t, ss.isInitialState())
+}
+
+func TestSequencePartialsLimits(t *testing.T) {
+	newProcEvent := func(seq uint64, pid uint32) *event.Event {
+		return &event.Event{
+			Seq:       seq,
+			Type:      event.CreateProcess,
+			Timestamp: time.Now().Add(time.Millisecond * time.Duration(seq)),
+			Name:      "CreateProcess",
+			Tid:       2484,
+			PID:       pid,
+			PS: &pstypes.PS{
+				PID:  pid,
+				Name: "cmd.exe",
+			},
+			Params: event.Params{
+				params.ProcessID: {Name: params.ProcessID, Type: params.Uint32, Value: uint32(4143)},
+			},
+		}
+	}
+
+	var tests = []struct {
+		name     string
+		limits   config.Sequences
+		pids     []uint32
+		partials []uint64
+		breached bool
+	}{
+		{
+			"drop newest partial per link key",
+			config.Sequences{MaxPartialsPerKey: 2},
+			[]uint32{10, 10, 10, 20},
+			[]uint64{1, 2, 4},
+			true,
+		},
+		{
+			"evict oldest partial per link key",
+			config.Sequences{MaxPartialsPerKey: 2, Eviction: config.EvictOldestPartial},
+			[]uint32{10, 10, 10, 20},
+			[]uint64{2, 3, 4},
+			false,
+		},
+		{
+			"evict oldest partial in slot",
+			config.Sequences{MaxPartials: 1, Eviction: config.EvictOldestPartial},
+			[]uint32{10, 20, 30},
+			[]uint64{2, 3},
+			false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &config.FilterConfig{Name: tt.name}
+			f := filter.New(`
+			sequence
+			maxspan 1h
+			|evt.name = 'CreateProcess'| by ps.pid
+			|evt.name = 'CreateFile'| by ps.pid
+			`, &config.Config{EventSource: config.EventSourceConfig{EnableFileIOEvents: true}, Filters: &config.Filters{}})
+			require.NoError(t, f.Compile())
+
+			ss := newSequenceState(f, c, new(ps.SnapshotterMock))
+			ss.setLimits(tt.limits)
+
+			for i, pid := range tt.pids {
+				require.False(t, runSequence(ss, newProcEvent(uint64(i+1), pid)))
+			}
+
+			partials := make([]uint64, 0, len(ss.partials[0]))
+			for _, e := range ss.partials[0] {
+				partials = append(partials, e.Seq)
+			}
+			assert.Equal(t, tt.partials, partials)
+			assert.Equal(t, tt.breached, ss.isPartialsBreached.Load())
+			assert.Equal(t, int64(len(tt.partials))*partialSize(ss.partials[0][0]), ss.partialsSize)
+		})
+	}
+}
+
+func TestSequencePartialsSpill(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	psnap := new(ps.SnapshotterMock)
+	psnap.On("Find", uint32(10)).Return(true, &pstypes.PS{PID: 10, Name: "cmd.exe"})
+
+	c := &config.FilterConfig{Name: "Spilled partials"}
+	f := filter.New(`
+	sequence
+	maxspan 1h
+	|evt.name = 'CreateProcess'| by ps.pid
+	|evt.name = 'CreateFile'| by ps.pid
+	`, &config.Config{EventSource: config.EventSourceConfig{EnableFileIOEvents: true}, Filters: &config.Filters{}})
+	require.NoError(t, f.Compile())
+
+	ss := newSequenceState(f, c, psnap)
+	ss.setLimits(config.Sequences{MaxPartialsMemory: 1, SpillDir: t.TempDir()})
+	require.NotNil(t, ss.spill)
+	defer ss.drain()
+
+	// each partial occupies more than half of the memory
+	// budget, so the newest partial is spilled to disk
+	cmdline := strings.Repeat("A", 600*1024)
+	for i, pid := range []uint32{20, 10} {
+		e := &event.Event{
+			Seq:       uint64(i + 1),
+			Type:      event.CreateProcess,
+			Timestamp: time.Now().Add(time.Millisecond * time.Duration(i)),
+			Name:      "CreateProcess",
+			Tid:       2484,
+			PID:       pid,
+			PS: &pstypes.PS{
+				PID:  pid,
+				Name: "cmd.exe",
+			},
+			Params: event.Params{
+				params.ProcessID: {Name: params.ProcessID, Type: params.Uint32, Value: uint32(4143)},
+				params.Cmdline:   {Name: params.Cmdline, Type: params.UnicodeString, Value: cmdline},
+			},
+		}
+		require.False(t, runSequence(ss, e))
+	}
+
+	require.Len(t, ss.partials[0], 1)
+	assert.Equal(t, uint32(20), ss.partials[0][0].PID)
+	assert.Equal(t, 1, ss.spill.size())
+	assert.LessOrEqual(t, ss.partialsSize, int64(1024*1024))
+
+	e := &event.Event{
+		Seq:       3,
+		Type:      event.CreateFile,
+		Timestamp: time.Now().Add(time.Second),
+		Name:      "CreateFile",
+		Tid:       2484,
+		PID:       10,
+		Category:  event.File,
+		PS: &pstypes.PS{
+			PID:  10,
+			Name: "cmd.exe",
+		},
+		Params: event.Params{
+			params.FilePath: {Name: params.FilePath, Type: params.UnicodeString, Value: "C:\\Temp\\dropper.exe"},
+		},
+	}
+
+	// the spilled partial is faulted back in
+	// and joined with the incoming event
+	require.True(t, runSequence(ss, e))
+	assert.Equal(t, 0, ss.spill.size())
+	events := ss.events()
+	require.Len(t, events, 2)
+	for _, evt := range events {
+		assert.Equal(t, uint32(10), evt.PID)
+	}
+}
@@ -0,0 +1,451 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sigma
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/rabbitstack/fibratus/pkg/config"
+	"github.com/rabbitstack/fibratus/pkg/filter"
+	"gopkg.in/yaml.v3"
+)
+
+// minEngineVersion is the minimum engine version that
+// supports all the fields produced by the converter.
+const minEngineVersion = "3.0.0"
+
+// severities maps Sigma rule levels to rule severities.
+var severities = map[string]string{
+	"informational": "low",
+	"low":           "low",
+	"medium":        "medium",
+	"high":          "high",
+	"critical":      "critical",
+}
+
+// converter keeps the state of the Sigma rule conversion.
+type converter struct {
+	rule *Rule
+	ls   logsource
+	// selections contains detection
+	// identifiers in declaration order
+	selections []string
+	detection  map[string]*yaml.Node
+	exprs      map[string]*expr
+	conditions []string
+
+	warnings    []string
+	unsupported []string
+}
+
+// Convert translates the Sigma rule into the rule definition. Non-fatal
+// conversion notes are returned as warnings. If the Sigma rule contains
+// constructs that have no equivalent in the rule language, the error
+// enumerating all unsupported constructs is returned.
+func Convert(r *Rule) (*config.FilterConfig, []string, error) {
+	c := &converter{rule: r, detection: make(map[string]*yaml.Node), exprs: make(map[string]*expr)}
+
+	if !strings.EqualFold(r.LogSource.Product, "windows") {
+		c.unsupport("log source %s: only the windows product is supported", r.LogSource)
+		return nil, nil, c.err()
+	}
+	ls, ok := logsources[r.LogSource.Category]
+	if !ok {
+		c.unsupport("log source %s", r.LogSource)
+		return nil, nil, c.err()
+	}
+	c.ls = ls
+	if r.LogSource.Service != "" {
+		c.warn("log source service %s is ignored", r.LogSource.Service)
+	}
+
+	c.readDetection()
+	// translate all selections upfront to report
+	// unsupported constructs regardless of whether
+	// the selection is referenced in the condition
+	for _, name := range c.selections {
+		c.exprs[name] = c.selection(name)
+	}
+
+	var cond *expr
+	if len(c.conditions) == 0 {
+		c.unsupport("detection without condition")
+	} else {
+		conds := make([]*expr, 0, len(c.conditions))
+		for _, s := range c.conditions {
+			e, err := c.parseCondition(s)
+			if err != nil {
+				c.unsupport("condition %q: %v", s, err)
+				continue
+			}
+			conds = append(conds, e)
+		}
+		cond = join(or, conds)
+	}
+	if len(c.unsupported) > 0 {
+		return nil, c.warnings, c.err()
+	}
+
+	condition := c.ls.expr + " and\n" + cond.lines()
+	// make sure the resulting condition is valid
+	if _, err := filter.NewFromCLIWithAllAccessors([]string{condition}); err != nil {
+		return nil, c.warnings, fmt.Errorf("rule %q converted to invalid condition: %v", r.Title, err)
+	}
+
+	f := &config.FilterConfig{
+		ID:               c.ruleID(),
+		Name:             r.Title,
+		Description:      strings.TrimSpace(r.Description),
+		Version:          "1.0.0",
+		Condition:        condition,
+		References:       r.References,
+		Notes:            c.notes(),
+		MinEngineVersion: minEngineVersion,
+	}
+	if r.Author != "" {
+		for _, author := range strings.Split(r.Author, ",") {
+			f.Authors = append(f.Authors, strings.TrimSpace(author))
+		}
+	}
+	if r.Level != "" {
+		severity, ok := severities[strings.ToLower(r.Level)]
+		if !ok {
+			c.warn("unknown level %s", r.Level)
+		}
+		if r.Level == "informational" {
+			c.warn("informational level is mapped to low severity")
+		}
+		f.Severity = severity
+	}
+	if r.Status == "deprecated" || r.Status == "unsupported" {
+		c.warn("rule has %s status", r.Status)
+	}
+	f.Labels, f.Tags = c.mapTags()
+
+	return f, c.warnings, nil
+}
+
+func (c *converter) warn(s string, args ...any) {
+	c.warnings = append(c.warnings, fmt.Sprintf(s, args...))
+}
+
+func (c *converter) unsupport(s string, args ...any) {
+	c.unsupported = append(c.unsupported, fmt.Sprintf(s, args...))
+}
+
+func (c *converter) err() error {
+	return &UnsupportedError{Rule: c.rule.Title, Constructs: c.unsupported}
+}
+
+// ruleID keeps the Sigma rule identifier if it is a valid
+// UUID. Otherwise, the new rule identifier is generated.
+func (c *converter) ruleID() string {
+	id, err := uuid.Parse(c.rule.ID)
+	if err != nil {
+		c.warn("rule has invalid id %q, generated a new one", c.rule.ID)
+		return uuid.New().String()
+	}
+	return id.String()
+}
+
+// notes renders known false positives as rule notes.
+func (c *converter) notes() string {
+	if len(c.rule.FalsePositives) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("False positives:\n")
+	for _, fp := range c.rule.FalsePositives {
+		b.WriteString("- " + fp + "\n")
+	}
+	return b.String()
+}
+
+// readDetection indexes detection selections
+// and collects the detection conditions.
+func (c *converter) readDetection() {
+	nodes := c.rule.Detection.Content
+	for i := 0; i+1 < len(nodes); i += 2 {
+		key, value := nodes[i].Value, nodes[i+1]
+		switch key {
+		case "condition":
+			switch value.Kind {
+			case yaml.ScalarNode:
+				c.conditions = append(c.conditions, value.Value)
+			case yaml.SequenceNode:
+				for _, n := range value.Content {
+					c.conditions = append(c.conditions, n.Value)
+				}
+			}
+		case "timeframe":
+			c.unsupport("timeframe %s", value.Value)
+		default:
+			c.selections = append(c.selections, key)
+			c.detection[key] = value
+		}
+	}
+}
+
+// selection translates the detection selection into the expression.
+// Maps are converted to the conjunction of field expressions, while
+// lists of maps are converted to the disjunction of map expressions.
+func (c *converter) selection(name string) *expr {
+	node := c.detection[name]
+	switch node.Kind {
+	case yaml.MappingNode:
+		return c.fields(node)
+	case yaml.SequenceNode:
+		exprs := make([]*expr, 0, len(node.Content))
+		for _, n := range node.Content {
+			if n.Kind != yaml.MappingNode {
+				c.unsupport("keyword search in selection %s", name)
+				return nil
+			}
+			exprs = append(exprs, c.fields(n))
+		}
+		return join(or, exprs)
+	default:
+		c.unsupport("keyword search in selection %s", name)
+		return nil
+	}
+}
+
+// fields builds the conjunction of field expressions.
+func (c *converter) fields(node *yaml.Node) *expr {
+	exprs := make([]*expr, 0, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		e := c.field(node.Content[i].Value, node.Content[i+1])
+		if e != nil {
+			exprs = append(exprs, e)
+		}
+	}
+	return join(and, exprs)
+}
+
+// field translates the field with optional value modifiers.
+func (c *converter) field(key string, node *yaml.Node) *expr {
+	name, mods, err := parseModifiers(key)
+	if err != nil {
+		c.unsupport("%s: %v", key, err)
+		return nil
+	}
+	field, ok := c.ls.field(name)
+	if !ok {
+		c.unsupport("field %s in %s log source", name, c.rule.LogSource)
+		return nil
+	}
+
+	var nodes []*yaml.Node
+	switch node.Kind {
+	case yaml.ScalarNode:
+		nodes = []*yaml.Node{node}
+	case yaml.SequenceNode:
+		nodes = node.Content
+	default:
+		c.unsupport("%s: nested values", key)
+		return nil
+	}
+
+	vals := make([]value, 0, len(nodes))
+	for _, n := range nodes {
+		if n.Kind != yaml.ScalarNode {
+			c.unsupport("%s: nested values", key)
+			return nil
+		}
+		if n.Tag == "!!null" {
+			c.unsupport("%s: null value", key)
+			return nil
+		}
+		v := newValue(n)
+		if m, ok := c.ls.values[name]; ok {
+			s, ok := m[strings.ToLower(n.Value)]
+			if !ok {
+				c.unsupport("%s: value %s", key, n.Value)
+				return nil
+			}
+			v = value{s: s, str: true}
+		}
+		vals = append(vals, v)
+	}
+
+	if mods.windash {
+		vals = expandWindash(vals)
+		c.warn("%s: windash modifier expands to hyphen and slash variants", key)
+	}
+
+	e, err := mods.expr(field, vals)
+	if err != nil {
+		c.unsupport("%s: %v", key, err)
+		return nil
+	}
+	return e
+}
+
+// parseCondition parses the Sigma detection condition.
+func (c *converter) parseCondition(s string) (*expr, error) {
+	if strings.Contains(s, "|") {
+		return nil, fmt.Errorf("aggregation expressions")
+	}
+	p := &condParser{c: c, toks: tokenize(s)}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, fmt.Errorf("unexpected token %q", p.toks[p.pos])
+	}
+	return e, nil
+}
+
+// tokenize splits the condition into parentheses and words.
+func tokenize(s string) []string {
+	s = strings.NewReplacer("(", " ( ", ")", " ) ").Replace(s)
+	return strings.Fields(s)
+}
+
+// condParser is the recursive-descent parser of the detection
+// condition. The not operator binds tighter than and, which in
+// turn binds tighter than or.
+type condParser struct {
+	c    *converter
+	toks []string
+	pos  int
+}
+
+func (p *condParser) peek() string {
+	if p.pos < len(p.toks) {
+		return strings.ToLower(p.toks[p.pos])
+	}
+	return ""
+}
+
+func (p *condParser) next() string {
+	tok := p.toks[p.pos]
+	p.pos++
+	return tok
+}
+
+func (p *condParser) parseOr() (*expr, error) {
+	lhs, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	exprs := []*expr{lhs}
+	for p.peek() == "or" {
+		p.next()
+		rhs, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, rhs)
+	}
+	return join(or, exprs), nil
+}
+
+func (p *condParser) parseAnd() (*expr, error) {
+	lhs, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	exprs := []*expr{lhs}
+	for p.peek() == "and" {
+		p.next()
+		rhs, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, rhs)
+	}
+	return join(and, exprs), nil
+}
+
+func (p *condParser) parseNot() (*expr, error) {
+	if p.peek() == "not" {
+		p.next()
+		e, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &expr{op: not, args: []*expr{e}}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *condParser) parsePrimary() (*expr, error) {
+	switch tok := p.peek(); tok {
+	case "":
+		return nil, fmt.Errorf("unexpected end of condition")
+	case "(":
+		p.next()
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		p.next()
+		return e, nil
+	case "1", "any", "all":
+		p.next()
+		if p.peek() != "of" {
+			return nil, fmt.Errorf("expected of after %s", tok)
+		}
+		p.next()
+		if p.peek() == "" {
+			return nil, fmt.Errorf("expected selection pattern after of")
+		}
+		op := or
+		if tok == "all" {
+			op = and
+		}
+		return p.quantify(op, p.next())
+	default:
+		name := p.next()
+		if _, ok := p.c.detection[name]; !ok {
+			return nil, fmt.Errorf("unknown selection %s", name)
+		}
+		return p.c.exprs[name], nil
+	}
+}
+
+// quantify joins all selections matching the pattern.
+// The them keyword matches all selections except those
+// starting with the underscore.
+func (p *condParser) quantify(op string, pattern string) (*expr, error) {
+	exprs := make([]*expr, 0)
+	for _, name := range p.c.selections {
+		var ok bool
+		if strings.EqualFold(pattern, "them") {
+			ok = !strings.HasPrefix(name, "_")
+		} else {
+			ok, _ = path.Match(pattern, name)
+		}
+		if ok {
+			exprs = append(exprs, p.c.exprs[name])
+		}
+	}
+	if len(exprs) == 0 {
+		return nil, fmt.Errorf("no selections match %s", pattern)
+	}
+	return join(op, exprs), nil
+}
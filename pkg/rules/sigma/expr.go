@@ -0,0 +1,112 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sigma
+
+import "strings"
+
+const (
+	or   = "or"
+	and  = "and"
+	not  = "not"
+	leaf = ""
+)
+
+// expr is the node of the translated condition.
+// Leaf nodes contain the rule language expression,
+// while other nodes combine their arguments with
+// the logical operator.
+type expr struct {
+	op   string
+	args []*expr
+	s    string
+}
+
+// join combines expressions with the logical operator.
+// Nested expressions with the same operator are flattened.
+func join(op string, exprs []*expr) *expr {
+	args := make([]*expr, 0, len(exprs))
+	for _, e := range exprs {
+		switch {
+		case e == nil:
+		case e.op == op:
+			args = append(args, e.args...)
+		default:
+			args = append(args, e)
+		}
+	}
+	switch len(args) {
+	case 0:
+		return nil
+	case 1:
+		return args[0]
+	default:
+		return &expr{op: op, args: args}
+	}
+}
+
+func precedence(op string) int {
+	switch op {
+	case or:
+		return 1
+	case and:
+		return 2
+	case not:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// render produces the expression string. The expression
+// is wrapped in parentheses if the precedence of the parent
+// operator is higher than the precedence of this expression.
+// Negated expressions are always parenthesized.
+func (e *expr) render(parent string) string {
+	var s string
+	switch e.op {
+	case leaf:
+		return e.s
+	case not:
+		s = "not (" + e.args[0].render(or) + ")"
+	default:
+		args := make([]string, len(e.args))
+		for i, arg := range e.args {
+			args[i] = arg.render(e.op)
+		}
+		s = strings.Join(args, " "+e.op+" ")
+	}
+	if precedence(e.op) < precedence(parent) {
+		return "(" + s + ")"
+	}
+	return s
+}
+
+// lines renders the expression placing each
+// operand of the top-level conjunction on its
+// own line.
+func (e *expr) lines() string {
+	if e.op != and {
+		return e.render(and)
+	}
+	args := make([]string, len(e.args))
+	for i, arg := range e.args {
+		args[i] = arg.render(and)
+	}
+	return strings.Join(args, " and\n")
+}
@@ -0,0 +1,184 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sigma
+
+// logsource describes how the Sigma log source
+// maps onto Fibratus events and filter fields.
+type logsource struct {
+	// expr scopes the rule to events of the log source
+	expr string
+	// fields maps Sigma field names to filter fields
+	fields map[string]string
+	// values maps Sigma field values to field values for
+	// fields with distinct value domains, e.g. the network
+	// connection direction translates to the event name
+	values map[string]map[string]string
+}
+
+// processFields contains fields of the process
+// that generated the event. These fields are
+// available in all log sources.
+var processFields = map[string]string{
+	"Image":             "ps.exe",
+	"CommandLine":       "ps.cmdline",
+	"ProcessId":         "ps.pid",
+	"User":              "ps.username",
+	"CurrentDirectory":  "ps.cwd",
+	"ParentImage":       "ps.parent.exe",
+	"ParentCommandLine": "ps.parent.cmdline",
+	"ParentProcessId":   "ps.ppid",
+	"ParentUser":        "ps.parent.username",
+}
+
+// logsources contains supported Sigma log source
+// categories for the Windows product.
+var logsources = map[string]logsource{
+	"process_creation": {
+		expr: "evt.name = 'CreateProcess'",
+		fields: map[string]string{
+			"OriginalFileName": "ps.pe.file.name",
+			"Product":          "ps.pe.product",
+			"Company":          "ps.pe.company",
+			"Description":      "ps.pe.description",
+			"FileVersion":      "ps.pe.file.version",
+			"Imphash":          "ps.pe.imphash",
+		},
+	},
+	"process_access": {
+		expr: "evt.name = 'OpenProcess'",
+		fields: map[string]string{
+			"SourceImage":     "ps.exe",
+			"SourceProcessId": "ps.pid",
+			"SourceUser":      "ps.username",
+			"TargetImage":     "evt.arg[exe]",
+			"TargetProcessId": "evt.arg[pid]",
+			"GrantedAccess":   "ps.access.mask",
+		},
+	},
+	"create_remote_thread": {
+		expr: "evt.name = 'CreateThread' and evt.pid != 4 and evt.pid != thread.pid",
+		fields: map[string]string{
+			"SourceImage":     "ps.exe",
+			"SourceProcessId": "ps.pid",
+			"SourceUser":      "ps.username",
+			"TargetProcessId": "thread.pid",
+			"StartAddress":    "thread.start_address",
+			"StartModule":     "thread.start_address.module",
+			"StartFunction":   "thread.start_address.symbol",
+		},
+	},
+	"file_event": {
+		expr: "evt.name = 'CreateFile' and file.operation != 'OPEN'",
+		fields: map[string]string{
+			"TargetFilename": "file.path",
+		},
+	},
+	"file_access": {
+		expr: "evt.name = 'CreateFile' and file.operation = 'OPEN'",
+		fields: map[string]string{
+			"TargetFilename": "file.path",
+			"FileName":       "file.path",
+		},
+	},
+	"file_delete": {
+		expr: "evt.name = 'DeleteFile'",
+		fields: map[string]string{
+			"TargetFilename": "file.path",
+		},
+	},
+	"file_rename": {
+		expr: "evt.name = 'RenameFile'",
+		fields: map[string]string{
+			"SourceFilename": "file.path",
+		},
+	},
+	"image_load": {
+		expr: "evt.name = 'LoadModule'",
+		fields: map[string]string{
+			"ImageLoaded": "module.path",
+			"Signed":      "module.signature.exists",
+			"Signature":   "module.signature.subject",
+		},
+	},
+	"driver_load": {
+		expr: "evt.name = 'LoadModule' and (module.name iendswith '.sys' or module.is_driver)",
+		fields: map[string]string{
+			"ImageLoaded": "module.path",
+			"Signed":      "module.signature.exists",
+			"Signature":   "module.signature.subject",
+		},
+	},
+	"registry_add": {
+		expr: "evt.name = 'RegCreateKey'",
+		fields: map[string]string{
+			"TargetObject": "registry.path",
+		},
+	},
+	"registry_set": {
+		expr: "evt.name = 'RegSetValue'",
+		fields: map[string]string{
+			"TargetObject": "registry.path",
+			"Details":      "registry.data",
+		},
+	},
+	"registry_delete": {
+		expr: "evt.name in ('RegDeleteKey', 'RegDeleteValue')",
+		fields: map[string]string{
+			"TargetObject": "registry.path",
+		},
+	},
+	"registry_event": {
+		expr: "evt.name in ('RegCreateKey', 'RegSetValue', 'RegDeleteKey', 'RegDeleteValue')",
+		fields: map[string]string{
+			"TargetObject": "registry.path",
+			"Details":      "registry.data",
+		},
+	},
+	"network_connection": {
+		expr: "evt.name in ('Connect', 'Accept')",
+		fields: map[string]string{
+			"DestinationIp":       "net.dip",
+			"DestinationPort":     "net.dport",
+			"DestinationHostname": "net.dip.names",
+			"SourceIp":            "net.sip",
+			"SourcePort":          "net.sport",
+			"SourceHostname":      "net.sip.names",
+			"Initiated":           "evt.name",
+		},
+		values: map[string]map[string]string{
+			"Initiated": {"true": "Connect", "false": "Accept"},
+		},
+	},
+	"dns_query": {
+		expr: "evt.name = 'QueryDns'",
+		fields: map[string]string{
+			"QueryName":    "dns.name",
+			"QueryResults": "dns.answers",
+		},
+	},
+}
+
+// field resolves the filter field for the Sigma field name.
+func (l logsource) field(name string) (string, bool) {
+	if f, ok := l.fields[name]; ok {
+		return f, true
+	}
+	f, ok := processFields[name]
+	return f, ok
+}
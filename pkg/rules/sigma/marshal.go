@@ -0,0 +1,132 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sigma
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+
+	"github.com/rabbitstack/fibratus/pkg/config"
+	"gopkg.in/yaml.v3"
+)
+
+// labelKeys determines the order of the rule labels.
+var labelKeys = []string{
+	"tactic.id",
+	"tactic.name",
+	"tactic.ref",
+	"technique.id",
+	"technique.name",
+	"technique.ref",
+	"subtechnique.id",
+	"subtechnique.name",
+	"subtechnique.ref",
+}
+
+var ruleTemplate = template.Must(template.New("rule").Funcs(template.FuncMap{
+	"str":    str,
+	"indent": indent,
+	"labels": labels,
+}).Parse(`name: {{ str .Name }}
+id: {{ .ID }}
+version: {{ .Version }}
+{{- if .Description }}
+description: |
+{{ indent .Description }}
+{{- end }}
+{{- if .Labels }}
+labels:
+{{- range labels .Labels }}
+  {{ index . 0 }}: {{ str (index . 1) }}
+{{- end }}
+{{- end }}
+{{- if .Tags }}
+tags:
+{{- range .Tags }}
+  - {{ str . }}
+{{- end }}
+{{- end }}
+{{- if .References }}
+references:
+{{- range .References }}
+  - {{ str . }}
+{{- end }}
+{{- end }}
+{{- if .Authors }}
+authors:
+{{- range .Authors }}
+  - {{ str . }}
+{{- end }}
+{{- end }}
+{{- if .Notes }}
+notes: |
+{{ indent .Notes }}
+{{- end }}
+
+condition: >
+{{ indent .Condition }}
+{{- if .Severity }}
+
+severity: {{ .Severity }}
+{{- end }}
+
+min-engine-version: {{ .MinEngineVersion }}
+`))
+
+// Marshal renders the rule definition in the layout
+// followed by the rules in the Fibratus ruleset.
+func Marshal(f *config.FilterConfig) ([]byte, error) {
+	var b bytes.Buffer
+	if err := ruleTemplate.Execute(&b, f); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// str renders the string as YAML scalar, quoting it if necessary.
+func str(s string) string {
+	b, err := yaml.Marshal(s)
+	if err != nil {
+		return s
+	}
+	return strings.TrimSuffix(string(b), "\n")
+}
+
+// indent indents all lines of the block scalar.
+func indent(s string) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = "  " + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// labels returns label key/value pairs in the conventional order.
+func labels(m map[string]string) [][2]string {
+	pairs := make([][2]string, 0, len(m))
+	for _, k := range labelKeys {
+		if v, ok := m[k]; ok {
+			pairs = append(pairs, [2]string{k, v})
+		}
+	}
+	return pairs
+}
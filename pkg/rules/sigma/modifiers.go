@@ -0,0 +1,274 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sigma
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// modifiers contains the Sigma value modifiers
+// applied to the detection field.
+type modifiers struct {
+	contains   bool
+	startswith bool
+	endswith   bool
+	all        bool
+	cased      bool
+	re         bool
+	cidr       bool
+	windash    bool
+	// cmp is the comparison operator of numeric modifiers
+	cmp string
+	// flags contains regular expression flags
+	flags string
+}
+
+// parseModifiers splits the detection key into the field name and modifiers.
+func parseModifiers(key string) (string, modifiers, error) {
+	var m modifiers
+	parts := strings.Split(key, "|")
+	for _, mod := range parts[1:] {
+		switch mod {
+		case "contains":
+			m.contains = true
+		case "startswith":
+			m.startswith = true
+		case "endswith":
+			m.endswith = true
+		case "all":
+			m.all = true
+		case "cased":
+			m.cased = true
+		case "re":
+			m.re = true
+		case "i", "m", "s":
+			m.flags += mod
+		case "cidr":
+			m.cidr = true
+		case "windash":
+			m.windash = true
+		case "gt":
+			m.cmp = ">"
+		case "gte":
+			m.cmp = ">="
+		case "lt":
+			m.cmp = "<"
+		case "lte":
+			m.cmp = "<="
+		case "base64", "base64offset", "utf16", "utf16le", "utf16be", "wide", "exists", "expand", "fieldref":
+			return "", m, fmt.Errorf("%s modifier", mod)
+		default:
+			return "", m, fmt.Errorf("unknown modifier %s", mod)
+		}
+	}
+	if m.flags != "" && !m.re {
+		return "", m, fmt.Errorf("regular expression flags without re modifier")
+	}
+	return parts[0], m, nil
+}
+
+// value is the detection value. String values are unescaped
+// and keep track of the wildcards they contain.
+type value struct {
+	// s is the unescaped value
+	s string
+	// raw is the value as it appears in the rule
+	raw string
+	// str indicates if the value is a string
+	str bool
+	// wildcard indicates if the string contains wildcards
+	wildcard bool
+	// escaped indicates if the string contains escaped wildcards
+	escaped bool
+}
+
+func newValue(n *yaml.Node) value {
+	if n.Tag != "!!str" {
+		return value{s: n.Value, raw: n.Value}
+	}
+	v := value{raw: n.Value, str: true}
+	var b strings.Builder
+	s := n.Value
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && (s[i+1] == '*' || s[i+1] == '?' || s[i+1] == '\\'):
+			i++
+			if s[i] != '\\' {
+				v.escaped = true
+			}
+			b.WriteByte(s[i])
+		case c == '*' || c == '?':
+			v.wildcard = true
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	v.s = b.String()
+	return v
+}
+
+// literal returns the rule language literal of the value.
+func (v value) literal() string {
+	if !v.str {
+		return v.s
+	}
+	return quote(v.s)
+}
+
+// quote produces the string literal escaping
+// backslashes, quotes, and newline characters.
+func quote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`).Replace(s) + "'"
+}
+
+// expandWindash adds the slash variant of the command
+// line flags that start with the hyphen.
+func expandWindash(vals []value) []value {
+	expanded := make([]value, 0, len(vals)*2)
+	for _, v := range vals {
+		expanded = append(expanded, v)
+		if !v.str || !strings.Contains(v.s, "-") {
+			continue
+		}
+		w := v
+		if strings.HasPrefix(w.s, "-") {
+			w.s = "/" + w.s[1:]
+		}
+		w.s = strings.ReplaceAll(w.s, " -", " /")
+		if w.s != v.s {
+			expanded = append(expanded, w)
+		}
+	}
+	return expanded
+}
+
+// expr builds the expression for the field and its values.
+// Multiple values are matched if any of the values matches,
+// unless the all modifier is given.
+func (m modifiers) expr(field string, vals []value) (*expr, error) {
+	op := or
+	if m.all {
+		op = and
+	}
+
+	switch {
+	case m.re:
+		flags := ""
+		if m.flags != "" {
+			flags = "(?" + m.flags + ")"
+		}
+		return m.call("regex", field, vals, func(v value) string { return quote(flags + v.raw) }), nil
+	case m.cidr:
+		return m.call("cidr_contains", field, vals, func(v value) string { return quote(v.s) }), nil
+	case m.cmp != "":
+		exprs := make([]*expr, len(vals))
+		for i, v := range vals {
+			if v.str {
+				return nil, fmt.Errorf("%s comparison with string value %s", m.cmp, v.raw)
+			}
+			exprs[i] = &expr{s: field + " " + m.cmp + " " + v.literal()}
+		}
+		return join(op, exprs), nil
+	}
+
+	var pattern, escaped, typed bool
+	for _, v := range vals {
+		pattern = pattern || v.wildcard
+		escaped = escaped || v.escaped
+		typed = typed || !v.str
+	}
+	if pattern && escaped {
+		return nil, fmt.Errorf("escaped wildcards in wildcard patterns")
+	}
+
+	var operator string
+	switch {
+	case pattern:
+		operator = "matches"
+	case m.contains:
+		operator = "contains"
+	case m.startswith:
+		operator = "startswith"
+	case m.endswith:
+		operator = "endswith"
+	case len(vals) > 1 && !m.all:
+		operator = "in"
+	default:
+		operator = "="
+	}
+
+	literals := make([]string, len(vals))
+	for i, v := range vals {
+		if operator != "=" && operator != "in" {
+			v.str = true
+		}
+		if pattern {
+			switch {
+			case m.contains:
+				v.s = "*" + v.s + "*"
+			case m.startswith:
+				v.s += "*"
+			case m.endswith:
+				v.s = "*" + v.s
+			}
+		}
+		literals[i] = v.literal()
+	}
+
+	// Sigma string matching is case-insensitive by default
+	switch {
+	case m.cased:
+	case operator == "=" && !typed:
+		operator = "~="
+	case operator == "in" && !typed:
+		operator = "iin"
+	case operator != "=" && operator != "in":
+		operator = "i" + operator
+	}
+
+	if m.all || len(literals) == 1 {
+		exprs := make([]*expr, len(literals))
+		for i, lit := range literals {
+			exprs[i] = &expr{s: field + " " + operator + " " + lit}
+		}
+		return join(op, exprs), nil
+	}
+	return &expr{s: field + " " + operator + " (" + strings.Join(literals, ", ") + ")"}, nil
+}
+
+// call builds the function call expression.
+func (m modifiers) call(fn string, field string, vals []value, arg func(value) string) *expr {
+	if m.all {
+		exprs := make([]*expr, len(vals))
+		for i, v := range vals {
+			exprs[i] = &expr{s: fn + "(" + field + ", " + arg(v) + ")"}
+		}
+		return join(and, exprs)
+	}
+	args := make([]string, len(vals))
+	for i, v := range vals {
+		args[i] = arg(v)
+	}
+	return &expr{s: fn + "(" + field + ", " + strings.Join(args, ", ") + ")"}
+}
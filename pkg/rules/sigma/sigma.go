@@ -0,0 +1,92 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package sigma converts Sigma rules into Fibratus rule definitions.
+// Sigma log sources and field names are mapped onto event names and
+// filter fields, value modifiers are translated into rule language
+// operators and functions, and the detection condition is rewritten
+// as the filter expression.
+package sigma
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rule represents the Sigma rule definition.
+type Rule struct {
+	Title          string    `yaml:"title"`
+	ID             string    `yaml:"id"`
+	Status         string    `yaml:"status"`
+	Description    string    `yaml:"description"`
+	References     []string  `yaml:"references"`
+	Author         string    `yaml:"author"`
+	Date           string    `yaml:"date"`
+	Modified       string    `yaml:"modified"`
+	Tags           []string  `yaml:"tags"`
+	LogSource      LogSource `yaml:"logsource"`
+	Detection      yaml.Node `yaml:"detection"`
+	FalsePositives []string  `yaml:"falsepositives"`
+	Level          string    `yaml:"level"`
+}
+
+// LogSource describes the data the Sigma rule is applied to.
+type LogSource struct {
+	Category string `yaml:"category"`
+	Product  string `yaml:"product"`
+	Service  string `yaml:"service"`
+}
+
+// String returns the log source representation.
+func (l LogSource) String() string {
+	s := make([]string, 0, 3)
+	for _, v := range []string{l.Product, l.Category, l.Service} {
+		if v != "" {
+			s = append(s, v)
+		}
+	}
+	return strings.Join(s, "/")
+}
+
+// Parse decodes the Sigma rule from the YAML document.
+func Parse(b []byte) (*Rule, error) {
+	var r Rule
+	if err := yaml.Unmarshal(b, &r); err != nil {
+		return nil, fmt.Errorf("invalid Sigma rule: %v", err)
+	}
+	if r.Title == "" {
+		return nil, fmt.Errorf("invalid Sigma rule: missing title")
+	}
+	if r.Detection.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("invalid Sigma rule %q: missing detection", r.Title)
+	}
+	return &r, nil
+}
+
+// UnsupportedError is returned when the Sigma rule contains
+// constructs that can't be expressed as the Fibratus rule.
+type UnsupportedError struct {
+	Rule       string
+	Constructs []string
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("rule %q contains unsupported constructs:\n  %s", e.Rule, strings.Join(e.Constructs, "\n  "))
+}
@@ -0,0 +1,161 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sigma
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func parseFixture(t *testing.T, name string) *Rule {
+	b, err := os.ReadFile("_fixtures/" + name)
+	require.NoError(t, err)
+	r, err := Parse(b)
+	require.NoError(t, err)
+	return r
+}
+
+func TestConvert(t *testing.T) {
+	f, warnings, err := Convert(parseFixture(t, "proc_creation_whoami.yml"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "8f3b2c1a-9d4e-4f6a-b7c8-1e2d3f4a5b6c", f.ID)
+	assert.Equal(t, "Whoami Execution From Office Application", f.Name)
+	assert.Equal(t, "evt.name = 'CreateProcess' and\n"+
+		"(ps.exe iendswith '\\\\whoami.exe' or ps.pe.file.name ~= 'whoami.exe') and\n"+
+		"ps.parent.exe iendswith ('\\\\winword.exe', '\\\\excel.exe') and\n"+
+		"ps.cmdline icontains '/user' and\nps.cmdline icontains '/priv' and\n"+
+		"not (ps.cmdline imatches '*C:\\\\Program Files\\\\*\\\\agent*')", f.Condition)
+	assert.Equal(t, "high", f.Severity)
+	assert.Equal(t, []string{"Jane Doe", "John Smith"}, f.Authors)
+	assert.Equal(t, []string{"car.2016-03-001"}, f.Tags)
+	assert.Equal(t, "False positives:\n- Administrative scripts\n", f.Notes)
+	assert.Equal(t, map[string]string{
+		"tactic.id":     "TA0007",
+		"tactic.name":   "Discovery",
+		"tactic.ref":    "https://attack.mitre.org/tactics/TA0007/",
+		"technique.id":  "T1033",
+		"technique.ref": "https://attack.mitre.org/techniques/T1033/",
+	}, f.Labels)
+	assert.Contains(t, warnings, "technique.name label for T1033 must be filled in manually")
+}
+
+func TestConvertNetworkConnection(t *testing.T) {
+	f, warnings, err := Convert(parseFixture(t, "network_connection.yml"))
+	require.NoError(t, err)
+
+	assert.Len(t, f.ID, 36)
+	assert.NotEqual(t, "not-a-uuid", f.ID)
+	assert.Equal(t, "evt.name in ('Connect', 'Accept') and\n"+
+		"evt.name ~= 'Connect' and\nnet.dport in (139, 445) and\n"+
+		"not (cidr_contains(net.dip, '10.0.0.0/8', '192.168.0.0/16') or "+
+		"regex(ps.exe, '(?i)^C:\\\\\\\\Windows\\\\\\\\System32\\\\\\\\.*'))", f.Condition)
+	assert.Equal(t, "low", f.Severity)
+	assert.Equal(t, "T1021.002", f.Labels["subtechnique.id"])
+	assert.Equal(t, "https://attack.mitre.org/techniques/T1021/002/", f.Labels["subtechnique.ref"])
+	assert.Equal(t, "TA0008", f.Labels["tactic.id"])
+	assert.Equal(t, []string{"attack.exfiltration"}, f.Tags)
+	assert.Contains(t, warnings, "tactic Exfiltration is kept as tag")
+	assert.Contains(t, warnings, "informational level is mapped to low severity")
+}
+
+func TestConvertUnsupported(t *testing.T) {
+	_, _, err := Convert(parseFixture(t, "unsupported.yml"))
+	require.Error(t, err)
+	var e *UnsupportedError
+	require.ErrorAs(t, err, &e)
+	assert.Equal(t, []string{
+		"CommandLine|base64offset|contains: base64offset modifier",
+		"field Hashes in windows/process_creation log source",
+		"keyword search in selection keywords",
+		"condition \"selection or keywords | count() > 5\": aggregation expressions",
+	}, e.Constructs)
+
+	_, _, err = Convert(parseFixture(t, "linux.yml"))
+	require.ErrorAs(t, err, &e)
+	assert.Equal(t, []string{"log source linux/process_creation: only the windows product is supported"}, e.Constructs)
+}
+
+func TestParseCondition(t *testing.T) {
+	var detection yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte(`
+sel1:
+  Image: a.exe
+sel2:
+  Image: b.exe
+sel3:
+  CommandLine|startswith: c
+_sel4:
+  CommandLine|endswith: d
+`), &detection))
+
+	c := &converter{
+		rule:      &Rule{Title: "test", Detection: *detection.Content[0]},
+		ls:        logsources["process_creation"],
+		detection: make(map[string]*yaml.Node),
+		exprs:     make(map[string]*expr),
+	}
+	c.readDetection()
+	for _, name := range c.selections {
+		c.exprs[name] = c.selection(name)
+	}
+
+	var tests = []struct {
+		cond string
+		expr string
+	}{
+		{"sel1 or sel2 and not sel3", "ps.exe ~= 'a.exe' or ps.exe ~= 'b.exe' and not (ps.cmdline istartswith 'c')"},
+		{"(sel1 or sel2) and sel3", "(ps.exe ~= 'a.exe' or ps.exe ~= 'b.exe') and ps.cmdline istartswith 'c'"},
+		{"1 of them", "ps.exe ~= 'a.exe' or ps.exe ~= 'b.exe' or ps.cmdline istartswith 'c'"},
+		{"all of sel* and not _sel4", "ps.exe ~= 'a.exe' and ps.exe ~= 'b.exe' and ps.cmdline istartswith 'c' and not (ps.cmdline iendswith 'd')"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cond, func(t *testing.T) {
+			e, err := c.parseCondition(tt.cond)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expr, e.render(or))
+		})
+	}
+
+	_, err := c.parseCondition("sel1 and (sel2")
+	require.Error(t, err)
+	_, err = c.parseCondition("sel5")
+	require.Error(t, err)
+}
+
+func TestMarshal(t *testing.T) {
+	f, _, err := Convert(parseFixture(t, "proc_creation_whoami.yml"))
+	require.NoError(t, err)
+	b, err := Marshal(f)
+	require.NoError(t, err)
+
+	var out map[string]any
+	require.NoError(t, yaml.Unmarshal(b, &out))
+	assert.Equal(t, f.Name, out["name"])
+	// the folded condition joins lines with spaces
+	assert.Equal(t, strings.ReplaceAll(f.Condition, "\n", " ")+"\n", out["condition"])
+	assert.Equal(t, "TA0007", out["labels"].(map[string]any)["tactic.id"])
+	assert.Equal(t, minEngineVersion, out["min-engine-version"])
+}
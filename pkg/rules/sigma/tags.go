@@ -0,0 +1,132 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sigma
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// tactic describes the MITRE ATT&CK tactic.
+type tactic struct {
+	id   string
+	name string
+}
+
+// tactics maps Sigma tactic tags to MITRE ATT&CK tactics.
+var tactics = map[string]tactic{
+	"reconnaissance":       {"TA0043", "Reconnaissance"},
+	"resource_development": {"TA0042", "Resource Development"},
+	"initial_access":       {"TA0001", "Initial Access"},
+	"execution":            {"TA0002", "Execution"},
+	"persistence":          {"TA0003", "Persistence"},
+	"privilege_escalation": {"TA0004", "Privilege Escalation"},
+	"defense_evasion":      {"TA0005", "Defense Evasion"},
+	"credential_access":    {"TA0006", "Credential Access"},
+	"discovery":            {"TA0007", "Discovery"},
+	"lateral_movement":     {"TA0008", "Lateral Movement"},
+	"collection":           {"TA0009", "Collection"},
+	"exfiltration":         {"TA0010", "Exfiltration"},
+	"command_and_control":  {"TA0011", "Command and Control"},
+	"impact":               {"TA0040", "Impact"},
+}
+
+var (
+	tacticIDRegexp    = regexp.MustCompile(`^ta[0-9]{4}$`)
+	techniqueIDRegexp = regexp.MustCompile(`^t[0-9]{4}(\.[0-9]{3})?$`)
+)
+
+// tacticByID finds the tactic by its identifier.
+func tacticByID(id string) (tactic, bool) {
+	for _, t := range tactics {
+		if strings.EqualFold(t.id, id) {
+			return t, true
+		}
+	}
+	return tactic{}, false
+}
+
+// mapTags translates Sigma ATT&CK tags to tactic and technique
+// labels. Only the first tactic and technique are translated to
+// labels, as labels are single-valued. Remaining tags are kept
+// as rule tags.
+func (c *converter) mapTags() (map[string]string, []string) {
+	labels := make(map[string]string)
+	tags := make([]string, 0)
+
+	for _, tag := range c.rule.Tags {
+		name, ok := strings.CutPrefix(strings.ToLower(tag), "attack.")
+		if !ok {
+			tags = append(tags, tag)
+			continue
+		}
+
+		t, isTactic := tactics[name]
+		if !isTactic && tacticIDRegexp.MatchString(name) {
+			t, isTactic = tacticByID(name)
+		}
+
+		switch {
+		case isTactic:
+			if labels["tactic.id"] != "" {
+				if labels["tactic.id"] != t.id {
+					c.warn("tactic %s is kept as tag", t.name)
+					tags = append(tags, tag)
+				}
+				continue
+			}
+			labels["tactic.id"] = t.id
+			labels["tactic.name"] = t.name
+			labels["tactic.ref"] = fmt.Sprintf("https://attack.mitre.org/tactics/%s/", t.id)
+		case techniqueIDRegexp.MatchString(name):
+			id, sub, _ := strings.Cut(strings.ToUpper(name), ".")
+			switch labels["technique.id"] {
+			case "":
+				labels["technique.id"] = id
+				labels["technique.ref"] = fmt.Sprintf("https://attack.mitre.org/techniques/%s/", id)
+				c.warn("technique.name label for %s must be filled in manually", id)
+			case id:
+			default:
+				c.warn("technique %s is kept as tag", strings.ToUpper(name))
+				tags = append(tags, tag)
+				continue
+			}
+			switch {
+			case sub == "" || labels["subtechnique.id"] == id+"."+sub:
+			case labels["subtechnique.id"] == "":
+				labels["subtechnique.id"] = id + "." + sub
+				labels["subtechnique.ref"] = fmt.Sprintf("https://attack.mitre.org/techniques/%s/%s/", id, sub)
+			default:
+				c.warn("sub-technique %s is kept as tag", strings.ToUpper(name))
+				tags = append(tags, tag)
+			}
+		default:
+			tags = append(tags, tag)
+		}
+	}
+
+	if len(labels) == 0 {
+		labels = nil
+	}
+	if len(tags) == 0 {
+		tags = nil
+	}
+	return labels, tags
+}
@@ -0,0 +1,227 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rules
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	capver "github.com/rabbitstack/fibratus/pkg/cap/version"
+	"github.com/rabbitstack/fibratus/pkg/event"
+)
+
+// spillEntry describes the location of the spilled
+// partial in the backing file. Sequence links and the
+// out-of-order marker are kept apart from the raw event
+// bytes since metadata values are stringified when the
+// event is serialized.
+type spillEntry struct {
+	off   int64
+	n     int
+	ts    time.Time
+	links []any
+	isOOO bool
+}
+
+// spillStore keeps sequence partials evicted under
+// memory pressure in a temporary file. Spilled partials
+// are faulted back into the sequence state when the
+// downstream expression is about to match.
+type spillStore struct {
+	mu    sync.Mutex
+	f     *os.File
+	off   int64
+	slots map[int][]spillEntry
+}
+
+func newSpillStore(dir string) (*spillStore, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(dir, "seq-*.spill")
+	if err != nil {
+		return nil, err
+	}
+	return &spillStore{f: f, slots: make(map[int][]spillEntry)}, nil
+}
+
+// put writes the partial to the backing file.
+func (s *spillStore) put(seqID int, e *event.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := e.MarshalRaw()
+	if _, err := s.f.WriteAt(b, s.off); err != nil {
+		return err
+	}
+	s.slots[seqID] = append(s.slots[seqID], spillEntry{
+		off:   s.off,
+		n:     len(b),
+		ts:    e.Timestamp,
+		links: e.SequenceLinks(),
+		isOOO: e.ContainsMeta(event.RuleSequenceOOOKey),
+	})
+	s.off += int64(len(b))
+	return nil
+}
+
+// hasUpstream determines if there are spilled partials
+// in any of the slots preceding the given sequence slot.
+func (s *spillStore) hasUpstream(seqID int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for n := range seqID {
+		if len(s.slots[n]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// load reads back and discards all spilled partials of the
+// given slot. The backing file is truncated once the store
+// is left without spilled partials.
+func (s *spillStore) load(seqID int) ([]*event.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := s.slots[seqID]
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	delete(s.slots, seqID)
+	defer s.truncateIfEmpty()
+	return s.read(entries)
+}
+
+// peek reads back all spilled partials without
+// discarding them from the spill store.
+func (s *spillStore) peek() (map[int][]*event.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	partials := make(map[int][]*event.Event)
+	for seqID, entries := range s.slots {
+		evts, err := s.read(entries)
+		if err != nil {
+			return nil, err
+		}
+		partials[seqID] = evts
+	}
+	return partials, nil
+}
+
+func (s *spillStore) read(entries []spillEntry) ([]*event.Event, error) {
+	evts := make([]*event.Event, 0, len(entries))
+	for _, ent := range entries {
+		buf := make([]byte, ent.n)
+		if _, err := s.f.ReadAt(buf, ent.off); err != nil {
+			return evts, err
+		}
+		e, err := decodePartial(buf, ent.links, ent.isOOO)
+		if err != nil {
+			return evts, err
+		}
+		evts = append(evts, e)
+	}
+	return evts, nil
+}
+
+// gc discards spilled partials older than the
+// given lifetime and returns the number of
+// discarded partials.
+func (s *spillStore) gc(lifetime time.Duration) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int
+	for seqID, entries := range s.slots {
+		for i := len(entries) - 1; i >= 0; i-- {
+			if time.Since(entries[i].ts) > lifetime {
+				entries = append(entries[:i], entries[i+1:]...)
+				n++
+			}
+		}
+		if len(entries) == 0 {
+			delete(s.slots, seqID)
+		} else {
+			s.slots[seqID] = entries
+		}
+	}
+	if n > 0 {
+		s.truncateIfEmpty()
+	}
+	return n
+}
+
+// size returns the number of spilled partials.
+func (s *spillStore) size() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int
+	for _, entries := range s.slots {
+		n += len(entries)
+	}
+	return n
+}
+
+// reset discards all spilled partials.
+func (s *spillStore) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.slots = make(map[int][]spillEntry)
+	s.truncateIfEmpty()
+}
+
+// close closes and removes the backing file.
+func (s *spillStore) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.slots = make(map[int][]spillEntry)
+	if err := s.f.Close(); err != nil {
+		return err
+	}
+	return os.Remove(s.f.Name())
+}
+
+func (s *spillStore) truncateIfEmpty() {
+	if len(s.slots) > 0 || s.off == 0 {
+		return
+	}
+	if err := s.f.Truncate(0); err == nil {
+		s.off = 0
+	}
+}
+
+// decodePartial restores the partial from the raw event bytes. Since
+// metadata values are stringified when the event is serialized, sequence
+// links and the out-of-order marker are reattached with their original
+// values.
+func decodePartial(buf []byte, links []any, isOOO bool) (*event.Event, error) {
+	e, err := event.NewFromCapture(buf, capver.EvtSecV2)
+	if err != nil {
+		return nil, err
+	}
+	e.RemoveMeta(event.RuleSequenceLinks)
+	e.RemoveMeta(event.RuleSequenceOOOKey)
+	for _, link := range links {
+		e.AddSequenceLink(link)
+	}
+	if isOOO {
+		e.AddMeta(event.RuleSequenceOOOKey, true)
+	}
+	return e, nil
+}
@@ -0,0 +1,397 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rules
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/rabbitstack/fibratus/pkg/config"
+	"github.com/rabbitstack/fibratus/pkg/event"
+	"github.com/rabbitstack/fibratus/pkg/event/params"
+	"github.com/rabbitstack/fibratus/pkg/filter"
+	"github.com/rabbitstack/fibratus/pkg/ps"
+	pstypes "github.com/rabbitstack/fibratus/pkg/ps/types"
+	"github.com/stretchr/testify/mock"
+)
+
+// testParamTypes maps the parameter name to the parameter
+// type for synthetic event parameters with numeric values.
+// Numeric parameters absent from this map are stored as
+// uint32 values.
+var testParamTypes = map[string]params.Type{
+	params.NetDport:               params.Port,
+	params.NetSport:               params.Port,
+	params.BasePrio:               params.Uint8,
+	params.IOPrio:                 params.Uint8,
+	params.PagePrio:               params.Uint8,
+	params.ProcessObject:          params.Uint64,
+	params.DTB:                    params.Uint64,
+	params.CallstackTimestamp:     params.Uint64,
+	params.KstackBase:             params.Uint64,
+	params.KstackLimit:            params.Uint64,
+	params.UstackBase:             params.Uint64,
+	params.UstackLimit:            params.Uint64,
+	params.StartAddress:           params.Address,
+	params.TEB:                    params.Uint64,
+	params.FileObject:             params.Uint64,
+	params.FileKey:                params.Uint64,
+	params.FileOffset:             params.Uint64,
+	params.FileExtraInfo:          params.Uint64,
+	params.FileIrpPtr:             params.Uint64,
+	params.FileViewBase:           params.Uint64,
+	params.FileViewSize:           params.Uint64,
+	params.RegKeyHandle:           params.Uint64,
+	params.RegKCB:                 params.Uint64,
+	params.ModuleBase:             params.Uint64,
+	params.ModuleSize:             params.Uint64,
+	params.ModuleDefaultBase:      params.Uint64,
+	params.HandleObject:           params.Uint64,
+	params.MemRegionSize:          params.Uint64,
+	params.ThreadpoolTimerDuetime: params.Uint64,
+	params.NTStatus:               params.Status,
+	params.ExitStatus:             params.Status,
+	params.FileOperation:          params.Enum,
+	params.FileInfoClass:          params.Enum,
+	params.FileType:               params.Enum,
+	params.FileViewSectionType:    params.Enum,
+	params.NetL4Proto:             params.Enum,
+	params.RegValueType:           params.Enum,
+	params.DNSRR:                  params.Enum,
+	params.DNSRcode:               params.Enum,
+	params.FileCreateOptions:      params.Flags,
+	params.FileAttributes:         params.Flags,
+	params.FileShareMask:          params.Flags,
+	params.MemAllocType:           params.Flags,
+	params.MemProtect:             params.Flags,
+	params.DesiredAccess:          params.Flags,
+	params.DNSOpts:                params.Flags,
+}
+
+// testTimeParams contains the names of the parameters storing timestamps.
+var testTimeParams = map[string]bool{
+	params.StartTime:           true,
+	params.FileCreated:         true,
+	params.FileAccessed:        true,
+	params.FileModified:        true,
+	params.ModuleCertNotBefore: true,
+	params.ModuleCertNotAfter:  true,
+}
+
+// TestResult contains the outcome of the rule test case.
+type TestResult struct {
+	// Rule is the name of the tested rule.
+	Rule string
+	// Test is the name of the test case.
+	Test string
+	// Expected indicates if the rule is expected to match.
+	Expected bool
+	// Matched indicates if the rule matched.
+	Matched bool
+	// Err is the error that prevented running the test case.
+	Err error
+	// Fields contains, for each test event, the values of
+	// the fields referenced in the rule condition. Field
+	// values help pinpoint the reason of the failed test.
+	Fields []map[string]string
+}
+
+// Passed determines if the test case succeeded.
+func (r *TestResult) Passed() bool { return r.Err == nil && r.Expected == r.Matched }
+
+// RunTests runs the test cases declared in the rule definitions.
+// Each test case runs in the isolated engine that only contains
+// the rule under test, so the state of sequences or thresholds
+// never leaks across test cases. Alerts and rule actions are
+// never triggered while running the tests.
+func RunTests(cfg *config.Config) ([]*TestResult, error) {
+	if err := cfg.Filters.LoadMacros(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Filters.LoadFilters(); err != nil {
+		return nil, err
+	}
+
+	c := allAccessorsConfig(cfg)
+
+	results := make([]*TestResult, 0)
+	for _, f := range cfg.GetFilters() {
+		if f.IsDisabled() || !f.HasTests() {
+			continue
+		}
+		for _, t := range f.Tests {
+			results = append(results, runTest(c, f, t))
+		}
+	}
+
+	return results, nil
+}
+
+// allAccessorsConfig returns the config with the rule
+// filters of the given config that enables all accessors
+// regardless of the event source config.
+func allAccessorsConfig(cfg *config.Config) *config.Config {
+	// the sequence state and the frequency store of
+	// the running agent must not be restored or overwritten
+	filters := *cfg.Filters
+	filters.Sequences.StateFile = ""
+	filters.Rarity.StateFile = ""
+	// rules are tested against a fresh frequency store,
+	// so the learning period would suppress all matches
+	filters.Rarity.LearningPeriod = 0
+	return &config.Config{
+		EventSource: config.EventSourceConfig{
+			EnableThreadEvents:     true,
+			EnableRegistryEvents:   true,
+			EnableNetEvents:        true,
+			EnableFileIOEvents:     true,
+			EnableModuleEvents:     true,
+			EnableHandleEvents:     true,
+			EnableMemEvents:        true,
+			EnableDNSEvents:        true,
+			EnableThreadpoolEvents: true,
+		},
+		Filters: &filters,
+	}
+}
+
+// runTest feeds the test case events to the engine
+// with the single rule and captures the rule match.
+func runTest(cfg *config.Config, f *config.FilterConfig, t config.RuleTest) *TestResult {
+	r := &TestResult{Rule: f.Name, Test: t.Name, Expected: t.Match}
+
+	evts, err := newTestEvents(t.Events)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+
+	e := NewEngine(newTestSnapshotter(evts), cfg)
+	defer e.Close()
+	e.scavenger.Stop()
+	e.compiler.filters = []*config.FilterConfig{f}
+	e.dryRun = true
+	e.RegisterMatchFunc(func(*config.FilterConfig, ...*event.Event) { r.Matched = true })
+
+	if _, err := e.Compile(); err != nil {
+		r.Err = err
+		return r
+	}
+
+	for _, evt := range evts {
+		if _, err := e.ProcessEvent(evt); err != nil {
+			r.Err = err
+			return r
+		}
+	}
+
+	if !r.Passed() {
+		r.Fields = testFieldValues(e, f, evts)
+	}
+
+	return r
+}
+
+// testFieldValues extracts the values of the fields
+// referenced in the rule condition from every event.
+func testFieldValues(e *Engine, f *config.FilterConfig, evts []*event.Event) []map[string]string {
+	fltr, ok := e.rules[f.ID]
+	if !ok {
+		return nil
+	}
+	values := make([]map[string]string, len(evts))
+	for i, evt := range evts {
+		values[i] = make(map[string]string)
+		for _, field := range fltr.filter.GetFields() {
+			name := field.String()
+			values[i][name] = filter.InterpolateFields("%"+name, []*event.Event{evt})
+		}
+	}
+	return values
+}
+
+// newTestSnapshotter returns the snapshotter that resolves
+// processes attached to the test events. Any other lookup
+// yields an empty result.
+func newTestSnapshotter(evts []*event.Event) ps.Snapshotter {
+	psnap := new(ps.SnapshotterMock)
+	for _, evt := range evts {
+		for proc := evt.PS; proc != nil; proc = proc.Parent {
+			psnap.On("Find", proc.PID).Return(true, proc)
+		}
+	}
+	psnap.On("Find", mock.Anything).Return(false, (*pstypes.PS)(nil))
+	psnap.On("FindModule", mock.Anything).Return(false, nil)
+	psnap.On("FindAndPut", mock.Anything).Return((*pstypes.PS)(nil))
+	return psnap
+}
+
+// newTestEvents builds events from the test case event
+// definitions. Event timestamps increase monotonically
+// and are moved forward by the optional event delay.
+func newTestEvents(defs []config.TestEvent) ([]*event.Event, error) {
+	evts := make([]*event.Event, len(defs))
+	ts := time.Now()
+
+	for i, def := range defs {
+		if !event.IsKnown(def.Name) {
+			return nil, fmt.Errorf("event #%d: unknown event name %q", i+1, def.Name)
+		}
+
+		ts = ts.Add(def.Delay + time.Millisecond)
+
+		evt := &event.Event{
+			Seq:       uint64(i + 1),
+			Timestamp: ts,
+			PID:       def.PID,
+			Tid:       def.TID,
+			Type:      event.NameToType(def.Name),
+			Name:      def.Name,
+			Params:    make(event.Params),
+			Metadata:  make(map[event.MetadataKey]any),
+		}
+		if def.PS != nil {
+			evt.PS = newTestProcess(def.PS)
+			if evt.PID == 0 {
+				evt.PID = evt.PS.PID
+			}
+			if evt.PS.PID == 0 {
+				evt.PS.PID = evt.PID
+			}
+		}
+
+		for name, value := range def.Params {
+			par, err := newTestParam(name, value, evt.Type)
+			if err != nil {
+				return nil, fmt.Errorf("event #%d: %v", i+1, err)
+			}
+			evt.Params[name] = par
+		}
+
+		// network events have distinct types
+		// for IPv4 and IPv6 address families
+		if typs := event.NameToTypes(def.Name); len(typs) > 1 {
+			evt.Type = typs[0]
+			if ip, err := evt.Params.GetIP(params.NetDIP); err == nil && ip.To4() == nil {
+				evt.Type = typs[1]
+			}
+		}
+		evt.Category = evt.Type.Category()
+
+		evts[i] = evt
+	}
+
+	return evts, nil
+}
+
+func newTestProcess(p *config.TestProcess) *pstypes.PS {
+	proc := &pstypes.PS{
+		PID:                 p.PID,
+		Ppid:                p.Ppid,
+		Name:                p.Name,
+		Exe:                 p.Exe,
+		Cmdline:             p.Cmdline,
+		Cwd:                 p.Cwd,
+		SID:                 p.SID,
+		Username:            p.Username,
+		Domain:              p.Domain,
+		Args:                p.Args,
+		SessionID:           p.SessionID,
+		Envs:                p.Envs,
+		IsWOW64:             p.IsWOW64,
+		IsPackaged:          p.IsPackaged,
+		IsProtected:         p.IsProtected,
+		TokenIntegrityLevel: p.TokenIntegrityLevel,
+		TokenElevationType:  p.TokenElevationType,
+		IsTokenElevated:     p.IsTokenElevated,
+		StartTime:           time.Now(),
+	}
+	if p.Parent != nil {
+		proc.Parent = newTestProcess(p.Parent)
+		if proc.Ppid == 0 {
+			proc.Ppid = proc.Parent.PID
+		}
+	}
+	return proc
+}
+
+// newTestParam builds the event parameter from the test
+// event definition. The parameter type is derived from
+// the parameter name and the type of the value.
+func newTestParam(name string, value any, etype event.Type) (*event.Param, error) {
+	switch v := value.(type) {
+	case string:
+		switch {
+		case name == params.NetDIP || name == params.NetSIP:
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return nil, fmt.Errorf("%s parameter has invalid IP address %q", name, v)
+			}
+			if ip.To4() != nil {
+				return event.NewParamFromCapture(name, params.IPv4, ip.To4(), etype), nil
+			}
+			return event.NewParamFromCapture(name, params.IPv6, ip, etype), nil
+		case testTimeParams[name]:
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return nil, fmt.Errorf("%s parameter has invalid timestamp %q: %v", name, v, err)
+			}
+			return event.NewParamFromCapture(name, params.Time, t, etype), nil
+		default:
+			return event.NewParamFromCapture(name, params.UnicodeString, v, etype), nil
+		}
+	case time.Time:
+		return event.NewParamFromCapture(name, params.Time, v, etype), nil
+	case bool:
+		return event.NewParamFromCapture(name, params.Bool, v, etype), nil
+	case int:
+		if v < 0 {
+			return nil, fmt.Errorf("%s parameter has negative value %d", name, v)
+		}
+		return newTestNumericParam(name, uint64(v), etype), nil
+	case uint64:
+		return newTestNumericParam(name, v, etype), nil
+	case []any:
+		s := make([]string, len(v))
+		for i, e := range v {
+			s[i] = fmt.Sprintf("%v", e)
+		}
+		return event.NewParamFromCapture(name, params.Slice, s, etype), nil
+	default:
+		return nil, fmt.Errorf("%s parameter has unsupported value %v", name, value)
+	}
+}
+
+func newTestNumericParam(name string, v uint64, etype event.Type) *event.Param {
+	typ, ok := testParamTypes[name]
+	if !ok {
+		typ = params.Uint32
+	}
+	switch typ {
+	case params.Port:
+		return event.NewParamFromCapture(name, typ, uint16(v), etype)
+	case params.Uint8:
+		return event.NewParamFromCapture(name, typ, uint8(v), etype)
+	case params.Uint64, params.Address:
+		return event.NewParamFromCapture(name, typ, v, etype)
+	default:
+		return event.NewParamFromCapture(name, typ, uint32(v), etype)
+	}
+}
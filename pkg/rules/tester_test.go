@@ -0,0 +1,152 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rules
+
+import (
+	"net"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/rabbitstack/fibratus/pkg/config"
+	"github.com/rabbitstack/fibratus/pkg/event"
+	"github.com/rabbitstack/fibratus/pkg/event/params"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunTests(t *testing.T) {
+	results, err := RunTests(newConfig("_fixtures/tests/*.yml"))
+	require.NoError(t, err)
+	require.Len(t, results, 6)
+
+	outcomes := make(map[string]*TestResult)
+	for _, r := range results {
+		outcomes[r.Test] = r
+	}
+
+	var tests = []struct {
+		test    string
+		passed  bool
+		matched bool
+	}{
+		{"cmd connects to remote host", true, true},
+		{"powershell connects to remote host", true, false},
+		{"cmd connects to another port", false, false},
+		{"three deletions within the window", true, true},
+		{"deletions outside the window", true, false},
+		{"value set in run key", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.test, func(t *testing.T) {
+			r := outcomes[tt.test]
+			require.NotNil(t, r)
+			assert.Equal(t, tt.passed, r.Passed())
+			assert.Equal(t, tt.matched, r.Matched)
+		})
+	}
+
+	r := outcomes["cmd connects to another port"]
+	require.NoError(t, r.Err)
+	require.Len(t, r.Fields, 1)
+	assert.Equal(t, "80", r.Fields[0]["net.dport"])
+	assert.Equal(t, "cmd.exe", r.Fields[0]["ps.name"])
+
+	assert.ErrorContains(t, outcomes["value set in run key"].Err, `unknown event name "RegSetValueEx"`)
+}
+
+func TestRunTestsClosesEngines(t *testing.T) {
+	n := runtime.NumGoroutine()
+	for i := 0; i < 5; i++ {
+		_, err := RunTests(newConfig("_fixtures/tests/*.yml"))
+		require.NoError(t, err)
+	}
+	// the engine of each rule test stops its background tasks
+	require.Eventually(t, func() bool {
+		return runtime.NumGoroutine() <= n
+	}, time.Second*5, time.Millisecond*50)
+}
+
+func TestAllAccessorsConfig(t *testing.T) {
+	cfg := newConfig("_fixtures/tests/*.yml")
+	cfg.Filters.Sequences.StateFile = "sequences.state"
+	cfg.Filters.Rarity.StateFile = "rarity.state"
+	cfg.Filters.Rarity.LearningPeriod = time.Hour * 24
+
+	c := allAccessorsConfig(cfg)
+	assert.Empty(t, c.Filters.Sequences.StateFile)
+	assert.Empty(t, c.Filters.Rarity.StateFile)
+	assert.Zero(t, c.Filters.Rarity.LearningPeriod)
+
+	// the agent config is left intact
+	assert.Equal(t, "rarity.state", cfg.Filters.Rarity.StateFile)
+	assert.Equal(t, time.Hour*24, cfg.Filters.Rarity.LearningPeriod)
+}
+
+func TestNewTestEvents(t *testing.T) {
+	evts, err := newTestEvents([]config.TestEvent{
+		{
+			Name: "Connect",
+			Params: map[string]any{
+				params.NetDIP:     "fe80::1",
+				params.NetDport:   443,
+				params.NetSize:    1024,
+				params.FileObject: uint64(18446738026482168384),
+				params.StartTime:  "2024-03-01T10:00:00Z",
+			},
+			PS: &config.TestProcess{PID: 1234, Name: "cmd.exe", Parent: &config.TestProcess{PID: 4, Name: "System"}},
+		},
+		{
+			Name:  "CreateProcess",
+			PID:   4,
+			Delay: time.Minute,
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, evts, 2)
+
+	evt := evts[0]
+	assert.Equal(t, event.ConnectTCPv6, evt.Type)
+	assert.Equal(t, event.Net, evt.Category)
+	assert.Equal(t, uint32(1234), evt.PID)
+	assert.Equal(t, uint32(4), evt.PS.Ppid)
+
+	dip, err := evt.Params.GetIP(params.NetDIP)
+	require.NoError(t, err)
+	assert.Equal(t, net.ParseIP("fe80::1"), dip)
+	dport, err := evt.Params.GetUint16(params.NetDport)
+	require.NoError(t, err)
+	assert.Equal(t, uint16(443), dport)
+	size, err := evt.Params.GetUint32(params.NetSize)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(1024), size)
+	fobj, err := evt.Params.GetUint64(params.FileObject)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(18446738026482168384), fobj)
+	_, err = evt.Params.GetTime(params.StartTime)
+	require.NoError(t, err)
+
+	assert.Equal(t, event.CreateProcess, evts[1].Type)
+	assert.Nil(t, evts[1].PS)
+	assert.True(t, evts[1].Timestamp.Sub(evt.Timestamp) > time.Minute)
+
+	_, err = newTestEvents([]config.TestEvent{{Name: "Connect", Params: map[string]any{params.NetDport: -1}}})
+	require.Error(t, err)
+}
@@ -0,0 +1,179 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rules
+
+import (
+	"expvar"
+	"sync"
+	"time"
+
+	"github.com/rabbitstack/fibratus/pkg/config"
+	"github.com/rabbitstack/fibratus/pkg/event"
+	"github.com/rabbitstack/fibratus/pkg/filter"
+	"github.com/rabbitstack/fibratus/pkg/filter/ql"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// maxThresholdGroups determines the maximum number of groups per threshold rule
+	maxThresholdGroups = 10000
+)
+
+var (
+	thresholdGroups      = expvar.NewMap("threshold.groups.count")
+	thresholdEvictions   = expvar.NewMap("threshold.group.evictions")
+	thresholdBreaches    = expvar.NewMap("threshold.group.breaches")
+	thresholdWindowFires = expvar.NewMap("threshold.window.fires")
+)
+
+// thresholdState keeps the sliding time windows of
+// the threshold rule. Every distinct group, as given by
+// the values of the group-by fields, maintains its own
+// window with events that satisfied the threshold
+// expression. Once the number of events in the window
+// reaches the threshold count, the rule fires and the
+// window of the group is reset.
+type thresholdState struct {
+	filter    filter.Filter
+	threshold *ql.Threshold
+	name      string
+
+	// groups contains the events inside the
+	// sliding window indexed by the group key
+	groups map[string][]*event.Event
+	// matches stores the events of the window
+	// that reached the threshold count. These
+	// events are propagated in the rule action
+	// context
+	matches []*event.Event
+	// mu guards the groups map and matches slice
+	mu sync.Mutex
+
+	isGroupsBreached bool
+}
+
+func newThresholdState(f filter.Filter, c *config.FilterConfig) *thresholdState {
+	return &thresholdState{
+		filter:    f,
+		threshold: f.GetThreshold(),
+		name:      c.Name,
+		groups:    make(map[string][]*event.Event),
+		matches:   make([]*event.Event, 0),
+	}
+}
+
+// evalThreshold evaluates the event against the threshold
+// expression. If the expression matches, the event is pushed
+// to the sliding window of its group, and all events that fell
+// outside the window are dropped. The method returns true when
+// the number of events in the group window reaches the threshold
+// count.
+func (t *thresholdState) evalThreshold(e *event.Event, valuer *filter.ValuerCache) bool {
+	match, key := t.filter.EvalThreshold(e, valuer)
+	if !match {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	window, ok := t.groups[key]
+	if !ok && len(t.groups) >= maxThresholdGroups {
+		thresholdBreaches.Add(t.name, 1)
+		if !t.isGroupsBreached {
+			log.Warnf("max groups encountered in threshold %s. "+
+				"Dropping incoming event: %s", t.name, e)
+		}
+		t.isGroupsBreached = true
+		return false
+	}
+	if !ok {
+		thresholdGroups.Add(t.name, 1)
+	}
+
+	// slide the window by discarding events
+	// that are older than the time window
+	window = append(window, e)
+	n := 0
+	for _, evt := range window {
+		if e.Timestamp.Sub(evt.Timestamp) <= t.threshold.Within {
+			window[n] = evt
+			n++
+		}
+	}
+	clear(window[n:])
+	window = window[:n]
+
+	if uint64(len(window)) < t.threshold.Count {
+		t.groups[key] = window
+		return false
+	}
+
+	log.Debugf("threshold of %d events within %v reached for rule [%s]", t.threshold.Count, t.threshold.Within, t.name)
+	thresholdWindowFires.Add(t.name, 1)
+	t.matches = window
+	t.deleteGroup(key)
+
+	return true
+}
+
+// events returns the events of the window that
+// reached the threshold count and resets them.
+func (t *thresholdState) events() []*event.Event {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	events := t.matches
+	t.matches = make([]*event.Event, 0)
+	return events
+}
+
+// gc removes the groups whose newest event
+// is older than the threshold time window.
+func (t *thresholdState) gc(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key, window := range t.groups {
+		if len(window) > 0 && now.Sub(window[len(window)-1].Timestamp) <= t.threshold.Within {
+			continue
+		}
+		log.Debugf("garbage collecting group window of threshold [%s]", t.name)
+		thresholdEvictions.Add(t.name, 1)
+		t.deleteGroup(key)
+	}
+	if len(t.groups) < maxThresholdGroups {
+		t.isGroupsBreached = false
+	}
+}
+
+// drain discards all group windows. It is called
+// when the threshold rule is modified or removed
+// from the ruleset on reload.
+func (t *thresholdState) drain() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key := range t.groups {
+		t.deleteGroup(key)
+	}
+	t.matches = make([]*event.Event, 0)
+}
+
+func (t *thresholdState) deleteGroup(key string) {
+	delete(t.groups, key)
+	thresholdGroups.Add(t.name, -1)
+}
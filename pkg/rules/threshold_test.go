@@ -0,0 +1,148 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rabbitstack/fibratus/pkg/config"
+	"github.com/rabbitstack/fibratus/pkg/event"
+	"github.com/rabbitstack/fibratus/pkg/event/params"
+	"github.com/rabbitstack/fibratus/pkg/filter"
+	"github.com/rabbitstack/fibratus/pkg/ps"
+	pstypes "github.com/rabbitstack/fibratus/pkg/ps/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runThreshold(ts *thresholdState, e *event.Event) bool {
+	valuer := filter.AcquireValuerCache()
+	defer valuer.Release()
+	return ts.evalThreshold(e, valuer)
+}
+
+func newCreateFileEvent(pid uint32, path string, ts time.Time) *event.Event {
+	return &event.Event{
+		Type:      event.CreateFile,
+		Name:      "CreateFile",
+		Category:  event.File,
+		Tid:       2484,
+		PID:       pid,
+		Timestamp: ts,
+		PS: &pstypes.PS{
+			Name: "ransom.exe",
+			Exe:  "C:\\Temp\\ransom.exe",
+		},
+		Params: event.Params{
+			params.FilePath: {Name: params.FilePath, Type: params.UnicodeString, Value: path},
+		},
+		Metadata: make(map[event.MetadataKey]any),
+	}
+}
+
+func TestThresholdState(t *testing.T) {
+	c := &config.FilterConfig{Name: "Mass file encryption"}
+	f := filter.New(`
+	threshold 3 within 10s by ps.pid
+	|evt.name = 'CreateFile' and file.extension = '.encrypted'|`,
+		&config.Config{EventSource: config.EventSourceConfig{EnableFileIOEvents: true}, Filters: &config.Filters{}})
+	require.NoError(t, f.Compile())
+	require.True(t, f.IsThreshold())
+
+	ts := newThresholdState(f, c)
+	now := time.Now()
+
+	// doesn't satisfy the threshold expression
+	require.False(t, runThreshold(ts, newCreateFileEvent(1, "C:\\Users\\admin\\report.docx", now)))
+	assert.Len(t, ts.groups, 0)
+
+	require.False(t, runThreshold(ts, newCreateFileEvent(1, "C:\\Users\\admin\\report.docx.encrypted", now)))
+	require.False(t, runThreshold(ts, newCreateFileEvent(1, "C:\\Users\\admin\\budget.xlsx.encrypted", now.Add(time.Second))))
+	// different group
+	require.False(t, runThreshold(ts, newCreateFileEvent(2, "C:\\Users\\admin\\notes.txt.encrypted", now.Add(time.Second))))
+	assert.Len(t, ts.groups, 2)
+
+	require.True(t, runThreshold(ts, newCreateFileEvent(1, "C:\\Users\\admin\\photo.jpg.encrypted", now.Add(time.Second*2))))
+	evts := ts.events()
+	require.Len(t, evts, 3)
+	assert.Equal(t, "C:\\Users\\admin\\report.docx.encrypted", evts[0].GetParamAsString(params.FilePath))
+	assert.Len(t, ts.events(), 0)
+	// the window of the fired group is reset
+	assert.Len(t, ts.groups, 1)
+
+	// events fall outside the sliding window
+	require.False(t, runThreshold(ts, newCreateFileEvent(3, "C:\\a.encrypted", now)))
+	require.False(t, runThreshold(ts, newCreateFileEvent(3, "C:\\b.encrypted", now.Add(time.Second*8))))
+	require.False(t, runThreshold(ts, newCreateFileEvent(3, "C:\\c.encrypted", now.Add(time.Second*15))))
+	assert.Len(t, ts.groups[groupKey(t, f, 3)], 2)
+	require.True(t, runThreshold(ts, newCreateFileEvent(3, "C:\\d.encrypted", now.Add(time.Second*16))))
+	assert.Len(t, ts.events(), 3)
+}
+
+func TestThresholdGC(t *testing.T) {
+	c := &config.FilterConfig{Name: "Mass file encryption"}
+	f := filter.New(`
+	threshold 3 within 500ms
+	|evt.name = 'CreateFile' and file.extension = '.encrypted'|`,
+		&config.Config{EventSource: config.EventSourceConfig{EnableFileIOEvents: true}, Filters: &config.Filters{}})
+	require.NoError(t, f.Compile())
+
+	ts := newThresholdState(f, c)
+
+	now := time.Now()
+	require.False(t, runThreshold(ts, newCreateFileEvent(1, "C:\\a.encrypted", now)))
+	require.False(t, runThreshold(ts, newCreateFileEvent(2, "C:\\b.encrypted", now)))
+	// ungrouped thresholds keep a single window
+	assert.Len(t, ts.groups, 1)
+
+	ts.gc(now.Add(time.Millisecond * 500))
+	assert.Len(t, ts.groups, 1)
+
+	ts.gc(now.Add(time.Second))
+	assert.Len(t, ts.groups, 0)
+}
+
+func TestRunThresholdRule(t *testing.T) {
+	e := NewEngine(new(ps.SnapshotterMock), newConfig("_fixtures/threshold_rule.yml"))
+	compileRules(t, e)
+
+	var matchedEvents []*event.Event
+	e.RegisterMatchFunc(func(f *config.FilterConfig, evts ...*event.Event) {
+		matchedEvents = evts
+	})
+
+	now := time.Now()
+	for i := 0; i < 4; i++ {
+		require.False(t, wrapProcessEvent(newCreateFileEvent(1, "C:\\a.encrypted", now.Add(time.Millisecond*time.Duration(i))), e.ProcessEvent))
+	}
+	require.True(t, wrapProcessEvent(newCreateFileEvent(1, "C:\\a.encrypted", now.Add(time.Millisecond*5)), e.ProcessEvent))
+	require.Len(t, matchedEvents, 5)
+	for _, evt := range matchedEvents {
+		assert.Equal(t, "Mass file encryption", evt.GetMetaAsString(event.RuleNameKey))
+	}
+}
+
+func groupKey(t *testing.T, f filter.Filter, pid uint32) string {
+	valuer := filter.AcquireValuerCache()
+	defer valuer.Release()
+	ok, key := f.EvalThreshold(newCreateFileEvent(pid, "C:\\x.encrypted", time.Now()), valuer)
+	require.True(t, ok)
+	return key
+}
@@ -0,0 +1,157 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rules
+
+import (
+	"expvar"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rabbitstack/fibratus/pkg/config"
+	"github.com/rabbitstack/fibratus/pkg/event"
+	"github.com/rabbitstack/fibratus/pkg/filter"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// maxThrottleGroups determines the maximum number of alert groups per throttled rule
+	maxThrottleGroups = 10000
+	// throttleSuppressedLabel is the alert label that contains the number of suppressed alerts
+	throttleSuppressedLabel = "throttle.suppressed"
+)
+
+var (
+	throttleSuppressedAlerts = expvar.NewMap("throttle.alerts.suppressed")
+	throttleSummarizedAlerts = expvar.NewMap("throttle.alerts.summarized")
+	throttleGroupsCount      = expvar.NewMap("throttle.groups.count")
+	throttleGroupsBreaches   = expvar.NewMap("throttle.groups.breaches")
+	throttleGroupsEvictions  = expvar.NewMap("throttle.groups.evictions")
+)
+
+// throttle deduplicates and rate limits the alerts
+// produced by the rule. Alerts are grouped by the
+// values of the group-by fields. Each group allows
+// emitting a limited number of alerts per window.
+type throttle struct {
+	config *config.Throttle
+	name   string
+
+	// groups contains the throttle state of
+	// each alert group indexed by group key
+	groups map[string]*throttleGroup
+	mu     sync.Mutex
+}
+
+type throttleGroup struct {
+	// start is the start time of the current window
+	start time.Time
+	// count is the number of alerts emitted in the current window
+	count uint64
+	// suppressed is the number of suppressed alerts
+	// not yet reported in the emitted alert
+	suppressed uint64
+}
+
+func newThrottle(c *config.FilterConfig) *throttle {
+	return &throttle{
+		config: c.Throttle,
+		name:   c.Name,
+		groups: make(map[string]*throttleGroup),
+	}
+}
+
+// key computes the group key from the values of
+// the group-by fields extracted from the events.
+func (t *throttle) key(evts []*event.Event) string {
+	if len(t.config.By) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for i, field := range t.config.By {
+		if i > 0 {
+			b.WriteByte('|')
+		}
+		b.WriteString(filter.InterpolateFields("%"+field, evts))
+	}
+	return b.String()
+}
+
+// allow determines if the alert for the given events
+// should be emitted. The method also returns the number
+// of alerts suppressed since the last emitted alert if
+// the summarize mode is active.
+func (t *throttle) allow(evts []*event.Event, now time.Time) (bool, uint64) {
+	key := t.key(evts)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	g, ok := t.groups[key]
+	if !ok {
+		if len(t.groups) >= maxThrottleGroups {
+			// don't lose alerts if the
+			// groups can't be tracked
+			throttleGroupsBreaches.Add(t.name, 1)
+			return true, 0
+		}
+		g = &throttleGroup{start: now}
+		t.groups[key] = g
+		throttleGroupsCount.Add(t.name, 1)
+	}
+	if now.Sub(g.start) > t.config.Window {
+		// start a new window
+		g.start = now
+		g.count = 0
+	}
+
+	if g.count >= t.config.GetLimit() {
+		log.Debugf("alert for rule [%s] suppressed by throttle", t.name)
+		throttleSuppressedAlerts.Add(t.name, 1)
+		g.suppressed++
+		return false, 0
+	}
+	g.count++
+
+	if !t.config.IsSummarize() || g.suppressed == 0 {
+		g.suppressed = 0
+		return true, 0
+	}
+	suppressed := g.suppressed
+	g.suppressed = 0
+	throttleSummarizedAlerts.Add(t.name, 1)
+
+	return true, suppressed
+}
+
+// gc removes the groups whose window expired. The
+// groups with the unreported suppressed alerts are
+// retained in summarize mode.
+func (t *throttle) gc(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key, g := range t.groups {
+		if now.Sub(g.start) <= t.config.Window || (t.config.IsSummarize() && g.suppressed > 0) {
+			continue
+		}
+		throttleGroupsEvictions.Add(t.name, 1)
+		throttleGroupsCount.Add(t.name, -1)
+		delete(t.groups, key)
+	}
+}
@@ -0,0 +1,158 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rules
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rabbitstack/fibratus/pkg/alertsender"
+	"github.com/rabbitstack/fibratus/pkg/config"
+	"github.com/rabbitstack/fibratus/pkg/event"
+	"github.com/rabbitstack/fibratus/pkg/event/params"
+	"github.com/rabbitstack/fibratus/pkg/ps"
+	"github.com/rabbitstack/fibratus/pkg/ps/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRecvEvent(name string, dip string) *event.Event {
+	return &event.Event{
+		Type:     event.RecvTCPv4,
+		Name:     "Recv",
+		Tid:      2484,
+		PID:      859,
+		Category: event.Net,
+		PS: &types.PS{
+			Name: name,
+		},
+		Params: event.Params{
+			params.NetDport: {Name: params.NetDport, Type: params.Uint16, Value: uint16(443)},
+			params.NetSport: {Name: params.NetSport, Type: params.Uint16, Value: uint16(43123)},
+			params.NetSIP:   {Name: params.NetSIP, Type: params.IPv4, Value: net.ParseIP("127.0.0.1")},
+			params.NetDIP:   {Name: params.NetDIP, Type: params.IPv4, Value: net.ParseIP(dip)},
+		},
+		Metadata: make(map[event.MetadataKey]any),
+	}
+}
+
+func TestThrottleFirstMode(t *testing.T) {
+	th := newThrottle(&config.FilterConfig{
+		Name:     "match https connections",
+		Throttle: &config.Throttle{By: []string{"ps.name"}, Window: time.Minute, Limit: 2},
+	})
+
+	now := time.Now()
+	cmd := []*event.Event{newRecvEvent("cmd.exe", "216.58.201.174")}
+	pwsh := []*event.Event{newRecvEvent("powershell.exe", "216.58.201.174")}
+
+	assert.Equal(t, "cmd.exe", th.key(cmd))
+
+	for i := 0; i < 2; i++ {
+		allow, suppressed := th.allow(cmd, now)
+		require.True(t, allow)
+		require.Zero(t, suppressed)
+	}
+	allow, _ := th.allow(cmd, now.Add(time.Second))
+	require.False(t, allow)
+	// different group
+	allow, _ = th.allow(pwsh, now.Add(time.Second))
+	require.True(t, allow)
+	assert.Len(t, th.groups, 2)
+
+	// the window rolled over and the
+	// suppressed alerts are not reported
+	allow, suppressed := th.allow(cmd, now.Add(time.Minute*2))
+	require.True(t, allow)
+	require.Zero(t, suppressed)
+
+	th.gc(now.Add(time.Minute * 2))
+	assert.Len(t, th.groups, 1)
+	th.gc(now.Add(time.Minute * 4))
+	assert.Len(t, th.groups, 0)
+}
+
+func TestThrottleSummarizeMode(t *testing.T) {
+	th := newThrottle(&config.FilterConfig{
+		Name:     "match https connections",
+		Throttle: &config.Throttle{Window: time.Minute, Mode: config.ThrottleSummarize},
+	})
+
+	now := time.Now()
+	evts := []*event.Event{newRecvEvent("cmd.exe", "216.58.201.174")}
+
+	allow, _ := th.allow(evts, now)
+	require.True(t, allow)
+	for i := 0; i < 5; i++ {
+		allow, _ = th.allow(evts, now.Add(time.Second))
+		require.False(t, allow)
+	}
+
+	// suppressed groups survive the GC until reported
+	th.gc(now.Add(time.Minute * 2))
+	assert.Len(t, th.groups, 1)
+
+	allow, suppressed := th.allow(evts, now.Add(time.Minute*2))
+	require.True(t, allow)
+	assert.Equal(t, uint64(5), suppressed)
+
+	allow, suppressed = th.allow(evts, now.Add(time.Minute*4))
+	require.True(t, allow)
+	assert.Zero(t, suppressed)
+}
+
+func TestThrottleAlerts(t *testing.T) {
+	require.NoError(t, alertsender.LoadAll([]alertsender.Config{{Type: alertsender.Noop}}))
+	e := NewEngine(new(ps.SnapshotterMock), newConfig("_fixtures/throttle/summarize.yml"))
+	compileRules(t, e)
+	require.Contains(t, e.throttles, "3c9a7f1e-52d4-4b6a-8e0f-1d2c3b4a5f60")
+
+	var alerts int
+	for i := 0; i < 5; i++ {
+		emitAlert = nil
+		require.True(t, wrapProcessEvent(newRecvEvent("cmd.exe", "216.58.201.174"), e.ProcessEvent))
+		if emitAlert != nil {
+			alerts++
+		}
+	}
+	assert.Equal(t, 2, alerts)
+
+	// rewind the window to emit the summarized alert
+	th := e.throttles["3c9a7f1e-52d4-4b6a-8e0f-1d2c3b4a5f60"]
+	for _, g := range th.groups {
+		g.start = g.start.Add(-time.Hour * 2)
+	}
+
+	emitAlert = nil
+	require.True(t, wrapProcessEvent(newRecvEvent("cmd.exe", "216.58.201.174"), e.ProcessEvent))
+	require.NotNil(t, emitAlert)
+	assert.Equal(t, "3", emitAlert.Labels[throttleSuppressedLabel])
+	assert.Equal(t, "TA0011", emitAlert.Labels["tactic.id"])
+	assert.Equal(t, "cmd.exe process received data on port 443\n\n3 similar alert(s) suppressed since the last alert", emitAlert.Text)
+	// the rule labels are left intact
+	assert.NotContains(t, e.config.GetFilters()[0].Labels, throttleSuppressedLabel)
+	emitAlert = nil
+}
+
+func TestThrottleUnknownField(t *testing.T) {
+	e := NewEngine(new(ps.SnapshotterMock), newConfig("_fixtures/throttle/invalid_field.yml"))
+	_, err := e.Compile()
+	require.EqualError(t, err, ErrUnknownThrottleField("match https connections", "ps.exename").Error())
+}
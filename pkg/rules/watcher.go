@@ -0,0 +1,313 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rules
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rabbitstack/fibratus/pkg/config"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// reloadDebounce is the quiet period after the last file system
+	// change notification before the rules are reloaded. Editors and
+	// deployment tools usually produce bursts of notifications
+	reloadDebounce = time.Second
+	// defaultRefreshInterval is the default interval for checking rule URLs
+	defaultRefreshInterval = time.Minute * 5
+)
+
+// Reloader reloads the ruleset.
+type Reloader interface {
+	Reload() error
+}
+
+// Watcher monitors rule, macro, and exception files for changes
+// and periodically checks rule URLs for new revisions. When a
+// change is detected, the ruleset is reloaded.
+type Watcher struct {
+	reloader Reloader
+	config   *config.Filters
+
+	fsw      *fsnotify.Watcher
+	patterns []string
+
+	// revisions contains the last seen revision of each
+	// rule URL. The revision is given by the ETag or the
+	// Last-Modified header, or derived from the content
+	// hash if the server doesn't supply any of them
+	revisions map[string]string
+	client    *http.Client
+
+	// pending is signaled when the reload is requested
+	pending chan struct{}
+	quit    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewWatcher creates a new watcher for the rule resources.
+func NewWatcher(reloader Reloader, c *config.Config) *Watcher {
+	return &Watcher{
+		reloader:  reloader,
+		config:    c.Filters,
+		patterns:  make([]string, 0),
+		revisions: make(map[string]string),
+		client:    &http.Client{Timeout: time.Second * 30},
+		pending:   make(chan struct{}, 1),
+		quit:      make(chan struct{}),
+	}
+}
+
+// Start starts watching the directories of rule, macro,
+// and exception paths and polling the rule URLs.
+func (w *Watcher) Start() error {
+	var err error
+	w.fsw, err = fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("unable to create rules watcher: %v", err)
+	}
+
+	dirs := make(map[string]bool)
+	paths := append(append(append([]string{}, w.config.Rules.FromPaths...), w.config.Macros.FromPaths...), w.config.Exceptions.FromPaths...)
+	for _, p := range paths {
+		w.patterns = append(w.patterns, strings.ToLower(filepath.Clean(p)))
+		for _, dir := range watchDirs(p) {
+			if dirs[dir] {
+				continue
+			}
+			if _, err := os.Stat(dir); err != nil {
+				log.Warnf("unable to watch rules directory %s: %v", dir, err)
+				continue
+			}
+			if err := w.fsw.Add(dir); err != nil {
+				log.Warnf("unable to watch rules directory %s: %v", dir, err)
+				continue
+			}
+			dirs[dir] = true
+			log.Infof("watching rules directory %s", dir)
+		}
+	}
+
+	// record the initial revisions
+	for _, url := range w.config.Rules.FromURLs {
+		rev, err := w.revision(url)
+		if err != nil {
+			log.Warnf("unable to get revision of rules URL %s: %v", url, err)
+			continue
+		}
+		w.revisions[url] = rev
+	}
+
+	w.wg.Add(2)
+	go w.watch()
+	go w.reload()
+
+	return nil
+}
+
+// watchDirs returns the directories to watch for the path. If the
+// directory of the path is the glob pattern, all the directories
+// matching the pattern are returned.
+func watchDirs(path string) []string {
+	dir := filepath.Dir(path)
+	if !strings.ContainsAny(dir, "*?[") {
+		return []string{dir}
+	}
+	matches, err := filepath.Glob(dir)
+	if err != nil {
+		log.Warnf("unable to expand rules directory %s: %v", dir, err)
+		return nil
+	}
+	dirs := make([]string, 0, len(matches))
+	for _, m := range matches {
+		if fi, err := os.Stat(m); err == nil && fi.IsDir() {
+			dirs = append(dirs, m)
+		}
+	}
+	return dirs
+}
+
+// Close stops the watcher.
+func (w *Watcher) Close() error {
+	close(w.quit)
+	w.wg.Wait()
+	if w.fsw != nil {
+		return w.fsw.Close()
+	}
+	return nil
+}
+
+// watch consumes file system change notifications
+// and polls rule URLs. The reload is requested after
+// the quiet period following the last relevant change.
+func (w *Watcher) watch() {
+	defer w.wg.Done()
+
+	interval := w.config.Rules.RefreshInterval
+	if interval <= 0 {
+		interval = defaultRefreshInterval
+	}
+	refresh := time.NewTicker(interval)
+	defer refresh.Stop()
+
+	debounce := time.NewTimer(reloadDebounce)
+	debounce.Stop()
+	defer debounce.Stop()
+
+	for {
+		select {
+		case e, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			if !w.matches(e.Name) {
+				continue
+			}
+			log.Debugf("rules watcher received %s", e)
+			debounce.Reset(reloadDebounce)
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			log.Warnf("rules watcher error: %v", err)
+		case <-debounce.C:
+			w.requestReload()
+		case <-refresh.C:
+			if w.urlsChanged() {
+				w.requestReload()
+			}
+		case <-w.quit:
+			return
+		}
+	}
+}
+
+// reload executes requested reloads. Reloads are
+// executed outside the watch loop, so notifications
+// keep flowing while the ruleset is being compiled.
+func (w *Watcher) reload() {
+	defer w.wg.Done()
+	for {
+		select {
+		case <-w.pending:
+			// the reload error is already reported
+			// and the previous ruleset remains active
+			_ = w.reloader.Reload()
+		case <-w.quit:
+			return
+		}
+	}
+}
+
+// requestReload signals the reload. Reload requests
+// that arrive while the reload is pending are merged.
+func (w *Watcher) requestReload() {
+	select {
+	case w.pending <- struct{}{}:
+	default:
+	}
+}
+
+// matches determines if the changed file matches
+// any of the rule, macro, or exception path patterns.
+func (w *Watcher) matches(name string) bool {
+	if ext := filepath.Ext(name); ext != ".yml" && ext != ".yaml" {
+		return false
+	}
+	name = strings.ToLower(filepath.Clean(name))
+	for _, pattern := range w.patterns {
+		if ok, err := filepath.Match(pattern, name); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// urlsChanged checks all rule URLs for new revisions.
+func (w *Watcher) urlsChanged() bool {
+	var changed bool
+	for _, url := range w.config.Rules.FromURLs {
+		rev, err := w.revision(url)
+		if err != nil {
+			log.Warnf("unable to get revision of rules URL %s: %v", url, err)
+			continue
+		}
+		if w.revisions[url] != rev {
+			log.Infof("detected new revision of rules URL %s", url)
+			changed = true
+		}
+		w.revisions[url] = rev
+	}
+	return changed
+}
+
+// revision fetches the rule URL and returns its revision. The
+// previous entity tag is sent in the If-None-Match header, so
+// the server can skip sending the content if it didn't change.
+func (w *Watcher) revision(url string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	prev := w.revisions[url]
+	if isETag(prev) {
+		req.Header.Set("If-None-Match", prev)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return prev, nil
+	case http.StatusOK:
+	default:
+		return "", fmt.Errorf("got non-ok status code: %s", http.StatusText(resp.StatusCode))
+	}
+
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		return etag, nil
+	}
+	if lastModified := resp.Header.Get("Last-Modified"); lastModified != "" {
+		return lastModified, nil
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, resp.Body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// isETag determines if the revision is the entity tag.
+func isETag(rev string) bool {
+	return strings.HasPrefix(rev, `"`) || strings.HasPrefix(rev, `W/"`)
+}
@@ -0,0 +1,254 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package ahocorasick implements the Aho-Corasick automaton for
+// matching a large set of string patterns in a single pass over
+// the text.
+package ahocorasick
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Mode determines where the patterns must occur in the text.
+type Mode uint8
+
+const (
+	// Substring matches patterns anywhere in the text.
+	Substring Mode = iota
+	// Prefix matches patterns at the start of the text.
+	Prefix
+	// Suffix matches patterns at the end of the text.
+	Suffix
+)
+
+// Matcher is the automaton built from the set of patterns. Failure
+// links are resolved at build time, so the transition table is a DFA
+// and the text is scanned with a single table lookup per byte. Table
+// columns are indexed by byte classes, and only the bytes appearing in
+// patterns get their own class. Matcher is safe for concurrent use.
+type Matcher struct {
+	mode Mode
+	fold bool
+	// classes maps each byte to the transition table column. Class 0
+	// is shared by all bytes that don't appear in any pattern
+	classes [256]uint16
+	stride  int
+	// delta is the transition table indexed by state and byte class
+	delta []int32
+	// depth is the length of the path from the root to the state
+	depth []int32
+	// outs contains identifiers of the patterns ending in the state
+	outs [][]int32
+	// dict is the dictionary suffix link of the state. It points to
+	// the nearest state reachable through failure links that ends
+	// any of the patterns, or -1 if there is no such state
+	dict []int32
+}
+
+// New builds the matcher for the given patterns and mode. If fold is
+// true, the text and patterns are compared as if both were converted
+// to lower case by strings.ToLower.
+func New(patterns []string, mode Mode, fold bool) *Matcher {
+	m := &Matcher{mode: mode, fold: fold}
+
+	keys := make([]string, len(patterns))
+	for i, p := range patterns {
+		if fold {
+			p = strings.ToLower(p)
+		}
+		if mode == Suffix {
+			p = reverse(p)
+		}
+		keys[i] = p
+	}
+
+	for _, k := range keys {
+		for i := 0; i < len(k); i++ {
+			if m.classes[k[i]] == 0 {
+				m.stride++
+				m.classes[k[i]] = uint16(m.stride)
+			}
+		}
+	}
+	m.stride++
+	if fold {
+		// lower case patterns never contain ASCII upper case
+		// letters, so they can share the lower case classes
+		for b := 'A'; b <= 'Z'; b++ {
+			m.classes[b] = m.classes[b+'a'-'A']
+		}
+	}
+
+	// build the trie. The root state is never the
+	// target of the trie edge, so zero designates
+	// the missing edge
+	m.addState(0)
+	for id, k := range keys {
+		s := int32(0)
+		for i := 0; i < len(k); i++ {
+			n := int(s)*m.stride + int(m.classes[k[i]])
+			if m.delta[n] == 0 {
+				m.delta[n] = m.addState(m.depth[s] + 1)
+			}
+			s = m.delta[n]
+		}
+		m.outs[s] = append(m.outs[s], int32(id))
+	}
+
+	// resolve failure links in breadth-first order. The
+	// missing edges are replaced by the transition of the
+	// failure state, which has already been resolved since
+	// it is closer to the root
+	fail := make([]int32, len(m.depth))
+	queue := make([]int32, 0, len(m.depth))
+	for c := 0; c < m.stride; c++ {
+		if s := m.delta[c]; s != 0 {
+			queue = append(queue, s)
+		}
+	}
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		f := fail[s]
+		if len(m.outs[f]) > 0 {
+			m.dict[s] = f
+		} else {
+			m.dict[s] = m.dict[f]
+		}
+		for c := 0; c < m.stride; c++ {
+			n := int(s)*m.stride + c
+			t := m.delta[n]
+			if t != 0 && m.depth[t] == m.depth[s]+1 {
+				fail[t] = m.delta[int(f)*m.stride+c]
+				queue = append(queue, t)
+				continue
+			}
+			m.delta[n] = m.delta[int(f)*m.stride+c]
+		}
+	}
+
+	return m
+}
+
+func (m *Matcher) addState(depth int32) int32 {
+	m.delta = append(m.delta, make([]int32, m.stride)...)
+	m.depth = append(m.depth, depth)
+	m.outs = append(m.outs, nil)
+	m.dict = append(m.dict, -1)
+	return int32(len(m.depth) - 1)
+}
+
+// Match reports whether any of the patterns matches the text.
+func (m *Matcher) Match(s string) bool {
+	return m.Find(s, nil)
+}
+
+// Find calls fn with the index of every pattern matching the text
+// until fn returns true. If fn is nil, Find stops at the first match.
+// In substring mode, fn may be called multiple times for the same
+// pattern if it occurs multiple times in the text. Find reports
+// whether the search was stopped.
+func (m *Matcher) Find(s string, fn func(int) bool) bool {
+	if m.fold && !isASCII(s) {
+		s = strings.ToLower(s)
+	}
+	if m.report(0, fn) {
+		return true
+	}
+
+	var state int32
+	switch m.mode {
+	case Substring:
+		for i := 0; i < len(s); i++ {
+			state = m.delta[int(state)*m.stride+int(m.classes[s[i]])]
+			if m.report(state, fn) {
+				return true
+			}
+		}
+	case Prefix:
+		for i := 0; i < len(s); i++ {
+			if state = m.next(state, s[i]); state < 0 {
+				return false
+			}
+			if m.reportOwn(state, fn) {
+				return true
+			}
+		}
+	case Suffix:
+		for i := len(s) - 1; i >= 0; i-- {
+			if state = m.next(state, s[i]); state < 0 {
+				return false
+			}
+			if m.reportOwn(state, fn) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// next follows the trie edge from the state, or
+// returns -1 if the state has no edge for the byte.
+func (m *Matcher) next(state int32, b byte) int32 {
+	t := m.delta[int(state)*m.stride+int(m.classes[b])]
+	if m.depth[t] != m.depth[state]+1 {
+		return -1
+	}
+	return t
+}
+
+// report reports patterns ending in the state, including
+// the patterns reachable through dictionary suffix links.
+func (m *Matcher) report(state int32, fn func(int) bool) bool {
+	for s := state; s >= 0; s = m.dict[s] {
+		if m.reportOwn(s, fn) {
+			return true
+		}
+	}
+	return false
+}
+
+// reportOwn reports patterns ending exactly in the state.
+func (m *Matcher) reportOwn(state int32, fn func(int) bool) bool {
+	for _, id := range m.outs[state] {
+		if fn == nil || fn(int(id)) {
+			return true
+		}
+	}
+	return false
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+func reverse(s string) string {
+	b := make([]byte, len(s))
+	for i := 0; i < len(s); i++ {
+		b[len(s)-1-i] = s[i]
+	}
+	return string(b)
+}
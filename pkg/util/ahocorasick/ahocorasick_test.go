@@ -0,0 +1,151 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ahocorasick
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	patterns := []string{`\windows\system32\`, `\temp\`, `.ps1`, `C:\Program Files\`, `rundll32`, `appdata`}
+
+	var tests = []struct {
+		s    string
+		mode Mode
+		fold bool
+		want bool
+	}{
+		{`C:\Windows\System32\cmd.exe`, Substring, true, true},
+		{`C:\Windows\System32\cmd.exe`, Substring, false, false},
+		{`C:\windows\system32\cmd.exe`, Substring, false, true},
+		{`C:\Users\admin\AppData\Local\Temp\a.exe`, Substring, true, true},
+		{`C:\Users\admin\Downloads\a.exe`, Substring, true, false},
+		{`C:\Program Files\Mozilla\firefox.exe`, Prefix, false, true},
+		{`c:\program files\Mozilla\firefox.exe`, Prefix, true, true},
+		{`c:\program files\Mozilla\firefox.exe`, Prefix, false, false},
+		{`D:\C:\Program Files\firefox.exe`, Prefix, false, false},
+		{`C:\scripts\run.PS1`, Suffix, true, true},
+		{`C:\scripts\run.PS1`, Suffix, false, false},
+		{`C:\scripts\run.ps1.txt`, Suffix, true, false},
+		{`C:\USERS\ÅSA\APPDATA\x.exe`, Substring, true, true},
+		{``, Substring, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			m := New(patterns, tt.mode, tt.fold)
+			assert.Equal(t, tt.want, m.Match(tt.s))
+		})
+	}
+}
+
+func TestFind(t *testing.T) {
+	m := New([]string{"he", "she", "his", "hers"}, Substring, false)
+	found := make([]int, 0)
+	assert.False(t, m.Find("ushers", func(id int) bool {
+		found = append(found, id)
+		return false
+	}))
+	assert.ElementsMatch(t, []int{0, 1, 3}, found)
+	assert.True(t, m.Find("ushers", func(id int) bool { return id == 3 }))
+}
+
+func TestEmptyPattern(t *testing.T) {
+	for _, mode := range []Mode{Substring, Prefix, Suffix} {
+		assert.True(t, New([]string{"foo", ""}, mode, false).Match("bar"))
+		assert.False(t, New(nil, mode, false).Match("bar"))
+	}
+}
+
+func TestMatchRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	alphabet := []rune{'a', 'b', 'A', 'B', 'c', 'É', 'é', 'K'}
+	randString := func(n int) string {
+		var b strings.Builder
+		for i := 0; i < n; i++ {
+			b.WriteRune(alphabet[r.Intn(len(alphabet))])
+		}
+		return b.String()
+	}
+
+	for i := 0; i < 200; i++ {
+		patterns := make([]string, 1+r.Intn(20))
+		for j := range patterns {
+			patterns[j] = randString(1 + r.Intn(4))
+		}
+		for _, fold := range []bool{false, true} {
+			for _, mode := range []Mode{Substring, Prefix, Suffix} {
+				m := New(patterns, mode, fold)
+				for k := 0; k < 20; k++ {
+					s := randString(r.Intn(12))
+					var want bool
+					for _, p := range patterns {
+						text := s
+						if fold {
+							text, p = strings.ToLower(s), strings.ToLower(p)
+						}
+						switch mode {
+						case Substring:
+							want = strings.Contains(text, p)
+						case Prefix:
+							want = strings.HasPrefix(text, p)
+						case Suffix:
+							want = strings.HasSuffix(text, p)
+						}
+						if want {
+							break
+						}
+					}
+					assert.Equal(t, want, m.Match(s), "patterns=%q text=%q mode=%d fold=%t", patterns, s, mode, fold)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkMatch(b *testing.B) {
+	patterns := make([]string, 0, 200)
+	for i := 0; i < 200; i++ {
+		patterns = append(patterns, strings.Repeat(string(rune('a'+i%26)), 1+i%7)+".exe")
+	}
+	s := `C:\Users\admin\AppData\Local\Microsoft\OneDrive\OneDrive.Update.exe`
+
+	b.Run("linear", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			v := strings.ToLower(s)
+			for _, p := range patterns {
+				if strings.Contains(v, strings.ToLower(p)) {
+					break
+				}
+			}
+		}
+	})
+	b.Run("automaton", func(b *testing.B) {
+		b.ReportAllocs()
+		m := New(patterns, Substring, true)
+		for i := 0; i < b.N; i++ {
+			m.Match(s)
+		}
+	})
+}
@@ -0,0 +1,33 @@
+//go:build cap
+// +build cap
+
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rotate
+
+import (
+	"io"
+
+	"github.com/valyala/gozstd"
+)
+
+// zstdSupported indicates if rotated files can be compressed with zstd
+const zstdSupported = true
+
+func compressZstd(dst io.Writer, src io.Reader) error { return gozstd.StreamCompress(dst, src) }
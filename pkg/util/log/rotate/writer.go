@@ -0,0 +1,424 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rotate
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	// CompressionNone leaves rotated files uncompressed
+	CompressionNone = "none"
+	// CompressionGzip compresses rotated files with gzip
+	CompressionGzip = "gzip"
+	// CompressionZstd compresses rotated files with zstd
+	CompressionZstd = "zstd"
+)
+
+// defaultMaxSize is the size in megabytes lumberjack rotates the file at if the size is not set
+const defaultMaxSize = 100
+
+// timeSpecifiers replaces time specifiers in file name templates
+var timeSpecifiers = map[byte]string{
+	'Y': "2006",
+	'y': "06",
+	'm': "01",
+	'd': "02",
+	'H': "15",
+}
+
+// WriterConfig is the configuration for the rotating file writer.
+type WriterConfig struct {
+	// Filename is the path of the file. The file name may contain %Y, %y,
+	// %m, %d, and %H time specifiers rendered in UTC. The file is rolled
+	// over when the rendered name changes.
+	Filename string
+	// MaxSize is the size in megabytes that triggers the rotation. Defaults to 100 megabytes.
+	MaxSize int
+	// Interval is the period after which the file is rotated. Zero disables time rotation.
+	Interval time.Duration
+	// MaxBackups is the maximum number of rotated files to retain. Zero retains all files.
+	MaxBackups int
+	// MaxTotalSize is the maximum total size in bytes of rotated files. Zero disables the limit.
+	MaxTotalSize int64
+	// Compression is the algorithm used to compress rotated files.
+	Compression string
+}
+
+// Writer is an io.WriteCloser that writes to the lumberjack logger
+// of the file name rendered from the template. Lumberjack rotates
+// the file by size, and compresses and prunes the rotated files of
+// the same name. Writer opens a new logger when the template renders
+// a new name, rotates the file on the interval, and compresses with
+// zstd and prunes the rotated files across all rendered names.
+type Writer struct {
+	config WriterConfig
+	dir    string
+	tmpl   string // template of the file name without extension
+	ext    string
+	files  *regexp.Regexp
+
+	mu     sync.Mutex
+	logger *lumberjack.Logger
+	size   int64
+	opened time.Time
+	closed bool
+
+	mill chan struct{}
+	wg   sync.WaitGroup
+	now  func() time.Time
+}
+
+// NewWriter creates a new rotating file writer. The file is
+// lazily opened on the first write.
+func NewWriter(config WriterConfig) (*Writer, error) {
+	dir, base := filepath.Split(config.Filename)
+	if base == "" {
+		return nil, fmt.Errorf("invalid file name: %q", config.Filename)
+	}
+	if strings.Contains(dir, "%") {
+		return nil, fmt.Errorf("time specifiers are only allowed in the file name: %q", config.Filename)
+	}
+	switch config.Compression {
+	case CompressionZstd:
+		if !zstdSupported {
+			log.Warnf("zstd compression is not available. Rotated files of %s are not compressed", config.Filename)
+			config.Compression = CompressionNone
+		}
+	case "", CompressionNone, CompressionGzip:
+	default:
+		return nil, fmt.Errorf("unknown compression algorithm %q", config.Compression)
+	}
+	if config.MaxSize <= 0 {
+		config.MaxSize = defaultMaxSize
+	}
+	ext := filepath.Ext(base)
+	w := &Writer{
+		config: config,
+		dir:    filepath.Clean(dir),
+		tmpl:   strings.TrimSuffix(base, ext),
+		ext:    ext,
+		mill:   make(chan struct{}, 1),
+		now:    time.Now,
+	}
+	// matches the files rolled over by the template and the
+	// files rotated by lumberjack with the timestamp suffix
+	w.files = regexp.MustCompile(`^` + templateRegexp(w.tmpl) +
+		`(-\d{4}-\d{2}-\d{2}T\d{2}-\d{2}-\d{2}\.\d{3})?` + regexp.QuoteMeta(ext) + `(\.gz|\.zst)?$`)
+	w.wg.Add(1)
+	go w.runMill()
+	return w, nil
+}
+
+// Write writes the buffer to the active file. The file is rolled
+// over if the template renders a new name, or rotated if the interval
+// elapsed. Lumberjack rotates the file if the write exceeds the size.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+
+	now := w.now().UTC()
+	name := w.render(now)
+	switch {
+	case w.logger == nil:
+		w.open(name, now)
+	case name != w.logger.Filename:
+		if err := w.logger.Close(); err != nil {
+			return 0, err
+		}
+		w.open(name, now)
+	case w.config.Interval > 0 && now.Truncate(w.config.Interval).After(w.opened.Truncate(w.config.Interval)):
+		if err := w.rotate(now); err != nil {
+			return 0, err
+		}
+	}
+
+	if w.size > 0 && w.size+int64(len(p)) > int64(w.config.MaxSize)*1024*1024 {
+		// lumberjack rotates the file before writing the buffer
+		w.size = 0
+		defer w.signalMill()
+	}
+	n, err := w.logger.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Rotate closes the active file and opens a new one.
+func (w *Writer) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+	if w.logger == nil {
+		return nil
+	}
+	return w.rotate(w.now().UTC())
+}
+
+// Close closes the active file and waits for
+// the pending compression and pruning to finish.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	var err error
+	if w.logger != nil {
+		err = w.logger.Close()
+	}
+	w.closed = true
+	w.mu.Unlock()
+	close(w.mill)
+	w.wg.Wait()
+	return err
+}
+
+// open creates the lumberjack logger for the file with the given name.
+// The file is opened by the logger on the first write.
+func (w *Writer) open(name string, now time.Time) {
+	w.logger = &lumberjack.Logger{
+		Filename:   name,
+		MaxSize:    w.config.MaxSize,
+		MaxBackups: w.config.MaxBackups,
+		Compress:   w.config.Compression == CompressionGzip,
+	}
+	w.size = 0
+	if fi, err := os.Stat(name); err == nil {
+		w.size = fi.Size()
+	}
+	w.opened = now
+	w.signalMill()
+}
+
+func (w *Writer) rotate(now time.Time) error {
+	if err := w.logger.Rotate(); err != nil {
+		return err
+	}
+	w.size, w.opened = 0, now
+	w.signalMill()
+	return nil
+}
+
+// render produces the path of the active file from the template.
+func (w *Writer) render(now time.Time) string {
+	var b strings.Builder
+	for i := 0; i < len(w.tmpl); i++ {
+		if w.tmpl[i] == '%' && i+1 < len(w.tmpl) {
+			if layout, ok := timeSpecifiers[w.tmpl[i+1]]; ok {
+				b.WriteString(now.Format(layout))
+				i++
+				continue
+			}
+		}
+		b.WriteByte(w.tmpl[i])
+	}
+	return filepath.Join(w.dir, b.String()+w.ext)
+}
+
+// templateRegexp converts the file name template to the regular
+// expression where time specifiers match any sequence of digits.
+func templateRegexp(tmpl string) string {
+	var b strings.Builder
+	for i := 0; i < len(tmpl); i++ {
+		if tmpl[i] == '%' && i+1 < len(tmpl) {
+			if _, ok := timeSpecifiers[tmpl[i+1]]; ok {
+				b.WriteString(`\d+`)
+				i++
+				continue
+			}
+		}
+		b.WriteString(regexp.QuoteMeta(string(tmpl[i])))
+	}
+	return b.String()
+}
+
+func (w *Writer) signalMill() {
+	select {
+	case w.mill <- struct{}{}:
+	default:
+	}
+}
+
+// runMill compresses and prunes rotated files
+// every time the file is opened or rotated.
+func (w *Writer) runMill() {
+	defer w.wg.Done()
+	for range w.mill {
+		if err := w.compressFiles(); err != nil {
+			log.Warnf("unable to compress rotated files: %v", err)
+		}
+		if err := w.pruneFiles(); err != nil {
+			log.Warnf("unable to remove rotated files: %v", err)
+		}
+	}
+}
+
+type rotatedFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+	backup  bool // rotated by lumberjack
+}
+
+// listFiles returns rotated files sorted from newest to oldest.
+func (w *Writer) listFiles() ([]rotatedFile, error) {
+	w.mu.Lock()
+	var active string
+	if w.logger != nil {
+		active = w.logger.Filename
+	}
+	w.mu.Unlock()
+
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]rotatedFile, 0)
+	for _, e := range entries {
+		path := filepath.Join(w.dir, e.Name())
+		if e.IsDir() || path == active {
+			continue
+		}
+		m := w.files.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, rotatedFile{path: path, size: fi.Size(), modTime: fi.ModTime(), backup: m[1] != ""})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].modTime.Equal(files[j].modTime) {
+			// timestamps in file names sort chronologically
+			return files[i].path > files[j].path
+		}
+		return files[i].modTime.After(files[j].modTime)
+	})
+	return files, nil
+}
+
+// compressFiles compresses the files rolled over by the template.
+// Lumberjack gzips its own backups, so they are only compressed
+// here if the zstd compression is used.
+func (w *Writer) compressFiles() error {
+	var ext string
+	switch w.config.Compression {
+	case CompressionGzip:
+		ext = ".gz"
+	case CompressionZstd:
+		ext = ".zst"
+	default:
+		return nil
+	}
+	files, err := w.listFiles()
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if strings.HasSuffix(f.path, ".gz") || strings.HasSuffix(f.path, ".zst") {
+			continue
+		}
+		if f.backup && w.config.Compression == CompressionGzip {
+			continue
+		}
+		if err := compressFile(f.path, f.path+ext, w.config.Compression); err != nil {
+			return err
+		}
+		// keep the modification time for pruning
+		_ = os.Chtimes(f.path+ext, f.modTime, f.modTime)
+		if err := os.Remove(f.path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneFiles removes the oldest rotated files exceeding the retention
+// limits. Lumberjack only prunes the backups of the active file name,
+// so the limits are enforced here across all names the template renders.
+func (w *Writer) pruneFiles() error {
+	if w.config.MaxBackups <= 0 && w.config.MaxTotalSize <= 0 {
+		return nil
+	}
+	files, err := w.listFiles()
+	if err != nil {
+		return err
+	}
+	var size int64
+	for i, f := range files {
+		size += f.size
+		if (w.config.MaxBackups > 0 && i >= w.config.MaxBackups) ||
+			(w.config.MaxTotalSize > 0 && size > w.config.MaxTotalSize) {
+			if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// compressFile compresses the source file into the destination file.
+func compressFile(src, dst, algo string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			_ = os.Remove(dst)
+		}
+	}()
+
+	if algo == CompressionZstd {
+		return compressZstd(out, in)
+	}
+	gz := gzip.NewWriter(out)
+	if _, err = io.Copy(gz, in); err != nil {
+		_ = gz.Close()
+		return err
+	}
+	return gz.Close()
+}
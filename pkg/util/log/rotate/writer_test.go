@@ -0,0 +1,203 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rotate
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clock is the fake time source advanced manually by tests.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestWriter(t *testing.T, config WriterConfig) (*Writer, *clock) {
+	w, err := NewWriter(config)
+	require.NoError(t, err)
+	c := &clock{t: time.Date(2024, time.March, 5, 10, 0, 0, 0, time.UTC)}
+	w.now = c.now
+	return w, c
+}
+
+func listDir(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+var backupRegexp = regexp.MustCompile(`^events-\d{4}-\d{2}-\d{2}T\d{2}-\d{2}-\d{2}\.\d{3}\.jsonl$`)
+
+func TestWriterRotateBySize(t *testing.T) {
+	dir := t.TempDir()
+	w, _ := newTestWriter(t, WriterConfig{Filename: filepath.Join(dir, "events.jsonl"), MaxSize: 1})
+
+	chunk := bytes.Repeat([]byte("a"), 700*1024)
+	_, err := w.Write(chunk)
+	require.NoError(t, err)
+	// exceeds the size limit
+	_, err = w.Write([]byte("abc"))
+	require.NoError(t, err)
+	_, err = w.Write(chunk)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	names := listDir(t, dir)
+	require.Len(t, names, 2)
+	assert.Regexp(t, backupRegexp, names[0])
+	assert.Equal(t, "events.jsonl", names[1])
+	b, err := os.ReadFile(filepath.Join(dir, names[0]))
+	require.NoError(t, err)
+	assert.Equal(t, len(chunk)+3, len(b))
+	b, err = os.ReadFile(filepath.Join(dir, "events.jsonl"))
+	require.NoError(t, err)
+	assert.Equal(t, chunk, b)
+
+	_, err = w.Write([]byte("abc"))
+	require.ErrorIs(t, err, os.ErrClosed)
+}
+
+func TestWriterRotateByInterval(t *testing.T) {
+	dir := t.TempDir()
+	w, c := newTestWriter(t, WriterConfig{Filename: filepath.Join(dir, "events.jsonl"), Interval: time.Hour})
+
+	_, err := w.Write([]byte("a"))
+	require.NoError(t, err)
+	c.advance(time.Minute * 59)
+	_, err = w.Write([]byte("b"))
+	require.NoError(t, err)
+	c.advance(time.Minute)
+	_, err = w.Write([]byte("c"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	names := listDir(t, dir)
+	require.Len(t, names, 2)
+	assert.Regexp(t, backupRegexp, names[0])
+	b, err := os.ReadFile(filepath.Join(dir, names[0]))
+	require.NoError(t, err)
+	assert.Equal(t, "ab", string(b))
+	b, err = os.ReadFile(filepath.Join(dir, "events.jsonl"))
+	require.NoError(t, err)
+	assert.Equal(t, "c", string(b))
+}
+
+func TestWriterTimeTemplate(t *testing.T) {
+	dir := t.TempDir()
+	w, c := newTestWriter(t, WriterConfig{Filename: filepath.Join(dir, "fibratus-%Y.%m.%d.jsonl")})
+
+	_, err := w.Write([]byte("a"))
+	require.NoError(t, err)
+	c.advance(time.Hour * 24)
+	_, err = w.Write([]byte("b"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	assert.Equal(t, []string{"fibratus-2024.03.05.jsonl", "fibratus-2024.03.06.jsonl"}, listDir(t, dir))
+
+	_, err = NewWriter(WriterConfig{Filename: filepath.Join(dir, "%Y", "fibratus.jsonl")})
+	require.Error(t, err)
+}
+
+// testCompression writes to the file rolled over by the template
+// and checks the rolled file is compressed with the algorithm.
+func testCompression(t *testing.T, compression, ext string, reader func(io.Reader) (io.Reader, error)) {
+	dir := t.TempDir()
+	w, c := newTestWriter(t, WriterConfig{Filename: filepath.Join(dir, "fibratus-%Y.%m.%d.jsonl"), Compression: compression})
+
+	_, err := w.Write([]byte(`{"seq":1}` + "\n"))
+	require.NoError(t, err)
+	c.advance(time.Hour * 24)
+	_, err = w.Write([]byte(`{"seq":2}` + "\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	assert.Equal(t, []string{"fibratus-2024.03.05.jsonl" + ext, "fibratus-2024.03.06.jsonl"}, listDir(t, dir))
+
+	f, err := os.Open(filepath.Join(dir, "fibratus-2024.03.05.jsonl"+ext))
+	require.NoError(t, err)
+	defer f.Close()
+	r, err := reader(f)
+	require.NoError(t, err)
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, `{"seq":1}`+"\n", string(b))
+}
+
+func TestWriterCompression(t *testing.T) {
+	testCompression(t, CompressionGzip, ".gz", func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) })
+
+	_, err := NewWriter(WriterConfig{Filename: "events.jsonl", Compression: "brotli"})
+	require.Error(t, err)
+}
+
+func TestWriterRetention(t *testing.T) {
+	t.Run("max backups", func(t *testing.T) {
+		dir := t.TempDir()
+		w, c := newTestWriter(t, WriterConfig{Filename: filepath.Join(dir, "events-%Y.%m.%d.jsonl"), MaxBackups: 2})
+		for i := 0; i < 5; i++ {
+			_, err := w.Write([]byte("0123456789"))
+			require.NoError(t, err)
+			c.advance(time.Hour * 24)
+		}
+		require.NoError(t, w.Close())
+
+		assert.Equal(t, []string{
+			"events-2024.03.07.jsonl",
+			"events-2024.03.08.jsonl",
+			"events-2024.03.09.jsonl",
+		}, listDir(t, dir))
+	})
+
+	t.Run("max total size", func(t *testing.T) {
+		dir := t.TempDir()
+		// unrelated files are left intact
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("keep"), 0644))
+		w, c := newTestWriter(t, WriterConfig{Filename: filepath.Join(dir, "events-%Y.%m.%d.jsonl"), MaxTotalSize: 25})
+		for i := 0; i < 4; i++ {
+			_, err := w.Write([]byte("0123456789"))
+			require.NoError(t, err)
+			c.advance(time.Hour * 24)
+		}
+		require.NoError(t, w.Close())
+
+		assert.Equal(t, []string{
+			"events-2024.03.06.jsonl",
+			"events-2024.03.07.jsonl",
+			"events-2024.03.08.jsonl",
+			"notes.txt",
+		}, listDir(t, dir))
+	})
+}
@@ -0,0 +1,47 @@
+/*
+ * Copyright 2021-present by Nedim Sabic Sabic
+ * https://www.fibratus.io
+ * All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stringcase
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFold(t *testing.T) {
+	var tests = []struct {
+		s1, s2 string
+	}{
+		{"cmd.exe", "CMD.EXE"},
+		{"cmd.exe", "cmd.ex"},
+		{"straße", "STRASSE"},
+		{"Kelvin", "Kelvin"},
+		{"ſvchost.exe", "SVCHOST.EXE"},
+		{"Σίσυφος", "ΣΊΣΥΦΟς"},
+		{"\xff", "�"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s1, func(t *testing.T) {
+			assert.Equal(t, strings.EqualFold(tt.s1, tt.s2), Fold(tt.s1) == Fold(tt.s2))
+		})
+	}
+}